@@ -8,10 +8,12 @@ import (
 	"Tella-Desktop/backend/core/database"
 	"Tella-Desktop/backend/core/modules/auth"
 	"Tella-Desktop/backend/core/modules/filestore"
+	"Tella-Desktop/backend/core/modules/preview"
 	"Tella-Desktop/backend/core/modules/registration"
 	"Tella-Desktop/backend/core/modules/server"
 	"Tella-Desktop/backend/core/modules/transfer"
 	"Tella-Desktop/backend/utils/authutils"
+	"Tella-Desktop/backend/utils/filestoreutils"
 	"Tella-Desktop/backend/utils/network"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
@@ -27,6 +29,7 @@ type App struct {
 	transferService     transfer.Service
 	serverService       server.Service
 	fileService         filestore.Service
+	previewHandler      *preview.Handler
 	defaultFolderID     int64
 }
 
@@ -68,9 +71,71 @@ func (a *App) VerifyPassword(password string) error {
 	return nil
 }
 
-// NewApp creates a new App application struct
-func NewApp() *App {
-	return &App{}
+// ListProfiles returns the vault profiles. Only the unlocked profile's name is shown.
+func (a *App) ListProfiles() ([]auth.ProfileInfo, error) {
+	return a.authService.ListProfiles()
+}
+
+// CreateProfile locks the current vault, then creates and unlocks a new profile
+func (a *App) CreateProfile(name string, password string) (string, error) {
+	if err := a.LockApp(); err != nil {
+		return "", err
+	}
+
+	id, err := a.authService.CreateProfile(name, password)
+	if err != nil {
+		return "", err
+	}
+
+	if err := a.initializeDatabase(); err != nil {
+		runtime.LogError(a.ctx, "Failed to initialize database for new profile: "+err.Error())
+		return "", err
+	}
+
+	runtime.LogInfo(a.ctx, "Profile created and unlocked")
+	return id, nil
+}
+
+// SwitchProfile locks the current vault and selects another profile, which
+// is then unlocked with its own password
+func (a *App) SwitchProfile(id string) error {
+	if err := a.LockApp(); err != nil {
+		return err
+	}
+	return a.authService.SwitchProfile(id)
+}
+
+// GetVaultLocation returns where the vaults are stored and whether the app runs in portable mode
+func (a *App) GetVaultLocation() auth.VaultLocation {
+	return a.authService.GetVaultLocation()
+}
+
+// MoveVault locks the vault and relocates it, with every profile, to destination.
+// The originals are removed only once every copy has been verified.
+func (a *App) MoveVault(destination string) error {
+	if err := a.LockApp(); err != nil {
+		return err
+	}
+
+	if err := a.authService.MoveVault(destination); err != nil {
+		runtime.LogError(a.ctx, "Failed to move vault: "+err.Error())
+		return err
+	}
+	return nil
+}
+
+// SelectVaultDirectory asks the user for an empty directory to move the vault to
+func (a *App) SelectVaultDirectory() (string, error) {
+	return runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:                "Select where to move the vault",
+		CanCreateDirectories: true,
+	})
+}
+
+// NewApp creates a new App application struct. previewHandler is also
+// given to the asset server, and serves files only while the vault is unlocked.
+func NewApp(previewHandler *preview.Handler) *App {
+	return &App{previewHandler: previewHandler}
 }
 
 func (a *App) Startup(ctx context.Context) {
@@ -83,6 +148,11 @@ func (a *App) Startup(ctx context.Context) {
 		return
 	}
 
+	// Decrypted copies left behind if the app crashed while they were open
+	if err := filestoreutils.WipeTempCopies(authutils.GetTempDir()); err != nil {
+		runtime.LogError(ctx, "Failed to wipe temporary copies: "+err.Error())
+	}
+
 	a.registrationService = registration.NewService(a.ctx)
 	a.registrationHandler = registration.NewHandler(a.registrationService, a.ctx)
 }
@@ -131,6 +201,10 @@ func (a *App) initializeDatabase() error {
 	a.fileService = filestore.NewService(a.ctx, db.DB, dbKey)
 	runtime.LogInfo(a.ctx, "File storage service initialized")
 
+	if err := a.previewHandler.Start(a.fileService); err != nil {
+		return err
+	}
+
 	a.transferService = transfer.NewService(a.ctx, a.fileService, db.DB)
 	runtime.LogInfo(a.ctx, "Transfer service initialized")
 
@@ -169,6 +243,14 @@ func (a *App) ensureDefaultFolder(db *sql.DB) (int64, error) {
 }
 
 func (a *App) Shutdown(ctx context.Context) {
+	a.previewHandler.Stop()
+	if a.fileService != nil {
+		a.fileService.WipeTempFiles()
+		a.fileService.StopJobs()
+		a.fileService.PauseCompaction()
+		a.fileService.StopThumbnailBackfill()
+		a.fileService.StopTrashPurge()
+	}
 	if a.db != nil {
 		a.db.Close()
 	}
@@ -204,11 +286,46 @@ func (a *App) GetWiFiNetworkName() (string, error) {
 
 // Filestore functions
 
-func (a *App) GetStoredFolders() ([]filestore.FolderInfo, error) {
+func (a *App) GetStoredFolders(options filestore.FolderListOptions) ([]filestore.FolderInfo, error) {
 	if a.fileService == nil {
 		return nil, fmt.Errorf("file service not initialized")
 	}
-	return a.fileService.GetStoredFolders()
+	return a.fileService.GetStoredFolders(options)
+}
+
+func (a *App) CreateFolder(name string, parentID int64) (*filestore.FolderInfo, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.CreateFolder(name, parentID)
+}
+
+func (a *App) RenameFolder(id int64, name string) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.RenameFolder(id, name)
+}
+
+func (a *App) MoveFolder(id int64, parentID int64) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.MoveFolder(id, parentID)
+}
+
+func (a *App) ListChildFolders(parentID int64) ([]filestore.FolderInfo, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.ListChildFolders(parentID)
+}
+
+func (a *App) GetFolderPath(id int64) ([]filestore.FolderPathEntry, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.GetFolderPath(id)
 }
 
 func (a *App) GetFilesInFolder(folderID int64) (*filestore.FilesInFolderResponse, error) {
@@ -218,18 +335,159 @@ func (a *App) GetFilesInFolder(folderID int64) (*filestore.FilesInFolderResponse
 	return a.fileService.GetFilesInFolder(folderID)
 }
 
-func (a *App) ExportFiles(ids []int64) ([]string, error) {
+func (a *App) QueryFiles(query filestore.FileQuery) (*filestore.FileQueryResult, error) {
 	if a.fileService == nil {
 		return nil, fmt.Errorf("file service not initialized")
 	}
-	return a.fileService.ExportFiles(ids)
+	return a.fileService.QueryFiles(query)
 }
 
-func (a *App) ExportZipFolders(folderIDs []int64, selectedFileIDs []int64) ([]string, error) {
+func (a *App) VerifyFiles(ids []int64) ([]filestore.FileVerification, error) {
 	if a.fileService == nil {
 		return nil, fmt.Errorf("file service not initialized")
 	}
-	return a.fileService.ExportZipFolders(folderIDs, selectedFileIDs)
+	return a.fileService.VerifyFiles(ids)
+}
+
+// ExportFiles starts exporting files and returns the job ID. The job's result is an ExportReport.
+func (a *App) ExportFiles(ids []int64, options filestore.ExportOptions) (string, error) {
+	if a.fileService == nil {
+		return "", fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.StartExportFiles(ids, options)
+}
+
+// ExportZipFolders starts exporting folders as ZIP archives and returns the job ID
+func (a *App) ExportZipFolders(folderIDs []int64, selectedFileIDs []int64, options filestore.ExportOptions) (string, error) {
+	if a.fileService == nil {
+		return "", fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.StartExportZipFolders(folderIDs, selectedFileIDs, options)
+}
+
+// ExportBag starts exporting folders as a BagIt bag and returns the job ID. The job's result is an ExportReport.
+func (a *App) ExportBag(folderIDs []int64, selectedFileIDs []int64, options filestore.ExportOptions, bag filestore.BagOptions) (string, error) {
+	if a.fileService == nil {
+		return "", fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.StartExportBag(folderIDs, selectedFileIDs, options, bag)
+}
+
+func (a *App) ValidateBag(bagPath string) (*filestore.BagValidation, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.ValidateBag(bagPath)
+}
+
+// SelectExportDirectory asks the user for a directory to export into
+func (a *App) SelectExportDirectory() (string, error) {
+	return runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:                "Select where to export",
+		CanCreateDirectories: true,
+	})
+}
+
+func (a *App) GetExports() ([]filestore.ExportRecord, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.GetExports()
+}
+
+// WipeExportedCopies securely deletes unchanged exported copies, all outstanding ones when no IDs are given
+func (a *App) WipeExportedCopies(exportIDs []int64) ([]filestore.ExportWipe, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+
+	results, err := a.fileService.WipeExportedCopies(exportIDs)
+	if err != nil {
+		runtime.LogError(a.ctx, fmt.Sprintf("WipeExportedCopies failed: %v", err))
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetAuditLog returns the chain-of-custody log, only the entries about fileID unless it is 0
+func (a *App) GetAuditLog(fileID int64) ([]filestore.AuditEntry, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.GetAuditLog(fileID)
+}
+
+func (a *App) VerifyAuditLog() (*filestore.AuditVerification, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+
+	result, err := a.fileService.VerifyAuditLog()
+	if err != nil {
+		return nil, err
+	}
+	if !result.Valid {
+		runtime.LogError(a.ctx, fmt.Sprintf("Audit log failed verification: %d problems", len(result.Errors)))
+	}
+	return result, nil
+}
+
+// ExportAuditLog writes the audit log as signed "json" or "csv" into destination, or the downloads directory when empty
+func (a *App) ExportAuditLog(format string, destination string) (string, error) {
+	if a.fileService == nil {
+		return "", fmt.Errorf("file service not initialized")
+	}
+
+	path, err := a.fileService.ExportAuditLog(format, destination)
+	if err != nil {
+		runtime.LogError(a.ctx, fmt.Sprintf("ExportAuditLog failed: %v", err))
+		return "", err
+	}
+	runtime.LogInfo(a.ctx, fmt.Sprintf("Audit log exported to %s", path))
+	return path, nil
+}
+
+// ImportPaths starts importing local files and directories and returns the job ID. The job's result is an ImportResult.
+func (a *App) ImportPaths(paths []string, folderID int64, options filestore.ImportOptions) (string, error) {
+	if a.fileService == nil {
+		return "", fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.StartImportPaths(paths, folderID, options)
+}
+
+// SelectImportFiles asks the user for local files to import
+func (a *App) SelectImportFiles() ([]string, error) {
+	return runtime.OpenMultipleFilesDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Select files to import",
+	})
+}
+
+// SelectImportDirectory asks the user for a local directory to import
+func (a *App) SelectImportDirectory() (string, error) {
+	return runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Select a folder to import",
+	})
+}
+
+func (a *App) RenameFile(id int64, name string) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.RenameFile(id, name)
+}
+
+func (a *App) MoveFiles(ids []int64, folderID int64) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.MoveFiles(ids, folderID)
+}
+
+func (a *App) CopyFiles(ids []int64, folderID int64) ([]int64, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.CopyFiles(ids, folderID)
 }
 
 func (a *App) DeleteFiles(ids []int64) error {
@@ -238,7 +496,7 @@ func (a *App) DeleteFiles(ids []int64) error {
 		return fmt.Errorf("file service not initialized")
 	}
 
-	err := a.fileService.DeleteFiles(ids)
+	err := a.fileService.TrashFiles(ids)
 	if err != nil {
 		runtime.LogError(a.ctx, fmt.Sprintf("DeleteFiles failed: %v", err))
 		return err
@@ -252,7 +510,381 @@ func (a *App) DeleteFolders(folderIDs []int64) error {
 	if a.fileService == nil {
 		return fmt.Errorf("file service not initialized")
 	}
-	return a.fileService.DeleteFolders(folderIDs)
+	return a.fileService.TrashFolders(folderIDs)
+}
+
+// DeleteFilesPermanently wipes files immediately, bypassing the trash
+func (a *App) DeleteFilesPermanently(ids []int64) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.DeleteFiles(ids)
+}
+
+// DeleteFoldersPermanently starts wiping folders and their files, bypassing the trash, and returns the job ID
+func (a *App) DeleteFoldersPermanently(folderIDs []int64) (string, error) {
+	if a.fileService == nil {
+		return "", fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.StartDeleteFolders(folderIDs)
+}
+
+func (a *App) GetJob(id string) (*filestore.JobStatus, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.GetJob(id)
+}
+
+func (a *App) ListJobs() ([]filestore.JobStatus, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.ListJobs(), nil
+}
+
+func (a *App) CancelJob(id string) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.CancelJob(id)
+}
+
+func (a *App) RestoreFiles(ids []int64) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.RestoreFiles(ids)
+}
+
+func (a *App) RestoreFolders(folderIDs []int64) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.RestoreFolders(folderIDs)
+}
+
+func (a *App) GetTrash() ([]filestore.TrashItem, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.GetTrash()
+}
+
+func (a *App) EmptyTrash() error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.EmptyTrash()
+}
+
+func (a *App) GetTrashRetention() (int, error) {
+	if a.fileService == nil {
+		return 0, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.GetTrashRetention()
+}
+
+// SetTrashRetention sets how many days items stay in the trash before being wiped, 0 keeps them until emptied
+func (a *App) SetTrashRetention(days int) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.SetTrashRetention(days)
+}
+
+func (a *App) CreateRetentionRule(rule filestore.RetentionRule) (*filestore.RetentionRule, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.CreateRetentionRule(rule)
+}
+
+func (a *App) UpdateRetentionRule(rule filestore.RetentionRule) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.UpdateRetentionRule(rule)
+}
+
+func (a *App) DeleteRetentionRule(id int64) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.DeleteRetentionRule(id)
+}
+
+func (a *App) ListRetentionRules() ([]filestore.RetentionRule, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.ListRetentionRules()
+}
+
+// PreviewRetentionRule lists the files a rule would delete, before it is saved
+func (a *App) PreviewRetentionRule(rule filestore.RetentionRule) ([]filestore.RetentionMatch, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.PreviewRetentionRule(rule)
+}
+
+// ApplyRetentionRules runs the enabled rules now instead of waiting for the
+// scheduler. With dryRun it only lists what would be deleted.
+func (a *App) ApplyRetentionRules(dryRun bool) ([]filestore.RetentionMatch, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+
+	matches, err := a.fileService.ApplyRetentionRules(dryRun)
+	if err != nil {
+		runtime.LogError(a.ctx, fmt.Sprintf("Failed to apply retention rules: %v", err))
+		return nil, err
+	}
+	return matches, nil
+}
+
+func (a *App) GetRetentionLog() ([]filestore.RetentionLogEntry, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.GetRetentionLog()
+}
+
+// AddFileNote annotates a file. status is "", "unverified", "verified" or "disputed".
+func (a *App) AddFileNote(fileID int64, author string, body string, status string) (*filestore.Note, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.AddFileNote(fileID, author, body, status)
+}
+
+func (a *App) AddFolderNote(folderID int64, author string, body string, status string) (*filestore.Note, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.AddFolderNote(folderID, author, body, status)
+}
+
+func (a *App) UpdateNote(id int64, author string, body string, status string) (*filestore.Note, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.UpdateNote(id, author, body, status)
+}
+
+func (a *App) DeleteNote(id int64) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.DeleteNote(id)
+}
+
+func (a *App) GetFileNotes(fileID int64) ([]filestore.Note, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.GetFileNotes(fileID)
+}
+
+func (a *App) GetFolderNotes(folderID int64) ([]filestore.Note, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.GetFolderNotes(folderID)
+}
+
+func (a *App) GetNoteHistory(id int64) ([]filestore.NoteRevision, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.GetNoteHistory(id)
+}
+
+func (a *App) SearchNotes(query string) ([]filestore.Note, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.SearchNotes(query)
+}
+
+func (a *App) StartCompaction() error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.StartCompaction()
+}
+
+func (a *App) PauseCompaction() error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.PauseCompaction()
+}
+
+// OpenExternally opens a temporary decrypted copy of a file in the default application.
+// The copy is wiped when the vault is locked or after a while, whichever comes first.
+func (a *App) OpenExternally(fileID int64) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+
+	if _, err := a.fileService.OpenExternally(fileID); err != nil {
+		runtime.LogError(a.ctx, fmt.Sprintf("OpenExternally failed: %v", err))
+		return err
+	}
+	return nil
+}
+
+// GetPreviewURL returns a URL the webview can load a file from, such as an
+// img or video src. It stops working when the vault is locked.
+func (a *App) GetPreviewURL(fileID int64) (string, error) {
+	if a.fileService == nil {
+		return "", fmt.Errorf("file service not initialized")
+	}
+
+	metadata, err := a.fileService.ViewFile(fileID)
+	if err != nil {
+		return "", err
+	}
+	return a.previewHandler.URL(metadata.ID, metadata.Name, metadata.MimeType)
+}
+
+// GetCompressionSettings returns whether compressible files are compressed before encryption
+func (a *App) GetCompressionSettings() (filestore.CompressionSettings, error) {
+	if a.fileService == nil {
+		return filestore.CompressionSettings{}, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.GetCompressionSettings()
+}
+
+// SetCompressionSettings changes compression and padding for files stored from now on
+func (a *App) SetCompressionSettings(settings filestore.CompressionSettings) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.SetCompressionSettings(settings)
+}
+
+func (a *App) CreateTag(name string, color string) (*filestore.Tag, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.CreateTag(name, color)
+}
+
+func (a *App) ListTags() ([]filestore.Tag, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.ListTags()
+}
+
+func (a *App) RenameTag(id int64, name string) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.RenameTag(id, name)
+}
+
+func (a *App) SetTagColor(id int64, color string) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.SetTagColor(id, color)
+}
+
+func (a *App) DeleteTag(id int64) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.DeleteTag(id)
+}
+
+// MergeTags moves everything tagged with the source tags onto the target tag
+func (a *App) MergeTags(sourceIDs []int64, targetID int64) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.MergeTags(sourceIDs, targetID)
+}
+
+func (a *App) TagFiles(fileIDs []int64, tagID int64) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.TagFiles(fileIDs, tagID)
+}
+
+func (a *App) UntagFiles(fileIDs []int64, tagID int64) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.UntagFiles(fileIDs, tagID)
+}
+
+func (a *App) TagFolders(folderIDs []int64, tagID int64) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.TagFolders(folderIDs, tagID)
+}
+
+func (a *App) UntagFolders(folderIDs []int64, tagID int64) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.UntagFolders(folderIDs, tagID)
+}
+
+func (a *App) GetThumbnail(fileID int64, size int) (*filestore.Thumbnail, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.GetThumbnail(fileID, size)
+}
+
+func (a *App) StartThumbnailBackfill() error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.StartThumbnailBackfill()
+}
+
+func (a *App) StopThumbnailBackfill() error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.StopThumbnailBackfill()
+}
+
+func (a *App) GetVaultStats() (*filestore.VaultStats, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.GetVaultStats()
+}
+
+func (a *App) GetStorageThresholds() (filestore.StorageThresholds, error) {
+	if a.fileService == nil {
+		return filestore.StorageThresholds{}, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.GetStorageThresholds()
+}
+
+// SetStorageThresholds sets when a storage-warning event is emitted, as free bytes and a percentage of the disk
+func (a *App) SetStorageThresholds(thresholds filestore.StorageThresholds) error {
+	if a.fileService == nil {
+		return fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.SetStorageThresholds(thresholds)
+}
+
+func (a *App) GetCompactionStatus() (*filestore.CompactionStatus, error) {
+	if a.fileService == nil {
+		return nil, fmt.Errorf("file service not initialized")
+	}
+	return a.fileService.GetCompactionStatus()
 }
 
 // upload functions
@@ -272,6 +904,9 @@ func (a *App) RejectTransfer(sessionID string) error {
 
 // LockApp locks the application by closing database and clearing auth state
 func (a *App) LockApp() error {
+	// Preview URLs stop working before anything else is torn down
+	a.previewHandler.Stop()
+
 	// Stop the server if it's running
 	if a.serverService != nil && a.serverService.IsRunning() {
 		if err := a.serverService.Stop(a.ctx); err != nil {
@@ -279,6 +914,25 @@ func (a *App) LockApp() error {
 		}
 	}
 
+	// Let a running compaction finish its current move before the database goes away
+	if a.fileService != nil {
+		if err := a.fileService.StopJobs(); err != nil {
+			runtime.LogError(a.ctx, "Failed to stop jobs during lock: "+err.Error())
+		}
+		if err := a.fileService.PauseCompaction(); err != nil {
+			runtime.LogError(a.ctx, "Failed to pause compaction during lock: "+err.Error())
+		}
+		if err := a.fileService.StopThumbnailBackfill(); err != nil {
+			runtime.LogError(a.ctx, "Failed to stop thumbnail backfill during lock: "+err.Error())
+		}
+		if err := a.fileService.StopTrashPurge(); err != nil {
+			runtime.LogError(a.ctx, "Failed to stop trash purge during lock: "+err.Error())
+		}
+		if err := a.fileService.WipeTempFiles(); err != nil {
+			runtime.LogError(a.ctx, "Failed to wipe temporary copies during lock: "+err.Error())
+		}
+	}
+
 	// Close database connection
 	if a.db != nil {
 		a.db.Close()
@@ -73,10 +73,33 @@ func runMigrations(db *sql.DB) error {
 		return fmt.Errorf("failed to begin transaction: %v", err)
 	}
 	defer tx.Rollback()
+
+	// Track applied migrations so non-idempotent statements (ALTER TABLE) run once
+	if _, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			name TEXT PRIMARY KEY,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return fmt.Errorf("failed to create migrations table: %v", err)
+	}
+
 	for _, migration := range getMigrations() {
+		var applied int
+		err := tx.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE name = ?", migration.Name).Scan(&applied)
+		if err != nil {
+			return fmt.Errorf("failed to check migration %s: %v", migration.Name, err)
+		}
+		if applied > 0 {
+			continue
+		}
+
 		if _, err := tx.Exec(string(migration.Content)); err != nil {
 			return fmt.Errorf("failed to execute migration %s: %v", migration.Name, err)
 		}
+		if _, err := tx.Exec("INSERT INTO schema_migrations (name) VALUES (?)", migration.Name); err != nil {
+			return fmt.Errorf("failed to record migration %s: %v", migration.Name, err)
+		}
 	}
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
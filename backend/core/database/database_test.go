@@ -0,0 +1,62 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestReceivedFilesBackfill(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	// Bring the schema up to the migration before the backfill
+	if _, err := db.Exec("CREATE TABLE schema_migrations (name TEXT PRIMARY KEY, applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)"); err != nil {
+		t.Fatalf("Failed to create migrations table: %v", err)
+	}
+	for _, migration := range getMigrations() {
+		if migration.Name == "016_received_files" {
+			break
+		}
+		if _, err := db.Exec(migration.Content); err != nil {
+			t.Fatalf("Failed to execute migration %s: %v", migration.Name, err)
+		}
+		if _, err := db.Exec("INSERT INTO schema_migrations (name) VALUES (?)", migration.Name); err != nil {
+			t.Fatalf("Failed to record migration %s: %v", migration.Name, err)
+		}
+	}
+
+	// A file from before the audit log, a received one and an imported one
+	if _, err := db.Exec("INSERT INTO folders (id, name) VALUES (1, 'Received Files')"); err != nil {
+		t.Fatalf("Failed to insert folder: %v", err)
+	}
+	for i, event := range []string{"", "received", "imported"} {
+		id := i + 1
+		if _, err := db.Exec("INSERT INTO files (id, uuid, name, size, folder_id, mime_type, offset, length) VALUES (?, ?, 'file', 1, 1, 'text/plain', 0, 1)", id, event+"-uuid"); err != nil {
+			t.Fatalf("Failed to insert file: %v", err)
+		}
+		if event != "" {
+			if _, err := db.Exec("INSERT INTO audit_log (event, actor, file_id, created_at, prev_hash, hash) VALUES (?, '', ?, '', '', '')", event, id); err != nil {
+				t.Fatalf("Failed to insert audit entry: %v", err)
+			}
+		}
+	}
+
+	if err := runMigrations(db); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	for id, want := range map[int]bool{1: true, 2: true, 3: false} {
+		var received bool
+		if err := db.QueryRow("SELECT received FROM files WHERE id = ?", id).Scan(&received); err != nil {
+			t.Fatalf("Failed to read file %d: %v", id, err)
+		}
+		if received != want {
+			t.Errorf("File %d: got received %v, want %v", id, received, want)
+		}
+	}
+}
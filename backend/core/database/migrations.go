@@ -100,5 +100,238 @@ func getMigrations() []migrationEntry {
 	BEGIN
 	UPDATE files SET updated_at = CURRENT_TIMESTAMP 
 	WHERE id = NEW.id;
-	END;`}}
+	END;`},
+	migrationEntry{"002_chunked_encryption", `-- Files stored before chunked encryption keep cipher version 1 (single AES-GCM message)
+	ALTER TABLE files ADD COLUMN cipher_version INTEGER NOT NULL DEFAULT 1;`},
+	migrationEntry{"003_free_space_allocator", `-- Every live extent in the TVault, used by the free-space allocator
+	CREATE VIEW IF NOT EXISTS vault_extents AS
+		SELECT offset, length FROM files WHERE is_deleted = 0;
+
+	-- Neighbour lookups when coalescing free regions
+	CREATE INDEX IF NOT EXISTS idx_free_spaces_offset ON free_spaces(offset);
+	CREATE INDEX IF NOT EXISTS idx_files_offset ON files(offset);`},
+	migrationEntry{"004_compaction", `-- Extents carry their kind so compaction knows which table to update when moving them
+	DROP VIEW IF EXISTS vault_extents;
+	CREATE VIEW vault_extents AS
+		SELECT 'file' AS kind, offset, length FROM files WHERE is_deleted = 0;
+
+	-- Single-row journal for the TVault compaction job
+	CREATE TABLE IF NOT EXISTS compaction_state (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		status TEXT NOT NULL DEFAULT 'idle', -- idle, running, paused
+		moved_extents INTEGER NOT NULL DEFAULT 0,
+		moved_bytes INTEGER NOT NULL DEFAULT 0,
+		initial_free_bytes INTEGER NOT NULL DEFAULT 0,
+		pending_offset INTEGER, -- destination reserved by an uncommitted move
+		pending_length INTEGER,
+		wipe_offset INTEGER,    -- vacated region not yet overwritten
+		wipe_length INTEGER,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT OR IGNORE INTO compaction_state (id) VALUES (1);`},
+	migrationEntry{"005_file_query", `-- Case-folded copy of the file name for search and sorting, filled in by the application
+	-- since SQLite's lower() only folds ASCII
+	ALTER TABLE files ADD COLUMN search_name TEXT;
+
+	-- Each sort key is indexed together with id, the pagination tiebreaker, for keyset pagination
+	CREATE INDEX IF NOT EXISTS idx_files_live_created ON files(is_deleted, created_at, id);
+	CREATE INDEX IF NOT EXISTS idx_files_live_name ON files(is_deleted, search_name, id);
+	CREATE INDEX IF NOT EXISTS idx_files_live_size ON files(is_deleted, size, id);
+	CREATE INDEX IF NOT EXISTS idx_files_live_mime ON files(is_deleted, mime_type, id);
+	CREATE INDEX IF NOT EXISTS idx_files_folder_created ON files(folder_id, is_deleted, created_at, id);`},
+	migrationEntry{"006_thumbnails", `-- Encrypted thumbnails stored in the TVault, one per file and size
+	CREATE TABLE IF NOT EXISTS thumbnails (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		file_id INTEGER NOT NULL,
+		uuid TEXT NOT NULL UNIQUE, -- thumbnails get their own key, like files
+		size INTEGER NOT NULL,     -- requested longest edge in pixels
+		width INTEGER NOT NULL,
+		height INTEGER NOT NULL,
+		mime_type TEXT NOT NULL,
+		offset INTEGER NOT NULL,
+		length INTEGER NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (file_id) REFERENCES files(id) ON DELETE CASCADE,
+		UNIQUE (file_id, size)
+	);
+	CREATE INDEX IF NOT EXISTS idx_thumbnails_offset ON thumbnails(offset);
+
+	-- 0 = not yet processed, 1 = previews generated, 2 = no preview possible
+	ALTER TABLE files ADD COLUMN thumbnail_state INTEGER NOT NULL DEFAULT 0;
+
+	DROP VIEW IF EXISTS vault_extents;
+	CREATE VIEW vault_extents AS
+		SELECT 'file' AS kind, offset, length FROM files WHERE is_deleted = 0
+		UNION ALL
+		SELECT 'thumbnail' AS kind, offset, length FROM thumbnails;`},
+	migrationEntry{"007_dedup", `-- Keyed content hash, so identical uploads can share one extent
+	ALTER TABLE files ADD COLUMN content_hash TEXT;
+	-- UUID whose key encrypted a shared extent, NULL when it is the file's own
+	ALTER TABLE files ADD COLUMN key_uuid TEXT;
+	CREATE INDEX IF NOT EXISTS idx_files_content_hash ON files(content_hash, size);
+
+	-- A shared extent is listed once however many files refer to it
+	DROP VIEW IF EXISTS vault_extents;
+	CREATE VIEW vault_extents AS
+		SELECT 'file' AS kind, offset, MAX(length) AS length FROM files WHERE is_deleted = 0 GROUP BY offset
+		UNION ALL
+		SELECT 'thumbnail' AS kind, offset, length FROM thumbnails;`},
+	migrationEntry{"008_forensic_hashes", `-- Plaintext digests recorded as files are stored, hex encoded
+	ALTER TABLE files ADD COLUMN sha256 TEXT;
+	ALTER TABLE files ADD COLUMN sha512 TEXT;`},
+	migrationEntry{"009_trash", `-- Trashed items keep their data until the trash is emptied or expires.
+	-- trash_root is the folder whose trashing took the item with it, NULL for
+	-- files trashed on their own; a trashed folder is its own root.
+	ALTER TABLE files ADD COLUMN trashed_at TIMESTAMP;
+	ALTER TABLE files ADD COLUMN trash_root INTEGER;
+	ALTER TABLE folders ADD COLUMN trashed_at TIMESTAMP;
+	ALTER TABLE folders ADD COLUMN trash_root INTEGER;
+	CREATE INDEX IF NOT EXISTS idx_files_trash_root ON files(trash_root);
+	CREATE INDEX IF NOT EXISTS idx_folders_trash_root ON folders(trash_root);
+
+	CREATE TABLE IF NOT EXISTS settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);`},
+	migrationEntry{"010_exports", `-- Plaintext copies written outside the vault, so they can be found and wiped later.
+	-- sha256 and size describe the exported file or ZIP as written to disk.
+	CREATE TABLE IF NOT EXISTS exports (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		path TEXT NOT NULL,
+		sha256 TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		exported_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		-- set once the copy has been wiped or found missing
+		closed_at TIMESTAMP,
+		status TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS exported_files (
+		export_id INTEGER NOT NULL,
+		file_id INTEGER NOT NULL,
+		-- name on disk or inside the ZIP
+		name TEXT NOT NULL,
+		FOREIGN KEY (export_id) REFERENCES exports(id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_exported_files_export_id ON exported_files(export_id);
+	CREATE INDEX IF NOT EXISTS idx_exported_files_file_id ON exported_files(file_id);`},
+	migrationEntry{"011_audit_log", `-- Chain-of-custody log. Each entry's hash covers its fields and the hash of
+	-- the entry before it, so a changed or removed entry breaks the chain.
+	-- created_at is TEXT as it is hashed exactly as stored.
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY,
+		event TEXT NOT NULL,
+		actor TEXT NOT NULL,
+		file_id INTEGER,
+		folder_id INTEGER,
+		content_hash TEXT NOT NULL DEFAULT '',
+		details TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL,
+		prev_hash TEXT NOT NULL,
+		hash TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_audit_log_file_id ON audit_log(file_id);
+
+	-- Entries are never changed or removed once written
+	CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+	BEGIN
+		SELECT RAISE(ABORT, 'audit log is append-only');
+	END;
+	CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+	BEGIN
+		SELECT RAISE(ABORT, 'audit log is append-only');
+	END;`},
+	migrationEntry{"012_compression", `-- Algorithm the plaintext was compressed with before encryption, '' for none.
+	ALTER TABLE files ADD COLUMN compression TEXT NOT NULL DEFAULT '';`},
+	migrationEntry{"013_tags", `-- Tags organise files and folders across the folder hierarchy.
+	-- search_name is the case-folded name, so names are unique regardless of case.
+	CREATE TABLE IF NOT EXISTS tags (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		search_name TEXT NOT NULL UNIQUE,
+		color TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS file_tags (
+		file_id INTEGER NOT NULL,
+		tag_id INTEGER NOT NULL,
+		PRIMARY KEY (file_id, tag_id),
+		FOREIGN KEY (file_id) REFERENCES files(id) ON DELETE CASCADE,
+		FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_file_tags_tag_id ON file_tags(tag_id);
+
+	CREATE TABLE IF NOT EXISTS folder_tags (
+		folder_id INTEGER NOT NULL,
+		tag_id INTEGER NOT NULL,
+		PRIMARY KEY (folder_id, tag_id),
+		FOREIGN KEY (folder_id) REFERENCES folders(id) ON DELETE CASCADE,
+		FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_folder_tags_tag_id ON folder_tags(tag_id);`},
+	migrationEntry{"014_retention", `-- Retention rules delete files a number of days after they were stored.
+	-- A NULL folder_id applies the rule to every folder; received_only limits
+	-- it to files received from nearby devices.
+	CREATE TABLE IF NOT EXISTS retention_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		folder_id INTEGER,
+		include_subfolders INTEGER NOT NULL DEFAULT 0,
+		received_only INTEGER NOT NULL DEFAULT 0,
+		days INTEGER NOT NULL,
+		enabled INTEGER NOT NULL DEFAULT 1,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		last_run_at TIMESTAMP
+	);
+
+	-- Files deleted by retention rules. The rule name is copied so the log
+	-- still reads correctly after the rule is changed or removed.
+	CREATE TABLE IF NOT EXISTS retention_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		rule_id INTEGER NOT NULL,
+		rule_name TEXT NOT NULL,
+		file_id INTEGER NOT NULL,
+		file_name TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		stored_at TIMESTAMP NOT NULL,
+		deleted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`},
+	migrationEntry{"015_notes", `-- Notes annotate either a file or a folder. search_body is the case-folded
+	-- author and body, for searching; status is an optional verification status.
+	CREATE TABLE IF NOT EXISTS notes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		file_id INTEGER,
+		folder_id INTEGER,
+		author TEXT NOT NULL DEFAULT '',
+		body TEXT NOT NULL,
+		search_body TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		CHECK ((file_id IS NULL) != (folder_id IS NULL)),
+		FOREIGN KEY (file_id) REFERENCES files(id) ON DELETE CASCADE,
+		FOREIGN KEY (folder_id) REFERENCES folders(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_notes_file_id ON notes(file_id);
+	CREATE INDEX IF NOT EXISTS idx_notes_folder_id ON notes(folder_id);
+
+	-- Each edit keeps the version it replaced
+	CREATE TABLE IF NOT EXISTS note_revisions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		note_id INTEGER NOT NULL,
+		author TEXT NOT NULL,
+		body TEXT NOT NULL,
+		status TEXT NOT NULL,
+		written_at TIMESTAMP NOT NULL,
+		FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_note_revisions_note_id ON note_revisions(note_id);`},
+	migrationEntry{"016_received_files", `-- Files received from nearby devices. Files stored before the audit log
+	-- existed have no entries in it, and could then only be received.
+	ALTER TABLE files ADD COLUMN received INTEGER NOT NULL DEFAULT 0;
+	UPDATE files SET received = 1
+	WHERE id IN (SELECT file_id FROM audit_log WHERE event = 'received')
+		OR id NOT IN (SELECT file_id FROM audit_log WHERE file_id IS NOT NULL);`},
+	}
 }
@@ -0,0 +1,17 @@
+package auth
+
+// ProfileInfo describes a vault profile. Name is only known for the unlocked
+// profile; the others are told apart by their Label.
+type ProfileInfo struct {
+	ID          string `json:"id"`
+	Label       string `json:"label"`
+	Name        string `json:"name,omitempty"`
+	Active      bool   `json:"active"`
+	Initialized bool   `json:"initialized"`
+}
+
+// VaultLocation tells where the vaults are stored
+type VaultLocation struct {
+	DataDir  string `json:"dataDir"`
+	Portable bool   `json:"portable"`
+}
@@ -20,4 +20,19 @@ type Service interface {
 
 	// ClearSession clears the current authentication session
 	ClearSession()
+
+	// ListProfiles returns every vault profile
+	ListProfiles() ([]ProfileInfo, error)
+
+	// CreateProfile creates a new profile with its own vault and password, and unlocks it
+	CreateProfile(name string, password string) (string, error)
+
+	// SwitchProfile locks the session and selects another profile to unlock
+	SwitchProfile(id string) error
+
+	// GetVaultLocation returns where the vaults are stored
+	GetVaultLocation() VaultLocation
+
+	// MoveVault relocates every profile's vault to another directory (only while locked)
+	MoveVault(destination string) error
 }
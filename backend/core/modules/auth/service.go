@@ -35,6 +35,15 @@ func NewService(ctx context.Context) Service {
 func (s *service) Initialize(ctx context.Context) error {
 	s.ctx = ctx
 
+	// Pick up where the vault was moved to and the profile used last time
+	if err := authutils.LoadDataLocation(); err != nil {
+		runtime.LogError(ctx, "Failed to load vault location: "+err.Error())
+	}
+	if err := authutils.RestoreActiveProfile(); err != nil {
+		runtime.LogError(ctx, "Failed to restore active profile: "+err.Error())
+	}
+	s.usePaths()
+
 	// create directory if they don't exists
 	vaultDir := filepath.Dir(s.tvaultPath)
 	if err := os.MkdirAll(vaultDir, 0755); err != nil {
@@ -152,3 +161,110 @@ func (s *service) ClearSession() {
 	s.isUnlocked = false
 	runtime.LogInfo(s.ctx, "Session cleared")
 }
+
+func (s *service) ListProfiles() ([]ProfileInfo, error) {
+	profiles, err := authutils.ListProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	active := authutils.ActiveProfile()
+	infos := make([]ProfileInfo, 0, len(profiles))
+	for i, profile := range profiles {
+		info := ProfileInfo{
+			ID:     profile.ID,
+			Label:  fmt.Sprintf("Vault %d", i+1),
+			Active: profile.ID == active,
+		}
+
+		_, err := os.Stat(filepath.Join(authutils.GetProfileDir(profile.ID), authutils.TVaultFile))
+		info.Initialized = err == nil
+
+		if info.Active && s.isUnlocked {
+			name, err := authutils.DecryptProfileName(profile, s.databaseKey)
+			if err != nil {
+				return nil, err
+			}
+			info.Name = name
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (s *service) CreateProfile(name string, password string) (string, error) {
+	if len(password) < 6 {
+		return "", constants.ErrPasswordTooShort
+	}
+
+	previous := authutils.ActiveProfile()
+	id, err := authutils.AddProfile()
+	if err != nil {
+		return "", err
+	}
+
+	// Undo the profile if its vault cannot be set up
+	fail := func(err error) (string, error) {
+		s.ClearSession()
+		if removeErr := authutils.RemoveProfile(id); removeErr != nil {
+			runtime.LogError(s.ctx, "Failed to remove incomplete profile: "+removeErr.Error())
+		}
+		authutils.SetActiveProfile(previous)
+		s.usePaths()
+		return "", err
+	}
+
+	s.ClearSession()
+	if err := authutils.SetActiveProfile(id); err != nil {
+		return fail(err)
+	}
+	s.usePaths()
+
+	if err := s.CreatePassword(password); err != nil {
+		return fail(err)
+	}
+	if err := authutils.SetProfileName(id, name, s.databaseKey); err != nil {
+		return fail(err)
+	}
+
+	runtime.LogInfo(s.ctx, "Profile created")
+	return id, nil
+}
+
+func (s *service) SwitchProfile(id string) error {
+	s.ClearSession()
+	if err := authutils.SetActiveProfile(id); err != nil {
+		return err
+	}
+	s.usePaths()
+
+	runtime.LogInfo(s.ctx, "Switched profile")
+	return nil
+}
+
+func (s *service) GetVaultLocation() VaultLocation {
+	return VaultLocation{
+		DataDir:  authutils.GetDataDir(),
+		Portable: authutils.PortableRoot() != "",
+	}
+}
+
+func (s *service) MoveVault(destination string) error {
+	if s.isUnlocked {
+		return errors.New("the vault must be locked before it is moved")
+	}
+
+	if err := authutils.MoveDataDir(destination); err != nil {
+		return err
+	}
+	s.usePaths()
+
+	runtime.LogInfo(s.ctx, "Vault moved to "+authutils.GetDataDir())
+	return nil
+}
+
+// usePaths points the service at the active profile's vault and database
+func (s *service) usePaths() {
+	s.tvaultPath = authutils.GetTVaultPath()
+	s.databasePath = authutils.GetDatabasePath()
+}
@@ -2,6 +2,8 @@ package auth
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -16,6 +18,12 @@ type testService struct {
 	tempDir      string
 	dbKey        []byte
 	isUnlocked   bool
+
+	// Profiles keep their vaults under dataDir; the first one at its root
+	dataDir  string
+	profiles []string
+	names    map[string]string
+	active   string
 }
 
 // Implement the Service interface for our test service
@@ -76,6 +84,7 @@ func (s *testService) DecryptDatabaseKey(password string) error {
 		return nil
 	}
 
+	s.ClearSession()
 	return constants.ErrInvalidPassword
 }
 
@@ -86,6 +95,93 @@ func (s *testService) GetDBKey() ([]byte, error) {
 	return s.dbKey, nil
 }
 
+func (s *testService) ClearSession() {
+	for i := range s.dbKey {
+		s.dbKey[i] = 0
+	}
+	s.dbKey = nil
+	s.isUnlocked = false
+}
+
+func (s *testService) ListProfiles() ([]ProfileInfo, error) {
+	infos := make([]ProfileInfo, 0, len(s.profiles))
+	for i, id := range s.profiles {
+		info := ProfileInfo{ID: id, Label: fmt.Sprintf("Vault %d", i+1), Active: id == s.active}
+		_, err := os.Stat(s.profileVault(id))
+		info.Initialized = err == nil
+		if info.Active && s.isUnlocked {
+			info.Name = s.names[id]
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (s *testService) CreateProfile(name string, password string) (string, error) {
+	if len(password) < 6 {
+		return "", constants.ErrPasswordTooShort
+	}
+
+	previous := s.active
+	id := fmt.Sprintf("profile-%d", len(s.profiles)+1)
+	s.ClearSession()
+	s.profiles = append(s.profiles, id)
+	s.usePaths(id)
+	if err := os.MkdirAll(filepath.Dir(s.tvaultPath), 0755); err != nil {
+		s.profiles = s.profiles[:len(s.profiles)-1]
+		s.usePaths(previous)
+		return "", err
+	}
+
+	if err := s.CreatePassword(password); err != nil {
+		s.profiles = s.profiles[:len(s.profiles)-1]
+		s.usePaths(previous)
+		return "", err
+	}
+	s.names[id] = name
+	return id, nil
+}
+
+func (s *testService) SwitchProfile(id string) error {
+	s.ClearSession()
+	for _, profile := range s.profiles {
+		if profile == id {
+			s.usePaths(id)
+			return nil
+		}
+	}
+	return fmt.Errorf("profile not found: %s", id)
+}
+
+func (s *testService) GetVaultLocation() VaultLocation {
+	return VaultLocation{DataDir: s.dataDir}
+}
+
+func (s *testService) MoveVault(destination string) error {
+	if s.isUnlocked {
+		return errors.New("the vault must be locked before it is moved")
+	}
+	if err := os.Rename(s.dataDir, destination); err != nil {
+		return err
+	}
+	s.dataDir = destination
+	s.usePaths(s.active)
+	return nil
+}
+
+func (s *testService) profileVault(id string) string {
+	if id == s.profiles[0] {
+		return filepath.Join(s.dataDir, ".tvault")
+	}
+	return filepath.Join(s.dataDir, "profiles", id, ".tvault")
+}
+
+func (s *testService) usePaths(id string) {
+	s.active = id
+	s.tvaultPath = s.profileVault(id)
+	s.databasePath = filepath.Join(filepath.Dir(s.tvaultPath), "tella.db")
+}
+
 // Setup test environment
 func setupTestEnvironment(t *testing.T) (Service, func()) {
 	// Create temporary test directory
@@ -99,6 +195,10 @@ func setupTestEnvironment(t *testing.T) (Service, func()) {
 		databasePath: filepath.Join(tempDir, "tella.db"),
 		tempDir:      filepath.Join(tempDir, "temp"),
 		isUnlocked:   false,
+		dataDir:      tempDir,
+		profiles:     []string{"default"},
+		names:        make(map[string]string),
+		active:       "default",
 	}
 
 	// Initialize the service
@@ -330,3 +430,76 @@ func TestGetDBKey(t *testing.T) {
 		t.Errorf("Expected DB key length %d, got %d", constants.KeyLength, len(dbKey))
 	}
 }
+
+func TestProfiles(t *testing.T) {
+	service, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	password := "secure-password-1234"
+	if err := service.CreatePassword(password); err != nil {
+		t.Fatalf("Failed to create password: %v", err)
+	}
+
+	if _, err := service.CreateProfile("Second", "short"); err != constants.ErrPasswordTooShort {
+		t.Errorf("Expected a short password to be rejected, got %v", err)
+	}
+	id, err := service.CreateProfile("Second", password)
+	if err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+
+	// The new profile is unlocked, and only its name is shown
+	profiles, err := service.ListProfiles()
+	if err != nil {
+		t.Fatalf("Failed to list profiles: %v", err)
+	}
+	if len(profiles) != 2 || profiles[0].Active || profiles[0].Name != "" || !profiles[1].Active || profiles[1].Name != "Second" || !profiles[1].Initialized {
+		t.Errorf("Unexpected profiles: %+v", profiles)
+	}
+	if _, err := service.GetDBKey(); err != nil {
+		t.Errorf("Expected the new profile to be unlocked: %v", err)
+	}
+
+	// Switching locks the session
+	if err := service.SwitchProfile("missing"); err == nil {
+		t.Error("Expected switching to an unknown profile to fail")
+	}
+	if err := service.SwitchProfile(profiles[0].ID); err != nil {
+		t.Fatalf("Failed to switch profile: %v", err)
+	}
+	if _, err := service.GetDBKey(); err == nil {
+		t.Error("Expected switching profiles to lock the session")
+	}
+	if service.IsFirstTimeSetup() {
+		t.Error("Expected the first profile to keep its vault")
+	}
+	profiles, _ = service.ListProfiles()
+	if !profiles[0].Active || profiles[1].Name != "" || profiles[1].ID != id {
+		t.Errorf("Unexpected profiles after switching: %+v", profiles)
+	}
+}
+
+func TestMoveVault(t *testing.T) {
+	service, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	if err := service.CreatePassword("secure-password-1234"); err != nil {
+		t.Fatalf("Failed to create password: %v", err)
+	}
+
+	destination := filepath.Join(t.TempDir(), "moved")
+	if err := service.MoveVault(destination); err == nil {
+		t.Error("Expected moving an unlocked vault to be rejected")
+	}
+
+	service.ClearSession()
+	if err := service.MoveVault(destination); err != nil {
+		t.Fatalf("Failed to move vault: %v", err)
+	}
+	if location := service.GetVaultLocation(); location.DataDir != destination || location.Portable {
+		t.Errorf("Unexpected vault location: %+v", location)
+	}
+	if service.IsFirstTimeSetup() {
+		t.Error("Expected the vault to be found at its new location")
+	}
+}
@@ -0,0 +1,171 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/auditutils"
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// appendStoreAudit logs a newly stored file, with the event and actor of
+// origin. Received files are also flagged on the file itself.
+func appendStoreAudit(tx *sql.Tx, origin auditutils.Entry, fileID, folderID int64, hashes filestoreutils.ContentHashes) error {
+	if origin.Event == auditutils.EventReceived {
+		if _, err := tx.Exec("UPDATE files SET received = 1 WHERE id = ?", fileID); err != nil {
+			return fmt.Errorf("failed to mark file as received: %w", err)
+		}
+	}
+
+	origin.FileID = fileID
+	origin.FolderID = folderID
+	origin.ContentHash = hashes.SHA256
+	return auditutils.Append(tx, origin)
+}
+
+// appendFilesAudit logs event for every file the query selects within tx
+func appendFilesAudit(tx *sql.Tx, event string, details string, query string, args ...interface{}) error {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query files for audit log: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan file ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating files: %w", err)
+	}
+
+	for _, id := range ids {
+		if err := auditutils.AppendFile(tx, event, "", id, details); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ViewFile looks up a file about to be shown in the app and logs that it was viewed
+func (s *service) ViewFile(id int64) (*FileMetadata, error) {
+	metadata, err := filestoreutils.GetFileMetadataByID(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := auditutils.AppendFile(tx, auditutils.EventViewed, "", id, ""); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &FileMetadata{
+		ID:       metadata.ID,
+		UUID:     metadata.UUID,
+		Name:     metadata.Name,
+		Size:     metadata.Size,
+		MimeType: metadata.MimeType,
+		FolderID: metadata.FolderID,
+		Offset:   metadata.Offset,
+		Length:   metadata.Length,
+	}, nil
+}
+
+// GetAuditLog returns the chain-of-custody log, only the entries about fileID unless it is 0
+func (s *service) GetAuditLog(fileID int64) ([]AuditEntry, error) {
+	entries, err := auditutils.List(s.db, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	log := make([]AuditEntry, len(entries))
+	for i, entry := range entries {
+		log[i] = AuditEntry(entry)
+	}
+	return log, nil
+}
+
+// VerifyAuditLog recomputes every entry's hash and checks the links between them
+func (s *service) VerifyAuditLog() (*AuditVerification, error) {
+	entries, err := auditutils.List(s.db, 0)
+	if err != nil {
+		return nil, err
+	}
+	result := auditutils.Verify(entries)
+	return &AuditVerification{
+		Valid:   result.Valid,
+		Entries: result.Entries,
+		Head:    result.Head,
+		Errors:  result.Errors,
+	}, nil
+}
+
+// ExportAuditLog writes the whole log as JSON or CSV to the destination, or
+// the downloads directory, and signs it with the vault's audit key. The
+// signature goes in a .sig file next to the log. Returns the log's path.
+func (s *service) ExportAuditLog(format string, destination string) (string, error) {
+	entries, err := auditutils.List(s.db, 0)
+	if err != nil {
+		return "", err
+	}
+
+	var data bytes.Buffer
+	switch format {
+	case AuditFormatJSON:
+		err = auditutils.WriteJSON(&data, entries)
+	case AuditFormatCSV:
+		err = auditutils.WriteCSV(&data, entries)
+	default:
+		return "", fmt.Errorf("unsupported audit log format: %s", format)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to encode audit log: %w", err)
+	}
+
+	exportDir, err := ExportOptions{Destination: destination}.exportDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(exportDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	name := fmt.Sprintf("audit-log-%s.%s", time.Now().Format("20060102-150405"), format)
+	logPath := filestoreutils.CreateUniqueFilename(exportDir, name)
+	if err := os.WriteFile(logPath, data.Bytes(), 0644); err != nil {
+		return "", fmt.Errorf("failed to write audit log: %w", err)
+	}
+
+	head := auditutils.Verify(entries).Head
+	signature, err := json.MarshalIndent(auditutils.Sign(auditutils.SigningKey(s.dbKey), data.Bytes(), head, len(entries)), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode audit log signature: %w", err)
+	}
+	if err := os.WriteFile(logPath+".sig", signature, 0644); err != nil {
+		os.Remove(logPath)
+		return "", fmt.Errorf("failed to write audit log signature: %w", err)
+	}
+
+	// The log names files and folders, so it is wiped along with other exports
+	if err := s.recordExport(logPath, "", nil); err != nil {
+		fmt.Printf("Warning: Failed to record export of '%s': %v\n", logPath, err)
+	}
+
+	fmt.Printf("Exported audit log with %d entries to %s\n", len(entries), logPath)
+	return logPath, nil
+}
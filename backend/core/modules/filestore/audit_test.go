@@ -0,0 +1,154 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/auditutils"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func auditEvents(t *testing.T, s *service, fileID int64) []string {
+	t.Helper()
+
+	entries, err := s.GetAuditLog(fileID)
+	if err != nil {
+		t.Fatalf("GetAuditLog failed: %v", err)
+	}
+	var events []string
+	for _, entry := range entries {
+		events = append(events, entry.Event)
+	}
+	return events
+}
+
+func TestAuditLogRecordsMutations(t *testing.T) {
+	s, folderID := setupTestService(t)
+	cases, _ := s.CreateFolder("Cases", 0)
+
+	received, err := s.ReceiveFile(folderID, "statement.txt", "text/plain", bytes.NewReader([]byte("first statement")), "nearby device (session abc)")
+	if err != nil {
+		t.Fatalf("ReceiveFile failed: %v", err)
+	}
+	id := received.ID
+
+	steps := []func() error{
+		func() error { return s.RenameFile(id, "statement-1.txt") },
+		func() error {
+			_, err := s.ViewFile(id)
+			return err
+		},
+		func() error { return s.MoveFiles([]int64{id}, cases.ID) },
+		func() error {
+			_, err := s.ExportFiles([]int64{id}, ExportOptions{Destination: t.TempDir()})
+			return err
+		},
+		func() error { return s.TrashFolders([]int64{cases.ID}) },
+		func() error { return s.RestoreFolders([]int64{cases.ID}) },
+		func() error { return s.DeleteFiles([]int64{id}) },
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("Step %d failed: %v", i, err)
+		}
+	}
+
+	want := "received renamed viewed moved exported trashed restored deleted"
+	if got := strings.Join(auditEvents(t, s, id), " "); got != want {
+		t.Errorf("Expected events %q, got %q", want, got)
+	}
+
+	entries, _ := s.GetAuditLog(id)
+	first := entries[0]
+	if first.Actor != "nearby device (session abc)" || first.FolderID != folderID || first.ContentHash == "" {
+		t.Errorf("Unexpected received entry: %+v", first)
+	}
+	if entries[3].FolderID != cases.ID || entries[3].Details != fmt.Sprintf("from folder %d", folderID) {
+		t.Errorf("Unexpected moved entry: %+v", entries[3])
+	}
+
+	result, err := s.VerifyAuditLog()
+	if err != nil {
+		t.Fatalf("VerifyAuditLog failed: %v", err)
+	}
+	// The file's eight events, plus the folder being created, trashed and restored
+	if !result.Valid || result.Entries != 11 {
+		t.Errorf("Expected an intact chain, got %+v", result)
+	}
+}
+
+func TestAuditLogDetectsTampering(t *testing.T) {
+	s, folderID := setupTestService(t)
+	storeRandomFiles(t, s, folderID, []int{100, 200, 300})
+
+	if _, err := s.db.Exec("UPDATE audit_log SET actor = 'someone else' WHERE id = 2"); err == nil {
+		t.Fatal("Expected the audit log to reject updates")
+	}
+	if _, err := s.db.Exec("DELETE FROM audit_log WHERE id = 2"); err == nil {
+		t.Fatal("Expected the audit log to reject deletes")
+	}
+
+	// Someone with the database key could still drop the triggers
+	s.db.Exec("DROP TRIGGER audit_log_no_update")
+	s.db.Exec("DROP TRIGGER audit_log_no_delete")
+
+	s.db.Exec("UPDATE audit_log SET actor = 'someone else' WHERE id = 2")
+	result, _ := s.VerifyAuditLog()
+	if result.Valid || len(result.Errors) != 1 || result.Errors[0] != "entry 2 does not match its hash" {
+		t.Errorf("Expected the changed entry to be reported, got %+v", result)
+	}
+
+	s.db.Exec("DELETE FROM audit_log WHERE id = 2")
+	result, _ = s.VerifyAuditLog()
+	if result.Valid || !strings.Contains(strings.Join(result.Errors, "\n"), "entry 3 does not link to the entry before it") {
+		t.Errorf("Expected the removed entry to be reported, got %+v", result)
+	}
+}
+
+func TestExportAuditLog(t *testing.T) {
+	s, folderID := setupTestService(t)
+	storeRandomFiles(t, s, folderID, []int{100, 200})
+	destination := t.TempDir()
+
+	if _, err := s.ExportAuditLog("xml", destination); err == nil {
+		t.Error("Expected an unsupported format to be rejected")
+	}
+
+	for _, format := range []string{AuditFormatJSON, AuditFormatCSV} {
+		path, err := s.ExportAuditLog(format, destination)
+		if err != nil {
+			t.Fatalf("ExportAuditLog (%s) failed: %v", format, err)
+		}
+
+		data, _ := os.ReadFile(path)
+		signatureData, _ := os.ReadFile(path + ".sig")
+		var signature auditutils.Signature
+		if err := json.Unmarshal(signatureData, &signature); err != nil {
+			t.Fatalf("Failed to read signature: %v", err)
+		}
+		if !auditutils.VerifySignature(signature, data) || signature.Entries != 2 {
+			t.Errorf("Expected a valid signature over 2 entries, got %+v", signature)
+		}
+		if auditutils.VerifySignature(signature, append(data, '\n')) {
+			t.Error("Expected the signature to reject a changed log")
+		}
+
+		if format == AuditFormatJSON {
+			var entries []auditutils.Entry
+			json.Unmarshal(data, &entries)
+			if result := auditutils.Verify(entries); !result.Valid || result.Head != signature.Head {
+				t.Errorf("Expected the exported chain to verify up to the signed head, got %+v", result)
+			}
+		} else if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 3 {
+			t.Errorf("Expected a header and 2 rows, got %d lines", len(lines))
+		}
+	}
+
+	// Exported logs are wiped like any other export
+	exports, _ := s.GetExports()
+	if len(exports) != 2 {
+		t.Errorf("Expected both logs to be recorded as exports, got %d", len(exports))
+	}
+}
@@ -0,0 +1,181 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ExportBag exports folders, or files selected in a single folder, as one
+// BagIt bag. Files of a single folder go directly into data/, several
+// folders each get a directory under data/.
+func (s *service) ExportBag(folderIDs []int64, selectedFileIDs []int64, options ExportOptions, bag BagOptions) (*ExportReport, error) {
+	return s.exportBag(s.ctx, nil, folderIDs, selectedFileIDs, options, bag)
+}
+
+// StartExportBag runs ExportBag as a background job and returns its ID
+func (s *service) StartExportBag(folderIDs []int64, selectedFileIDs []int64, options ExportOptions, bag BagOptions) (string, error) {
+	if len(folderIDs) == 0 {
+		return "", fmt.Errorf("no folder IDs provided")
+	}
+	if _, err := options.exportDir(); err != nil {
+		return "", err
+	}
+
+	return s.startJob(JobExportBag, func(ctx context.Context, j *job) (interface{}, error) {
+		return s.exportBag(ctx, j, folderIDs, selectedFileIDs, options, bag)
+	}), nil
+}
+
+func (s *service) exportBag(ctx context.Context, j *job, folderIDs []int64, selectedFileIDs []int64, options ExportOptions, bag BagOptions) (*ExportReport, error) {
+	if len(folderIDs) == 0 {
+		return nil, fmt.Errorf("no folder IDs provided")
+	}
+
+	exportDir, err := options.exportDir()
+	if err != nil {
+		return nil, err
+	}
+
+	exports, sizes := s.folderExports(j, folderIDs, selectedFileIDs)
+	if len(exports) == 0 {
+		return nil, fmt.Errorf("no files to export")
+	}
+	if !options.NeutralNames {
+		if err := s.attachExportMetadata(exports); err != nil {
+			return nil, err
+		}
+	}
+
+	var totalBytes int64
+	for _, size := range sizes {
+		totalBytes += size
+	}
+	j.setTotal(len(sizes), totalBytes)
+
+	files, folderNotes, info := bagContents(exports, options.NeutralNames)
+	info = append(bag.infoFields(), info...)
+
+	bagName := "bag"
+	if len(exports) == 1 {
+		bagName = exports[0].folder.Name
+	}
+	if options.NeutralNames {
+		bagName = "export"
+	}
+
+	added := func(entry filestoreutils.ExportEntry) {
+		j.advance(1, sizes[entry.FileID])
+	}
+	bagPath, entries, err := filestoreutils.CreateBag(ctx, s.OpenFile, bagName, files, folderNotes, info, exportDir, bag.Zip, options.scrubOptions(), added)
+	if err != nil {
+		if ctx.Err() != nil {
+			return &ExportReport{}, ctx.Err()
+		}
+		return nil, fmt.Errorf("failed to create bag: %w", err)
+	}
+
+	if err := s.recordExport(bagPath, "", entries); err != nil {
+		fmt.Printf("Warning: Failed to record export of '%s': %v\n", bagPath, err)
+	}
+
+	report := &ExportReport{Paths: []string{bagPath}}
+	for _, entry := range entries {
+		report.Files = append(report.Files, exportedFile(entry))
+	}
+	fmt.Printf("Bag created successfully: %s (%d files)\n", bagPath, len(entries))
+	return report, nil
+}
+
+// bagContents places each file in the bag's payload, collects folder notes
+// by payload directory and describes the folders in bag-info.txt. Neutral
+// names hide folder and file names. Names are reduced to a single path
+// component, as received names can contain separators or "..".
+func bagContents(exports []folderExport, neutral bool) ([]filestoreutils.BagFile, map[string][]filestoreutils.ExportNote, []filestoreutils.BagInfoField) {
+	var files []filestoreutils.BagFile
+	var info []filestoreutils.BagInfoField
+	folderNotes := make(map[string][]filestoreutils.ExportNote)
+	taken := make(map[string]bool)
+
+	count := 0
+	for i, export := range exports {
+		dir := ""
+		if len(exports) > 1 {
+			dir = filestoreutils.SafePathComponent(export.folder.Name)
+			if neutral || dir == "" {
+				dir = fmt.Sprintf("folder-%02d", i+1)
+			}
+		}
+
+		if !neutral {
+			// Folders received from a nearby device are named after the transfer
+			// and created when it was accepted
+			info = append(info, filestoreutils.BagInfoField{
+				Label: "Tella-Folder",
+				Value: fmt.Sprintf("%s (created %s)", export.folder.Name, export.folder.Timestamp),
+			})
+			if len(export.meta.Tags) > 0 {
+				info = append(info, filestoreutils.BagInfoField{
+					Label: "Tella-Folder-Tags",
+					Value: fmt.Sprintf("%s: %s", export.folder.Name, strings.Join(export.meta.Tags, ", ")),
+				})
+			}
+			if len(export.meta.Notes) > 0 {
+				folderNotes[dir] = export.meta.Notes
+			}
+		}
+
+		for _, file := range export.files {
+			name := filestoreutils.SafePathComponent(file.Name)
+			if neutral || name == "" {
+				count++
+				name = fmt.Sprintf("file-%04d", count)
+			}
+			name = uniqueBagName(taken, path.Join(dir, filestoreutils.EnsureFileExtension(name, file.MimeType)))
+			files = append(files, filestoreutils.BagFile{FileInfo: file, Path: name})
+		}
+	}
+
+	return files, folderNotes, info
+}
+
+// uniqueBagName numbers a payload path that is already taken, e.g. when two
+// files only differed in the extension added for their type
+func uniqueBagName(taken map[string]bool, name string) string {
+	unique := name
+	ext := path.Ext(name)
+	for i := 1; taken[unique]; i++ {
+		unique = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), i, ext)
+	}
+	taken[unique] = true
+	return unique
+}
+
+func (bag BagOptions) infoFields() []filestoreutils.BagInfoField {
+	return []filestoreutils.BagInfoField{
+		{Label: "Source-Organization", Value: bag.SourceOrganization},
+		{Label: "Contact-Name", Value: bag.ContactName},
+		{Label: "Contact-Email", Value: bag.ContactEmail},
+		{Label: "External-Identifier", Value: bag.ExternalIdentifier},
+		{Label: "External-Description", Value: bag.ExternalDescription},
+	}
+}
+
+// ValidateBag checks a bag directory or ZIP, for instance one exported earlier
+func (s *service) ValidateBag(bagPath string) (*BagValidation, error) {
+	result, err := filestoreutils.ValidateBag(bagPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BagValidation{
+		Valid:      result.Valid,
+		Version:    result.Version,
+		Files:      result.Files,
+		Bytes:      result.Bytes,
+		Algorithms: result.Algorithms,
+		Errors:     result.Errors,
+	}, nil
+}
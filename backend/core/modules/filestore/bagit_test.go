@@ -0,0 +1,94 @@
+package filestore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportBag(t *testing.T) {
+	s, folderID := setupTestService(t)
+	other, _ := s.CreateFolder("Interviews", 0)
+	storeRandomFiles(t, s, folderID, []int{100, 200})
+	storeRandomFiles(t, s, other.ID, []int{300})
+	destination := t.TempDir()
+
+	report, err := s.ExportBag([]int64{folderID, other.ID}, nil, ExportOptions{Destination: destination}, BagOptions{SourceOrganization: "Example Collective"})
+	if err != nil {
+		t.Fatalf("ExportBag failed: %v", err)
+	}
+	bagPath := report.Paths[0]
+	if len(report.Files) != 3 || report.Files[2].ExportedName != "data/Interviews/TestExportBaga.file" {
+		t.Errorf("Unexpected export report: %+v", report.Files)
+	}
+
+	result, err := s.ValidateBag(bagPath)
+	if err != nil {
+		t.Fatalf("ValidateBag failed: %v", err)
+	}
+	if !result.Valid || result.Files != 3 || result.Bytes != 600 {
+		t.Errorf("Expected a valid bag, got %+v", result)
+	}
+
+	bagInfo, _ := os.ReadFile(filepath.Join(bagPath, "bag-info.txt"))
+	for _, want := range []string{"Source-Organization: Example Collective", "Tella-Folder: Interviews", "Payload-Oxum: 600.3"} {
+		if !strings.Contains(string(bagInfo), want) {
+			t.Errorf("Expected bag-info.txt to contain %q:\n%s", want, bagInfo)
+		}
+	}
+
+	zipReport, err := s.ExportBag([]int64{other.ID}, nil, ExportOptions{Destination: destination, NeutralNames: true}, BagOptions{Zip: true})
+	if err != nil {
+		t.Fatalf("ExportBag as ZIP failed: %v", err)
+	}
+	if filepath.Base(zipReport.Paths[0]) != "export.zip" || zipReport.Files[0].ExportedName != "data/file-0001.file" {
+		t.Errorf("Expected neutral names in the ZIP bag, got %s %+v", zipReport.Paths[0], zipReport.Files)
+	}
+	if result, _ := s.ValidateBag(zipReport.Paths[0]); !result.Valid {
+		t.Errorf("Expected the ZIP bag to be valid: %v", result.Errors)
+	}
+
+	// Both bags are recorded and wiped like any other export
+	results, err := s.WipeExportedCopies(nil)
+	if err != nil {
+		t.Fatalf("WipeExportedCopies failed: %v", err)
+	}
+	for _, result := range results {
+		if result.Status != ExportWiped {
+			t.Errorf("Export %s: got status %s (%s)", result.Path, result.Status, result.Error)
+		}
+	}
+	if left, _ := os.ReadDir(destination); len(left) != 0 {
+		t.Errorf("Expected the bags to be wiped, found %d entries", len(left))
+	}
+}
+
+func TestExportBagKeepsPathsInside(t *testing.T) {
+	s, folderID := setupTestService(t)
+	other, _ := s.CreateFolder("Interviews", 0)
+	ids, _ := storeRandomFiles(t, s, folderID, []int{100})
+	storeRandomFiles(t, s, other.ID, []int{200})
+
+	// Names stored before they were validated, or received from another device
+	s.db.Exec("UPDATE files SET name = '../../escape.txt' WHERE id = ?", ids[0])
+	s.db.Exec("UPDATE folders SET name = '../up' WHERE id = ?", folderID)
+
+	parent := t.TempDir()
+	destination := filepath.Join(parent, "exports")
+	os.Mkdir(destination, 0755)
+
+	report, err := s.ExportBag([]int64{folderID, other.ID}, nil, ExportOptions{Destination: destination}, BagOptions{})
+	if err != nil {
+		t.Fatalf("ExportBag failed: %v", err)
+	}
+	if report.Files[0].ExportedName != "data/up/escape.txt" {
+		t.Errorf("Expected the names to be reduced to one component, got %+v", report.Files)
+	}
+	if result, _ := s.ValidateBag(report.Paths[0]); !result.Valid {
+		t.Errorf("Expected the bag to be valid: %v", result.Errors)
+	}
+	if left, _ := os.ReadDir(parent); len(left) != 1 {
+		t.Errorf("Expected nothing to be written outside the destination, found %d entries", len(left))
+	}
+}
@@ -0,0 +1,505 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+)
+
+// Compaction states stored in compaction_state.status
+const (
+	CompactionIdle    = "idle"
+	CompactionRunning = "running"
+	CompactionPaused  = "paused"
+)
+
+// extentMove describes one relocation planned by compaction
+type extentMove struct {
+	kind   string
+	src    int64
+	length int64
+	dst    int64
+	toTail bool
+}
+
+func (s *service) StartCompaction() error {
+	s.compactMu.Lock()
+	defer s.compactMu.Unlock()
+
+	if s.compactDone != nil {
+		select {
+		case <-s.compactDone:
+			// The previous run already finished on its own
+		default:
+			return fmt.Errorf("compaction is already running")
+		}
+	}
+
+	if err := s.beginCompaction(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	done := make(chan struct{})
+	s.compactCancel = cancel
+	s.compactDone = done
+
+	go func() {
+		defer close(done)
+		s.runCompaction(ctx)
+	}()
+
+	return nil
+}
+
+func (s *service) PauseCompaction() error {
+	s.compactMu.Lock()
+	defer s.compactMu.Unlock()
+
+	if s.compactDone == nil {
+		return nil
+	}
+
+	s.compactCancel()
+	<-s.compactDone
+	s.compactCancel = nil
+	s.compactDone = nil
+
+	return nil
+}
+
+func (s *service) GetCompactionStatus() (*CompactionStatus, error) {
+	var status CompactionStatus
+	var initialFree int64
+	err := s.db.QueryRow(`
+		SELECT status, moved_extents, moved_bytes, initial_free_bytes
+		FROM compaction_state WHERE id = 1
+	`).Scan(&status.State, &status.MovedExtents, &status.MovedBytes, &initialFree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compaction state: %w", err)
+	}
+
+	if err := s.db.QueryRow("SELECT COALESCE(SUM(length), 0) FROM free_spaces").Scan(&status.FreeBytes); err != nil {
+		return nil, fmt.Errorf("failed to sum free space: %w", err)
+	}
+
+	if info, err := os.Stat(s.tvaultPath); err == nil {
+		status.VaultSize = info.Size()
+	}
+
+	switch {
+	case status.State == CompactionIdle:
+		status.Progress = 1
+	case initialFree > 0:
+		status.Progress = 1 - float64(status.FreeBytes)/float64(initialFree)
+		if status.Progress < 0 {
+			status.Progress = 0
+		}
+	}
+
+	return &status, nil
+}
+
+// beginCompaction recovers any interrupted step, rebuilds the free list and marks the job running
+func (s *service) beginCompaction() error {
+	if err := s.recoverCompaction(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := filestoreutils.RebuildFreeSpace(tx); err != nil {
+		return fmt.Errorf("failed to rebuild free space: %w", err)
+	}
+
+	var state string
+	if err := tx.QueryRow("SELECT status FROM compaction_state WHERE id = 1").Scan(&state); err != nil {
+		return fmt.Errorf("failed to read compaction state: %w", err)
+	}
+
+	if state == CompactionIdle {
+		// A fresh run: reset counters and remember how much there is to reclaim
+		_, err = tx.Exec(`
+			UPDATE compaction_state SET
+				status = ?, moved_extents = 0, moved_bytes = 0,
+				initial_free_bytes = (SELECT COALESCE(SUM(length), 0) FROM free_spaces),
+				updated_at = datetime('now')
+			WHERE id = 1
+		`, CompactionRunning)
+	} else {
+		_, err = tx.Exec(`
+			UPDATE compaction_state SET status = ?, updated_at = datetime('now') WHERE id = 1
+		`, CompactionRunning)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update compaction state: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit compaction start: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) runCompaction(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			s.finishCompaction(CompactionPaused, nil)
+			return
+		}
+
+		moved, err := s.compactStep()
+		if err != nil {
+			fmt.Printf("Compaction stopped: %v\n", err)
+			s.finishCompaction(CompactionPaused, err)
+			return
+		}
+		if !moved {
+			s.mu.Lock()
+			err := s.trimVault()
+			s.mu.Unlock()
+			s.finishCompaction(CompactionIdle, err)
+			return
+		}
+
+		s.emitCompactionProgress(nil)
+	}
+}
+
+func (s *service) finishCompaction(state string, cause error) {
+	_, err := s.db.Exec(`
+		UPDATE compaction_state SET status = ?, updated_at = datetime('now') WHERE id = 1
+	`, state)
+	if err != nil {
+		fmt.Printf("Failed to record compaction state: %v\n", err)
+	}
+	s.emitCompactionProgress(cause)
+}
+
+func (s *service) emitCompactionProgress(cause error) {
+	status, err := s.GetCompactionStatus()
+	if err != nil {
+		return
+	}
+
+	data := map[string]interface{}{
+		"state":        status.State,
+		"movedExtents": status.MovedExtents,
+		"movedBytes":   status.MovedBytes,
+		"freeBytes":    status.FreeBytes,
+		"vaultSize":    status.VaultSize,
+		"progress":     status.Progress,
+	}
+	if cause != nil {
+		data["error"] = cause.Error()
+	}
+	s.emit("compaction-progress", data)
+}
+
+// compactStep relocates one extent. It returns false once nothing can be moved.
+func (s *service) compactStep() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	move, err := s.planMove(s.pinnedExtents())
+	if err != nil || move == nil {
+		return false, err
+	}
+
+	// Readers may have opened the extent since planning; try again next step
+	if !s.pinForMove(move.src) {
+		return true, nil
+	}
+	defer s.unpinMove()
+
+	return true, s.moveExtent(move)
+}
+
+// planMove picks the next relocation. For the first gap in the TVault it
+// prefers moving the last extent that fits into it; otherwise the extent right
+// after the gap is parked at the end so the gap grows enough to take it back.
+func (s *service) planMove(pinned map[int64]bool) (*extentMove, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	end, err := filestoreutils.VaultEnd(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query("SELECT offset, length FROM free_spaces ORDER BY offset ASC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query free regions: %w", err)
+	}
+	var regions []filestoreutils.FreeRegion
+	for rows.Next() {
+		var region filestoreutils.FreeRegion
+		if err := rows.Scan(&region.Offset, &region.Length); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan free region: %w", err)
+		}
+		regions = append(regions, region)
+	}
+	rows.Close()
+
+	for _, region := range regions {
+		if region.End() >= end {
+			break
+		}
+
+		candidates, err := tx.Query(`
+			SELECT DISTINCT kind, offset, length FROM vault_extents
+			WHERE offset > ? AND length <= ?
+			ORDER BY offset DESC
+		`, region.Offset, region.Length)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query extents: %w", err)
+		}
+		var fill *extentMove
+		for candidates.Next() {
+			var m extentMove
+			if err := candidates.Scan(&m.kind, &m.src, &m.length); err != nil {
+				candidates.Close()
+				return nil, fmt.Errorf("failed to scan extent: %w", err)
+			}
+			if !pinned[m.src] {
+				m.dst = region.Offset
+				fill = &m
+				break
+			}
+		}
+		candidates.Close()
+		if fill != nil {
+			return fill, nil
+		}
+
+		var next extentMove
+		err = tx.QueryRow(`
+			SELECT kind, offset, length FROM vault_extents WHERE offset = ? LIMIT 1
+		`, region.End()).Scan(&next.kind, &next.src, &next.length)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to query extent: %w", err)
+		}
+		// Nothing can be parked at the end while a streamed store writes there
+		if err == nil && !pinned[next.src] && s.tailReserved == 0 {
+			next.toTail = true
+			return &next, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// moveExtent copies an extent to its destination and switches its owners over in one transaction
+func (s *service) moveExtent(move *extentMove) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if move.toTail {
+		move.dst, err = filestoreutils.AllocateTail(tx)
+	} else {
+		err = filestoreutils.AllocateAt(tx, move.dst, move.length)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to reserve destination: %w", err)
+	}
+
+	// Journal the reserved destination so a crash mid-copy can be rolled back
+	_, err = tx.Exec(`
+		UPDATE compaction_state SET pending_offset = ?, pending_length = ?, updated_at = datetime('now')
+		WHERE id = 1
+	`, move.dst, move.length)
+	if err != nil {
+		return fmt.Errorf("failed to journal move: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit move reservation: %w", err)
+	}
+
+	tvault, err := os.OpenFile(s.tvaultPath, os.O_RDWR, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open TVault: %w", err)
+	}
+	defer tvault.Close()
+
+	if err := filestoreutils.CopyVaultData(tvault, move.src, move.dst, move.length); err != nil {
+		s.rollbackPendingMove()
+		return err
+	}
+
+	tx, err = s.db.Begin()
+	if err != nil {
+		s.rollbackPendingMove()
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := updateExtentOffset(tx, move.kind, move.src, move.dst); err != nil {
+		s.rollbackPendingMove()
+		return err
+	}
+	if err := filestoreutils.AddFreeSpace(tx, move.src, move.length); err != nil {
+		s.rollbackPendingMove()
+		return err
+	}
+	_, err = tx.Exec(`
+		UPDATE compaction_state SET
+			pending_offset = NULL, pending_length = NULL,
+			wipe_offset = ?, wipe_length = ?,
+			moved_extents = moved_extents + 1, moved_bytes = moved_bytes + ?,
+			updated_at = datetime('now')
+		WHERE id = 1
+	`, move.src, move.length, move.length)
+	if err != nil {
+		s.rollbackPendingMove()
+		return fmt.Errorf("failed to journal move: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		s.rollbackPendingMove()
+		return fmt.Errorf("failed to commit move: %w", err)
+	}
+
+	return s.wipeVacated()
+}
+
+// updateExtentOffset points every owner of an extent at its new location
+func updateExtentOffset(tx *sql.Tx, kind string, src, dst int64) error {
+	var err error
+	switch kind {
+	case "file":
+		_, err = tx.Exec("UPDATE files SET offset = ? WHERE offset = ? AND is_deleted = 0", dst, src)
+	case "thumbnail":
+		_, err = tx.Exec("UPDATE thumbnails SET offset = ? WHERE offset = ?", dst, src)
+	default:
+		return fmt.Errorf("unknown extent kind: %s", kind)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update %s offset: %w", kind, err)
+	}
+	return nil
+}
+
+// wipeVacated overwrites the region a committed move left behind and clears it from the journal
+func (s *service) wipeVacated() error {
+	var offset, length sql.NullInt64
+	err := s.db.QueryRow("SELECT wipe_offset, wipe_length FROM compaction_state WHERE id = 1").Scan(&offset, &length)
+	if err != nil {
+		return fmt.Errorf("failed to read compaction journal: %w", err)
+	}
+	if !offset.Valid {
+		return nil
+	}
+
+	if err := filestoreutils.SecurelyOverwriteFileData(s.tvaultPath, offset.Int64, length.Int64); err != nil {
+		return fmt.Errorf("failed to wipe vacated region: %w", err)
+	}
+
+	_, err = s.db.Exec("UPDATE compaction_state SET wipe_offset = NULL, wipe_length = NULL WHERE id = 1")
+	if err != nil {
+		return fmt.Errorf("failed to clear compaction journal: %w", err)
+	}
+	return nil
+}
+
+// rollbackPendingMove wipes and frees a destination whose move never committed
+func (s *service) rollbackPendingMove() error {
+	var offset, length sql.NullInt64
+	err := s.db.QueryRow("SELECT pending_offset, pending_length FROM compaction_state WHERE id = 1").Scan(&offset, &length)
+	if err != nil {
+		return fmt.Errorf("failed to read compaction journal: %w", err)
+	}
+	if !offset.Valid {
+		return nil
+	}
+
+	if err := filestoreutils.SecurelyOverwriteFileData(s.tvaultPath, offset.Int64, length.Int64); err != nil {
+		fmt.Printf("Warning: Failed to wipe abandoned compaction copy: %v\n", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := filestoreutils.AddFreeSpace(tx, offset.Int64, length.Int64); err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE compaction_state SET pending_offset = NULL, pending_length = NULL WHERE id = 1")
+	if err != nil {
+		return fmt.Errorf("failed to clear compaction journal: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// recoverCompaction completes whatever an interrupted compaction step left in its journal
+func (s *service) recoverCompaction() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.rollbackPendingMove(); err != nil {
+		return err
+	}
+	if err := s.wipeVacated(); err != nil {
+		return err
+	}
+
+	// A job still marked running was interrupted, it can be resumed later
+	_, err := s.db.Exec(`
+		UPDATE compaction_state SET status = ? WHERE id = 1 AND status = ?
+	`, CompactionPaused, CompactionRunning)
+	if err != nil {
+		return fmt.Errorf("failed to update compaction state: %w", err)
+	}
+
+	return nil
+}
+
+// pinnedExtents returns a snapshot of extents that currently have open readers
+func (s *service) pinnedExtents() map[int64]bool {
+	s.openMu.Lock()
+	defer s.openMu.Unlock()
+
+	pinned := make(map[int64]bool, len(s.openExtents))
+	for offset := range s.openExtents {
+		pinned[offset] = true
+	}
+	return pinned
+}
+
+// pinForMove blocks new readers of an extent while it is relocated
+func (s *service) pinForMove(offset int64) bool {
+	s.openMu.Lock()
+	defer s.openMu.Unlock()
+
+	if s.openExtents[offset] > 0 {
+		return false
+	}
+	s.movingExtent = offset
+	return true
+}
+
+func (s *service) unpinMove() {
+	s.openMu.Lock()
+	defer s.openMu.Unlock()
+
+	s.movingExtent = 0
+	s.openCond.Broadcast()
+}
@@ -0,0 +1,291 @@
+package filestore
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"Tella-Desktop/backend/core/database"
+	"Tella-Desktop/backend/utils/constants"
+	"Tella-Desktop/backend/utils/filestoreutils"
+)
+
+// setupTestService creates a filestore service backed by a temporary TVault and database
+func setupTestService(t *testing.T) (*service, int64) {
+	t.Helper()
+
+	dir := t.TempDir()
+	dbKey := make([]byte, constants.KeyLength)
+	if _, err := rand.Read(dbKey); err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	db, err := database.Initialize(filepath.Join(dir, ".tella.db"), dbKey)
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	tvaultPath := filepath.Join(dir, ".tvault")
+	if err := os.WriteFile(tvaultPath, make([]byte, constants.TVaultHeaderSize), 0600); err != nil {
+		t.Fatalf("Failed to create TVault: %v", err)
+	}
+
+	result, err := db.Exec("INSERT INTO folders (name) VALUES ('Received Files')")
+	if err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	folderID, _ := result.LastInsertId()
+
+	s := &service{
+		ctx:          context.Background(),
+		db:           db.DB,
+		tvaultPath:   tvaultPath,
+		dbKey:        dbKey,
+		emit:         func(string, ...interface{}) {},
+		openExtents:  make(map[int64]int),
+		tempDir:      t.TempDir(),
+		openExternal: func(string) error { return nil },
+		tempTimers:   make(map[int64]*time.Timer),
+	}
+	s.openCond = sync.NewCond(&s.openMu)
+
+	return s, folderID
+}
+
+// storeRandomFiles stores files of the given sizes and returns their IDs and contents
+func storeRandomFiles(t *testing.T, s *service, folderID int64, sizes []int) ([]int64, map[int64][]byte) {
+	t.Helper()
+
+	var ids []int64
+	contents := make(map[int64][]byte)
+	for i, size := range sizes {
+		data := make([]byte, size)
+		rand.Read(data)
+		metadata, err := s.StoreFile(folderID, filepath.Base(t.Name())+string(rune('a'+i)), "application/octet-stream", bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Failed to store file: %v", err)
+		}
+		ids = append(ids, metadata.ID)
+		contents[metadata.ID] = data
+	}
+	return ids, contents
+}
+
+func checkFileContents(t *testing.T, s *service, contents map[int64][]byte) {
+	t.Helper()
+
+	for id, want := range contents {
+		reader, err := s.OpenFile(id)
+		if err != nil {
+			t.Fatalf("Failed to open file %d: %v", id, err)
+		}
+		got, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatalf("Failed to read file %d: %v", id, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("File %d content changed", id)
+		}
+	}
+}
+
+func runCompactionToEnd(t *testing.T, s *service) {
+	t.Helper()
+
+	if err := s.StartCompaction(); err != nil {
+		t.Fatalf("Failed to start compaction: %v", err)
+	}
+	<-s.compactDone
+}
+
+func TestCompactionRemovesHoles(t *testing.T) {
+	s, folderID := setupTestService(t)
+
+	ids, contents := storeRandomFiles(t, s, folderID, []int{5000, 100, 70000, 3000, 200000, 10, 4000})
+
+	// Delete every other file to leave holes of different sizes
+	var deleted []int64
+	for i, id := range ids {
+		if i%2 == 0 && i != len(ids)-1 {
+			deleted = append(deleted, id)
+			delete(contents, id)
+		}
+	}
+	if err := s.DeleteFiles(deleted); err != nil {
+		t.Fatalf("Failed to delete files: %v", err)
+	}
+
+	runCompactionToEnd(t, s)
+
+	status, err := s.GetCompactionStatus()
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	if status.State != CompactionIdle || status.FreeBytes != 0 || status.MovedExtents == 0 {
+		t.Errorf("Unexpected status after compaction: %+v", status)
+	}
+
+	var live int64
+	s.db.QueryRow("SELECT SUM(length) FROM files WHERE is_deleted = 0").Scan(&live)
+	if status.VaultSize != constants.TVaultHeaderSize+live {
+		t.Errorf("TVault size = %d, want %d", status.VaultSize, constants.TVaultHeaderSize+live)
+	}
+
+	checkFileContents(t, s, contents)
+}
+
+func TestCompactionSkipsOpenFiles(t *testing.T) {
+	s, folderID := setupTestService(t)
+
+	ids, contents := storeRandomFiles(t, s, folderID, []int{1000, 2000, 3000})
+	if err := s.DeleteFiles([]int64{ids[0]}); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+	delete(contents, ids[0])
+
+	reader, err := s.OpenFile(ids[2])
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	before, _ := filestoreutils.GetFileMetadataByID(s.db, ids[2])
+
+	runCompactionToEnd(t, s)
+
+	after, _ := filestoreutils.GetFileMetadataByID(s.db, ids[2])
+	if before.Offset != after.Offset {
+		t.Errorf("Open file was moved from %d to %d", before.Offset, after.Offset)
+	}
+
+	got, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil || !bytes.Equal(got, contents[ids[2]]) {
+		t.Errorf("Open reader broken by compaction: %v", err)
+	}
+
+	checkFileContents(t, s, contents)
+}
+
+func TestStreamedStoreReleasesVault(t *testing.T) {
+	s, folderID := setupTestService(t)
+	ids, contents := storeRandomFiles(t, s, folderID, []int{1000, 1000})
+
+	// A large upload sends its first part, then the sender stalls
+	upload := make([]byte, storeSpoolLimit+4096)
+	rand.Read(upload)
+	reader, writer := io.Pipe()
+	received := make(chan *FileMetadata, 1)
+	go func() {
+		metadata, err := s.ReceiveFile(folderID, "recording.bin", "application/octet-stream", reader, "phone")
+		if err != nil {
+			t.Errorf("ReceiveFile failed: %v", err)
+		}
+		received <- metadata
+	}()
+	writer.Write(upload[:storeSpoolLimit+1024])
+
+	// Deleting and storing into free space go ahead meanwhile
+	finished := make(chan error, 1)
+	go func() { finished <- s.DeleteFiles(ids[:1]) }()
+	select {
+	case err := <-finished:
+		if err != nil {
+			t.Fatalf("DeleteFiles failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("DeleteFiles waited for the stalled upload")
+	}
+	delete(contents, ids[0])
+	small, smallContents := storeRandomFiles(t, s, folderID, []int{500})
+	contents[small[0]] = smallContents[small[0]]
+
+	// A store that needs the end of the TVault waits for the upload instead of overlapping it
+	large := make([]byte, 100*1024)
+	rand.Read(large)
+	stored := make(chan *FileMetadata, 1)
+	go func() {
+		metadata, err := s.StoreFile(folderID, "large.bin", "application/octet-stream", bytes.NewReader(large))
+		if err != nil {
+			t.Errorf("StoreFile failed: %v", err)
+		}
+		stored <- metadata
+	}()
+	select {
+	case <-stored:
+		t.Fatal("Expected the store at the end of the TVault to wait for the upload")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	writer.Write(upload[storeSpoolLimit+1024:])
+	writer.Close()
+	if metadata := <-received; metadata != nil {
+		contents[metadata.ID] = upload
+	}
+	if metadata := <-stored; metadata != nil {
+		contents[metadata.ID] = large
+	}
+	checkFileContents(t, s, contents)
+
+	// A failed upload gives the end of the TVault back
+	reader, writer = io.Pipe()
+	go func() {
+		writer.Write(upload)
+		writer.CloseWithError(io.ErrUnexpectedEOF)
+	}()
+	if _, err := s.ReceiveFile(folderID, "broken.bin", "application/octet-stream", reader, "phone"); err == nil {
+		t.Fatal("Expected the interrupted upload to fail")
+	}
+	tx, _ := s.db.Begin()
+	end, _ := filestoreutils.VaultEnd(tx)
+	tx.Rollback()
+	if info, _ := os.Stat(s.tvaultPath); info.Size() != end || s.tailReserved != 0 {
+		t.Errorf("Expected the TVault to end at %d with no reservation, got %d bytes (reserved %d)", end, info.Size(), s.tailReserved)
+	}
+	checkFileContents(t, s, contents)
+}
+
+func TestCompactionRecoversInterruptedMove(t *testing.T) {
+	s, folderID := setupTestService(t)
+
+	ids, contents := storeRandomFiles(t, s, folderID, []int{4000, 1000})
+	if err := s.DeleteFiles([]int64{ids[0]}); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+	delete(contents, ids[0])
+
+	// Simulate a crash after the destination was reserved but before the move committed
+	tx, _ := s.db.Begin()
+	if err := filestoreutils.AllocateAt(tx, constants.TVaultHeaderSize, 500); err != nil {
+		t.Fatalf("Failed to reserve: %v", err)
+	}
+	tx.Exec("UPDATE compaction_state SET status = 'running', pending_offset = ?, pending_length = 500 WHERE id = 1", constants.TVaultHeaderSize)
+	tx.Commit()
+
+	if err := s.recoverCompaction(); err != nil {
+		t.Fatalf("Failed to recover: %v", err)
+	}
+
+	status, _ := s.GetCompactionStatus()
+	if status.State != CompactionPaused {
+		t.Errorf("Interrupted compaction state = %s, want %s", status.State, CompactionPaused)
+	}
+
+	regions, _ := filestoreutils.GetFreeRegions(s.db)
+	if len(regions) != 1 || regions[0].Offset != constants.TVaultHeaderSize {
+		t.Errorf("Reserved destination was not returned to free space: %v", regions)
+	}
+
+	// Resuming finishes the job
+	runCompactionToEnd(t, s)
+	checkFileContents(t, s, contents)
+	if regions, _ := filestoreutils.GetFreeRegions(s.db); len(regions) != 0 {
+		t.Errorf("Expected no free space after compaction, got %v", regions)
+	}
+}
@@ -0,0 +1,57 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"fmt"
+	"strconv"
+)
+
+const (
+	compressionEnabledSetting = "compression_enabled"
+	compressionPaddingSetting = "compression_padding"
+
+	defaultCompressionPadding = filestoreutils.PaddingPadme
+)
+
+// GetCompressionSettings returns the compression settings. Compression is off
+// until enabled, as compressed sizes can reveal something of the content.
+func (s *service) GetCompressionSettings() (CompressionSettings, error) {
+	enabled, err := s.getIntSetting(compressionEnabledSetting, 0)
+	if err != nil {
+		return CompressionSettings{}, err
+	}
+	padding, err := s.getSetting(compressionPaddingSetting, defaultCompressionPadding)
+	if err != nil {
+		return CompressionSettings{}, err
+	}
+	return CompressionSettings{Enabled: enabled != 0, Padding: padding}, nil
+}
+
+// SetCompressionSettings applies to files stored from now on; stored files keep their format
+func (s *service) SetCompressionSettings(settings CompressionSettings) error {
+	if !filestoreutils.ValidPadding(settings.Padding) {
+		return fmt.Errorf("unknown padding scheme: %s", settings.Padding)
+	}
+
+	enabled := 0
+	if settings.Enabled {
+		enabled = 1
+	}
+	if err := s.setSetting(compressionEnabledSetting, strconv.Itoa(enabled)); err != nil {
+		return err
+	}
+	return s.setSetting(compressionPaddingSetting, settings.Padding)
+}
+
+// compressionFor returns the padding to compress a new file with, or "" to
+// store it uncompressed. head is the start of the file.
+func (s *service) compressionFor(mimeType string, head []byte) (string, error) {
+	settings, err := s.GetCompressionSettings()
+	if err != nil {
+		return "", err
+	}
+	if !settings.Enabled || !filestoreutils.ShouldCompress(mimeType, head) {
+		return "", nil
+	}
+	return settings.Padding, nil
+}
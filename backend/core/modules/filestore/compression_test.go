@@ -0,0 +1,94 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func storedFormat(t *testing.T, s *service, id int64) (string, int64, int64) {
+	t.Helper()
+
+	var compression string
+	var size, length int64
+	if err := s.db.QueryRow("SELECT compression, size, length FROM files WHERE id = ?", id).Scan(&compression, &size, &length); err != nil {
+		t.Fatalf("Failed to read file %d: %v", id, err)
+	}
+	return compression, size, length
+}
+
+func TestStoreFileCompresses(t *testing.T) {
+	s, folderID := setupTestService(t)
+	s.SetCompressionSettings(CompressionSettings{Enabled: true, Padding: filestoreutils.PaddingPadme})
+	contents := make(map[int64][]byte)
+
+	notes := []byte(strings.Repeat("Interview notes, second session. ", 2000))
+	small, err := s.StoreFile(folderID, "notes.txt", "text/plain", bytes.NewReader(notes))
+	if err != nil {
+		t.Fatalf("StoreFile failed: %v", err)
+	}
+	contents[small.ID] = notes
+
+	// Larger than the in-memory limit, so compressed as it streams
+	recording := bytes.Repeat([]byte{0, 1, 2, 3, 2, 1}, storeSpoolLimit/3)
+	large, err := s.StoreFile(folderID, "recording.wav", "audio/wav", bytes.NewReader(recording))
+	if err != nil {
+		t.Fatalf("StoreFile failed: %v", err)
+	}
+	contents[large.ID] = recording
+
+	for id, data := range contents {
+		compression, size, length := storedFormat(t, s, id)
+		if compression != filestoreutils.CompressionDeflate || size != int64(len(data)) || length >= filestoreutils.ChunkedLength(size) {
+			t.Errorf("File %d: expected a compressed extent, got %q with %d bytes for %d", id, compression, length, size)
+		}
+	}
+	checkFileContents(t, s, contents)
+
+	// Duplicates share the compressed extent
+	duplicate, _ := s.StoreFile(folderID, "copy.txt", "text/plain", bytes.NewReader(notes))
+	if compression, _, _ := storedFormat(t, s, duplicate.ID); compression != filestoreutils.CompressionDeflate {
+		t.Errorf("Expected the duplicate to be compressed too, got %q", compression)
+	}
+	checkFileContents(t, s, map[int64][]byte{duplicate.ID: notes})
+
+	// Random data and other types are stored as is
+	ids, random := storeRandomFiles(t, s, folderID, []int{5000})
+	if compression, _, _ := storedFormat(t, s, ids[0]); compression != filestoreutils.CompressionNone {
+		t.Errorf("Expected random data to be stored uncompressed, got %q", compression)
+	}
+	checkFileContents(t, s, random)
+}
+
+func TestCompressionSettings(t *testing.T) {
+	s, folderID := setupTestService(t)
+
+	if settings, _ := s.GetCompressionSettings(); settings.Enabled || settings.Padding != filestoreutils.PaddingPadme {
+		t.Errorf("Unexpected defaults %+v", settings)
+	}
+	if err := s.SetCompressionSettings(CompressionSettings{Enabled: true, Padding: "random"}); err == nil {
+		t.Error("Expected an unknown padding scheme to be rejected")
+	}
+
+	// A fresh vault stores text as is until compression is enabled
+	notes := []byte(strings.Repeat("a", 3000))
+	fresh, _ := s.StoreFile(folderID, "fresh.txt", "text/plain", bytes.NewReader(notes[:2000]))
+	if compression, _, _ := storedFormat(t, s, fresh.ID); compression != filestoreutils.CompressionNone {
+		t.Errorf("Expected text to be stored uncompressed by default, got %q", compression)
+	}
+
+	s.SetCompressionSettings(CompressionSettings{Enabled: true, Padding: filestoreutils.PaddingPowerOfTwo})
+	padded, _ := s.StoreFile(folderID, "padded.txt", "text/plain", bytes.NewReader(notes))
+	_, _, length := storedFormat(t, s, padded.ID)
+	if payload := length - filestoreutils.ChunkedLength(0); payload&(payload-1) != 0 {
+		t.Errorf("Expected the compressed data to be padded to a power of two, got %d bytes", payload)
+	}
+
+	s.SetCompressionSettings(CompressionSettings{Enabled: false, Padding: filestoreutils.PaddingNone})
+	plain, _ := s.StoreFile(folderID, "plain.txt", "text/plain", bytes.NewReader(append(notes, 'b')))
+	if compression, _, _ := storedFormat(t, s, plain.ID); compression != filestoreutils.CompressionNone {
+		t.Errorf("Expected compression to be off, got %q", compression)
+	}
+	checkFileContents(t, s, map[int64][]byte{padded.ID: notes, plain.ID: append(notes, 'b')})
+}
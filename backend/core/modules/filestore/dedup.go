@@ -0,0 +1,71 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/auditutils"
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// findDuplicate returns a live file whose content has the same keyed hash and
+// size, or nil. Files stored before content hashing existed never match.
+func findDuplicate(tx *sql.Tx, contentHash string, size int64) (*filestoreutils.FileMetadata, error) {
+	var original filestoreutils.FileMetadata
+	err := tx.QueryRow(`
+		SELECT id, offset, length, cipher_version, compression, COALESCE(key_uuid, uuid)
+		FROM files
+		WHERE content_hash = ? AND size = ? AND is_deleted = 0
+		LIMIT 1
+	`, contentHash, size).Scan(&original.ID, &original.Offset, &original.Length, &original.CipherVersion, &original.Compression, &original.KeyUUID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up duplicate content: %w", err)
+	}
+
+	original.Size = size
+	return &original, nil
+}
+
+// storeDuplicate records a file that shares the extent and key of an identical
+// stored file, and commits tx. Callers hold s.mu.
+func (s *service) storeDuplicate(tx *sql.Tx, fileUUID, fileName, mimeType string, folderID int64, original *filestoreutils.FileMetadata, hashes filestoreutils.ContentHashes, origin auditutils.Entry) (*FileMetadata, error) {
+	fileID, err := filestoreutils.InsertFileMetadata(tx, fileUUID, fileName, original.Size, mimeType, folderID, original.Offset, original.Length, original.CipherVersion, original.Compression, hashes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert file metadata: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE files SET key_uuid = ? WHERE id = ?", original.KeyUUID, fileID); err != nil {
+		return nil, fmt.Errorf("failed to link duplicate file: %w", err)
+	}
+	if err := appendStoreAudit(tx, origin, fileID, folderID, hashes); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	fmt.Printf("Stored file %s (%s) as a duplicate of file %d", fileName, fileUUID, original.ID)
+	return &FileMetadata{
+		ID:        fileID,
+		UUID:      fileUUID,
+		Name:      fileName,
+		Size:      original.Size,
+		MimeType:  mimeType,
+		FolderID:  folderID,
+		Offset:    original.Offset,
+		Length:    original.Length,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// extentReferences counts the live files still using the extent at offset
+func extentReferences(tx *sql.Tx, offset int64) (int, error) {
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM files WHERE offset = ? AND is_deleted = 0", offset).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count extent references: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,103 @@
+package filestore
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"testing"
+)
+
+func vaultSize(t *testing.T, s *service) int64 {
+	t.Helper()
+
+	info, err := os.Stat(s.tvaultPath)
+	if err != nil {
+		t.Fatalf("Failed to stat TVault: %v", err)
+	}
+	return info.Size()
+}
+
+func TestStoreFileDeduplicates(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"buffered", 4096},
+		{"streamed", storeSpoolLimit + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, folderID := setupTestService(t)
+
+			data := make([]byte, tt.size)
+			rand.Read(data)
+
+			first, err := s.StoreFile(folderID, "a.bin", "application/octet-stream", bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Failed to store file: %v", err)
+			}
+			sizeAfterFirst := vaultSize(t, s)
+
+			second, err := s.StoreFile(folderID, "b.bin", "application/octet-stream", bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Failed to store duplicate: %v", err)
+			}
+			if second.Offset != first.Offset {
+				t.Errorf("Duplicate stored at %d, want shared extent at %d", second.Offset, first.Offset)
+			}
+			if size := vaultSize(t, s); size != sizeAfterFirst {
+				t.Errorf("TVault grew from %d to %d for a duplicate", sizeAfterFirst, size)
+			}
+
+			// Different content of the same size is stored separately
+			other := make([]byte, tt.size)
+			rand.Read(other)
+			third, err := s.StoreFile(folderID, "c.bin", "application/octet-stream", bytes.NewReader(other))
+			if err != nil {
+				t.Fatalf("Failed to store file: %v", err)
+			}
+			if third.Offset == first.Offset {
+				t.Error("Different content shares an extent")
+			}
+
+			// The extent survives until its last reference is deleted
+			if err := s.DeleteFiles([]int64{first.ID}); err != nil {
+				t.Fatalf("Failed to delete file: %v", err)
+			}
+			checkFileContents(t, s, map[int64][]byte{second.ID: data, third.ID: other})
+
+			if err := s.DeleteFiles([]int64{second.ID}); err != nil {
+				t.Fatalf("Failed to delete file: %v", err)
+			}
+			var free int
+			s.db.QueryRow("SELECT COUNT(*) FROM free_spaces WHERE offset = ?", first.Offset).Scan(&free)
+			if free != 1 {
+				t.Errorf("Extent was not freed after its last reference was deleted")
+			}
+		})
+	}
+}
+
+func TestDeleteDuplicatesTogether(t *testing.T) {
+	s, folderID := setupTestService(t)
+
+	data := []byte("the same photo, received twice")
+	var ids []int64
+	for _, name := range []string{"one.jpg", "two.jpg", "three.jpg"} {
+		metadata, err := s.StoreFile(folderID, name, "image/jpeg", bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Failed to store file: %v", err)
+		}
+		ids = append(ids, metadata.ID)
+	}
+
+	if err := s.DeleteFiles(ids[:2]); err != nil {
+		t.Fatalf("Failed to delete files: %v", err)
+	}
+	checkFileContents(t, s, map[int64][]byte{ids[2]: data})
+
+	if err := s.DeleteFiles(ids[2:]); err != nil {
+		t.Fatalf("Failed to delete last reference: %v", err)
+	}
+}
@@ -0,0 +1,358 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/auditutils"
+	"Tella-Desktop/backend/utils/authutils"
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// exportDir returns the directory exports are written to
+func (options ExportOptions) exportDir() (string, error) {
+	if options.Destination == "" {
+		return authutils.GetExportDir(), nil
+	}
+
+	if !filepath.IsAbs(options.Destination) {
+		return "", fmt.Errorf("export destination must be an absolute path")
+	}
+	info, err := os.Stat(options.Destination)
+	if err != nil {
+		return "", fmt.Errorf("failed to access export destination: %w", err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("export destination is not a directory: %s", options.Destination)
+	}
+	return filepath.Clean(options.Destination), nil
+}
+
+// attachExportMetadata adds the tags and notes of each exported folder and
+// its files, which exports write alongside the files. Neutral exports leave
+// them out, as they could identify what was exported.
+func (s *service) attachExportMetadata(exports []folderExport) error {
+	for i := range exports {
+		export := &exports[i]
+		folderID := export.folder.ID
+		ids := make([]int64, len(export.files))
+		for j, file := range export.files {
+			ids[j] = file.ID
+		}
+
+		folderTags, err := s.folderTags([]int64{folderID})
+		if err != nil {
+			return err
+		}
+		fileTags, err := s.fileTags(ids)
+		if err != nil {
+			return err
+		}
+		folderNotes, err := s.exportNotesOf("folder_id", []int64{folderID})
+		if err != nil {
+			return err
+		}
+		fileNotes, err := s.exportNotesOf("file_id", ids)
+		if err != nil {
+			return err
+		}
+
+		export.meta = filestoreutils.ExportFolderMetadata{
+			Tags:  tagNames(folderTags[folderID]),
+			Notes: folderNotes[folderID],
+		}
+		for j := range export.files {
+			export.files[j].Tags = tagNames(fileTags[export.files[j].ID])
+			export.files[j].Notes = fileNotes[export.files[j].ID]
+		}
+	}
+	return nil
+}
+
+// recordExport registers a plaintext copy written to path and the files it
+// contains. digest is the SHA-256 of the copy when already known.
+func (s *service) recordExport(path string, digest string, entries []filestoreutils.ExportEntry) error {
+	fileDigest, size, err := exportDigest(path)
+	if err != nil {
+		return err
+	}
+	if digest != "" && digest != fileDigest {
+		return fmt.Errorf("exported file changed while it was being written")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO exports (path, sha256, size) VALUES (?, ?, ?)", path, fileDigest, size)
+	if err != nil {
+		return fmt.Errorf("failed to record export: %w", err)
+	}
+	exportID, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get export ID: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.Err != nil {
+			continue
+		}
+		if _, err := tx.Exec("INSERT INTO exported_files (export_id, file_id, name) VALUES (?, ?, ?)", exportID, entry.FileID, entry.Name); err != nil {
+			return fmt.Errorf("failed to record exported file: %w", err)
+		}
+		if err := auditutils.AppendFile(tx, auditutils.EventExported, "", entry.FileID, fmt.Sprintf("as '%s' to %s", entry.Name, path)); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *service) GetExports() ([]ExportRecord, error) {
+	rows, err := s.db.Query(`
+		SELECT id, path, sha256, size,
+			strftime('%Y-%m-%dT%H:%M:%SZ', exported_at),
+			COALESCE(strftime('%Y-%m-%dT%H:%M:%SZ', closed_at), ''),
+			COALESCE(status, '')
+		FROM exports
+		ORDER BY id DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exports: %w", err)
+	}
+	defer rows.Close()
+
+	var records []ExportRecord
+	index := make(map[int64]int)
+	for rows.Next() {
+		var record ExportRecord
+		if err := rows.Scan(&record.ID, &record.Path, &record.SHA256, &record.Size, &record.ExportedAt, &record.ClosedAt, &record.Status); err != nil {
+			return nil, fmt.Errorf("failed to scan export: %w", err)
+		}
+		record.Files = []ExportRecordedFile{}
+		index[record.ID] = len(records)
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating exports: %w", err)
+	}
+
+	fileRows, err := s.db.Query("SELECT export_id, file_id, name FROM exported_files ORDER BY rowid")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exported files: %w", err)
+	}
+	defer fileRows.Close()
+
+	for fileRows.Next() {
+		var exportID int64
+		var file ExportRecordedFile
+		if err := fileRows.Scan(&exportID, &file.FileID, &file.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan exported file: %w", err)
+		}
+		if i, ok := index[exportID]; ok {
+			records[i].Files = append(records[i].Files, file)
+		}
+	}
+
+	return records, fileRows.Err()
+}
+
+// WipeExportedCopies overwrites and deletes exported copies. A copy is only
+// wiped if it still matches the recorded hash, so files the user changed or
+// replaced after exporting are left in place and reported as modified.
+func (s *service) WipeExportedCopies(exportIDs []int64) ([]ExportWipe, error) {
+	query := "SELECT id, path, sha256, size FROM exports WHERE closed_at IS NULL"
+	var args []interface{}
+	if len(exportIDs) > 0 {
+		query += " AND id IN (" + placeholders(len(exportIDs)) + ")"
+		args = int64sToArgs(exportIDs)
+	}
+
+	rows, err := s.db.Query(query+" ORDER BY id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exports: %w", err)
+	}
+	type outstanding struct {
+		id     int64
+		path   string
+		sha256 string
+		size   int64
+	}
+	var exports []outstanding
+	for rows.Next() {
+		var export outstanding
+		if err := rows.Scan(&export.id, &export.path, &export.sha256, &export.size); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan export: %w", err)
+		}
+		exports = append(exports, export)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating exports: %w", err)
+	}
+
+	results := make([]ExportWipe, 0, len(exports))
+	for _, export := range exports {
+		result := ExportWipe{ExportID: export.id, Path: export.path}
+		result.Status, err = wipeExport(export.path, export.sha256, export.size)
+		if err != nil {
+			result.Error = err.Error()
+		}
+
+		if result.Status == ExportWiped || result.Status == ExportMissing {
+			if err := s.closeExport(export.id, export.path, result.Status); err != nil {
+				return nil, err
+			}
+		}
+		if result.Status != ExportWiped {
+			fmt.Printf("Wiping exported copy %s: %s %s\n", export.path, result.Status, result.Error)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// closeExport marks an exported copy as wiped or missing and logs what happened to it
+func (s *service) closeExport(id int64, path string, status string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE exports SET closed_at = datetime('now'), status = ? WHERE id = ?", status, id); err != nil {
+		return fmt.Errorf("failed to update export %d: %w", id, err)
+	}
+
+	// A missing copy was deleted or moved outside Tella
+	entry := auditutils.Entry{Event: auditutils.EventWiped, Details: "exported copy " + path}
+	if status == ExportMissing {
+		entry = auditutils.Entry{Event: auditutils.EventDeleted, Actor: "unknown", Details: "exported copy " + path + " found missing"}
+	}
+	if err := auditutils.Append(tx, entry); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func wipeExport(path string, digest string, size int64) (string, error) {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return ExportMissing, nil
+	}
+	if err != nil {
+		return ExportError, fmt.Errorf("failed to stat exported copy: %w", err)
+	}
+	if info.IsDir() {
+		return wipeExportedBag(path, digest)
+	}
+	if !info.Mode().IsRegular() || info.Size() != size {
+		return ExportModified, nil
+	}
+
+	current, _, err := hashFile(path)
+	if err != nil {
+		return ExportError, err
+	}
+	if current != digest {
+		return ExportModified, nil
+	}
+
+	if err := filestoreutils.SecurelyDeleteFile(path); err != nil {
+		return ExportError, err
+	}
+	return ExportWiped, nil
+}
+
+// wipeExportedBag wipes a bag exported as a directory. The bag must still
+// validate and carry the tag manifest recorded at export, which covers
+// every other file in it.
+func wipeExportedBag(path string, digest string) (string, error) {
+	current, _, err := exportDigest(path)
+	if err != nil || current != digest {
+		return ExportModified, nil
+	}
+	validation, err := filestoreutils.ValidateBag(path)
+	if err != nil {
+		return ExportError, err
+	}
+	if !validation.Valid {
+		return ExportModified, nil
+	}
+
+	err = filepath.WalkDir(path, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() {
+			return filestoreutils.SecurelyDeleteFile(name)
+		}
+		return nil
+	})
+	if err != nil {
+		return ExportError, err
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return ExportError, fmt.Errorf("failed to remove bag directory: %w", err)
+	}
+	return ExportWiped, nil
+}
+
+// exportDigest identifies an exported copy by its SHA-256 and size. A bag
+// exported as a directory is identified by its SHA-256 tag manifest.
+func exportDigest(path string) (string, int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return hashFile(path)
+	}
+
+	digest, _, err := hashFile(filepath.Join(path, "tagmanifest-sha256.txt"))
+	if err != nil {
+		return "", 0, err
+	}
+	var size int64
+	err = filepath.WalkDir(path, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if info, err := entry.Info(); err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return digest, size, err
+}
+
+// hashFile returns the SHA-256 and size of a file on disk
+func hashFile(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	digest := sha256.New()
+	size, err := io.Copy(digest, file)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(digest.Sum(nil)), size, nil
+}
@@ -0,0 +1,75 @@
+package filestore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportRegistryAndWipe(t *testing.T) {
+	s, folderID := setupTestService(t)
+	ids, _ := storeRandomFiles(t, s, folderID, []int{100, 200, 300})
+	destination := t.TempDir()
+
+	if _, err := s.ExportFiles(ids, ExportOptions{Destination: "relative/dir"}); err == nil {
+		t.Error("Expected a relative destination to be rejected")
+	}
+	if _, err := s.ExportFiles(ids, ExportOptions{Destination: filepath.Join(destination, "missing")}); err == nil {
+		t.Error("Expected a missing destination to be rejected")
+	}
+
+	report, err := s.ExportFiles(ids, ExportOptions{Destination: destination})
+	if err != nil {
+		t.Fatalf("ExportFiles failed: %v", err)
+	}
+	zipReport, err := s.ExportZipFolders([]int64{folderID}, ids[:2], ExportOptions{Destination: destination})
+	if err != nil {
+		t.Fatalf("ExportZipFolders failed: %v", err)
+	}
+	for _, path := range append(report.Paths, zipReport.Paths...) {
+		if filepath.Dir(path) != destination {
+			t.Errorf("Expected %s to be exported into %s", path, destination)
+		}
+	}
+
+	records, err := s.GetExports()
+	if err != nil {
+		t.Fatalf("GetExports failed: %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("Expected 4 recorded exports, got %d", len(records))
+	}
+	if zip := records[0]; zip.Path != zipReport.Paths[0] || len(zip.Files) != 2 || zip.SHA256 == "" || zip.Status != "" {
+		t.Errorf("Unexpected ZIP export record: %+v", zip)
+	}
+
+	// One copy is edited by the user and another removed outside Tella
+	os.WriteFile(report.Paths[0], []byte("edited"), 0644)
+	os.Remove(report.Paths[1])
+
+	results, err := s.WipeExportedCopies(nil)
+	if err != nil {
+		t.Fatalf("WipeExportedCopies failed: %v", err)
+	}
+	want := []string{ExportModified, ExportMissing, ExportWiped, ExportWiped}
+	for i, result := range results {
+		if result.Status != want[i] {
+			t.Errorf("Export %s: got status %s, want %s (%s)", result.Path, result.Status, want[i], result.Error)
+		}
+	}
+
+	if _, err := os.Stat(report.Paths[0]); err != nil {
+		t.Error("Expected the modified copy to be left in place")
+	}
+	for _, path := range []string{report.Paths[2], zipReport.Paths[0]} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be wiped", path)
+		}
+	}
+
+	// Only the modified copy is still outstanding
+	results, _ = s.WipeExportedCopies(nil)
+	if len(results) != 1 || results[0].Path != report.Paths[0] {
+		t.Errorf("Expected only the modified copy to remain outstanding, got %+v", results)
+	}
+}
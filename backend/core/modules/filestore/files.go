@@ -0,0 +1,192 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/auditutils"
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+)
+
+// queryRower is satisfied by both *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func (s *service) RenameFile(id int64, name string) error {
+	name, err := validateName(name, "file")
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var oldName string
+	var folderID int64
+	err = tx.QueryRow("SELECT name, folder_id FROM files WHERE id = ? AND is_deleted = 0 AND trashed_at IS NULL", id).Scan(&oldName, &folderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("file not found with ID: %d", id)
+		}
+		return fmt.Errorf("failed to get file: %w", err)
+	}
+
+	if err := checkFileName(tx, name, folderID, id); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE files SET name = ?, search_name = ? WHERE id = ?", name, filestoreutils.SearchName(name), id); err != nil {
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+	if err := auditutils.AppendFile(tx, auditutils.EventRenamed, "", id, fmt.Sprintf("from '%s' to '%s'", oldName, name)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) MoveFiles(ids []int64, folderID int64) error {
+	if len(ids) == 0 {
+		return fmt.Errorf("no file IDs provided")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkFolderExists(tx, folderID); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		var name string
+		var fromID int64
+		err := tx.QueryRow("SELECT name, folder_id FROM files WHERE id = ? AND is_deleted = 0 AND trashed_at IS NULL", id).Scan(&name, &fromID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("file not found with ID: %d", id)
+			}
+			return fmt.Errorf("failed to get file: %w", err)
+		}
+
+		// Checked one at a time so files moved earlier in the batch count as conflicts too
+		if err := checkFileName(tx, name, folderID, id); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("UPDATE files SET folder_id = ? WHERE id = ?", folderID, id); err != nil {
+			return fmt.Errorf("failed to move file %d: %w", id, err)
+		}
+		if err := auditutils.AppendFile(tx, auditutils.EventMoved, "", id, fmt.Sprintf("from folder %d", fromID)); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) CopyFiles(ids []int64, folderID int64) ([]int64, error) {
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no file IDs provided")
+	}
+
+	if _, err := filestoreutils.GetFolderInfo(s.db, folderID); err != nil {
+		return nil, err
+	}
+
+	var copied []int64
+	for _, id := range ids {
+		copyID, err := s.copyFile(id, folderID)
+		if err != nil {
+			// Leave the vault as it was rather than keeping half of the batch
+			if len(copied) > 0 {
+				if delErr := s.DeleteFiles(copied); delErr != nil {
+					fmt.Printf("Warning: Failed to remove partial copies: %v\n", delErr)
+				}
+			}
+			return nil, fmt.Errorf("failed to copy file %d: %w", id, err)
+		}
+		copied = append(copied, copyID)
+	}
+
+	return copied, nil
+}
+
+// copyFile decrypts a file and stores it again, so the copy gets its own UUID,
+// key and extent instead of sharing ciphertext with the original
+func (s *service) copyFile(id int64, folderID int64) (int64, error) {
+	metadata, err := filestoreutils.GetFileMetadataByID(s.db, id)
+	if err != nil {
+		return 0, err
+	}
+
+	name, err := uniqueFileName(s.db, metadata.Name, folderID)
+	if err != nil {
+		return 0, err
+	}
+
+	reader, err := s.OpenFile(id)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	copyMetadata, err := s.storeWithPreviews(folderID, name, metadata.MimeType, reader, false, auditutils.Entry{Event: auditutils.EventCopied, Details: fmt.Sprintf("copy of file %d", id)})
+	if err != nil {
+		return 0, err
+	}
+
+	return copyMetadata.ID, nil
+}
+
+// uniqueFileName appends a counter to name until no live file in the folder uses it,
+// following the same pattern as CreateUniqueFilename for exports
+func uniqueFileName(q queryRower, name string, folderID int64) (string, error) {
+	ext := filepath.Ext(name)
+	baseName := name[:len(name)-len(ext)]
+
+	candidate := name
+	for counter := 1; ; counter++ {
+		var count int
+		err := q.QueryRow(`
+			SELECT COUNT(*) FROM files WHERE folder_id = ? AND name = ? AND is_deleted = 0 AND trashed_at IS NULL
+		`, folderID, candidate).Scan(&count)
+		if err != nil {
+			return "", fmt.Errorf("failed to check file name: %w", err)
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d%s", baseName, counter, ext)
+	}
+}
+
+// checkFileName rejects a name already used by another live file in the folder
+func checkFileName(tx *sql.Tx, name string, folderID int64, excludeID int64) error {
+	var count int
+	err := tx.QueryRow(`
+		SELECT COUNT(*) FROM files
+		WHERE folder_id = ? AND name = ? AND id != ? AND is_deleted = 0 AND trashed_at IS NULL
+	`, folderID, name, excludeID).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to check file name: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("a file named '%s' already exists in this folder", name)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package filestore
+
+import (
+	"testing"
+
+	"Tella-Desktop/backend/utils/filestoreutils"
+)
+
+func TestRenameAndMoveFiles(t *testing.T) {
+	s, folderID := setupTestService(t)
+	other, _ := s.CreateFolder("Other", 0)
+
+	ids, contents := storeRandomFiles(t, s, folderID, []int{100, 200})
+
+	if err := s.RenameFile(ids[0], "evidence.jpg"); err != nil {
+		t.Fatalf("Failed to rename file: %v", err)
+	}
+	if err := s.RenameFile(ids[1], "evidence.jpg"); err == nil {
+		t.Error("Expected rename to an existing name in the folder to be rejected")
+	}
+	if err := s.RenameFile(ids[1], "../evidence.jpg"); err == nil {
+		t.Error("Expected name with path separator to be rejected")
+	}
+
+	if err := s.MoveFiles(ids, 9999); err == nil {
+		t.Error("Expected move to missing folder to be rejected")
+	}
+	if err := s.MoveFiles(ids, other.ID); err != nil {
+		t.Fatalf("Failed to move files: %v", err)
+	}
+
+	response, _ := s.GetFilesInFolder(other.ID)
+	if len(response.Files) != 2 {
+		t.Errorf("Expected 2 files in target folder, got %d", len(response.Files))
+	}
+	checkFileContents(t, s, contents)
+}
+
+func TestCopyFilesReencrypts(t *testing.T) {
+	s, folderID := setupTestService(t)
+
+	ids, contents := storeRandomFiles(t, s, folderID, []int{70000})
+	copies, err := s.CopyFiles(ids, folderID)
+	if err != nil {
+		t.Fatalf("Failed to copy files: %v", err)
+	}
+	if len(copies) != 1 {
+		t.Fatalf("Expected 1 copy, got %d", len(copies))
+	}
+
+	original, _ := filestoreutils.GetFileMetadataByID(s.db, ids[0])
+	copied, _ := filestoreutils.GetFileMetadataByID(s.db, copies[0])
+	if copied.UUID == original.UUID || copied.Offset == original.Offset {
+		t.Errorf("Copy shares key or extent with the original: %+v", copied)
+	}
+	if copied.Name == original.Name {
+		t.Errorf("Copy in the same folder kept the name %q", copied.Name)
+	}
+
+	contents[copies[0]] = contents[ids[0]]
+	checkFileContents(t, s, contents)
+
+	// Deleting the original leaves the copy intact
+	if err := s.DeleteFiles(ids); err != nil {
+		t.Fatalf("Failed to delete original: %v", err)
+	}
+	delete(contents, ids[0])
+	checkFileContents(t, s, contents)
+}
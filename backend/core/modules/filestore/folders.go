@@ -0,0 +1,397 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/auditutils"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// maxNameLength limits file and folder names to what common filesystems accept on export
+const maxNameLength = 255
+
+func (s *service) CreateFolder(name string, parentID int64) (*FolderInfo, error) {
+	name, err := validateFolderName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if parentID != 0 {
+		if err := checkFolderExists(tx, parentID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := checkSiblingName(tx, name, parentID, 0); err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO folders (name, parent_id, created_at, updated_at)
+		VALUES (?, ?, datetime('now'), datetime('now'))
+	`, name, nullableFolderID(parentID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create folder: %w", err)
+	}
+
+	folderID, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get folder ID: %w", err)
+	}
+
+	var folder FolderInfo
+	err = tx.QueryRow(`
+		SELECT id, name, COALESCE(parent_id, 0), created_at FROM folders WHERE id = ?
+	`, folderID).Scan(&folder.ID, &folder.Name, &folder.ParentID, &folder.Timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read created folder: %w", err)
+	}
+
+	if err := auditutils.Append(tx, auditutils.Entry{Event: auditutils.EventCreated, FolderID: folderID, Details: fmt.Sprintf("'%s' in folder %d", name, parentID)}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	fmt.Printf("Created folder '%s' with ID %d under parent %d\n", name, folderID, parentID)
+	return &folder, nil
+}
+
+func (s *service) RenameFolder(id int64, name string) error {
+	name, err := validateFolderName(name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	parentID, err := getFolderParent(tx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := checkSiblingName(tx, name, parentID, id); err != nil {
+		return err
+	}
+
+	var oldName string
+	if err := tx.QueryRow("SELECT name FROM folders WHERE id = ?", id).Scan(&oldName); err != nil {
+		return fmt.Errorf("failed to get folder: %w", err)
+	}
+
+	if _, err := tx.Exec("UPDATE folders SET name = ? WHERE id = ?", name, id); err != nil {
+		return fmt.Errorf("failed to rename folder: %w", err)
+	}
+	if err := auditutils.Append(tx, auditutils.Entry{Event: auditutils.EventRenamed, FolderID: id, Details: fmt.Sprintf("from '%s' to '%s'", oldName, name)}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) MoveFolder(id int64, parentID int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var name string
+	var fromID int64
+	err = tx.QueryRow("SELECT name, COALESCE(parent_id, 0) FROM folders WHERE id = ? AND trashed_at IS NULL", id).Scan(&name, &fromID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("folder not found with ID: %d", id)
+		}
+		return fmt.Errorf("failed to get folder: %w", err)
+	}
+
+	if parentID != 0 {
+		if err := checkFolderExists(tx, parentID); err != nil {
+			return err
+		}
+
+		// Walk up from the new parent; reaching the folder itself would create a cycle
+		ancestors, err := getFolderAncestors(tx, parentID)
+		if err != nil {
+			return err
+		}
+		for _, ancestor := range ancestors {
+			if ancestor.ID == id {
+				return fmt.Errorf("cannot move folder %d into itself or one of its subfolders", id)
+			}
+		}
+	}
+
+	if err := checkSiblingName(tx, name, parentID, id); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE folders SET parent_id = ? WHERE id = ?", nullableFolderID(parentID), id); err != nil {
+		return fmt.Errorf("failed to move folder: %w", err)
+	}
+	if err := auditutils.Append(tx, auditutils.Entry{Event: auditutils.EventMoved, FolderID: id, Details: fmt.Sprintf("from folder %d to folder %d", fromID, parentID)}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) ListChildFolders(parentID int64) ([]FolderInfo, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			f.id,
+			f.name,
+			COALESCE(f.parent_id, 0),
+			f.created_at,
+			COUNT(files.id) as file_count
+		FROM folders f
+		LEFT JOIN files ON f.id = files.folder_id AND files.is_deleted = 0 AND files.trashed_at IS NULL
+		WHERE f.parent_id IS ? AND f.trashed_at IS NULL
+		GROUP BY f.id, f.name, f.parent_id, f.created_at
+		ORDER BY f.name COLLATE NOCASE ASC
+	`, nullableFolderID(parentID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query child folders: %w", err)
+	}
+	defer rows.Close()
+
+	var folders []FolderInfo
+	for rows.Next() {
+		var folder FolderInfo
+		if err := rows.Scan(&folder.ID, &folder.Name, &folder.ParentID, &folder.Timestamp, &folder.FileCount); err != nil {
+			return nil, fmt.Errorf("failed to scan folder: %w", err)
+		}
+		folders = append(folders, folder)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating child folders: %w", err)
+	}
+	if err := s.attachFolderTags(folders); err != nil {
+		return nil, err
+	}
+
+	return folders, nil
+}
+
+func (s *service) GetFolderPath(id int64) ([]FolderPathEntry, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ancestors, err := getFolderAncestors(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ancestors are collected bottom-up; breadcrumbs read top-down
+	path := make([]FolderPathEntry, len(ancestors))
+	for i, ancestor := range ancestors {
+		path[len(ancestors)-1-i] = ancestor
+	}
+
+	return path, nil
+}
+
+// getFolderAncestors returns the folder followed by each of its parents up to the top level
+func getFolderAncestors(tx *sql.Tx, id int64) ([]FolderPathEntry, error) {
+	var ancestors []FolderPathEntry
+	seen := make(map[int64]bool)
+
+	for current := id; current != 0; {
+		if seen[current] {
+			return nil, fmt.Errorf("folder hierarchy contains a cycle at folder %d", current)
+		}
+		seen[current] = true
+
+		var entry FolderPathEntry
+		var parentID int64
+		err := tx.QueryRow(`
+			SELECT id, name, COALESCE(parent_id, 0) FROM folders WHERE id = ?
+		`, current).Scan(&entry.ID, &entry.Name, &parentID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("folder not found with ID: %d", current)
+			}
+			return nil, fmt.Errorf("failed to get folder: %w", err)
+		}
+
+		ancestors = append(ancestors, entry)
+		current = parentID
+	}
+
+	return ancestors, nil
+}
+
+// getFolderSubtrees expands the given folders to include all of their descendants
+func (s *service) getFolderSubtrees(folderIDs []int64) ([]int64, error) {
+	placeholders := make([]string, len(folderIDs))
+	args := make([]interface{}, len(folderIDs))
+
+	for i, id := range folderIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+
+	query := fmt.Sprintf(`
+		WITH RECURSIVE subtree(id) AS (
+			SELECT id FROM folders WHERE id IN (%s)
+			UNION
+			SELECT folders.id FROM folders JOIN subtree ON folders.parent_id = subtree.id
+		)
+		SELECT id FROM subtree
+	`, strings.Join(placeholders, ","))
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subfolders: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan folder ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating subfolders: %w", err)
+	}
+
+	return ids, nil
+}
+
+// buildFolderTree nests folders under their parents, keeping the input order
+// among siblings. Unless includeEmpty is set, branches without files are pruned.
+func buildFolderTree(folders []FolderInfo, includeEmpty bool) []FolderInfo {
+	known := make(map[int64]bool, len(folders))
+	for _, folder := range folders {
+		known[folder.ID] = true
+	}
+
+	children := make(map[int64][]FolderInfo)
+	for _, folder := range folders {
+		// Folders whose parent no longer exists are shown at the top level
+		parent := folder.ParentID
+		if !known[parent] {
+			parent = 0
+		}
+		children[parent] = append(children[parent], folder)
+	}
+
+	visited := make(map[int64]bool, len(folders))
+	var build func(parent int64) ([]FolderInfo, int)
+	build = func(parent int64) ([]FolderInfo, int) {
+		var nodes []FolderInfo
+		total := 0
+		for _, folder := range children[parent] {
+			if visited[folder.ID] {
+				continue
+			}
+			visited[folder.ID] = true
+
+			subtree, count := build(folder.ID)
+			count += folder.FileCount
+			if count == 0 && !includeEmpty {
+				continue
+			}
+			folder.Children = subtree
+			nodes = append(nodes, folder)
+			total += count
+		}
+		return nodes, total
+	}
+
+	tree, _ := build(0)
+	return tree
+}
+
+// validateFolderName trims a folder name and rejects names that cannot be exported as a directory
+func validateFolderName(name string) (string, error) {
+	return validateName(name, "folder")
+}
+
+// validateName trims a file or folder name and rejects names that cannot be used on disk
+func validateName(name string, kind string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%s name cannot be empty", kind)
+	}
+	if name == "." || name == ".." {
+		return "", fmt.Errorf("invalid %s name: %s", kind, name)
+	}
+	if strings.ContainsAny(name, "/\\\x00") {
+		return "", fmt.Errorf("%s name cannot contain path separators", kind)
+	}
+	if len(name) > maxNameLength {
+		return "", fmt.Errorf("%s name is longer than %d bytes", kind, maxNameLength)
+	}
+	return name, nil
+}
+
+func checkFolderExists(tx *sql.Tx, id int64) error {
+	_, err := getFolderParent(tx, id)
+	return err
+}
+
+func getFolderParent(tx *sql.Tx, id int64) (int64, error) {
+	var parentID int64
+	err := tx.QueryRow("SELECT COALESCE(parent_id, 0) FROM folders WHERE id = ? AND trashed_at IS NULL", id).Scan(&parentID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("folder not found with ID: %d", id)
+		}
+		return 0, fmt.Errorf("failed to get folder: %w", err)
+	}
+	return parentID, nil
+}
+
+// checkSiblingName rejects a name already used by another folder with the same parent
+func checkSiblingName(tx *sql.Tx, name string, parentID int64, excludeID int64) error {
+	var count int
+	err := tx.QueryRow(`
+		SELECT COUNT(*) FROM folders
+		WHERE name = ? AND parent_id IS ? AND id != ? AND trashed_at IS NULL
+	`, name, nullableFolderID(parentID), excludeID).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to check folder name: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("a folder named '%s' already exists here", name)
+	}
+	return nil
+}
+
+// nullableFolderID maps the top-level sentinel 0 to NULL for parent_id
+func nullableFolderID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
@@ -0,0 +1,123 @@
+package filestore
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFolderHierarchy(t *testing.T) {
+	s, _ := setupTestService(t)
+
+	cases, err := s.CreateFolder("Cases", 0)
+	if err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	interviews, err := s.CreateFolder(" Interviews ", cases.ID)
+	if err != nil {
+		t.Fatalf("Failed to create subfolder: %v", err)
+	}
+	if interviews.Name != "Interviews" || interviews.ParentID != cases.ID {
+		t.Errorf("Unexpected subfolder: %+v", interviews)
+	}
+	audio, _ := s.CreateFolder("Audio", interviews.ID)
+
+	if _, err := s.CreateFolder("Interviews", cases.ID); err == nil {
+		t.Error("Expected duplicate sibling name to be rejected")
+	}
+	for _, name := range []string{"", "  ", "..", "a/b"} {
+		if _, err := s.CreateFolder(name, 0); err == nil {
+			t.Errorf("Expected folder name %q to be rejected", name)
+		}
+	}
+
+	path, err := s.GetFolderPath(audio.ID)
+	if err != nil {
+		t.Fatalf("Failed to get folder path: %v", err)
+	}
+	if len(path) != 3 || path[0].ID != cases.ID || path[2].ID != audio.ID {
+		t.Errorf("Unexpected breadcrumbs: %+v", path)
+	}
+
+	// A folder cannot be moved under itself or any of its descendants
+	if err := s.MoveFolder(cases.ID, audio.ID); err == nil {
+		t.Error("Expected move into descendant to be rejected")
+	}
+	if err := s.MoveFolder(cases.ID, cases.ID); err == nil {
+		t.Error("Expected move into itself to be rejected")
+	}
+
+	// Trashed folders can neither be moved nor receive other folders
+	bin, _ := s.CreateFolder("Bin", 0)
+	old, _ := s.CreateFolder("Old", 0)
+	s.TrashFolders([]int64{bin.ID, old.ID})
+	if err := s.MoveFolder(audio.ID, bin.ID); err == nil {
+		t.Error("Expected move into a trashed folder to be rejected")
+	}
+	if err := s.MoveFolder(old.ID, cases.ID); err == nil {
+		t.Error("Expected a trashed folder to stay where it is")
+	}
+
+	if err := s.MoveFolder(audio.ID, 0); err != nil {
+		t.Fatalf("Failed to move folder to top level: %v", err)
+	}
+	if err := s.RenameFolder(audio.ID, "Recordings"); err != nil {
+		t.Fatalf("Failed to rename folder: %v", err)
+	}
+
+	children, err := s.ListChildFolders(interviews.ID)
+	if err != nil || len(children) != 0 {
+		t.Errorf("Expected no children after move, got %v (%v)", children, err)
+	}
+	path, _ = s.GetFolderPath(audio.ID)
+	if len(path) != 1 || path[0].Name != "Recordings" {
+		t.Errorf("Unexpected breadcrumbs after move: %+v", path)
+	}
+}
+
+func TestGetStoredFoldersOptions(t *testing.T) {
+	s, _ := setupTestService(t)
+
+	parent, _ := s.CreateFolder("Parent", 0)
+	child, _ := s.CreateFolder("Child", parent.ID)
+	s.CreateFolder("Empty", 0)
+	if _, err := s.StoreFile(child.ID, "note.txt", "text/plain", bytes.NewReader([]byte("hello"))); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	flat, _ := s.GetStoredFolders(FolderListOptions{})
+	if len(flat) != 1 || flat[0].ID != child.ID {
+		t.Errorf("Expected only the folder with files, got %+v", flat)
+	}
+
+	all, _ := s.GetStoredFolders(FolderListOptions{IncludeEmpty: true})
+	if len(all) != 4 {
+		t.Errorf("Expected 4 folders including empty ones and the default folder, got %d", len(all))
+	}
+
+	// Parents of folders with files are kept in the tree, empty branches are pruned
+	tree, _ := s.GetStoredFolders(FolderListOptions{Tree: true})
+	if len(tree) != 1 || tree[0].ID != parent.ID || len(tree[0].Children) != 1 || tree[0].Children[0].ID != child.ID {
+		t.Errorf("Unexpected folder tree: %+v", tree)
+	}
+
+	full, _ := s.GetStoredFolders(FolderListOptions{Tree: true, IncludeEmpty: true})
+	if len(full) != 3 {
+		t.Errorf("Expected 3 top-level folders, got %+v", full)
+	}
+
+	// Deleting a folder removes its subfolders and their files
+	if err := s.DeleteFolders([]int64{parent.ID}); err != nil {
+		t.Fatalf("Failed to delete folders: %v", err)
+	}
+	all, _ = s.GetStoredFolders(FolderListOptions{IncludeEmpty: true})
+	for _, folder := range all {
+		if folder.ID == parent.ID || folder.ID == child.ID {
+			t.Errorf("Folder %d survived deletion of its parent", folder.ID)
+		}
+	}
+	var live int
+	s.db.QueryRow("SELECT COUNT(*) FROM files WHERE is_deleted = 0").Scan(&live)
+	if live != 0 {
+		t.Errorf("Expected files in deleted subfolder to be deleted, %d remain", live)
+	}
+}
@@ -0,0 +1,288 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/auditutils"
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// sniffLength is how much of a file http.DetectContentType looks at
+const sniffLength = 512
+
+func (s *service) ImportPaths(paths []string, folderID int64, options ImportOptions) (*ImportResult, error) {
+	return s.importPaths(s.ctx, nil, paths, folderID, options)
+}
+
+// StartImportPaths imports local files and directories in a background job and returns its ID
+func (s *service) StartImportPaths(paths []string, folderID int64, options ImportOptions) (string, error) {
+	if len(paths) == 0 {
+		return "", fmt.Errorf("no paths provided for import")
+	}
+	if _, err := filestoreutils.GetFolderInfo(s.db, folderID); err != nil {
+		return "", err
+	}
+
+	return s.startJob(JobImport, func(ctx context.Context, j *job) (interface{}, error) {
+		return s.importPaths(ctx, j, paths, folderID, options)
+	}), nil
+}
+
+func (s *service) importPaths(ctx context.Context, j *job, paths []string, folderID int64, options ImportOptions) (*ImportResult, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no paths provided for import")
+	}
+
+	if _, err := filestoreutils.GetFolderInfo(s.db, folderID); err != nil {
+		return nil, err
+	}
+
+	if j != nil {
+		count, bytes := countImport(paths)
+		j.setTotal(count, bytes)
+
+		// The import still runs when space is low, the warning lets the user cancel it
+		if _, err := s.CheckDiskSpace(bytes); err != nil {
+			fmt.Printf("Warning: Failed to check disk space: %v\n", err)
+		}
+	}
+
+	result := &ImportResult{}
+	var verified []string
+	var directories []string
+
+	fail := func(path string, err error) {
+		fmt.Printf("Failed to import %s: %v\n", path, err)
+		result.Failed = append(result.Failed, ImportFailure{Path: path, Error: err.Error()})
+		j.addError(path, err)
+	}
+
+	importFile := func(path string, targetFolder int64, size int64) {
+		fileID, err := s.importFile(path, targetFolder, options.DeleteOriginals)
+		j.advance(1, size)
+		if err != nil {
+			fail(path, err)
+			return
+		}
+		result.FileIDs = append(result.FileIDs, fileID)
+		if options.DeleteOriginals {
+			verified = append(verified, path)
+		}
+	}
+
+	for _, root := range paths {
+		if ctx.Err() != nil {
+			break
+		}
+
+		info, err := os.Lstat(root)
+		if err != nil {
+			fail(root, err)
+			continue
+		}
+
+		if info.Mode().IsRegular() {
+			importFile(root, folderID, info.Size())
+			continue
+		}
+		if !info.IsDir() {
+			fail(root, fmt.Errorf("not a regular file or directory"))
+			continue
+		}
+
+		// Recreate the directory tree as nested folders, parents before children
+		folderIDs := make(map[string]int64)
+		err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				fail(path, err)
+				if entry != nil && entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if entry.IsDir() {
+				parentID := folderID
+				if path != root {
+					parentID = folderIDs[filepath.Dir(path)]
+				}
+				name, err := uniqueFolderName(s.db, entry.Name(), parentID)
+				if err != nil {
+					fail(path, err)
+					return filepath.SkipDir
+				}
+				folder, err := s.CreateFolder(name, parentID)
+				if err != nil {
+					fail(path, err)
+					return filepath.SkipDir
+				}
+				folderIDs[path] = folder.ID
+				result.FolderIDs = append(result.FolderIDs, folder.ID)
+				directories = append(directories, path)
+				return nil
+			}
+
+			if !entry.Type().IsRegular() {
+				// Symbolic links and devices are never followed
+				fail(path, fmt.Errorf("not a regular file"))
+				return nil
+			}
+
+			var size int64
+			if info, err := entry.Info(); err == nil {
+				size = info.Size()
+			}
+			importFile(path, folderIDs[filepath.Dir(path)], size)
+			return nil
+		})
+		if err != nil && ctx.Err() == nil {
+			fail(root, err)
+		}
+	}
+
+	if options.DeleteOriginals {
+		for _, path := range verified {
+			if err := filestoreutils.SecurelyDeleteFile(path); err != nil {
+				fmt.Printf("Warning: Failed to securely delete original %s: %v\n", path, err)
+				continue
+			}
+			result.Deleted = append(result.Deleted, path)
+		}
+
+		// Remove directories deepest first; any still holding files that failed to import stay
+		for i := len(directories) - 1; i >= 0; i-- {
+			os.Remove(directories[i])
+		}
+	}
+
+	// Files imported before a cancellation stay in the vault, and their verified originals are still removed
+	if err := ctx.Err(); err != nil {
+		fmt.Printf("Import cancelled: %d files, %d folders, %d failures\n", len(result.FileIDs), len(result.FolderIDs), len(result.Failed))
+		return result, err
+	}
+
+	fmt.Printf("Import completed: %d files, %d folders, %d failures\n", len(result.FileIDs), len(result.FolderIDs), len(result.Failed))
+	return result, nil
+}
+
+// countImport returns how many regular files and bytes an import of paths covers
+func countImport(paths []string) (int, int64) {
+	var count int
+	var bytes int64
+	for _, root := range paths {
+		filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || !entry.Type().IsRegular() {
+				return nil
+			}
+			if info, err := entry.Info(); err == nil {
+				count++
+				bytes += info.Size()
+			}
+			return nil
+		})
+	}
+	return count, bytes
+}
+
+// importFile streams one local file into the vault. When verify is set the stored
+// copy is decrypted again and compared with the original before it may be deleted.
+func (s *service) importFile(path string, folderID int64, verify bool) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	reader := bufio.NewReaderSize(file, sniffLength)
+	head, err := reader.Peek(sniffLength)
+	if err != nil && err != io.EOF {
+		return 0, fmt.Errorf("failed to read file: %w", err)
+	}
+	mimeType := filestoreutils.DetectMimeType(path, head)
+
+	name, err := validateName(filepath.Base(path), "file")
+	if err != nil {
+		return 0, err
+	}
+	name, err = uniqueFileName(s.db, name, folderID)
+	if err != nil {
+		return 0, err
+	}
+
+	hash := sha256.New()
+	metadata, err := s.storeWithPreviews(folderID, name, mimeType, io.TeeReader(reader, hash), true, auditutils.Entry{Event: auditutils.EventImported, Details: path})
+	if err != nil {
+		return 0, err
+	}
+
+	if verify {
+		if err := s.verifyImport(metadata.ID, info.Size(), hash.Sum(nil)); err != nil {
+			if delErr := s.DeleteFiles([]int64{metadata.ID}); delErr != nil {
+				fmt.Printf("Warning: Failed to remove unverified import %d: %v\n", metadata.ID, delErr)
+			}
+			return 0, err
+		}
+	}
+
+	return metadata.ID, nil
+}
+
+// verifyImport checks that a stored file decrypts to exactly what was read from disk
+func (s *service) verifyImport(id int64, size int64, digest []byte) error {
+	reader, err := s.OpenFile(id)
+	if err != nil {
+		return fmt.Errorf("failed to verify import: %w", err)
+	}
+	defer reader.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(hash, reader)
+	if err != nil {
+		return fmt.Errorf("failed to verify import: %w", err)
+	}
+
+	// A size mismatch means the original changed while it was being read
+	if n != size || !bytes.Equal(hash.Sum(nil), digest) {
+		return fmt.Errorf("stored copy does not match the original, keeping it on disk")
+	}
+
+	return nil
+}
+
+// uniqueFolderName appends a counter to name until no sibling folder uses it
+func uniqueFolderName(q queryRower, name string, parentID int64) (string, error) {
+	name, err := validateFolderName(name)
+	if err != nil {
+		return "", err
+	}
+
+	candidate := name
+	for counter := 1; ; counter++ {
+		var count int
+		err := q.QueryRow(`
+			SELECT COUNT(*) FROM folders WHERE name = ? AND parent_id IS ? AND trashed_at IS NULL
+		`, candidate, nullableFolderID(parentID)).Scan(&count)
+		if err != nil {
+			return "", fmt.Errorf("failed to check folder name: %w", err)
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", name, counter)
+	}
+}
@@ -0,0 +1,66 @@
+package filestore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImportPaths(t *testing.T) {
+	s, folderID := setupTestService(t)
+
+	// A small directory tree plus a loose file
+	src := filepath.Join(t.TempDir(), "Evidence")
+	os.MkdirAll(filepath.Join(src, "Photos"), 0700)
+	png := []byte("\x89PNG\r\n\x1a\n" + "rest of image")
+	os.WriteFile(filepath.Join(src, "Photos", "img.png"), png, 0600)
+	os.WriteFile(filepath.Join(src, "notes.md"), []byte("# notes"), 0600)
+	loose := filepath.Join(t.TempDir(), "report.pdf")
+	os.WriteFile(loose, []byte("%PDF-1.4 test"), 0600)
+
+	result, err := s.ImportPaths([]string{src, loose}, folderID, ImportOptions{DeleteOriginals: true})
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if len(result.Failed) != 0 || len(result.FileIDs) != 3 || len(result.FolderIDs) != 2 {
+		t.Fatalf("Unexpected import result: %+v", result)
+	}
+
+	children, _ := s.ListChildFolders(folderID)
+	if len(children) != 1 || children[0].Name != "Evidence" {
+		t.Fatalf("Expected an Evidence folder, got %+v", children)
+	}
+	photos, _ := s.ListChildFolders(children[0].ID)
+	if len(photos) != 1 || photos[0].Name != "Photos" {
+		t.Fatalf("Expected a Photos subfolder, got %+v", photos)
+	}
+
+	files, _ := s.GetFilesInFolder(photos[0].ID)
+	if len(files.Files) != 1 || files.Files[0].MimeType != "image/png" {
+		t.Errorf("Expected one PNG in Photos, got %+v", files.Files)
+	}
+	files, _ = s.GetFilesInFolder(folderID)
+	if len(files.Files) != 1 || files.Files[0].MimeType != "application/pdf" {
+		t.Errorf("Expected the loose PDF in the target folder, got %+v", files.Files)
+	}
+
+	// Verified originals are wiped and the emptied directories removed
+	if len(result.Deleted) != 3 {
+		t.Errorf("Expected 3 originals deleted, got %v", result.Deleted)
+	}
+	for _, path := range []string{src, loose} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Original %s still exists", path)
+		}
+	}
+
+	// Importing the same tree again gets a distinct folder name
+	os.MkdirAll(src, 0700)
+	if _, err := s.ImportPaths([]string{src}, folderID, ImportOptions{}); err != nil {
+		t.Fatalf("Failed to import again: %v", err)
+	}
+	children, _ = s.ListChildFolders(folderID)
+	if len(children) != 2 || children[1].Name != "Evidence-1" {
+		t.Errorf("Expected Evidence-1 alongside Evidence, got %+v", children)
+	}
+}
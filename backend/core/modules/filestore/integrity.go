@@ -0,0 +1,72 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// VerifyFiles decrypts each file, hashes its plaintext and compares the result
+// with the SHA-256 and SHA-512 recorded when the file was stored. Problems with
+// individual files are reported in their result rather than failing the call.
+func (s *service) VerifyFiles(ids []int64) ([]FileVerification, error) {
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no file IDs provided")
+	}
+
+	results := make([]FileVerification, 0, len(ids))
+	for _, id := range ids {
+		result := s.verifyFile(id)
+		if result.Status != VerificationOK {
+			fmt.Printf("Verification of file %d: %s %s\n", id, result.Status, result.Error)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (s *service) verifyFile(id int64) FileVerification {
+	result := FileVerification{FileID: id}
+
+	metadata, err := filestoreutils.GetFileMetadataByID(s.db, id)
+	if err != nil {
+		result.Status = VerificationError
+		result.Error = err.Error()
+		return result
+	}
+	result.Name = metadata.Name
+	result.ExpectedSHA256 = metadata.SHA256
+	result.ExpectedSHA512 = metadata.SHA512
+
+	reader, err := s.OpenFile(id)
+	if err != nil {
+		result.Status = VerificationError
+		result.Error = err.Error()
+		return result
+	}
+	defer reader.Close()
+
+	// Chunk authentication fails on tampered ciphertext before any digest is compared
+	sha256Hash, sha512Hash := sha256.New(), sha512.New()
+	if _, err := io.Copy(io.MultiWriter(sha256Hash, sha512Hash), reader); err != nil {
+		result.Status = VerificationError
+		result.Error = fmt.Sprintf("failed to decrypt file: %v", err)
+		return result
+	}
+	result.ActualSHA256 = hex.EncodeToString(sha256Hash.Sum(nil))
+	result.ActualSHA512 = hex.EncodeToString(sha512Hash.Sum(nil))
+
+	switch {
+	case metadata.SHA256 == "" || metadata.SHA512 == "":
+		result.Status = VerificationUnrecorded
+	case result.ActualSHA256 != metadata.SHA256 || result.ActualSHA512 != metadata.SHA512:
+		result.Status = VerificationMismatch
+	default:
+		result.Status = VerificationOK
+	}
+	return result
+}
@@ -0,0 +1,72 @@
+package filestore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"os"
+	"testing"
+)
+
+func TestStoreFileRecordsHashes(t *testing.T) {
+	s, folderID := setupTestService(t)
+
+	data := bytes.Repeat([]byte("evidence "), 1000)
+	metadata, err := s.StoreFile(folderID, "statement.txt", "text/plain", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	sum256 := sha256.Sum256(data)
+	sum512 := sha512.Sum512(data)
+
+	response, err := s.GetFilesInFolder(folderID)
+	if err != nil {
+		t.Fatalf("GetFilesInFolder failed: %v", err)
+	}
+	if got := response.Files[0]; got.SHA256 != hex.EncodeToString(sum256[:]) || got.SHA512 != hex.EncodeToString(sum512[:]) {
+		t.Errorf("FileInfo hashes %s/%s do not match content", got.SHA256, got.SHA512)
+	}
+
+	results, err := s.VerifyFiles([]int64{metadata.ID})
+	if err != nil {
+		t.Fatalf("VerifyFiles failed: %v", err)
+	}
+	if results[0].Status != VerificationOK {
+		t.Errorf("Got status %s, want %s: %+v", results[0].Status, VerificationOK, results[0])
+	}
+}
+
+func TestVerifyFilesReportsProblems(t *testing.T) {
+	s, folderID := setupTestService(t)
+	ids, _ := storeRandomFiles(t, s, folderID, []int{1000, 2000, 3000})
+
+	// A changed record, a file stored before hashing and corrupted ciphertext
+	s.db.Exec("UPDATE files SET sha256 = ? WHERE id = ?", hex.EncodeToString(make([]byte, 32)), ids[0])
+	s.db.Exec("UPDATE files SET sha256 = NULL, sha512 = NULL WHERE id = ?", ids[1])
+
+	corrupted, _ := s.acquireExtent(ids[2])
+	s.releaseExtent(corrupted.Offset)
+	tvault, err := os.OpenFile(s.tvaultPath, os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tvault.WriteAt([]byte{0xFF, 0xFF, 0xFF, 0xFF}, corrupted.Offset+corrupted.Length-20)
+	tvault.Close()
+
+	results, err := s.VerifyFiles(append(ids, 9999))
+	if err != nil {
+		t.Fatalf("VerifyFiles failed: %v", err)
+	}
+
+	want := []string{VerificationMismatch, VerificationUnrecorded, VerificationError, VerificationError}
+	for i, result := range results {
+		if result.Status != want[i] {
+			t.Errorf("File %d: got status %s, want %s (%s)", result.FileID, result.Status, want[i], result.Error)
+		}
+	}
+	if results[1].ActualSHA256 == "" {
+		t.Error("Expected hashes to be computed for a file without recorded hashes")
+	}
+}
@@ -0,0 +1,261 @@
+package filestore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// jobEmitInterval limits how often progress events are sent for one job
+const jobEmitInterval = 250 * time.Millisecond
+
+// Finished jobs, with their results, are kept for finishedJobTTL so the
+// frontend can collect them, and at most maxFinishedJobs of them at a time
+const (
+	finishedJobTTL  = 30 * time.Minute
+	maxFinishedJobs = 50
+)
+
+// job tracks a long operation running in the background. Operations report
+// through a *job that may be nil when they are called directly.
+type job struct {
+	mu       sync.Mutex
+	status   JobStatus
+	started  time.Time
+	finished time.Time // zero while running
+	lastEmit time.Time
+	emit     func(eventName string, optionalData ...interface{})
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// startJob runs fn in the background and returns the new job's ID
+func (s *service) startJob(kind string, fn func(ctx context.Context, j *job) (interface{}, error)) string {
+	ctx, cancel := context.WithCancel(s.ctx)
+	now := time.Now()
+	j := &job{
+		status: JobStatus{
+			ID:         uuid.New().String(),
+			Kind:       kind,
+			State:      JobRunning,
+			ETASeconds: -1,
+			StartedAt:  now.UTC().Format(time.RFC3339),
+			Errors:     []JobItemError{},
+		},
+		started: now,
+		emit:    s.emit,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	s.jobsMu.Lock()
+	if s.jobs == nil {
+		s.jobs = make(map[string]*job)
+	}
+	s.pruneJobs(now)
+	s.jobs[j.status.ID] = j
+	s.jobOrder = append(s.jobOrder, j.status.ID)
+	s.jobsMu.Unlock()
+
+	fmt.Printf("Started %s job %s\n", kind, j.status.ID)
+	j.emitProgress(true)
+
+	go func() {
+		defer close(j.done)
+		defer cancel()
+
+		result, err := fn(ctx, j)
+		j.finish(ctx, result, err)
+	}()
+
+	return j.status.ID
+}
+
+func (j *job) finish(ctx context.Context, result interface{}, err error) {
+	j.mu.Lock()
+	switch {
+	case ctx.Err() != nil:
+		j.status.State = JobCancelled
+		j.status.Result = result
+	case err != nil:
+		j.status.State = JobFailed
+		j.status.Error = err.Error()
+	default:
+		j.status.State = JobCompleted
+		j.status.Result = result
+	}
+	j.status.ETASeconds = 0
+	j.finished = time.Now()
+	j.status.FinishedAt = j.finished.UTC().Format(time.RFC3339)
+	fmt.Printf("Job %s %s: %d/%d items, %d errors\n", j.status.ID, j.status.State, j.status.ItemsDone, j.status.ItemsTotal, len(j.status.Errors))
+	j.mu.Unlock()
+
+	j.emitProgress(true)
+}
+
+// pruneJobs forgets finished jobs older than finishedJobTTL, and the oldest
+// finished jobs beyond maxFinishedJobs. Callers hold s.jobsMu.
+func (s *service) pruneJobs(now time.Time) {
+	finished := 0
+	for i := len(s.jobOrder) - 1; i >= 0; i-- {
+		if f := s.jobs[s.jobOrder[i]].finishedAt(); !f.IsZero() {
+			finished++
+			if now.Sub(f) > finishedJobTTL || finished > maxFinishedJobs {
+				delete(s.jobs, s.jobOrder[i])
+			}
+		}
+	}
+
+	order := s.jobOrder[:0]
+	for _, id := range s.jobOrder {
+		if _, ok := s.jobs[id]; ok {
+			order = append(order, id)
+		}
+	}
+	s.jobOrder = order
+}
+
+func (j *job) finishedAt() time.Time {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.finished
+}
+
+// setTotal records how much work the job has in items and bytes
+func (j *job) setTotal(items int, bytes int64) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	j.status.ItemsTotal = items
+	j.status.BytesTotal = bytes
+	j.mu.Unlock()
+	j.emitProgress(true)
+}
+
+// advance marks items as processed, whether they succeeded or failed
+func (j *job) advance(items int, bytes int64) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	j.status.ItemsDone += items
+	j.status.BytesDone += bytes
+	j.mu.Unlock()
+	j.emitProgress(false)
+}
+
+func (j *job) addError(item string, err error) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	j.status.Errors = append(j.status.Errors, JobItemError{Item: item, Error: err.Error()})
+	j.mu.Unlock()
+}
+
+// snapshot returns a copy of the job's status with the ETA estimated from the rate so far
+func (j *job) snapshot() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	status := j.status
+	status.Errors = append([]JobItemError{}, j.status.Errors...)
+
+	if status.State == JobRunning {
+		elapsed := time.Since(j.started).Seconds()
+		var done, total float64
+		if status.BytesTotal > 0 {
+			done, total = float64(status.BytesDone), float64(status.BytesTotal)
+		} else {
+			done, total = float64(status.ItemsDone), float64(status.ItemsTotal)
+		}
+		if done > 0 && total >= done {
+			status.ETASeconds = int64(elapsed * (total - done) / done)
+		}
+	}
+	return status
+}
+
+func (j *job) emitProgress(force bool) {
+	j.mu.Lock()
+	if !force && time.Since(j.lastEmit) < jobEmitInterval {
+		j.mu.Unlock()
+		return
+	}
+	j.lastEmit = time.Now()
+	j.mu.Unlock()
+
+	j.emit("job-progress", j.snapshot())
+}
+
+func (s *service) GetJob(id string) (*JobStatus, error) {
+	s.jobsMu.Lock()
+	j, ok := s.jobs[id]
+	s.jobsMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("job not found: %s", id)
+	}
+
+	status := j.snapshot()
+	return &status, nil
+}
+
+func (s *service) ListJobs() []JobStatus {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+
+	s.pruneJobs(time.Now())
+	jobs := make([]JobStatus, 0, len(s.jobOrder))
+	for _, id := range s.jobOrder {
+		jobs = append(jobs, s.jobs[id].snapshot())
+	}
+	return jobs
+}
+
+// CancelJob stops a running job after the item in progress. Work already
+// done is kept and reported in the job's result.
+func (s *service) CancelJob(id string) error {
+	s.jobsMu.Lock()
+	j, ok := s.jobs[id]
+	s.jobsMu.Unlock()
+	if !ok {
+		return fmt.Errorf("job not found: %s", id)
+	}
+
+	select {
+	case <-j.done:
+		return fmt.Errorf("job has already finished")
+	default:
+	}
+
+	j.cancel()
+	return nil
+}
+
+// StopJobs cancels all running jobs, waits for them to finish and forgets
+// every job along with its results
+func (s *service) StopJobs() error {
+	s.jobsMu.Lock()
+	jobs := make([]*job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	s.jobsMu.Unlock()
+
+	for _, j := range jobs {
+		j.cancel()
+	}
+	for _, j := range jobs {
+		<-j.done
+	}
+
+	s.jobsMu.Lock()
+	s.jobs = nil
+	s.jobOrder = nil
+	s.jobsMu.Unlock()
+	return nil
+}
@@ -0,0 +1,173 @@
+package filestore
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func waitForJob(t *testing.T, s *service, id string) JobStatus {
+	t.Helper()
+
+	s.jobsMu.Lock()
+	j := s.jobs[id]
+	s.jobsMu.Unlock()
+
+	select {
+	case <-j.done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Job %s did not finish", id)
+	}
+	status, _ := s.GetJob(id)
+	return *status
+}
+
+func TestExportJobReportsProgress(t *testing.T) {
+	s, folderID := setupTestService(t)
+	ids, _ := storeRandomFiles(t, s, folderID, []int{100, 200, 300})
+
+	var events []JobStatus
+	s.emit = func(name string, data ...interface{}) {
+		if name == "job-progress" {
+			events = append(events, data[0].(JobStatus))
+		}
+	}
+
+	if _, err := s.StartExportFiles(nil, ExportOptions{}); err == nil {
+		t.Error("Expected a job without files to be rejected up front")
+	}
+
+	id, err := s.StartExportFiles(append(ids, 9999), ExportOptions{Destination: t.TempDir()})
+	if err != nil {
+		t.Fatalf("StartExportFiles failed: %v", err)
+	}
+	status := waitForJob(t, s, id)
+
+	if status.State != JobCompleted || status.Kind != JobExportFiles {
+		t.Fatalf("Unexpected job status: %+v", status)
+	}
+	if status.ItemsDone != 4 || status.ItemsTotal != 4 || status.BytesDone != 600 || status.BytesTotal != 600 {
+		t.Errorf("Unexpected progress %d/%d items, %d/%d bytes", status.ItemsDone, status.ItemsTotal, status.BytesDone, status.BytesTotal)
+	}
+	if len(status.Errors) != 1 || status.Errors[0].Item != "ID 9999" {
+		t.Errorf("Expected the missing file to be reported, got %+v", status.Errors)
+	}
+	if report, ok := status.Result.(*ExportReport); !ok || len(report.Paths) != 3 {
+		t.Errorf("Unexpected job result: %+v", status.Result)
+	}
+
+	if len(events) < 2 || events[len(events)-1].State != JobCompleted {
+		t.Errorf("Expected progress events ending with completion, got %+v", events)
+	}
+	if jobs := s.ListJobs(); len(jobs) != 1 || jobs[0].ID != id {
+		t.Errorf("Unexpected job list: %+v", jobs)
+	}
+	if err := s.CancelJob(id); err == nil {
+		t.Error("Expected cancelling a finished job to be rejected")
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	s, _ := setupTestService(t)
+
+	started := make(chan struct{})
+	id := s.startJob(JobImport, func(ctx context.Context, j *job) (interface{}, error) {
+		j.setTotal(2, 0)
+		j.advance(1, 0)
+		close(started)
+		<-ctx.Done()
+		return &ImportResult{FileIDs: []int64{1}}, ctx.Err()
+	})
+	<-started
+
+	if status, _ := s.GetJob(id); status.State != JobRunning || status.ItemsDone != 1 {
+		t.Errorf("Unexpected running status: %+v", status)
+	}
+	if err := s.CancelJob(id); err != nil {
+		t.Fatalf("CancelJob failed: %v", err)
+	}
+
+	status := waitForJob(t, s, id)
+	if status.State != JobCancelled || status.Error != "" {
+		t.Errorf("Unexpected cancelled status: %+v", status)
+	}
+	if result, ok := status.Result.(*ImportResult); !ok || len(result.FileIDs) != 1 {
+		t.Errorf("Expected the partial result to be kept, got %+v", status.Result)
+	}
+
+	// Locking the vault stops whatever is still running and forgets every job
+	running := s.startJob(JobImport, func(ctx context.Context, j *job) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	s.jobsMu.Lock()
+	j := s.jobs[running]
+	s.jobsMu.Unlock()
+	if err := s.StopJobs(); err != nil {
+		t.Fatalf("StopJobs failed: %v", err)
+	}
+	select {
+	case <-j.done:
+	default:
+		t.Error("Expected the running job to be stopped")
+	}
+	if jobs := s.ListJobs(); len(jobs) != 0 {
+		t.Errorf("Expected no jobs after StopJobs, got %+v", jobs)
+	}
+}
+
+func TestFinishedJobsArePruned(t *testing.T) {
+	s, _ := setupTestService(t)
+	done := func(ctx context.Context, j *job) (interface{}, error) { return nil, nil }
+
+	old := s.startJob(JobImport, done)
+	waitForJob(t, s, old)
+	s.jobsMu.Lock()
+	s.jobs[old].finished = time.Now().Add(-2 * finishedJobTTL)
+	s.jobsMu.Unlock()
+
+	var recent []string
+	for i := 0; i < maxFinishedJobs+1; i++ {
+		id := s.startJob(JobImport, done)
+		waitForJob(t, s, id)
+		recent = append(recent, id)
+	}
+
+	// The expired job and the oldest job beyond the limit are gone
+	if jobs := s.ListJobs(); len(jobs) != maxFinishedJobs || jobs[len(jobs)-1].ID != recent[maxFinishedJobs] {
+		t.Errorf("Expected the %d most recent jobs to be kept, got %d", maxFinishedJobs, len(jobs))
+	}
+	if _, err := s.GetJob(old); err == nil {
+		t.Error("Expected the expired job to be forgotten")
+	}
+	if _, err := s.GetJob(recent[0]); err == nil {
+		t.Error("Expected the oldest job beyond the limit to be forgotten")
+	}
+}
+
+func TestDeleteFoldersCancelled(t *testing.T) {
+	s, _ := setupTestService(t)
+	cases, _ := s.CreateFolder("Cases", 0)
+	_, contents := storeRandomFiles(t, s, cases.ID, []int{100, 200})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.deleteFolders(ctx, nil, []int64{cases.ID}); err != context.Canceled {
+		t.Fatalf("Expected cancellation, got %v", err)
+	}
+
+	// Nothing is wiped once the job is cancelled
+	response, err := s.GetFilesInFolder(cases.ID)
+	if err != nil || len(response.Files) != 2 {
+		t.Fatalf("Expected the folder and its files to remain: %v", err)
+	}
+	checkFileContents(t, s, contents)
+
+	id, _ := s.StartDeleteFolders([]int64{cases.ID})
+	if status := waitForJob(t, s, id); status.State != JobCompleted || status.ItemsDone != 2 || status.BytesDone != 300 {
+		t.Errorf("Unexpected delete job status: %+v", status)
+	}
+	if _, err := s.GetFilesInFolder(cases.ID); err == nil {
+		t.Error("Expected folder to be deleted")
+	}
+}
@@ -13,6 +13,20 @@ type FileInfo struct {
 	MimeType  string `json:"mimeType"`
 	Timestamp string `json:"timestamp"`
 	Size      int64  `json:"size"`
+	FolderID  int64  `json:"folderId"`
+	Blurhash  string `json:"blurhash,omitempty"`
+	SHA256    string `json:"sha256,omitempty"` // recorded when the file was stored
+	SHA512    string `json:"sha512,omitempty"`
+	Tags      []Tag  `json:"tags,omitempty"`
+}
+
+// Tag labels files and folders. The counts are only filled in by ListTags.
+type Tag struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Color       string `json:"color"` // #rrggbb
+	FileCount   int    `json:"fileCount,omitempty"`
+	FolderCount int    `json:"folderCount,omitempty"`
 }
 
 type FileMetadata struct {
@@ -28,8 +42,425 @@ type FileMetadata struct {
 }
 
 type FolderInfo struct {
+	ID        int64        `json:"id"`
+	Name      string       `json:"name"`
+	ParentID  int64        `json:"parentId"` // 0 for top-level folders
+	Timestamp string       `json:"timestamp"`
+	FileCount int          `json:"fileCount"`
+	Tags      []Tag        `json:"tags,omitempty"`
+	Children  []FolderInfo `json:"children,omitempty"`
+}
+
+// FolderListOptions controls which folders GetStoredFolders returns
+type FolderListOptions struct {
+	IncludeEmpty bool    `json:"includeEmpty"` // also return folders without files
+	Tree         bool    `json:"tree"`         // nest subfolders under their parents
+	TagIDs       []int64 `json:"tagIds"`       // only folders carrying every one of these tags
+}
+
+// FolderPathEntry is one step of a folder's breadcrumb path
+type FolderPathEntry struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type CompactionStatus struct {
+	State        string  `json:"state"`
+	MovedExtents int64   `json:"movedExtents"`
+	MovedBytes   int64   `json:"movedBytes"`
+	FreeBytes    int64   `json:"freeBytes"`
+	VaultSize    int64   `json:"vaultSize"`
+	Progress     float64 `json:"progress"`
+}
+
+// ImportOptions controls how local files are brought into the vault
+type ImportOptions struct {
+	DeleteOriginals bool `json:"deleteOriginals"` // securely wipe originals once their import is verified
+}
+
+type ImportFailure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+type ImportResult struct {
+	FileIDs   []int64         `json:"fileIds"`
+	FolderIDs []int64         `json:"folderIds"`
+	Failed    []ImportFailure `json:"failed"`
+	Deleted   []string        `json:"deleted"`
+}
+
+// MIME categories accepted by FileQuery
+const (
+	CategoryImage    = "image"
+	CategoryVideo    = "video"
+	CategoryAudio    = "audio"
+	CategoryDocument = "document"
+	CategoryOther    = "other"
+)
+
+// Sort fields accepted by FileQuery
+const (
+	SortByName      = "name"
+	SortBySize      = "size"
+	SortByCreatedAt = "createdAt"
+	SortByMimeType  = "mimeType"
+)
+
+type FileSort struct {
+	Field      string `json:"field"`
+	Descending bool   `json:"descending"`
+}
+
+// FileQuery selects files across the vault. Zero values leave a filter unset.
+type FileQuery struct {
+	Search            string     `json:"search"`            // case-insensitive match anywhere in the name
+	FolderID          int64      `json:"folderId"`          // 0 searches every folder
+	IncludeSubfolders bool       `json:"includeSubfolders"` // with FolderID, also search its descendants
+	Categories        []string   `json:"categories"`
+	TagIDs            []int64    `json:"tagIds"` // only files carrying every one of these tags
+	MinSize           int64      `json:"minSize"`
+	MaxSize           int64      `json:"maxSize"`
+	CreatedAfter      string     `json:"createdAfter"`  // inclusive, RFC 3339 or YYYY-MM-DD
+	CreatedBefore     string     `json:"createdBefore"` // exclusive, RFC 3339 or YYYY-MM-DD
+	Sort              []FileSort `json:"sort"`          // defaults to newest first
+	Limit             int        `json:"limit"`
+	Cursor            string     `json:"cursor"` // NextCursor from the previous page
+}
+
+type FileQueryResult struct {
+	Files      []FileInfo `json:"files"`
+	NextCursor string     `json:"nextCursor"` // empty on the last page
+}
+
+type Thumbnail struct {
+	FileID   int64  `json:"fileId"`
+	Size     int    `json:"size"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	MimeType string `json:"mimeType"`
+	Data     []byte `json:"data"` // base64 in JSON
+}
+
+// ExportOptions controls what identifying information leaves the vault with exported files
+type ExportOptions struct {
+	StripMetadata    bool `json:"stripMetadata"`    // remove EXIF, XMP, document properties and similar metadata
+	KeepOrientation  bool `json:"keepOrientation"`  // with StripMetadata, keep the image orientation
+	KeepColorProfile bool `json:"keepColorProfile"` // with StripMetadata, keep embedded colour profiles
+	NeutralNames     bool `json:"neutralNames"`     // replace file and archive names with numbered ones
+	// Destination is an existing directory to export into, empty for the default export directory
+	Destination string `json:"destination"`
+}
+
+// ExportedFile reports the outcome of exporting one file
+type ExportedFile struct {
+	FileID       int64  `json:"fileId"`
+	Name         string `json:"name"`             // name in the vault
+	ExportedName string `json:"exportedName"`     // name on disk or inside the ZIP
+	Path         string `json:"path"`             // exported file or the ZIP containing it
+	SHA256       string `json:"sha256,omitempty"` // recorded when the file was stored
+	SHA512       string `json:"sha512,omitempty"`
+	// ExportedSHA256 is set when the exported bytes differ from the stored file, e.g. after scrubbing
+	ExportedSHA256 string   `json:"exportedSha256,omitempty"`
+	Format         string   `json:"format,omitempty"`
+	Removed        []string `json:"removed,omitempty"`
+	Kept           []string `json:"kept,omitempty"`
+	Warnings       []string `json:"warnings,omitempty"`
+	Error          string   `json:"error,omitempty"`
+}
+
+type ExportReport struct {
+	Paths []string       `json:"paths"`
+	Files []ExportedFile `json:"files"`
+}
+
+// Values of FileVerification.Status
+const (
+	VerificationOK         = "ok"
+	VerificationMismatch   = "mismatch"
+	VerificationUnrecorded = "unrecorded" // stored before hashes were recorded
+	VerificationError      = "error"      // the file could not be read or decrypted
+)
+
+// FileVerification compares a file's current content with the hashes recorded when it was stored
+type FileVerification struct {
+	FileID         int64  `json:"fileId"`
+	Name           string `json:"name"`
+	Status         string `json:"status"`
+	ExpectedSHA256 string `json:"expectedSha256,omitempty"`
+	ExpectedSHA512 string `json:"expectedSha512,omitempty"`
+	ActualSHA256   string `json:"actualSha256,omitempty"`
+	ActualSHA512   string `json:"actualSha512,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// Values of TrashItem.Kind
+const (
+	TrashKindFile   = "file"
+	TrashKindFolder = "folder"
+)
+
+// TrashItem is a file or folder trashed on its own. Files and subfolders
+// trashed along with a folder are restored or wiped together with it.
+type TrashItem struct {
+	Kind      string `json:"kind"`
 	ID        int64  `json:"id"`
 	Name      string `json:"name"`
-	Timestamp string `json:"timestamp"`
+	ParentID  int64  `json:"parentId"`  // folder the item is restored into, 0 for the top level
+	Size      int64  `json:"size"`      // for folders, the total size of their files
+	FileCount int    `json:"fileCount"` // for folders, the number of files trashed with them
+	TrashedAt string `json:"trashedAt"`
+}
+
+// Values of ExportRecord.Status and ExportWipe.Status
+const (
+	ExportWiped    = "wiped"
+	ExportMissing  = "missing"  // the copy was deleted or moved outside Tella
+	ExportModified = "modified" // the copy changed since it was exported and was left in place
+	ExportError    = "error"
+)
+
+// ExportRecord is a plaintext copy written outside the vault by an export
+type ExportRecord struct {
+	ID         int64                `json:"id"`
+	Path       string               `json:"path"`
+	SHA256     string               `json:"sha256"` // of the exported file or ZIP as written
+	Size       int64                `json:"size"`
+	ExportedAt string               `json:"exportedAt"`
+	ClosedAt   string               `json:"closedAt,omitempty"` // when the copy was wiped or found missing
+	Status     string               `json:"status,omitempty"`   // empty while the copy is outstanding
+	Files      []ExportRecordedFile `json:"files"`
+}
+
+type ExportRecordedFile struct {
+	FileID int64  `json:"fileId"`
+	Name   string `json:"name"` // name on disk or inside the ZIP
+}
+
+// ExportWipe reports the outcome of wiping one exported copy
+type ExportWipe struct {
+	ExportID int64  `json:"exportId"`
+	Path     string `json:"path"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Values of JobStatus.Kind
+const (
+	JobExportFiles   = "export-files"
+	JobExportZip     = "export-zip"
+	JobExportBag     = "export-bag"
+	JobImport        = "import"
+	JobDeleteFolders = "delete-folders"
+)
+
+// Values of JobStatus.State
+const (
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+type JobItemError struct {
+	Item  string `json:"item"` // file name, path or ID the error concerns
+	Error string `json:"error"`
+}
+
+// JobStatus is a snapshot of a background job, also sent with job-progress events
+type JobStatus struct {
+	ID         string         `json:"id"`
+	Kind       string         `json:"kind"`
+	State      string         `json:"state"`
+	ItemsDone  int            `json:"itemsDone"`
+	ItemsTotal int            `json:"itemsTotal"`
+	BytesDone  int64          `json:"bytesDone"`
+	BytesTotal int64          `json:"bytesTotal"`
+	ETASeconds int64          `json:"etaSeconds"` // -1 while unknown
+	StartedAt  string         `json:"startedAt"`
+	FinishedAt string         `json:"finishedAt,omitempty"`
+	Errors     []JobItemError `json:"errors"`
+	Error      string         `json:"error,omitempty"` // why the job as a whole failed
+	// Result holds the operation's return value once finished: *ExportReport,
+	// *ImportResult or nil. Cancelled jobs keep the result of the work done so far.
+	Result interface{} `json:"result,omitempty"`
+}
+
+// BagOptions controls how a BagIt (RFC 8493) export is packaged and described
+type BagOptions struct {
+	Zip bool `json:"zip"` // a ZIP holding the bag directory instead of a plain directory
+	// Optional bag-info.txt fields
+	SourceOrganization  string `json:"sourceOrganization"`
+	ContactName         string `json:"contactName"`
+	ContactEmail        string `json:"contactEmail"`
+	ExternalIdentifier  string `json:"externalIdentifier"`
+	ExternalDescription string `json:"externalDescription"`
+}
+
+// BagValidation reports whether a bag is complete and all its checksums match
+type BagValidation struct {
+	Valid      bool     `json:"valid"`
+	Version    string   `json:"version"`
+	Files      int      `json:"files"`
+	Bytes      int64    `json:"bytes"`
+	Algorithms []string `json:"algorithms"`
+	Errors     []string `json:"errors"`
+}
+
+// AuditEntry is one event in the chain-of-custody log, see auditutils.Entry
+type AuditEntry struct {
+	ID          int64  `json:"id"`
+	Event       string `json:"event"` // received, imported, stored, copied, created, viewed, exported, moved, renamed, trashed, restored, deleted or wiped
+	Actor       string `json:"actor"`
+	FileID      int64  `json:"fileId"`
+	FolderID    int64  `json:"folderId"`
+	ContentHash string `json:"contentHash"` // SHA-256 of the file's plaintext, when known
+	Details     string `json:"details"`
+	CreatedAt   string `json:"createdAt"`
+	PrevHash    string `json:"prevHash"`
+	Hash        string `json:"hash"`
+}
+
+// AuditVerification reports whether the audit log's hash chain is intact
+type AuditVerification struct {
+	Valid   bool     `json:"valid"`
+	Entries int      `json:"entries"`
+	Head    string   `json:"head"` // hash of the last entry
+	Errors  []string `json:"errors"`
+}
+
+// Formats accepted by ExportAuditLog
+const (
+	AuditFormatJSON = "json"
+	AuditFormatCSV  = "csv"
+)
+
+// VaultStats describes how the TVault and the disk holding it are used
+type VaultStats struct {
+	VaultSize      int64 `json:"vaultSize"`      // size of the TVault file
+	LiveBytes      int64 `json:"liveBytes"`      // encrypted bytes of files and thumbnails still in use
+	ThumbnailBytes int64 `json:"thumbnailBytes"` // part of LiveBytes taken by thumbnails
+	FreeBytes      int64 `json:"freeBytes"`      // unused regions inside the TVault, reclaimed by compaction
+	FreeRegions    int   `json:"freeRegions"`
+	LargestFree    int64 `json:"largestFree"`
+	// Fragmentation is the share of free bytes outside the largest free
+	// region, from 0 when free space is contiguous to nearly 1
+	Fragmentation float64 `json:"fragmentation"`
+
+	FileCount    int   `json:"fileCount"` // files outside the trash
+	FolderCount  int   `json:"folderCount"`
+	FileBytes    int64 `json:"fileBytes"` // plaintext size of files outside the trash
+	TrashedCount int   `json:"trashedCount"`
+	TrashedBytes int64 `json:"trashedBytes"`
+
+	Folders    []FolderUsage   `json:"folders"`    // largest first
+	Categories []CategoryUsage `json:"categories"` // in the order of the Category constants
+	Disk       *DiskSpaceCheck `json:"disk"`       // nil when the disk could not be queried
+}
+
+// FolderUsage counts the files directly inside a folder, not its subfolders
+type FolderUsage struct {
+	FolderID  int64  `json:"folderId"`
+	Name      string `json:"name"`
 	FileCount int    `json:"fileCount"`
+	Bytes     int64  `json:"bytes"`
+}
+
+type CategoryUsage struct {
+	Category  string `json:"category"`
+	FileCount int    `json:"fileCount"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// StorageThresholds set when the disk holding the vault counts as nearly
+// full. Either limit being crossed raises a warning; 0 disables it.
+type StorageThresholds struct {
+	MinFreeBytes   int64 `json:"minFreeBytes"`
+	MinFreePercent int   `json:"minFreePercent"`
+}
+
+// CompressionSettings control whether compressible files are compressed
+// before encryption, and how the result is padded to hide the ratio. Padding
+// is one of "none", "padme" or "power2".
+type CompressionSettings struct {
+	Enabled bool   `json:"enabled"`
+	Padding string `json:"padding"`
+}
+
+// DiskSpaceCheck reports the free space on the vault's disk, and what would be
+// left after storing IncomingBytes
+type DiskSpaceCheck struct {
+	FreeBytes     int64 `json:"freeBytes"`
+	TotalBytes    int64 `json:"totalBytes"`
+	IncomingBytes int64 `json:"incomingBytes"`
+	FreeAfter     int64 `json:"freeAfter"`
+	Low           bool  `json:"low"`          // FreeAfter is below a threshold
+	Insufficient  bool  `json:"insufficient"` // the incoming data does not fit at all
+}
+
+// RetentionRule deletes files once they have been in the vault for Days days
+type RetentionRule struct {
+	ID                int64  `json:"id"`
+	Name              string `json:"name"`
+	FolderID          int64  `json:"folderId"`          // 0 applies the rule to every folder
+	IncludeSubfolders bool   `json:"includeSubfolders"` // with FolderID, also its descendants
+	ReceivedOnly      bool   `json:"receivedOnly"`      // only files received from nearby devices
+	Days              int    `json:"days"`
+	Enabled           bool   `json:"enabled"`
+	CreatedAt         string `json:"createdAt"`
+	LastRunAt         string `json:"lastRunAt,omitempty"`
+}
+
+// RetentionMatch is a file a retention rule deletes, or would delete in a dry run
+type RetentionMatch struct {
+	RuleID   int64  `json:"ruleId"`
+	RuleName string `json:"ruleName"`
+	FileID   int64  `json:"fileId"`
+	FileName string `json:"fileName"`
+	FolderID int64  `json:"folderId"`
+	Size     int64  `json:"size"`
+	StoredAt string `json:"storedAt"`
+}
+
+// RetentionLogEntry records a file deleted by a retention rule
+type RetentionLogEntry struct {
+	ID        int64  `json:"id"`
+	RuleID    int64  `json:"ruleId"`
+	RuleName  string `json:"ruleName"`
+	FileID    int64  `json:"fileId"`
+	FileName  string `json:"fileName"`
+	Size      int64  `json:"size"`
+	StoredAt  string `json:"storedAt"`
+	DeletedAt string `json:"deletedAt"`
+}
+
+// Verification statuses of a note. A note without a status is just a comment.
+const (
+	NoteUnverified = "unverified"
+	NoteVerified   = "verified"
+	NoteDisputed   = "disputed"
+)
+
+// Note is a free-text annotation on a file or a folder. Like everything else
+// in the vault, notes are kept in the encrypted database.
+type Note struct {
+	ID        int64  `json:"id"`
+	FileID    int64  `json:"fileId,omitempty"`   // set for notes on a file
+	FolderID  int64  `json:"folderId,omitempty"` // set for notes on a folder
+	Author    string `json:"author"`
+	Body      string `json:"body"`
+	Status    string `json:"status,omitempty"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+	Revisions int    `json:"revisions"` // earlier versions kept in the history
+}
+
+// NoteRevision is an earlier version of a note, kept when the note was edited
+type NoteRevision struct {
+	ID        int64  `json:"id"`
+	NoteID    int64  `json:"noteId"`
+	Author    string `json:"author"`
+	Body      string `json:"body"`
+	Status    string `json:"status,omitempty"`
+	WrittenAt string `json:"writtenAt"`
 }
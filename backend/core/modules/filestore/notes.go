@@ -0,0 +1,304 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// maxNoteLength bounds a note's body in bytes
+const maxNoteLength = 64 * 1024
+
+// noteColumns are selected by every note query, in the order queryNotes scans them
+const noteColumns = `n.id, COALESCE(n.file_id, 0), COALESCE(n.folder_id, 0), n.author, n.body, n.status, n.created_at, n.updated_at,
+	(SELECT COUNT(*) FROM note_revisions r WHERE r.note_id = n.id)`
+
+func (s *service) AddFileNote(fileID int64, author string, body string, status string) (*Note, error) {
+	var exists int
+	err := s.db.QueryRow("SELECT 1 FROM files WHERE id = ? AND is_deleted = 0", fileID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("file not found with ID: %d", fileID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up file: %w", err)
+	}
+	return s.addNote("file_id", fileID, author, body, status)
+}
+
+func (s *service) AddFolderNote(folderID int64, author string, body string, status string) (*Note, error) {
+	var exists int
+	err := s.db.QueryRow("SELECT 1 FROM folders WHERE id = ?", folderID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("folder not found with ID: %d", folderID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up folder: %w", err)
+	}
+	return s.addNote("folder_id", folderID, author, body, status)
+}
+
+func (s *service) addNote(column string, id int64, author string, body string, status string) (*Note, error) {
+	author, body, err := validateNote(author, body, status)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec(
+		fmt.Sprintf("INSERT INTO notes (%s, author, body, search_body, status) VALUES (?, ?, ?, ?, ?)", column),
+		id, author, body, noteSearchText(author, body), status,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add note: %w", err)
+	}
+	noteID, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get note ID: %w", err)
+	}
+	return s.getNote(noteID)
+}
+
+// UpdateNote edits a note, keeping the version it replaces in the history
+func (s *service) UpdateNote(id int64, author string, body string, status string) (*Note, error) {
+	author, body, err := validateNote(author, body, status)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO note_revisions (note_id, author, body, status, written_at)
+		SELECT id, author, body, status, updated_at FROM notes WHERE id = ?
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to keep note history: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return nil, fmt.Errorf("note not found with ID: %d", id)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE notes SET author = ?, body = ?, search_body = ?, status = ?, updated_at = datetime('now')
+		WHERE id = ?
+	`, author, body, noteSearchText(author, body), status, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update note: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return s.getNote(id)
+}
+
+// DeleteNote removes a note and its history
+func (s *service) DeleteNote(id int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM note_revisions WHERE note_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete note history: %w", err)
+	}
+	result, err := tx.Exec("DELETE FROM notes WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete note: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return fmt.Errorf("note not found with ID: %d", id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetFileNotes returns the notes on a file, oldest first
+func (s *service) GetFileNotes(fileID int64) ([]Note, error) {
+	return s.queryNotes("WHERE n.file_id = ? ORDER BY n.created_at, n.id", fileID)
+}
+
+// GetFolderNotes returns the notes on a folder, oldest first
+func (s *service) GetFolderNotes(folderID int64) ([]Note, error) {
+	return s.queryNotes("WHERE n.folder_id = ? ORDER BY n.created_at, n.id", folderID)
+}
+
+// GetNoteHistory returns the earlier versions of a note, oldest first
+func (s *service) GetNoteHistory(id int64) ([]NoteRevision, error) {
+	rows, err := s.db.Query(`
+		SELECT id, note_id, author, body, status, written_at
+		FROM note_revisions
+		WHERE note_id = ?
+		ORDER BY id
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query note history: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []NoteRevision{}
+	for rows.Next() {
+		var revision NoteRevision
+		if err := rows.Scan(&revision.ID, &revision.NoteID, &revision.Author, &revision.Body, &revision.Status, &revision.WrittenAt); err != nil {
+			return nil, fmt.Errorf("failed to scan note revision: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating note history: %w", err)
+	}
+	return revisions, nil
+}
+
+// SearchNotes finds notes whose body or author contains query, ignoring case,
+// most recently updated first. Notes on trashed items are left out.
+func (s *service) SearchNotes(query string) ([]Note, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return []Note{}, nil
+	}
+
+	pattern := "%" + escapeLike(filestoreutils.SearchName(query)) + "%"
+	return s.queryNotes(`
+		WHERE n.search_body LIKE ? ESCAPE '\'
+			AND (n.file_id IS NULL OR n.file_id IN (SELECT id FROM files WHERE is_deleted = 0 AND trashed_at IS NULL))
+			AND (n.folder_id IS NULL OR n.folder_id IN (SELECT id FROM folders WHERE trashed_at IS NULL))
+		ORDER BY n.updated_at DESC, n.id DESC
+	`, pattern)
+}
+
+func (s *service) getNote(id int64) (*Note, error) {
+	notes, err := s.queryNotes("WHERE n.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(notes) == 0 {
+		return nil, fmt.Errorf("note not found with ID: %d", id)
+	}
+	return &notes[0], nil
+}
+
+func (s *service) queryNotes(filter string, args ...interface{}) ([]Note, error) {
+	rows, err := s.db.Query("SELECT "+noteColumns+" FROM notes n "+filter, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notes: %w", err)
+	}
+	defer rows.Close()
+
+	notes := []Note{}
+	for rows.Next() {
+		var note Note
+		if err := rows.Scan(&note.ID, &note.FileID, &note.FolderID, &note.Author, &note.Body, &note.Status, &note.CreatedAt, &note.UpdatedAt, &note.Revisions); err != nil {
+			return nil, fmt.Errorf("failed to scan note: %w", err)
+		}
+		notes = append(notes, note)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating notes: %w", err)
+	}
+	return notes, nil
+}
+
+// exportNotesOf returns the notes on files or folders, by ID, as exports write them
+func (s *service) exportNotesOf(column string, ids []int64) (map[int64][]filestoreutils.ExportNote, error) {
+	notes := make(map[int64][]filestoreutils.ExportNote)
+	if len(ids) == 0 {
+		return notes, nil
+	}
+
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT %s, author, body, status, created_at, updated_at
+		FROM notes
+		WHERE %s IN (%s)
+		ORDER BY created_at, id
+	`, column, column, placeholders(len(ids))), int64sToArgs(ids)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var note filestoreutils.ExportNote
+		if err := rows.Scan(&id, &note.Author, &note.Body, &note.Status, &note.CreatedAt, &note.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan note: %w", err)
+		}
+		notes[id] = append(notes[id], note)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating notes: %w", err)
+	}
+	return notes, nil
+}
+
+// exportNotesSidecar writes the notes of a file exported to exportPath next
+// to it and records the sidecar as an export. It returns the sidecar's path,
+// or "" when the file has no notes or they could not be written.
+func (s *service) exportNotesSidecar(fileID int64, exportPath string) string {
+	notes, err := s.exportNotesOf("file_id", []int64{fileID})
+	if err != nil {
+		fmt.Printf("Warning: Failed to read notes of file ID %d: %v\n", fileID, err)
+		return ""
+	}
+	sidecarPath, err := filestoreutils.WriteNotesSidecar(exportPath, notes[fileID])
+	if err != nil {
+		fmt.Printf("Warning: Failed to export notes of file ID %d: %v\n", fileID, err)
+		return ""
+	}
+	if sidecarPath == "" {
+		return ""
+	}
+
+	if err := s.recordExport(sidecarPath, "", nil); err != nil {
+		fmt.Printf("Warning: Failed to record export of '%s': %v\n", sidecarPath, err)
+	}
+	return sidecarPath
+}
+
+// removeNotes deletes the notes on files or folders, with their history
+func removeNotes(tx *sql.Tx, column string, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	in := placeholders(len(ids))
+	args := int64sToArgs(ids)
+	query := fmt.Sprintf("DELETE FROM note_revisions WHERE note_id IN (SELECT id FROM notes WHERE %s IN (%s))", column, in)
+	if _, err := tx.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to delete note history: %w", err)
+	}
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM notes WHERE %s IN (%s)", column, in), args...); err != nil {
+		return fmt.Errorf("failed to delete notes: %w", err)
+	}
+	return nil
+}
+
+// noteSearchText is what SearchNotes matches against: the case-folded author and body
+func noteSearchText(author string, body string) string {
+	return filestoreutils.SearchName(author + "\n" + body)
+}
+
+// validateNote trims the author and checks the body and status
+func validateNote(author string, body string, status string) (string, string, error) {
+	if strings.TrimSpace(body) == "" {
+		return "", "", fmt.Errorf("note cannot be empty")
+	}
+	if len(body) > maxNoteLength {
+		return "", "", fmt.Errorf("note is longer than %d bytes", maxNoteLength)
+	}
+
+	switch status {
+	case "", NoteUnverified, NoteVerified, NoteDisputed:
+	default:
+		return "", "", fmt.Errorf("unknown verification status: %s", status)
+	}
+	return strings.TrimSpace(author), body, nil
+}
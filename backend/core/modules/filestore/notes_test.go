@@ -0,0 +1,128 @@
+package filestore
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Tella-Desktop/backend/utils/filestoreutils"
+)
+
+func TestNotes(t *testing.T) {
+	s, folderID := setupTestService(t)
+	ids, _ := storeRandomFiles(t, s, folderID, []int{100, 200})
+
+	if _, err := s.AddFileNote(ids[0], "Ana", "  ", ""); err == nil {
+		t.Error("Expected an empty note to be rejected")
+	}
+	if _, err := s.AddFileNote(ids[0], "Ana", "Sent by a witness", "confirmed"); err == nil {
+		t.Error("Expected an unknown verification status to be rejected")
+	}
+	if _, err := s.AddFileNote(999, "Ana", "Missing", ""); err == nil {
+		t.Error("Expected a note on an unknown file to be rejected")
+	}
+
+	note, err := s.AddFileNote(ids[0], " Ana ", "Sent by a witness on the bridge", NoteUnverified)
+	if err != nil {
+		t.Fatalf("AddFileNote failed: %v", err)
+	}
+	if note.Author != "Ana" || note.FileID != ids[0] || note.Status != NoteUnverified || note.CreatedAt == "" {
+		t.Errorf("Unexpected note: %+v", note)
+	}
+	if _, err := s.AddFolderNote(folderID, "Sam", "Batch from the march", ""); err != nil {
+		t.Fatalf("AddFolderNote failed: %v", err)
+	}
+	s.AddFileNote(ids[1], "Sam", "Shows the BRIDGE from the north", "")
+
+	updated, err := s.UpdateNote(note.ID, "Sam", "Sent by a witness on the bridge, confirmed by a second source", NoteVerified)
+	if err != nil {
+		t.Fatalf("UpdateNote failed: %v", err)
+	}
+	if updated.Status != NoteVerified || updated.Revisions != 1 || updated.CreatedAt != note.CreatedAt {
+		t.Errorf("Unexpected updated note: %+v", updated)
+	}
+	history, err := s.GetNoteHistory(note.ID)
+	if err != nil {
+		t.Fatalf("GetNoteHistory failed: %v", err)
+	}
+	if len(history) != 1 || history[0].Author != "Ana" || history[0].Body != note.Body || history[0].Status != NoteUnverified {
+		t.Errorf("Expected the original version in the history, got %+v", history)
+	}
+
+	// Searching ignores case and covers authors, but not trashed files
+	if results, _ := s.SearchNotes("bridge"); len(results) != 2 {
+		t.Errorf("Expected two notes mentioning the bridge, got %+v", results)
+	}
+	if results, _ := s.SearchNotes("sam"); len(results) != 3 {
+		t.Errorf("Expected three notes by Sam, got %+v", results)
+	}
+	s.TrashFiles(ids[1:])
+	if results, _ := s.SearchNotes("bridge"); len(results) != 1 || results[0].ID != note.ID {
+		t.Errorf("Expected the trashed file's note to be left out, got %+v", results)
+	}
+
+	// Deleting a file takes its notes and their history with it
+	if err := s.DeleteFiles(ids[:1]); err != nil {
+		t.Fatalf("DeleteFiles failed: %v", err)
+	}
+	var remaining int
+	s.db.QueryRow("SELECT (SELECT COUNT(*) FROM notes WHERE file_id = ?) + (SELECT COUNT(*) FROM note_revisions)", ids[0]).Scan(&remaining)
+	if remaining != 0 {
+		t.Errorf("Expected the deleted file's notes to be removed, %d rows left", remaining)
+	}
+	if notes, _ := s.GetFolderNotes(folderID); len(notes) != 1 {
+		t.Errorf("Expected the folder note to remain, got %+v", notes)
+	}
+}
+
+func TestExportNotes(t *testing.T) {
+	s, folderID := setupTestService(t)
+	ids, _ := storeRandomFiles(t, s, folderID, []int{100, 200})
+	s.AddFileNote(ids[0], "Ana", "Sent by a witness", NoteVerified)
+	s.AddFolderNote(folderID, "Ana", "Batch from the march", "")
+
+	destination := t.TempDir()
+	report, err := s.ExportFiles(ids, ExportOptions{Destination: destination})
+	if err != nil {
+		t.Fatalf("ExportFiles failed: %v", err)
+	}
+	if len(report.Paths) != 3 || report.Paths[1] != report.Paths[0]+filestoreutils.ExportNotesSuffix {
+		t.Fatalf("Expected a sidecar next to the annotated file, got %v", report.Paths)
+	}
+	data, _ := os.ReadFile(report.Paths[1])
+	var notes []filestoreutils.ExportNote
+	if err := json.Unmarshal(data, &notes); err != nil || len(notes) != 1 || notes[0].Status != NoteVerified {
+		t.Errorf("Unexpected sidecar %s (%v)", data, err)
+	}
+	if records, _ := s.GetExports(); len(records) != 3 {
+		t.Errorf("Expected the sidecar to be recorded for wiping, got %d exports", len(records))
+	}
+
+	zipReport, err := s.ExportZipFolders([]int64{folderID}, nil, ExportOptions{Destination: destination})
+	if err != nil {
+		t.Fatalf("ExportZipFolders failed: %v", err)
+	}
+	reader, err := zip.OpenReader(zipReport.Paths[0])
+	if err != nil {
+		t.Fatalf("Failed to open ZIP: %v", err)
+	}
+	defer reader.Close()
+	names := make(map[string]bool)
+	for _, file := range reader.File {
+		names[file.Name] = true
+	}
+	if !names[filepath.Base(report.Paths[0])+filestoreutils.ExportNotesSuffix] || !names[filestoreutils.ExportFolderNotesFile] || len(names) != 4 {
+		t.Errorf("Expected file and folder notes in the ZIP, got %v", names)
+	}
+
+	// Neutral exports leave notes out
+	neutral, err := s.ExportFiles(ids[:1], ExportOptions{Destination: t.TempDir(), NeutralNames: true})
+	if err != nil {
+		t.Fatalf("ExportFiles failed: %v", err)
+	}
+	if len(neutral.Paths) != 1 {
+		t.Errorf("Expected no sidecar in a neutral export, got %v", neutral.Paths)
+	}
+}
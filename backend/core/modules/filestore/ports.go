@@ -6,21 +6,250 @@ type Service interface {
 	// StoreFile encrypts and stores a file in TVault, returning its metadata
 	StoreFile(folderID int64, fileName string, mimeType string, reader io.Reader) (*FileMetadata, error)
 
-	// GetStoredFolders returns a list of folders with file counts
-	GetStoredFolders() ([]FolderInfo, error)
+	// ReceiveFile stores a file sent by a nearby device, recording sender in the audit log
+	ReceiveFile(folderID int64, fileName string, mimeType string, reader io.Reader, sender string) (*FileMetadata, error)
+
+	// ImportPaths stores local files and directories under folderID, recreating directories as nested folders
+	ImportPaths(paths []string, folderID int64, options ImportOptions) (*ImportResult, error)
+
+	// StartImportPaths runs ImportPaths as a background job and returns the job ID
+	StartImportPaths(paths []string, folderID int64, options ImportOptions) (string, error)
+
+	// OpenFile returns a seekable reader that decrypts a stored file on the fly
+	OpenFile(id int64) (io.ReadSeekCloser, error)
+
+	// OpenExternally decrypts a temporary copy of a file and opens it in the default application
+	OpenExternally(id int64) (string, error)
+
+	// WipeTempFiles securely deletes every temporary copy made by OpenExternally
+	WipeTempFiles() error
+
+	// ViewFile returns a file's metadata for previewing it and logs that it was viewed
+	ViewFile(id int64) (*FileMetadata, error)
+
+	// GetStoredFolders returns folders with file counts, optionally including empty folders or nested as a tree
+	GetStoredFolders(options FolderListOptions) ([]FolderInfo, error)
+
+	// CreateFolder creates a folder inside parentID, or at the top level when parentID is 0
+	CreateFolder(name string, parentID int64) (*FolderInfo, error)
+
+	// RenameFolder changes the name of a folder
+	RenameFolder(id int64, name string) error
+
+	// MoveFolder moves a folder under a new parent, or to the top level when parentID is 0
+	MoveFolder(id int64, parentID int64) error
+
+	// ListChildFolders returns the direct subfolders of parentID, or top-level folders when parentID is 0
+	ListChildFolders(parentID int64) ([]FolderInfo, error)
+
+	// GetFolderPath returns the breadcrumb path from the top level down to the folder
+	GetFolderPath(id int64) ([]FolderPathEntry, error)
 
 	// GetFilesInFolder returns files in a specific folder
 	GetFilesInFolder(folderID int64) (*FilesInFolderResponse, error)
 
-	// ExportFile exports a file by its ID to the user's downloads directory
-	ExportFiles(ids []int64) ([]string, error)
+	// QueryFiles searches, filters and sorts files across the vault one page at a time
+	QueryFiles(query FileQuery) (*FileQueryResult, error)
+
+	// GetThumbnail returns the smallest stored thumbnail of an image at least size pixels on its longest edge
+	GetThumbnail(fileID int64, size int) (*Thumbnail, error)
+
+	// StartThumbnailBackfill generates missing thumbnails and blurhashes for stored images in the background
+	StartThumbnailBackfill() error
+
+	// StopThumbnailBackfill stops the backfill job after the current file
+	StopThumbnailBackfill() error
+
+	// VerifyFiles re-hashes decrypted files and compares them with the hashes recorded at store time
+	VerifyFiles(ids []int64) ([]FileVerification, error)
+
+	// ExportFiles exports files by ID to the chosen destination or the user's downloads directory
+	ExportFiles(ids []int64, options ExportOptions) (*ExportReport, error)
 
 	// ExportZipFolders exports files as ZIP archives
-	ExportZipFolders(folderIDs []int64, selectedFileIDs []int64) ([]string, error)
+	ExportZipFolders(folderIDs []int64, selectedFileIDs []int64, options ExportOptions) (*ExportReport, error)
+
+	// StartExportFiles and StartExportZipFolders run exports as background jobs and return the job ID
+	StartExportFiles(ids []int64, options ExportOptions) (string, error)
+	StartExportZipFolders(folderIDs []int64, selectedFileIDs []int64, options ExportOptions) (string, error)
+
+	// ExportBag exports folders as a single BagIt bag, as a directory or a ZIP
+	ExportBag(folderIDs []int64, selectedFileIDs []int64, options ExportOptions, bag BagOptions) (*ExportReport, error)
+
+	// StartExportBag runs ExportBag as a background job and returns the job ID
+	StartExportBag(folderIDs []int64, selectedFileIDs []int64, options ExportOptions, bag BagOptions) (string, error)
+
+	// ValidateBag checks that a BagIt bag directory or ZIP is complete and its checksums match
+	ValidateBag(bagPath string) (*BagValidation, error)
+
+	// GetExports lists the plaintext copies written by exports, newest first
+	GetExports() ([]ExportRecord, error)
+
+	// WipeExportedCopies securely deletes exported copies that are unchanged since
+	// export, all outstanding ones when no IDs are given
+	WipeExportedCopies(exportIDs []int64) ([]ExportWipe, error)
+
+	// RenameFile changes the name of a stored file
+	RenameFile(id int64, name string) error
+
+	// MoveFiles moves files into another folder
+	MoveFiles(ids []int64, folderID int64) error
+
+	// CopyFiles re-encrypts copies of files into a folder under new keys, returning the new IDs
+	CopyFiles(ids []int64, folderID int64) ([]int64, error)
+
+	// TrashFiles moves files to the trash, keeping their data until the trash is emptied
+	TrashFiles(ids []int64) error
+
+	// TrashFolders moves folders, their subfolders and files to the trash
+	TrashFolders(folderIDs []int64) error
+
+	// RestoreFiles brings trashed files back into their folders
+	RestoreFiles(ids []int64) error
+
+	// RestoreFolders brings trashed folders back along with everything trashed with them
+	RestoreFolders(folderIDs []int64) error
+
+	// GetTrash lists the items in the trash, most recently trashed first
+	GetTrash() ([]TrashItem, error)
+
+	// EmptyTrash securely deletes everything in the trash
+	EmptyTrash() error
+
+	// GetTrashRetention returns how many days items stay in the trash, 0 meaning until emptied
+	GetTrashRetention() (int, error)
+
+	// SetTrashRetention sets how many days items stay in the trash before being wiped
+	SetTrashRetention(days int) error
+
+	// StopTrashPurge stops wiping expired trash and applying retention rules in the background, before the vault is locked
+	StopTrashPurge() error
+
+	// CreateRetentionRule adds a rule deleting files a number of days after they were stored
+	CreateRetentionRule(rule RetentionRule) (*RetentionRule, error)
+
+	// UpdateRetentionRule changes a retention rule
+	UpdateRetentionRule(rule RetentionRule) error
+
+	// DeleteRetentionRule removes a retention rule, leaving its log entries
+	DeleteRetentionRule(id int64) error
+
+	// ListRetentionRules returns every retention rule in the order they were created
+	ListRetentionRules() ([]RetentionRule, error)
+
+	// PreviewRetentionRule lists the files a rule, saved or not, would delete now
+	PreviewRetentionRule(rule RetentionRule) ([]RetentionMatch, error)
+
+	// ApplyRetentionRules deletes the files matched by enabled rules, or with dryRun only lists them
+	ApplyRetentionRules(dryRun bool) ([]RetentionMatch, error)
+
+	// GetRetentionLog lists the files deleted by retention rules, most recent first
+	GetRetentionLog() ([]RetentionLogEntry, error)
+
+	// AddFileNote and AddFolderNote annotate a file or folder, with an optional verification status
+	AddFileNote(fileID int64, author string, body string, status string) (*Note, error)
+	AddFolderNote(folderID int64, author string, body string, status string) (*Note, error)
+
+	// UpdateNote edits a note, keeping the previous version in its history
+	UpdateNote(id int64, author string, body string, status string) (*Note, error)
+
+	// DeleteNote removes a note along with its history
+	DeleteNote(id int64) error
+
+	// GetFileNotes and GetFolderNotes return the notes on a file or folder, oldest first
+	GetFileNotes(fileID int64) ([]Note, error)
+	GetFolderNotes(folderID int64) ([]Note, error)
+
+	// GetNoteHistory returns the earlier versions of a note, oldest first
+	GetNoteHistory(id int64) ([]NoteRevision, error)
+
+	// SearchNotes finds notes by their text or author, ignoring case
+	SearchNotes(query string) ([]Note, error)
 
 	// DeleteFiles securely deletes files by their IDs
 	DeleteFiles(ids []int64) error
 
-	// DeleteFolders deletes folders and all their files by reusing DeleteFiles
+	// DeleteFolders deletes folders, their subfolders and all their files by reusing DeleteFiles
 	DeleteFolders(folderIDs []int64) error
+
+	// StartDeleteFolders runs DeleteFolders as a background job and returns the job ID
+	StartDeleteFolders(folderIDs []int64) (string, error)
+
+	// GetJob returns the progress or final result of a background job
+	GetJob(id string) (*JobStatus, error)
+
+	// ListJobs returns every job started since the vault was unlocked, oldest first
+	ListJobs() []JobStatus
+
+	// CancelJob stops a running job, keeping the work already done
+	CancelJob(id string) error
+
+	// StopJobs cancels running jobs, waits for them and forgets all jobs, before the vault is locked
+	StopJobs() error
+
+	// GetAuditLog returns the chain-of-custody log, only the entries about fileID unless it is 0
+	GetAuditLog(fileID int64) ([]AuditEntry, error)
+
+	// VerifyAuditLog checks that no audit log entry was changed, removed or inserted
+	VerifyAuditLog() (*AuditVerification, error)
+
+	// ExportAuditLog writes the audit log as signed JSON or CSV and returns its path
+	ExportAuditLog(format string, destination string) (string, error)
+
+	// GetVaultStats reports TVault usage and fragmentation, usage by folder and type, and free disk space
+	GetVaultStats() (*VaultStats, error)
+
+	// CheckDiskSpace reports whether incoming bytes fit on the vault's disk, emitting storage-warning when space runs low
+	CheckDiskSpace(incomingBytes int64) (*DiskSpaceCheck, error)
+
+	// CreateTag creates a tag; an empty colour gets the default one
+	CreateTag(name string, color string) (*Tag, error)
+
+	// ListTags returns every tag with the number of files and folders carrying it
+	ListTags() ([]Tag, error)
+
+	// RenameTag renames a tag
+	RenameTag(id int64, name string) error
+
+	// SetTagColor changes a tag's colour, given as #rrggbb
+	SetTagColor(id int64, color string) error
+
+	// DeleteTag removes a tag from every file and folder and deletes it
+	DeleteTag(id int64) error
+
+	// MergeTags moves everything tagged with the source tags to the target tag
+	MergeTags(sourceIDs []int64, targetID int64) error
+
+	// TagFiles adds a tag to files
+	TagFiles(fileIDs []int64, tagID int64) error
+
+	// UntagFiles removes a tag from files
+	UntagFiles(fileIDs []int64, tagID int64) error
+
+	// TagFolders adds a tag to folders
+	TagFolders(folderIDs []int64, tagID int64) error
+
+	// UntagFolders removes a tag from folders
+	UntagFolders(folderIDs []int64, tagID int64) error
+
+	// GetCompressionSettings returns how new files are compressed before encryption
+	GetCompressionSettings() (CompressionSettings, error)
+
+	// SetCompressionSettings changes how new files are compressed before encryption
+	SetCompressionSettings(settings CompressionSettings) error
+
+	// GetStorageThresholds returns the free space below which storage warnings are emitted
+	GetStorageThresholds() (StorageThresholds, error)
+
+	// SetStorageThresholds changes the free space below which storage warnings are emitted
+	SetStorageThresholds(thresholds StorageThresholds) error
+
+	// StartCompaction starts or resumes relocating files toward the start of the TVault
+	StartCompaction() error
+
+	// PauseCompaction stops compaction after the current move, keeping its progress
+	PauseCompaction() error
+
+	// GetCompactionStatus reports compaction progress and fragmentation
+	GetCompactionStatus() (*CompactionStatus, error)
 }
@@ -0,0 +1,354 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultQueryLimit = 50
+	maxQueryLimit     = 500
+)
+
+// sortColumns maps FileQuery sort fields to the indexed columns they order by
+var sortColumns = map[string]string{
+	SortByName:      "search_name",
+	SortBySize:      "size",
+	SortByCreatedAt: "created_at",
+	SortByMimeType:  "mime_type",
+}
+
+// documentMimeTypes are application/* types counted as documents; text/* always is
+var documentMimeTypes = []string{
+	"application/pdf",
+	"application/msword",
+	"application/vnd.ms-excel",
+	"application/vnd.ms-powerpoint",
+	"application/rtf",
+	"application/json",
+	"application/xml",
+}
+
+// queryCursor is the position after the last row of a page
+type queryCursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+	ID     int64    `json:"i"`
+}
+
+func (s *service) QueryFiles(query FileQuery) (*FileQueryResult, error) {
+	sorts := query.Sort
+	if len(sorts) == 0 {
+		sorts = []FileSort{{Field: SortByCreatedAt, Descending: true}}
+	}
+	for _, sort := range sorts {
+		if _, ok := sortColumns[sort.Field]; !ok {
+			return nil, fmt.Errorf("unknown sort field: %s", sort.Field)
+		}
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	if limit > maxQueryLimit {
+		limit = maxQueryLimit
+	}
+
+	where := []string{"is_deleted = 0", "trashed_at IS NULL"}
+	var args []interface{}
+
+	if query.Search != "" {
+		where = append(where, `search_name LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(filestoreutils.SearchName(query.Search))+"%")
+	}
+
+	if query.FolderID != 0 {
+		if query.IncludeSubfolders {
+			folderIDs, err := s.getFolderSubtrees([]int64{query.FolderID})
+			if err != nil {
+				return nil, err
+			}
+			where = append(where, "folder_id IN ("+placeholders(len(folderIDs))+")")
+			for _, id := range folderIDs {
+				args = append(args, id)
+			}
+		} else {
+			where = append(where, "folder_id = ?")
+			args = append(args, query.FolderID)
+		}
+	}
+
+	if len(query.Categories) > 0 {
+		var conditions []string
+		for _, category := range query.Categories {
+			condition, err := categoryCondition(category)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, condition)
+		}
+		where = append(where, "("+strings.Join(conditions, " OR ")+")")
+	}
+
+	if len(query.TagIDs) > 0 {
+		condition, tagArgs := tagCondition("id", "file_tags", "file_id", query.TagIDs)
+		where = append(where, condition)
+		args = append(args, tagArgs...)
+	}
+
+	if query.MinSize > 0 {
+		where = append(where, "size >= ?")
+		args = append(args, query.MinSize)
+	}
+	if query.MaxSize > 0 {
+		where = append(where, "size <= ?")
+		args = append(args, query.MaxSize)
+	}
+
+	if query.CreatedAfter != "" {
+		after, err := parseQueryTime(query.CreatedAfter)
+		if err != nil {
+			return nil, err
+		}
+		where = append(where, "created_at >= ?")
+		args = append(args, after)
+	}
+	if query.CreatedBefore != "" {
+		before, err := parseQueryTime(query.CreatedBefore)
+		if err != nil {
+			return nil, err
+		}
+		where = append(where, "created_at < ?")
+		args = append(args, before)
+	}
+
+	signature := sortSignature(sorts)
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor, signature, len(sorts))
+		if err != nil {
+			return nil, err
+		}
+		condition, cursorArgs := keysetCondition(sorts, cursor)
+		where = append(where, condition)
+		args = append(args, cursorArgs...)
+	}
+
+	var order []string
+	for _, sort := range sorts {
+		order = append(order, sortColumns[sort.Field]+direction(sort.Descending))
+	}
+	// id breaks ties so every row has a unique position for the cursor
+	order = append(order, "id"+direction(sorts[len(sorts)-1].Descending))
+
+	// Fetch one extra row to learn whether there is another page
+	sqlQuery := fmt.Sprintf(`
+		SELECT id, name, mime_type, created_at, size, folder_id, COALESCE(blurhash, ''), COALESCE(sha256, ''), COALESCE(sha512, ''), search_name, CAST(created_at AS TEXT)
+		FROM files
+		WHERE %s
+		ORDER BY %s
+		LIMIT ?
+	`, strings.Join(where, " AND "), strings.Join(order, ", "))
+	args = append(args, limit+1)
+
+	rows, err := s.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query files: %w", err)
+	}
+	defer rows.Close()
+
+	// The cursor keeps sort keys exactly as stored; the driver reformats
+	// created_at when scanning it through its declared TIMESTAMP type
+	result := &FileQueryResult{Files: []FileInfo{}}
+	var keys []rawSortKeys
+	for rows.Next() {
+		var file FileInfo
+		var key rawSortKeys
+		if err := rows.Scan(&file.ID, &file.Name, &file.MimeType, &file.Timestamp, &file.Size, &file.FolderID, &file.Blurhash, &file.SHA256, &file.SHA512, &key.searchName, &key.createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan file: %w", err)
+		}
+		result.Files = append(result.Files, file)
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating files: %w", err)
+	}
+	if err := s.attachFileTags(result.Files); err != nil {
+		return nil, err
+	}
+
+	if len(result.Files) > limit {
+		result.Files = result.Files[:limit]
+		last := result.Files[limit-1]
+
+		cursor := queryCursor{Sort: signature, ID: last.ID}
+		for _, sort := range sorts {
+			cursor.Values = append(cursor.Values, sortValue(sort.Field, last, keys[limit-1]))
+		}
+		result.NextCursor, err = encodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// keysetCondition selects rows that sort after the cursor. When all keys share
+// a direction a row-value comparison is used, which SQLite can answer from the
+// matching index; mixed directions expand to the equivalent OR chain.
+func keysetCondition(sorts []FileSort, cursor *queryCursor) (string, []interface{}) {
+	columns := make([]string, 0, len(sorts)+1)
+	values := make([]interface{}, 0, len(sorts)+1)
+	descending := make([]bool, 0, len(sorts)+1)
+	uniform := true
+
+	for i, sort := range sorts {
+		columns = append(columns, sortColumns[sort.Field])
+		values = append(values, cursorValue(sort.Field, cursor.Values[i]))
+		descending = append(descending, sort.Descending)
+		if sort.Descending != sorts[0].Descending {
+			uniform = false
+		}
+	}
+	columns = append(columns, "id")
+	values = append(values, cursor.ID)
+	descending = append(descending, sorts[len(sorts)-1].Descending)
+
+	if uniform {
+		return fmt.Sprintf("(%s) %s (%s)", strings.Join(columns, ", "), comparison(descending[0]), placeholders(len(columns))), values
+	}
+
+	var terms []string
+	var args []interface{}
+	for i := range columns {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, columns[j]+" = ?")
+			args = append(args, values[j])
+		}
+		parts = append(parts, columns[i]+" "+comparison(descending[i])+" ?")
+		args = append(args, values[i])
+		terms = append(terms, "("+strings.Join(parts, " AND ")+")")
+	}
+
+	return "(" + strings.Join(terms, " OR ") + ")", args
+}
+
+func categoryCondition(category string) (string, error) {
+	documents := "(mime_type LIKE 'text/%' OR mime_type IN ('" + strings.Join(documentMimeTypes, "', '") + "')" +
+		" OR mime_type LIKE 'application/vnd.openxmlformats-officedocument.%'" +
+		" OR mime_type LIKE 'application/vnd.oasis.opendocument.%')"
+
+	switch category {
+	case CategoryImage, CategoryVideo, CategoryAudio:
+		return "mime_type LIKE '" + category + "/%'", nil
+	case CategoryDocument:
+		return documents, nil
+	case CategoryOther:
+		return "NOT (mime_type LIKE 'image/%' OR mime_type LIKE 'video/%' OR mime_type LIKE 'audio/%' OR " + documents + ")", nil
+	default:
+		return "", fmt.Errorf("unknown file category: %s", category)
+	}
+}
+
+// parseQueryTime converts a date filter to the format of the created_at column
+func parseQueryTime(value string) (string, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t, err = time.Parse("2006-01-02", value)
+		if err != nil {
+			return "", fmt.Errorf("invalid date %q, expected RFC 3339 or YYYY-MM-DD", value)
+		}
+	}
+	return t.UTC().Format("2006-01-02 15:04:05"), nil
+}
+
+// rawSortKeys holds sort columns as stored in the database
+type rawSortKeys struct {
+	searchName string
+	createdAt  string
+}
+
+func sortValue(field string, file FileInfo, keys rawSortKeys) string {
+	switch field {
+	case SortByName:
+		return keys.searchName
+	case SortBySize:
+		return strconv.FormatInt(file.Size, 10)
+	case SortByCreatedAt:
+		return keys.createdAt
+	default:
+		return file.MimeType
+	}
+}
+
+func cursorValue(field string, value string) interface{} {
+	if field == SortBySize {
+		size, _ := strconv.ParseInt(value, 10, 64)
+		return size
+	}
+	return value
+}
+
+func sortSignature(sorts []FileSort) string {
+	parts := make([]string, len(sorts))
+	for i, sort := range sorts {
+		parts[i] = sort.Field + direction(sort.Descending)
+	}
+	return strings.Join(parts, ",")
+}
+
+func encodeCursor(cursor queryCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(encoded string, signature string, keys int) (*queryCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	var cursor queryCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || len(cursor.Values) != keys {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	if cursor.Sort != signature {
+		return nil, fmt.Errorf("cursor was created for a different sort order")
+	}
+
+	return &cursor, nil
+}
+
+func direction(descending bool) string {
+	if descending {
+		return " DESC"
+	}
+	return " ASC"
+}
+
+func comparison(descending bool) string {
+	if descending {
+		return "<"
+	}
+	return ">"
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+func escapeLike(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(value)
+}
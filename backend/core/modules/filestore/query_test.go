@@ -0,0 +1,116 @@
+package filestore
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func storeNamedFiles(t *testing.T, s *service, folderID int64, names []string, mimeTypes []string) {
+	t.Helper()
+
+	for i, name := range names {
+		data := bytes.Repeat([]byte{'x'}, (i*37)%11*100+1)
+		if _, err := s.StoreFile(folderID, name, mimeTypes[i%len(mimeTypes)], bytes.NewReader(data)); err != nil {
+			t.Fatalf("Failed to store file: %v", err)
+		}
+	}
+}
+
+func TestQueryFilesFilters(t *testing.T) {
+	s, folderID := setupTestService(t)
+	sub, _ := s.CreateFolder("Sub", folderID)
+
+	storeNamedFiles(t, s, folderID, []string{"Protest.JPG", "interview.mp3", "notes.txt"}, []string{"image/jpeg", "audio/mpeg", "text/plain"})
+	storeNamedFiles(t, s, sub.ID, []string{"ПРОТЕСТ.png", "100%_real.bin"}, []string{"image/png", "application/octet-stream"})
+
+	tests := []struct {
+		name  string
+		query FileQuery
+		want  int
+	}{
+		{"ascii case-insensitive", FileQuery{Search: "protest"}, 1},
+		{"unicode case-insensitive", FileQuery{Search: "протест"}, 1},
+		{"like wildcards are literal", FileQuery{Search: "100%"}, 1},
+		{"images", FileQuery{Categories: []string{CategoryImage}}, 2},
+		{"documents or audio", FileQuery{Categories: []string{CategoryDocument, CategoryAudio}}, 2},
+		{"other", FileQuery{Categories: []string{CategoryOther}}, 1},
+		{"folder only", FileQuery{FolderID: folderID}, 3},
+		{"folder and subfolders", FileQuery{FolderID: folderID, IncludeSubfolders: true}, 5},
+		{"created before epoch", FileQuery{CreatedBefore: "2000-01-01"}, 0},
+		{"created after epoch", FileQuery{CreatedAfter: "2000-01-01T00:00:00Z"}, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := s.QueryFiles(tt.query)
+			if err != nil {
+				t.Fatalf("QueryFiles failed: %v", err)
+			}
+			if len(result.Files) != tt.want {
+				t.Errorf("Got %d files, want %d: %+v", len(result.Files), tt.want, result.Files)
+			}
+		})
+	}
+
+	if _, err := s.QueryFiles(FileQuery{Sort: []FileSort{{Field: "offset"}}}); err == nil {
+		t.Error("Expected unknown sort field to be rejected")
+	}
+}
+
+func TestQueryFilesPagination(t *testing.T) {
+	s, folderID := setupTestService(t)
+
+	var names []string
+	for i := 0; i < 23; i++ {
+		names = append(names, fmt.Sprintf("file-%02d", i%7))
+	}
+	storeNamedFiles(t, s, folderID, names, []string{"text/plain"})
+
+	sortOrders := [][]FileSort{
+		{{Field: SortByName}},
+		{{Field: SortBySize, Descending: true}, {Field: SortByName}},
+		{{Field: SortByCreatedAt, Descending: true}},
+	}
+
+	for _, sorts := range sortOrders {
+		var paged []FileInfo
+		query := FileQuery{Sort: sorts, Limit: 4}
+		for page := 0; ; page++ {
+			result, err := s.QueryFiles(query)
+			if err != nil {
+				t.Fatalf("QueryFiles failed: %v", err)
+			}
+			paged = append(paged, result.Files...)
+			if result.NextCursor == "" {
+				break
+			}
+			if page > 10 {
+				t.Fatalf("Pagination did not terminate")
+			}
+			query.Cursor = result.NextCursor
+		}
+
+		all, _ := s.QueryFiles(FileQuery{Sort: sorts, Limit: maxQueryLimit})
+		if len(paged) != len(names) || len(all.Files) != len(names) {
+			t.Fatalf("Sort %v: paged %d files, unpaged %d, want %d", sorts, len(paged), len(all.Files), len(names))
+		}
+		for i := range paged {
+			if paged[i].ID != all.Files[i].ID {
+				t.Errorf("Sort %v: page order differs at %d", sorts, i)
+				break
+			}
+		}
+
+		if sorts[0].Field == SortByName && !sort.SliceIsSorted(paged, func(i, j int) bool { return paged[i].Name < paged[j].Name }) {
+			t.Errorf("Files not sorted by name")
+		}
+	}
+
+	// A cursor only continues the sort order it was created for
+	first, _ := s.QueryFiles(FileQuery{Limit: 2})
+	if _, err := s.QueryFiles(FileQuery{Limit: 2, Cursor: first.NextCursor, Sort: []FileSort{{Field: SortByName}}}); err == nil {
+		t.Error("Expected cursor from another sort order to be rejected")
+	}
+}
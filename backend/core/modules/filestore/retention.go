@@ -0,0 +1,301 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/auditutils"
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+func (s *service) CreateRetentionRule(rule RetentionRule) (*RetentionRule, error) {
+	if err := s.validateRetentionRule(&rule); err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec(`
+		INSERT INTO retention_rules (name, folder_id, include_subfolders, received_only, days, enabled)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, rule.Name, nullableFolderID(rule.FolderID), rule.IncludeSubfolders, rule.ReceivedOnly, rule.Days, rule.Enabled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create retention rule: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get retention rule ID: %w", err)
+	}
+
+	fmt.Printf("Created retention rule '%s' deleting files after %d days\n", rule.Name, rule.Days)
+	return s.getRetentionRule(id)
+}
+
+func (s *service) UpdateRetentionRule(rule RetentionRule) error {
+	if err := s.validateRetentionRule(&rule); err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(`
+		UPDATE retention_rules
+		SET name = ?, folder_id = ?, include_subfolders = ?, received_only = ?, days = ?, enabled = ?
+		WHERE id = ?
+	`, rule.Name, nullableFolderID(rule.FolderID), rule.IncludeSubfolders, rule.ReceivedOnly, rule.Days, rule.Enabled, rule.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update retention rule: %w", err)
+	}
+	return checkRetentionRuleUpdated(result, rule.ID)
+}
+
+func (s *service) DeleteRetentionRule(id int64) error {
+	result, err := s.db.Exec("DELETE FROM retention_rules WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete retention rule: %w", err)
+	}
+	return checkRetentionRuleUpdated(result, id)
+}
+
+func (s *service) ListRetentionRules() ([]RetentionRule, error) {
+	return s.queryRetentionRules("")
+}
+
+// PreviewRetentionRule lists the files a rule would delete if it ran now,
+// without saving or running it
+func (s *service) PreviewRetentionRule(rule RetentionRule) ([]RetentionMatch, error) {
+	if err := s.validateRetentionRule(&rule); err != nil {
+		return nil, err
+	}
+	return s.retentionMatches(rule)
+}
+
+// ApplyRetentionRules runs every enabled rule, securely deleting the files
+// they match and logging each one. With dryRun, nothing is deleted
+// and the files that would be are returned. A file matched by several rules
+// is counted against the first.
+func (s *service) ApplyRetentionRules(dryRun bool) ([]RetentionMatch, error) {
+	s.retentionMu.Lock()
+	defer s.retentionMu.Unlock()
+
+	rules, err := s.queryRetentionRules("WHERE enabled = 1")
+	if err != nil {
+		return nil, err
+	}
+
+	matched := []RetentionMatch{}
+	seen := make(map[int64]bool)
+	for _, rule := range rules {
+		matches, err := s.retentionMatches(rule)
+		if err != nil {
+			return nil, err
+		}
+
+		var ruleMatches []RetentionMatch
+		for _, match := range matches {
+			if !seen[match.FileID] {
+				seen[match.FileID] = true
+				ruleMatches = append(ruleMatches, match)
+			}
+		}
+
+		if !dryRun {
+			deleted, err := s.applyRetentionRule(rule, ruleMatches)
+			if err != nil {
+				return matched, err
+			}
+			ruleMatches = deleted
+		}
+		matched = append(matched, ruleMatches...)
+	}
+
+	if !dryRun && len(matched) > 0 {
+		fmt.Printf("Retention rules deleted %d files\n", len(matched))
+	}
+	return matched, nil
+}
+
+// applyRetentionRule deletes the files a rule matched and returns those it
+// deleted. The retention log is written in the deletion's transaction, and
+// files deleted since they were matched are skipped.
+func (s *service) applyRetentionRule(rule RetentionRule, matches []RetentionMatch) ([]RetentionMatch, error) {
+	deleted := []RetentionMatch{}
+	if len(matches) > 0 {
+		ids := make([]int64, len(matches))
+		for i, match := range matches {
+			ids[i] = match.FileID
+		}
+
+		origin := auditutils.Entry{Actor: "retention rule", Details: fmt.Sprintf("retention rule %d: %s", rule.ID, rule.Name)}
+		err := s.deleteFiles(ids, origin, func(tx *sql.Tx, files []filestoreutils.FileMetadata) error {
+			found := make(map[int64]bool, len(files))
+			for _, file := range files {
+				found[file.ID] = true
+			}
+			for _, match := range matches {
+				if !found[match.FileID] {
+					continue
+				}
+				_, err := tx.Exec(`
+					INSERT INTO retention_log (rule_id, rule_name, file_id, file_name, size, stored_at)
+					VALUES (?, ?, ?, ?, ?, ?)
+				`, rule.ID, rule.Name, match.FileID, match.FileName, match.Size, match.StoredAt)
+				if err != nil {
+					return fmt.Errorf("failed to log retention deletion: %w", err)
+				}
+				deleted = append(deleted, match)
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errNoFilesFound) {
+			return nil, fmt.Errorf("failed to apply retention rule '%s': %w", rule.Name, err)
+		}
+	}
+
+	if _, err := s.db.Exec("UPDATE retention_rules SET last_run_at = datetime('now') WHERE id = ?", rule.ID); err != nil {
+		return deleted, fmt.Errorf("failed to update retention rule: %w", err)
+	}
+	return deleted, nil
+}
+
+// GetRetentionLog lists the files deleted by retention rules, most recent first
+func (s *service) GetRetentionLog() ([]RetentionLogEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT id, rule_id, rule_name, file_id, file_name, size, stored_at, deleted_at
+		FROM retention_log
+		ORDER BY deleted_at DESC, id DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query retention log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []RetentionLogEntry{}
+	for rows.Next() {
+		var entry RetentionLogEntry
+		if err := rows.Scan(&entry.ID, &entry.RuleID, &entry.RuleName, &entry.FileID, &entry.FileName, &entry.Size, &entry.StoredAt, &entry.DeletedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan retention log entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating retention log: %w", err)
+	}
+	return entries, nil
+}
+
+// retentionMatches lists the live or trashed files older than the rule allows, oldest first
+func (s *service) retentionMatches(rule RetentionRule) ([]RetentionMatch, error) {
+	where := []string{"is_deleted = 0", "created_at <= datetime('now', ?)"}
+	args := []interface{}{fmt.Sprintf("-%d days", rule.Days)}
+
+	if rule.FolderID != 0 {
+		folderIDs := []int64{rule.FolderID}
+		if rule.IncludeSubfolders {
+			var err error
+			if folderIDs, err = s.getFolderSubtrees(folderIDs); err != nil {
+				return nil, err
+			}
+		}
+		where = append(where, "folder_id IN ("+placeholders(len(folderIDs))+")")
+		args = append(args, int64sToArgs(folderIDs)...)
+	}
+
+	if rule.ReceivedOnly {
+		where = append(where, "received = 1")
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, name, folder_id, size, created_at
+		FROM files
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY created_at, id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query files for retention: %w", err)
+	}
+	defer rows.Close()
+
+	var matches []RetentionMatch
+	for rows.Next() {
+		match := RetentionMatch{RuleID: rule.ID, RuleName: rule.Name}
+		if err := rows.Scan(&match.FileID, &match.FileName, &match.FolderID, &match.Size, &match.StoredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan file: %w", err)
+		}
+		matches = append(matches, match)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating files: %w", err)
+	}
+	return matches, nil
+}
+
+func (s *service) getRetentionRule(id int64) (*RetentionRule, error) {
+	rules, err := s.queryRetentionRules("WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("retention rule not found with ID: %d", id)
+	}
+	return &rules[0], nil
+}
+
+func (s *service) queryRetentionRules(filter string, args ...interface{}) ([]RetentionRule, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, COALESCE(folder_id, 0), include_subfolders, received_only, days, enabled, created_at, last_run_at
+		FROM retention_rules
+		`+filter+`
+		ORDER BY id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query retention rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []RetentionRule{}
+	for rows.Next() {
+		var rule RetentionRule
+		var lastRunAt sql.NullString
+		if err := rows.Scan(&rule.ID, &rule.Name, &rule.FolderID, &rule.IncludeSubfolders, &rule.ReceivedOnly, &rule.Days, &rule.Enabled, &rule.CreatedAt, &lastRunAt); err != nil {
+			return nil, fmt.Errorf("failed to scan retention rule: %w", err)
+		}
+		rule.LastRunAt = lastRunAt.String
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating retention rules: %w", err)
+	}
+	return rules, nil
+}
+
+// validateRetentionRule checks a rule and trims its name
+func (s *service) validateRetentionRule(rule *RetentionRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if rule.Name == "" {
+		return fmt.Errorf("retention rule name cannot be empty")
+	}
+	if rule.Days < 1 {
+		return fmt.Errorf("retention period must be at least one day")
+	}
+
+	if rule.FolderID != 0 {
+		var exists int
+		err := s.db.QueryRow("SELECT 1 FROM folders WHERE id = ?", rule.FolderID).Scan(&exists)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("folder not found with ID: %d", rule.FolderID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to look up folder: %w", err)
+		}
+	}
+	return nil
+}
+
+func checkRetentionRuleUpdated(result sql.Result, id int64) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check retention rule update: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("retention rule not found with ID: %d", id)
+	}
+	return nil
+}
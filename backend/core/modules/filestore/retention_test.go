@@ -0,0 +1,116 @@
+package filestore
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"Tella-Desktop/backend/utils/filestoreutils"
+)
+
+func TestRetentionRules(t *testing.T) {
+	s, folderID := setupTestService(t)
+	cases, _ := s.CreateFolder("Cases", 0)
+	nested, _ := s.CreateFolder("Nested", cases.ID)
+
+	old, _ := storeRandomFiles(t, s, cases.ID, []int{100, 200})
+	recent, _ := storeRandomFiles(t, s, nested.ID, []int{300})
+	received, err := s.ReceiveFile(folderID, "statement.txt", "text/plain", bytes.NewReader([]byte("statement")), "nearby device")
+	if err != nil {
+		t.Fatalf("ReceiveFile failed: %v", err)
+	}
+	imported, _ := storeRandomFiles(t, s, folderID, []int{50})
+
+	s.db.Exec("UPDATE files SET created_at = datetime('now', '-10 days') WHERE id IN (?, ?)", old[0], old[1])
+	s.db.Exec("UPDATE files SET created_at = datetime('now', '-40 days') WHERE id IN (?, ?)", received.ID, imported[0])
+	s.TrashFiles(old[1:])
+
+	if _, err := s.CreateRetentionRule(RetentionRule{Name: "Cases", FolderID: cases.ID, Days: 0}); err == nil {
+		t.Error("Expected a retention period of 0 days to be rejected")
+	}
+	if _, err := s.CreateRetentionRule(RetentionRule{Name: "Gone", FolderID: 999, Days: 7}); err == nil {
+		t.Error("Expected an unknown folder to be rejected")
+	}
+
+	casesRule, err := s.CreateRetentionRule(RetentionRule{Name: " Cases ", FolderID: cases.ID, IncludeSubfolders: true, Days: 7, Enabled: true})
+	if err != nil {
+		t.Fatalf("CreateRetentionRule failed: %v", err)
+	}
+	if casesRule.Name != "Cases" || casesRule.CreatedAt == "" || casesRule.LastRunAt != "" {
+		t.Errorf("Unexpected rule: %+v", casesRule)
+	}
+	if _, err := s.CreateRetentionRule(RetentionRule{Name: "Transfers", ReceivedOnly: true, Days: 30, Enabled: true}); err != nil {
+		t.Fatalf("CreateRetentionRule failed: %v", err)
+	}
+
+	// A draft rule can be previewed before it is saved
+	preview, err := s.PreviewRetentionRule(RetentionRule{Name: "Everything", Days: 5})
+	if err != nil {
+		t.Fatalf("PreviewRetentionRule failed: %v", err)
+	}
+	if len(preview) != 4 {
+		t.Errorf("Expected every old file in the preview, got %+v", preview)
+	}
+
+	// A dry run lists the trashed old file too, but neither the recent nor the imported one
+	dryRun, err := s.ApplyRetentionRules(true)
+	if err != nil {
+		t.Fatalf("ApplyRetentionRules dry run failed: %v", err)
+	}
+	if len(dryRun) != 3 || dryRun[0].FileID != old[0] || dryRun[1].FileID != old[1] || dryRun[2].FileID != received.ID {
+		t.Errorf("Unexpected dry run: %+v", dryRun)
+	}
+	if _, err := filestoreutils.GetFileMetadataByID(s.db, old[0]); err != nil {
+		t.Errorf("Expected a dry run to delete nothing: %v", err)
+	}
+
+	deleted, err := s.ApplyRetentionRules(false)
+	if err != nil {
+		t.Fatalf("ApplyRetentionRules failed: %v", err)
+	}
+	if len(deleted) != 3 {
+		t.Errorf("Expected 3 files to be deleted, got %+v", deleted)
+	}
+	for _, id := range []int64{old[0], old[1], received.ID} {
+		if _, err := filestoreutils.GetFileMetadataByID(s.db, id); err == nil {
+			t.Errorf("Expected file %d to be deleted", id)
+		}
+	}
+	for _, id := range []int64{recent[0], imported[0]} {
+		if _, err := filestoreutils.GetFileMetadataByID(s.db, id); err != nil {
+			t.Errorf("Expected file %d to be kept: %v", id, err)
+		}
+	}
+
+	// The audit log shows a rule deleted the files, not a user
+	audit, _ := s.GetAuditLog(received.ID)
+	if last := audit[len(audit)-1]; last.Event != "deleted" || last.Actor != "retention rule" || !strings.Contains(last.Details, "Transfers") {
+		t.Errorf("Expected the deletion to name the rule, got %+v", last)
+	}
+
+	// Matches deleted since they were found are skipped rather than failing the run
+	if again, err := s.applyRetentionRule(*casesRule, deleted[:2]); err != nil || len(again) != 0 {
+		t.Errorf("Expected already deleted files to be skipped, got %+v (%v)", again, err)
+	}
+
+	entries, err := s.GetRetentionLog()
+	if err != nil {
+		t.Fatalf("GetRetentionLog failed: %v", err)
+	}
+	if len(entries) != 3 || entries[0].FileName != "statement.txt" || entries[0].RuleName != "Transfers" || entries[2].RuleID != casesRule.ID {
+		t.Errorf("Unexpected retention log: %+v", entries)
+	}
+
+	rules, _ := s.ListRetentionRules()
+	if len(rules) != 2 || rules[0].LastRunAt == "" {
+		t.Errorf("Expected the rules to record when they ran, got %+v", rules)
+	}
+
+	// Deleting the folder takes its rule along
+	if err := s.DeleteFolders([]int64{cases.ID}); err != nil {
+		t.Fatalf("DeleteFolders failed: %v", err)
+	}
+	if rules, _ := s.ListRetentionRules(); len(rules) != 1 || rules[0].Name != "Transfers" {
+		t.Errorf("Expected only the transfers rule to remain, got %+v", rules)
+	}
+}
@@ -1,17 +1,22 @@
 package filestore
 
 import (
+	"Tella-Desktop/backend/utils/auditutils"
 	"Tella-Desktop/backend/utils/authutils"
 	"Tella-Desktop/backend/utils/filestoreutils"
+	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 type service struct {
@@ -19,106 +24,577 @@ type service struct {
 	db         *sql.DB
 	tvaultPath string
 	dbKey      []byte
+	mu         sync.Mutex // serialises writes to the TVault layout
+	emit       func(eventName string, optionalData ...interface{})
+
+	// A streamed store holds tailMu while it writes at the end of the TVault
+	// without s.mu. tailReserved is where it writes, 0 when none; guarded by s.mu.
+	tailMu       sync.Mutex
+	tailReserved int64
+
+	// Extents with open readers cannot be relocated by compaction
+	openMu       sync.Mutex
+	openCond     *sync.Cond
+	openExtents  map[int64]int
+	movingExtent int64
+
+	compactMu     sync.Mutex
+	compactCancel context.CancelFunc
+	compactDone   chan struct{}
+
+	backfillMu     sync.Mutex
+	backfillCancel context.CancelFunc
+	backfillDone   chan struct{}
+
+	purgeMu     sync.Mutex
+	purgeCancel context.CancelFunc
+	purgeDone   chan struct{}
+
+	// Retention rules run one at a time, whether scheduled or started by hand
+	retentionMu sync.Mutex
+
+	jobsMu   sync.Mutex
+	jobs     map[string]*job
+	jobOrder []string // job IDs in the order they were started
+
+	// Decrypted copies opened in other applications, wiped when their timer fires
+	tempDir      string
+	openExternal func(path string) error
+	tempMu       sync.Mutex
+	tempTimers   map[int64]*time.Timer
+}
+
+// vaultFile is a decrypting reader that owns its TVault handle
+type vaultFile struct {
+	io.ReadSeeker
+	tvault  *os.File
+	release func()
+	once    sync.Once
+}
+
+func (f *vaultFile) Close() error {
+	f.once.Do(f.release)
+	return f.tvault.Close()
 }
 
 func NewService(ctx context.Context, db *sql.DB, dbKey []byte) Service {
-	return &service{
+	s := &service{
 		ctx:        ctx,
 		db:         db,
 		tvaultPath: authutils.GetTVaultPath(),
 		dbKey:      dbKey,
+		emit: func(eventName string, optionalData ...interface{}) {
+			runtime.EventsEmit(ctx, eventName, optionalData...)
+		},
+		openExtents:  make(map[int64]int),
+		tempDir:      authutils.GetTempDir(),
+		openExternal: openWithDefaultApp,
+		tempTimers:   make(map[int64]*time.Timer),
+	}
+	s.openCond = sync.NewCond(&s.openMu)
+
+	// Copies left behind by a session that crashed
+	if err := s.WipeTempFiles(); err != nil {
+		fmt.Printf("Warning: Failed to wipe temporary copies: %v\n", err)
 	}
+
+	if err := filestoreutils.BackfillSearchNames(db); err != nil {
+		fmt.Printf("Warning: Failed to backfill file search names: %v\n", err)
+	}
+
+	// Finish or roll back a compaction step interrupted by a crash
+	if err := s.recoverCompaction(); err != nil {
+		fmt.Printf("Warning: Failed to recover interrupted compaction: %v\n", err)
+	}
+
+	s.startTrashPurge()
+
+	return s
 }
 
-// StoreFile encrypts and stores a file in TVault
+// storeSpoolLimit is the largest upload buffered in memory before storing.
+// Files that fit are placed in free space; larger ones are streamed to the end of the TVault.
+const storeSpoolLimit = 8 * 1024 * 1024
+
+// StoreFile encrypts and stores a file in TVault, then generates previews for images.
+// Content identical to a stored file shares that file's extent.
 func (s *service) StoreFile(folderID int64, fileName string, mimeType string, reader io.Reader) (*FileMetadata, error) {
-	// Begin Transaction
+	return s.storeWithPreviews(folderID, fileName, mimeType, reader, true, auditutils.Entry{Event: auditutils.EventStored})
+}
+
+// ReceiveFile stores a file sent by a nearby device, logging sender as the actor
+func (s *service) ReceiveFile(folderID int64, fileName string, mimeType string, reader io.Reader, sender string) (*FileMetadata, error) {
+	return s.storeWithPreviews(folderID, fileName, mimeType, reader, true, auditutils.Entry{Event: auditutils.EventReceived, Actor: sender})
+}
+
+// storeWithPreviews stores a file and logs origin, which sets the audit event, actor and details
+func (s *service) storeWithPreviews(folderID int64, fileName string, mimeType string, reader io.Reader, dedup bool, origin auditutils.Entry) (*FileMetadata, error) {
+	metadata, err := s.storeFile(folderID, fileName, mimeType, reader, dedup, origin)
+	if err != nil {
+		return nil, err
+	}
+
+	// A missing preview never fails the upload, the backfill job can retry it
+	if err := s.generatePreviews(metadata.ID, metadata.MimeType); err != nil {
+		fmt.Printf("Warning: Failed to generate previews for %s: %v\n", fileName, err)
+	}
+
+	return metadata, nil
+}
+
+func (s *service) storeFile(folderID int64, fileName string, mimeType string, reader io.Reader, dedup bool, origin auditutils.Entry) (*FileMetadata, error) {
+	// Buffer the head of the file to learn whether its final size is known up front
+	head, err := io.ReadAll(io.LimitReader(reader, storeSpoolLimit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file data: %w", err)
+	}
+	sizeKnown := len(head) <= storeSpoolLimit
+
+	// Forensic and deduplication hashes are computed over the plaintext as it streams in
+	hasher := filestoreutils.NewContentHasher(s.dbKey)
+	if sizeKnown {
+		hasher.Write(head)
+	}
+
+	// Compressible files are compressed before encryption. Files that fit in
+	// memory are only stored compressed if that saves space after padding.
+	padding, err := s.compressionFor(mimeType, head)
+	if err != nil {
+		return nil, err
+	}
+	compression := filestoreutils.CompressionNone
+	payload := head
+	if padding != "" {
+		compression = filestoreutils.CompressionDeflate
+		if sizeKnown {
+			packed, smaller, err := filestoreutils.CompressBuffer(head, padding)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compress file: %w", err)
+			}
+			payload = packed
+			if !smaller {
+				payload, compression = head, filestoreutils.CompressionNone
+			}
+		}
+	}
+
+	if !sizeKnown {
+		return s.storeStreamed(folderID, fileName, mimeType, io.MultiReader(bytes.NewReader(payload), reader), padding, compression, hasher, dedup, origin)
+	}
+
+	var metadata *FileMetadata
+	err = s.waitForTail(func() error {
+		var err error
+		metadata, err = s.storeBuffered(folderID, fileName, mimeType, payload, int64(len(head)), compression, hasher.Sum(), dedup, origin)
+		return err
+	})
+	return metadata, err
+}
+
+// storeBuffered stores a file held in memory, holding s.mu throughout
+func (s *service) storeBuffered(folderID int64, fileName string, mimeType string, payload []byte, size int64, compression string, hashes filestoreutils.ContentHashes, dedup bool, origin auditutils.Entry) (*FileMetadata, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	tx, err := s.db.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	// Generate UUID for the file
 	fileUUID := uuid.New().String()
 
-	// Read the entire file into memory
-	fileData, err := io.ReadAll(reader)
+	// Small files are hashed before writing, so a duplicate is never written at all
+	if dedup {
+		original, err := findDuplicate(tx, hashes.Keyed, size)
+		if err != nil {
+			return nil, err
+		}
+		if original != nil {
+			return s.storeDuplicate(tx, fileUUID, fileName, mimeType, folderID, original, hashes, origin)
+		}
+	}
+
+	tvault, err := os.OpenFile(s.tvaultPath, os.O_RDWR, 0600)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file data: %w", err)
+		return nil, fmt.Errorf("failed to open TVault: %w", err)
 	}
+	defer tvault.Close()
 
-	originalSize := int64(len(fileData))
-	fileKey := filestoreutils.GenerateFileKey(fileUUID, s.dbKey)
+	vaultInfo, err := tvault.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat TVault: %w", err)
+	}
 
-	encryptedData, err := authutils.EncryptData(fileData, fileKey)
+	offset, err := s.allocate(tx, filestoreutils.ChunkedLength(int64(len(payload))))
 	if err != nil {
-		return nil, fmt.Errorf("failed to encrypt file: %w", err)
+		return nil, err
 	}
 
-	encryptedSize := int64(len(encryptedData))
+	writer, err := filestoreutils.NewChunkWriter(tvault, offset, filestoreutils.GenerateFileKey(fileUUID, s.dbKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to write to TVault: %w", err)
+	}
+	if _, err := writer.Write(payload); err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		s.discardWrite(tvault, offset, writer.Length(), vaultInfo.Size())
+		return nil, fmt.Errorf("failed to write to TVault: %w", err)
+	}
 
-	// Find space in TVault to store the file
-	offset, err := filestoreutils.FindSpace(tx, encryptedSize, s.tvaultPath)
+	metadata, err := recordStore(tx, fileUUID, fileName, mimeType, folderID, offset, writer.Length(), size, compression, hashes, origin)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find space in TVault: %w", err)
+		s.discardWrite(tvault, offset, writer.Length(), vaultInfo.Size())
+		return nil, err
 	}
+	return metadata, nil
+}
+
+// storeStreamed stores a file of unknown size at the end of the TVault. The
+// end is reserved under s.mu and the data streamed without it, so a slow
+// sender does not hold up other work on the vault. s.mu is taken again to
+// record the file, or to discard what was written.
+func (s *service) storeStreamed(folderID int64, fileName string, mimeType string, source io.Reader, padding string, compression string, hasher *filestoreutils.ContentHasher, dedup bool, origin auditutils.Entry) (*FileMetadata, error) {
+	s.tailMu.Lock()
+	defer s.tailMu.Unlock()
 
-	// Open TVault file
 	tvault, err := os.OpenFile(s.tvaultPath, os.O_RDWR, 0600)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open TVault: %w", err)
 	}
 	defer tvault.Close()
 
-	// Write encrypted data to TVault
-	_, err = tvault.WriteAt(encryptedData, offset)
+	offset, vaultSize, err := s.reserveTail()
 	if err != nil {
-		return nil, fmt.Errorf("failed to write to TVault: %w", err)
+		return nil, err
+	}
+	abandon := func(length int64, err error) (*FileMetadata, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.abandonTail(tvault, offset, length, vaultSize)
+		return nil, err
 	}
 
-	// Insert file metadata into database
-	fileID, err := filestoreutils.InsertFileMetadata(tx, fileUUID, fileName, originalSize, mimeType, folderID, offset, encryptedSize)
+	// Encrypt and write the file chunk by chunk, hashing the plaintext as it streams in
+	fileUUID := uuid.New().String()
+	writer, err := filestoreutils.NewChunkWriter(tvault, offset, filestoreutils.GenerateFileKey(fileUUID, s.dbKey))
 	if err != nil {
-		return nil, fmt.Errorf("failed to insert file metadata: %w", err)
+		return abandon(0, fmt.Errorf("failed to write to TVault: %w", err))
+	}
+	source = io.TeeReader(source, hasher)
+	var sink io.Writer = writer
+	var compressor *filestoreutils.CompressWriter
+	if compression != filestoreutils.CompressionNone {
+		if compressor, err = filestoreutils.NewCompressWriter(writer, padding); err != nil {
+			return abandon(writer.Length(), fmt.Errorf("failed to compress file: %w", err))
+		}
+		sink = compressor
+	}
+	if _, err := io.Copy(sink, source); err != nil {
+		return abandon(writer.Length(), fmt.Errorf("failed to write to TVault: %w", err))
+	}
+	if compressor != nil {
+		if err := compressor.Close(); err != nil {
+			return abandon(writer.Length(), fmt.Errorf("failed to compress file: %w", err))
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return abandon(writer.Length(), fmt.Errorf("failed to write to TVault: %w", err))
+	}
+
+	// The TVault holds the compressed size, files.size the original one
+	originalSize := writer.Size()
+	if compressor != nil {
+		originalSize = compressor.Size()
+	}
+	length := writer.Length()
+	hashes := hasher.Sum()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.abandonTail(tvault, offset, length, vaultSize)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Streamed files are only known to be duplicates once written; drop the
+	// new copy and share the existing extent instead
+	if dedup {
+		original, err := findDuplicate(tx, hashes.Keyed, originalSize)
+		if err != nil {
+			tx.Rollback()
+			s.abandonTail(tvault, offset, length, vaultSize)
+			return nil, err
+		}
+		if original != nil {
+			tx.Rollback()
+			s.abandonTail(tvault, offset, length, vaultSize)
+
+			tx, err := s.db.Begin()
+			if err != nil {
+				return nil, fmt.Errorf("failed to begin transaction: %w", err)
+			}
+			defer tx.Rollback()
+			return s.storeDuplicate(tx, fileUUID, fileName, mimeType, folderID, original, hashes, origin)
+		}
 	}
 
-	// Commit transaction
+	metadata, err := recordStore(tx, fileUUID, fileName, mimeType, folderID, offset, length, originalSize, compression, hashes, origin)
+	if err != nil {
+		tx.Rollback()
+		s.abandonTail(tvault, offset, length, vaultSize)
+		return nil, err
+	}
+	s.releaseTail(offset)
+	return metadata, nil
+}
+
+// recordStore inserts a file written at offset, logs it and commits tx
+func recordStore(tx *sql.Tx, fileUUID, fileName, mimeType string, folderID, offset, length, size int64, compression string, hashes filestoreutils.ContentHashes, origin auditutils.Entry) (*FileMetadata, error) {
+	fileID, err := filestoreutils.InsertFileMetadata(tx, fileUUID, fileName, size, mimeType, folderID, offset, length, filestoreutils.CipherVersionChunked, compression, hashes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert file metadata: %w", err)
+	}
+	if err := appendStoreAudit(tx, origin, fileID, folderID, hashes); err != nil {
+		return nil, err
+	}
 	if err := tx.Commit(); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	// Return metadata
-	metadata := &FileMetadata{
+	fmt.Printf("Stored file %s (%s) at offset %d with size %d", fileName, fileUUID, offset, length)
+	return &FileMetadata{
 		ID:        fileID,
 		UUID:      fileUUID,
 		Name:      fileName,
-		Size:      originalSize,
+		Size:      size,
 		MimeType:  mimeType,
 		FolderID:  folderID,
 		Offset:    offset,
-		Length:    encryptedSize,
+		Length:    length,
 		CreatedAt: time.Now(),
+	}, nil
+}
+
+// errTailReserved is returned by allocate when the space it would hand out
+// lies past a streamed store that is still writing
+var errTailReserved = errors.New("the end of the TVault is reserved by a streamed store")
+
+// allocate reserves length bytes in the TVault within tx. Callers hold s.mu.
+func (s *service) allocate(tx *sql.Tx, length int64) (int64, error) {
+	offset, err := filestoreutils.AllocateSpace(tx, length)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find space in TVault: %w", err)
+	}
+	if s.tailReserved != 0 && offset >= s.tailReserved {
+		return 0, errTailReserved
+	}
+	return offset, nil
+}
+
+// waitForTail runs store, and runs it once more after the streamed store
+// holding the end of the TVault has finished if store needed space there
+func (s *service) waitForTail(store func() error) error {
+	err := store()
+	if err != errTailReserved {
+		return err
+	}
+
+	s.tailMu.Lock()
+	defer s.tailMu.Unlock()
+	return store()
+}
+
+// reserveTail hands the end of the TVault to a streamed store, which then
+// writes there without s.mu. The trailing free region is taken out of the
+// free list and the offset pinned, so nothing else is placed or trimmed past
+// it until releaseTail. It returns the offset and the TVault's current size.
+// Callers hold s.tailMu.
+func (s *service) reserveTail() (int64, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	offset, err := filestoreutils.AllocateTail(tx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to find space in TVault: %w", err)
+	}
+	vaultInfo, err := os.Stat(s.tvaultPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to stat TVault: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.tailReserved = offset
+	s.openMu.Lock()
+	s.openExtents[offset]++
+	s.openMu.Unlock()
+	return offset, vaultInfo.Size(), nil
+}
+
+// releaseTail ends a tail reservation. Callers hold s.mu.
+func (s *service) releaseTail(offset int64) {
+	s.tailReserved = 0
+	s.releaseExtent(offset)
+}
+
+// abandonTail discards what a streamed store wrote and ends its reservation.
+// The trailing region it took is trimmed away. Callers hold s.mu and no
+// open transaction.
+func (s *service) abandonTail(tvault *os.File, offset, length, vaultSize int64) {
+	s.discardWrite(tvault, offset, length, vaultSize)
+	s.releaseTail(offset)
+	if err := s.trimVault(); err != nil {
+		fmt.Printf("Warning: Failed to trim TVault: %v\n", err)
+	}
+}
+
+// discardWrite undoes a partially stored file: data written inside the old
+// TVault is wiped and anything appended past its old end is truncated
+func (s *service) discardWrite(tvault *os.File, offset, length, vaultSize int64) {
+	if wipeEnd := min(offset+length, vaultSize); wipeEnd > offset {
+		if err := filestoreutils.SecurelyOverwriteFileData(s.tvaultPath, offset, wipeEnd-offset); err != nil {
+			fmt.Printf("Warning: Failed to wipe data after failed store: %v\n", err)
+		}
+	}
+	if offset+length > vaultSize {
+		if err := tvault.Truncate(vaultSize); err != nil {
+			fmt.Printf("Warning: Failed to truncate TVault after failed store: %v\n", err)
+		}
+	}
+}
+
+// trimVault gives the free region at the end of the TVault back to the filesystem.
+// Callers hold s.mu and have already wiped the region. Nothing is trimmed
+// while a streamed store is writing at the end.
+func (s *service) trimVault() error {
+	if s.tailReserved != 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	end, err := filestoreutils.ReleaseTrailingFreeSpace(tx)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit trim transaction: %w", err)
+	}
+
+	return filestoreutils.TruncateVault(s.tvaultPath, end)
+}
+
+// OpenFile returns a reader that decrypts the file on the fly and supports seeking
+func (s *service) OpenFile(id int64) (io.ReadSeekCloser, error) {
+	metadata, err := s.acquireExtent(id)
+	if err != nil {
+		return nil, err
+	}
+	release := func() { s.releaseExtent(metadata.Offset) }
+
+	tvault, err := os.Open(s.tvaultPath)
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("failed to open TVault: %w", err)
 	}
 
-	fmt.Printf("Stored file %s (%s) at offset %d with size %d", fileName, fileUUID, offset, encryptedSize)
+	reader, err := filestoreutils.NewFileReader(tvault, metadata, s.dbKey)
+	if err != nil {
+		release()
+		tvault.Close()
+		return nil, err
+	}
+
+	return &vaultFile{ReadSeeker: reader, tvault: tvault, release: release}, nil
+}
+
+// acquireExtent looks up a file and marks its extent as being read
+func (s *service) acquireExtent(id int64) (*filestoreutils.FileMetadata, error) {
+	var metadata *filestoreutils.FileMetadata
+	_, err := s.pinExtent(func() (int64, error) {
+		var err error
+		metadata, err = filestoreutils.GetFileMetadataByID(s.db, id)
+		if err != nil {
+			return 0, err
+		}
+		return metadata.Offset, nil
+	})
+	if err != nil {
+		return nil, err
+	}
 	return metadata, nil
+}
+
+// pinExtent marks the extent found by lookup as being read, waiting for
+// compaction to finish if it is currently moving that extent. The lookup is
+// repeated after waiting since the move changes the offset.
+func (s *service) pinExtent(lookup func() (int64, error)) (int64, error) {
+	s.openMu.Lock()
+	defer s.openMu.Unlock()
 
+	for {
+		offset, err := lookup()
+		if err != nil {
+			return 0, err
+		}
+		if offset != s.movingExtent {
+			s.openExtents[offset]++
+			return offset, nil
+		}
+		s.openCond.Wait()
+	}
+}
+
+func (s *service) releaseExtent(offset int64) {
+	s.openMu.Lock()
+	defer s.openMu.Unlock()
+
+	s.openExtents[offset]--
+	if s.openExtents[offset] <= 0 {
+		delete(s.openExtents, offset)
+	}
 }
 
-func (s *service) GetStoredFolders() ([]FolderInfo, error) {
+func (s *service) GetStoredFolders(options FolderListOptions) ([]FolderInfo, error) {
+	// Folders whose parent is filtered out by tag are shown at the top of a tree
+	where := "f.trashed_at IS NULL"
+	var args []interface{}
+	if len(options.TagIDs) > 0 {
+		condition, tagArgs := tagCondition("f.id", "folder_tags", "folder_id", options.TagIDs)
+		where += " AND " + condition
+		args = tagArgs
+	}
+
 	rows, err := s.db.Query(`
 		SELECT 
 			f.id, 
 			f.name, 
+			COALESCE(f.parent_id, 0),
 			f.created_at,
 			COUNT(files.id) as file_count
 		FROM folders f
-		LEFT JOIN files ON f.id = files.folder_id AND files.is_deleted = 0
-		GROUP BY f.id, f.name, f.created_at
-		HAVING COUNT(files.id) > 0
-		ORDER BY f.created_at DESC
-	`)
+		LEFT JOIN files ON f.id = files.folder_id AND files.is_deleted = 0 AND files.trashed_at IS NULL
+		WHERE `+where+`
+		GROUP BY f.id, f.name, f.parent_id, f.created_at
+		ORDER BY f.created_at DESC, f.id DESC
+	`, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query folders: %w", err)
 	}
@@ -127,7 +603,7 @@ func (s *service) GetStoredFolders() ([]FolderInfo, error) {
 	var folders []FolderInfo
 	for rows.Next() {
 		var folder FolderInfo
-		if err := rows.Scan(&folder.ID, &folder.Name, &folder.Timestamp, &folder.FileCount); err != nil {
+		if err := rows.Scan(&folder.ID, &folder.Name, &folder.ParentID, &folder.Timestamp, &folder.FileCount); err != nil {
 			return nil, fmt.Errorf("failed to scan folder: %w", err)
 		}
 		folders = append(folders, folder)
@@ -136,13 +612,30 @@ func (s *service) GetStoredFolders() ([]FolderInfo, error) {
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating folders: %w", err)
 	}
+	if err := s.attachFolderTags(folders); err != nil {
+		return nil, err
+	}
+
+	if options.Tree {
+		return buildFolderTree(folders, options.IncludeEmpty), nil
+	}
+
+	if options.IncludeEmpty {
+		return folders, nil
+	}
 
-	return folders, nil
+	var nonEmpty []FolderInfo
+	for _, folder := range folders {
+		if folder.FileCount > 0 {
+			nonEmpty = append(nonEmpty, folder)
+		}
+	}
+	return nonEmpty, nil
 }
 
 func (s *service) GetFilesInFolder(folderID int64) (*FilesInFolderResponse, error) {
 	var folderName string
-	err := s.db.QueryRow("SELECT name FROM folders WHERE id = ?", folderID).Scan(&folderName)
+	err := s.db.QueryRow("SELECT name FROM folders WHERE id = ? AND trashed_at IS NULL", folderID).Scan(&folderName)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("folder not found with ID: %d", folderID)
@@ -151,9 +644,10 @@ func (s *service) GetFilesInFolder(folderID int64) (*FilesInFolderResponse, erro
 	}
 
 	rows, err := s.db.Query(`
-		SELECT id, name, mime_type, created_at, size 
+		SELECT id, name, mime_type, created_at, size, folder_id, COALESCE(blurhash, ''),
+			COALESCE(sha256, ''), COALESCE(sha512, '')
 		FROM files 
-		WHERE folder_id = ? AND is_deleted = 0 
+		WHERE folder_id = ? AND is_deleted = 0 AND trashed_at IS NULL
 		ORDER BY created_at DESC
 	`, folderID)
 	if err != nil {
@@ -164,7 +658,7 @@ func (s *service) GetFilesInFolder(folderID int64) (*FilesInFolderResponse, erro
 	var files []FileInfo
 	for rows.Next() {
 		var file FileInfo
-		if err := rows.Scan(&file.ID, &file.Name, &file.MimeType, &file.Timestamp, &file.Size); err != nil {
+		if err := rows.Scan(&file.ID, &file.Name, &file.MimeType, &file.Timestamp, &file.Size, &file.FolderID, &file.Blurhash, &file.SHA256, &file.SHA512); err != nil {
 			return nil, fmt.Errorf("failed to scan file: %w", err)
 		}
 		files = append(files, file)
@@ -173,6 +667,9 @@ func (s *service) GetFilesInFolder(folderID int64) (*FilesInFolderResponse, erro
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating files: %w", err)
 	}
+	if err := s.attachFileTags(files); err != nil {
+		return nil, err
+	}
 
 	return &FilesInFolderResponse{
 		FolderName: folderName,
@@ -180,7 +677,25 @@ func (s *service) GetFilesInFolder(folderID int64) (*FilesInFolderResponse, erro
 	}, nil
 }
 
-func (s *service) ExportFiles(ids []int64) ([]string, error) {
+func (s *service) ExportFiles(ids []int64, options ExportOptions) (*ExportReport, error) {
+	return s.exportFiles(s.ctx, nil, ids, options)
+}
+
+// StartExportFiles exports files in a background job and returns its ID
+func (s *service) StartExportFiles(ids []int64, options ExportOptions) (string, error) {
+	if len(ids) == 0 {
+		return "", fmt.Errorf("no file IDs provided")
+	}
+	if _, err := options.exportDir(); err != nil {
+		return "", err
+	}
+
+	return s.startJob(JobExportFiles, func(ctx context.Context, j *job) (interface{}, error) {
+		return s.exportFiles(ctx, j, ids, options)
+	}), nil
+}
+
+func (s *service) exportFiles(ctx context.Context, j *job, ids []int64, options ExportOptions) (*ExportReport, error) {
 	if len(ids) == 0 {
 		return nil, fmt.Errorf("no file IDs provided")
 	}
@@ -191,29 +706,46 @@ func (s *service) ExportFiles(ids []int64) ([]string, error) {
 		fmt.Printf("Exporting %d files in batch", len(ids))
 	}
 
-	var exportedPaths []string
+	report := &ExportReport{}
 	var failedFiles []string
 
 	// Get export directory once
-	exportDir := authutils.GetExportDir()
-
-	// Open TVault once for all operations
-	tvault, err := os.Open(s.tvaultPath)
+	exportDir, err := options.exportDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to open TVault: %w", err)
+		return nil, err
 	}
-	defer tvault.Close()
+	entryOptions := options.entryOptions()
+
+	sizes, total := s.fileSizes(ids)
+	j.setTotal(len(ids), total)
 
 	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
 		// Export each file individually
-		exportPath, err := filestoreutils.ExportSingleFile(s.db, s.dbKey, id, tvault, exportDir)
+		entry, err := filestoreutils.ExportSingleFile(s.db, s.OpenFile, id, exportDir, entryOptions(filestoreutils.FileInfo{ID: id}))
+		j.advance(1, sizes[id])
 		if err != nil {
 			fmt.Printf("Failed to export file ID %d: %v", id, err)
 			failedFiles = append(failedFiles, fmt.Sprintf("ID %d", id))
+			report.Files = append(report.Files, exportedFile(filestoreutils.ExportEntry{FileID: id, Err: err}))
+			j.addError(fmt.Sprintf("ID %d", id), err)
 			continue
 		}
 
-		exportedPaths = append(exportedPaths, exportPath)
+		exportPath := entry.Path
+		if err := s.recordExport(exportPath, entry.ExportedSHA256, []filestoreutils.ExportEntry{*entry}); err != nil {
+			fmt.Printf("Warning: Failed to record export of file ID %d: %v\n", id, err)
+		}
+		report.Paths = append(report.Paths, exportPath)
+		report.Files = append(report.Files, exportedFile(*entry))
+		if !options.NeutralNames {
+			if sidecarPath := s.exportNotesSidecar(id, exportPath); sidecarPath != "" {
+				report.Paths = append(report.Paths, sidecarPath)
+			}
+		}
 		if len(ids) == 1 {
 			fmt.Printf("File exported successfully to: %s", exportPath)
 		} else {
@@ -223,7 +755,7 @@ func (s *service) ExportFiles(ids []int64) ([]string, error) {
 
 	// Return results with error info if some files failed
 	if len(failedFiles) > 0 {
-		if len(exportedPaths) == 0 {
+		if len(report.Paths) == 0 {
 			return nil, fmt.Errorf("all files failed to export: %v", failedFiles)
 		}
 		fmt.Printf("Warning: Some files failed to export: %v", failedFiles)
@@ -232,32 +764,116 @@ func (s *service) ExportFiles(ids []int64) ([]string, error) {
 	if len(ids) == 1 {
 		fmt.Printf("Export completed successfully")
 	} else {
-		fmt.Printf("Batch export completed: %d/%d files exported successfully", len(exportedPaths), len(ids))
+		fmt.Printf("Batch export completed: %d/%d files exported successfully", len(report.Paths), len(ids))
 	}
 
-	return exportedPaths, nil
+	return report, nil
+}
+
+func (s *service) ExportZipFolders(folderIDs []int64, selectedFileIDs []int64, options ExportOptions) (*ExportReport, error) {
+	return s.exportZipFolders(s.ctx, nil, folderIDs, selectedFileIDs, options)
 }
 
-func (s *service) ExportZipFolders(folderIDs []int64, selectedFileIDs []int64) ([]string, error) {
+// StartExportZipFolders exports folders as ZIP archives in a background job and returns its ID
+func (s *service) StartExportZipFolders(folderIDs []int64, selectedFileIDs []int64, options ExportOptions) (string, error) {
 	if len(folderIDs) == 0 {
-		return nil, fmt.Errorf("no folder IDs provided")
+		return "", fmt.Errorf("no folder IDs provided")
+	}
+	if _, err := options.exportDir(); err != nil {
+		return "", err
 	}
 
-	var exportedPaths []string
-	exportDir := authutils.GetExportDir()
+	return s.startJob(JobExportZip, func(ctx context.Context, j *job) (interface{}, error) {
+		return s.exportZipFolders(ctx, j, folderIDs, selectedFileIDs, options)
+	}), nil
+}
 
-	// Open TVault once for all operations
-	tvault, err := os.Open(s.tvaultPath)
+func (s *service) exportZipFolders(ctx context.Context, j *job, folderIDs []int64, selectedFileIDs []int64, options ExportOptions) (*ExportReport, error) {
+	if len(folderIDs) == 0 {
+		return nil, fmt.Errorf("no folder IDs provided")
+	}
+
+	report := &ExportReport{}
+	exportDir, err := options.exportDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to open TVault: %w", err)
+		return nil, err
+	}
+	entryOptions := options.entryOptions()
+
+	// Collect every folder's files first so the job knows its total
+	zips, sizes := s.folderExports(j, folderIDs, selectedFileIDs)
+	if !options.NeutralNames {
+		if err := s.attachExportMetadata(zips); err != nil {
+			return nil, err
+		}
+	}
+	var totalBytes int64
+	for _, size := range sizes {
+		totalBytes += size
+	}
+
+	j.setTotal(len(sizes), totalBytes)
+	added := func(entry filestoreutils.ExportEntry) {
+		j.advance(1, sizes[entry.FileID])
+		if entry.Err != nil {
+			j.addError(entry.SourceName, entry.Err)
+		}
+	}
+
+	for _, export := range zips {
+		// Create ZIP file using filestoreutils
+		zipName := export.folder.Name
+		if options.NeutralNames {
+			zipName = "export"
+		}
+		zipPath, entries, err := filestoreutils.CreateZipFile(ctx, s.OpenFile, zipName, export.meta, export.files, exportDir, entryOptions, added)
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+		if err != nil {
+			fmt.Printf("Failed to create ZIP for folder '%s': %v", export.folder.Name, err)
+			j.addError(export.folder.Name, err)
+			continue
+		}
+
+		if err := s.recordExport(zipPath, "", entries); err != nil {
+			fmt.Printf("Warning: Failed to record export of '%s': %v\n", zipPath, err)
+		}
+		report.Paths = append(report.Paths, zipPath)
+		for _, entry := range entries {
+			report.Files = append(report.Files, exportedFile(entry))
+		}
+		fmt.Printf("ZIP created successfully: %s", zipPath)
+	}
+
+	if len(report.Paths) == 0 {
+		return nil, fmt.Errorf("no ZIP files were created successfully")
 	}
-	defer tvault.Close()
+
+	fmt.Printf("ZIP export completed: %d ZIP files created", len(report.Paths))
+	return report, nil
+}
+
+// folderExport is a folder and the files to export from it
+type folderExport struct {
+	folder *filestoreutils.FolderInfo
+	files  []filestoreutils.FileInfo
+	meta   filestoreutils.ExportFolderMetadata // see attachExportMetadata
+}
+
+// folderExports collects the files to export from each folder: the selected
+// files when a single folder is given with a selection, otherwise all of
+// each folder's files. It also returns every file's size by ID.
+func (s *service) folderExports(j *job, folderIDs []int64, selectedFileIDs []int64) ([]folderExport, map[int64]int64) {
+	var exports []folderExport
+	sizes := make(map[int64]int64)
 
 	for _, folderID := range folderIDs {
 		// Get folder info using filestoreutils
 		folderInfo, err := filestoreutils.GetFolderInfo(s.db, folderID)
 		if err != nil {
 			fmt.Printf("Failed to get folder info for ID %d: %v", folderID, err)
+			j.addError(fmt.Sprintf("folder %d", folderID), err)
 			continue
 		}
 
@@ -265,14 +881,15 @@ func (s *service) ExportZipFolders(folderIDs []int64, selectedFileIDs []int64) (
 
 		if len(selectedFileIDs) > 0 && len(folderIDs) == 1 {
 			// Scenario 1: Export selected files from within a folder
-			fmt.Printf("Exporting %d selected files from folder '%s' as ZIP", len(selectedFileIDs), folderInfo.Name)
+			fmt.Printf("Exporting %d selected files from folder '%s'", len(selectedFileIDs), folderInfo.Name)
 			filesToExport, err = filestoreutils.GetSelectedFilesInFolder(s.db, folderID, selectedFileIDs)
 		} else {
 			// Scenario 2: Export entire folder(s)
-			fmt.Printf("Exporting entire folder '%s' as ZIP", folderInfo.Name)
+			fmt.Printf("Exporting entire folder '%s'", folderInfo.Name)
 			response, err := s.GetFilesInFolder(folderID)
 			if err != nil {
 				fmt.Printf("Failed to get files in folder %d: %v", folderID, err)
+				j.addError(folderInfo.Name, err)
 				continue
 			}
 			// Convert from service FileInfo to filestoreutils FileInfo
@@ -283,12 +900,15 @@ func (s *service) ExportZipFolders(folderIDs []int64, selectedFileIDs []int64) (
 					MimeType:  file.MimeType,
 					Timestamp: file.Timestamp,
 					Size:      file.Size,
+					SHA256:    file.SHA256,
+					SHA512:    file.SHA512,
 				})
 			}
 		}
 
 		if err != nil {
 			fmt.Printf("Failed to get files for folder %d: %v", folderID, err)
+			j.addError(folderInfo.Name, err)
 			continue
 		}
 
@@ -297,30 +917,85 @@ func (s *service) ExportZipFolders(folderIDs []int64, selectedFileIDs []int64) (
 			continue
 		}
 
-		// Create ZIP file using filestoreutils
-		zipPath, err := filestoreutils.CreateZipFile(s.db, s.dbKey, folderInfo.Name, filesToExport, tvault, exportDir)
-		if err != nil {
-			fmt.Printf("Failed to create ZIP for folder '%s': %v", folderInfo.Name, err)
-			continue
+		for _, file := range filesToExport {
+			sizes[file.ID] = file.Size
 		}
+		exports = append(exports, folderExport{folder: folderInfo, files: filesToExport})
+	}
 
-		exportedPaths = append(exportedPaths, zipPath)
-		fmt.Printf("ZIP created successfully: %s", zipPath)
+	return exports, sizes
+}
+
+// entryOptions returns the per-file export settings. Neutral names are
+// numbered in the order files are exported.
+func (options ExportOptions) entryOptions() func(filestoreutils.FileInfo) filestoreutils.ExportEntryOptions {
+	scrub := options.scrubOptions()
+
+	count := 0
+	return func(filestoreutils.FileInfo) filestoreutils.ExportEntryOptions {
+		entry := filestoreutils.ExportEntryOptions{Scrub: scrub}
+		if options.NeutralNames {
+			count++
+			entry.Name = fmt.Sprintf("file-%04d", count)
+		}
+		return entry
 	}
+}
 
-	if len(exportedPaths) == 0 {
-		return nil, fmt.Errorf("no ZIP files were created successfully")
+// scrubOptions returns the metadata scrubbing settings, nil when metadata is kept
+func (options ExportOptions) scrubOptions() *filestoreutils.ScrubOptions {
+	if !options.StripMetadata {
+		return nil
+	}
+	return &filestoreutils.ScrubOptions{
+		KeepOrientation:  options.KeepOrientation,
+		KeepColorProfile: options.KeepColorProfile,
 	}
+}
 
-	fmt.Printf("ZIP export completed: %d ZIP files created", len(exportedPaths))
-	return exportedPaths, nil
+func exportedFile(entry filestoreutils.ExportEntry) ExportedFile {
+	file := ExportedFile{
+		FileID:       entry.FileID,
+		Name:         entry.SourceName,
+		ExportedName: entry.Name,
+		Path:         entry.Path,
+		SHA256:       entry.SHA256,
+		SHA512:       entry.SHA512,
+	}
+	if entry.ExportedSHA256 != entry.SHA256 {
+		file.ExportedSHA256 = entry.ExportedSHA256
+	}
+	if entry.Scrub != nil {
+		file.Format = entry.Scrub.Format
+		file.Removed = entry.Scrub.Removed
+		file.Kept = entry.Scrub.Kept
+		file.Warnings = entry.Scrub.Warnings
+	}
+	if entry.Err != nil {
+		file.Error = entry.Err.Error()
+	}
+	return file
 }
 
 func (s *service) DeleteFiles(ids []int64) error {
+	return s.deleteFiles(ids, auditutils.Entry{}, nil)
+}
+
+// errNoFilesFound is returned when none of the files to delete are left
+var errNoFilesFound = errors.New("no files found for deletion")
+
+// deleteFiles securely deletes files. origin sets the actor and details of
+// each file's audit entry. record, when set, runs in the deletion's
+// transaction with the files actually deleted, so what it writes is committed
+// together with the deletion.
+func (s *service) deleteFiles(ids []int64, origin auditutils.Entry, record func(tx *sql.Tx, deleted []filestoreutils.FileMetadata) error) error {
 	if len(ids) == 0 {
 		return fmt.Errorf("no file IDs provided for deletion")
 	}
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// Start transaction
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -335,10 +1010,11 @@ func (s *service) DeleteFiles(ids []int64) error {
 	}
 
 	if len(filesMetadata) == 0 {
-		return fmt.Errorf("no files found for deletion")
+		return errNoFilesFound
 	}
 
 	// Mark files as deleted in database and add to free spaces
+	var fileIDs []int64
 	for _, metadata := range filesMetadata {
 		_, err := tx.Exec(`
 			UPDATE files 
@@ -349,12 +1025,52 @@ func (s *service) DeleteFiles(ids []int64) error {
 		if err != nil {
 			return fmt.Errorf("failed to mark file %d as deleted: %w", metadata.ID, err)
 		}
+		if err := auditutils.AppendFile(tx, auditutils.EventDeleted, origin.Actor, metadata.ID, origin.Details); err != nil {
+			return err
+		}
+		fileIDs = append(fileIDs, metadata.ID)
+	}
+
+	// Deduplicated files share extents; an extent is freed once no live file refers to it
+	var released []filestoreutils.FileMetadata
+	seen := make(map[int64]bool)
+	for _, metadata := range filesMetadata {
+		if seen[metadata.Offset] {
+			continue
+		}
+		seen[metadata.Offset] = true
+
+		references, err := extentReferences(tx, metadata.Offset)
+		if err != nil {
+			return err
+		}
+		if references > 0 {
+			continue
+		}
 
 		// Add the file's space to free_spaces table
 		err = filestoreutils.AddFreeSpace(tx, metadata.Offset, metadata.Length)
 		if err != nil {
 			return fmt.Errorf("failed to add free space for file %d: %w", metadata.ID, err)
 		}
+		released = append(released, metadata)
+	}
+
+	// Thumbnails go with their files
+	thumbnailExtents, err := deleteThumbnailRecords(tx, fileIDs)
+	if err != nil {
+		return err
+	}
+	if err := removeTagAssignments(tx, "file_tags", "file_id", fileIDs); err != nil {
+		return err
+	}
+	if err := removeNotes(tx, "file_id", fileIDs); err != nil {
+		return err
+	}
+	if record != nil {
+		if err := record(tx, filesMetadata); err != nil {
+			return err
+		}
 	}
 
 	// Commit database transaction first
@@ -363,7 +1079,7 @@ func (s *service) DeleteFiles(ids []int64) error {
 	}
 
 	// Now securely overwrite the file data in TVault
-	for _, metadata := range filesMetadata {
+	for _, metadata := range released {
 		err := filestoreutils.SecurelyOverwriteFileData(s.tvaultPath, metadata.Offset, metadata.Length)
 		if err != nil {
 			// Log error but don't fail the entire operation since DB is already updated
@@ -372,26 +1088,71 @@ func (s *service) DeleteFiles(ids []int64) error {
 		}
 	}
 
+	s.wipeExtents(thumbnailExtents)
+
+	// Shrink the TVault if the deleted files were at its end
+	if err := s.trimVault(); err != nil {
+		fmt.Printf("Warning: Failed to trim TVault: %v\n", err)
+	}
+
 	return nil
 }
 
 func (s *service) DeleteFolders(folderIDs []int64) error {
+	return s.deleteFolders(s.ctx, nil, folderIDs)
+}
+
+// StartDeleteFolders securely deletes folders and their files in a background job and returns its ID
+func (s *service) StartDeleteFolders(folderIDs []int64) (string, error) {
+	if len(folderIDs) == 0 {
+		return "", fmt.Errorf("no folder IDs provided for deletion")
+	}
+
+	return s.startJob(JobDeleteFolders, func(ctx context.Context, j *job) (interface{}, error) {
+		return nil, s.deleteFolders(ctx, j, folderIDs)
+	}), nil
+}
+
+// deleteBatchSize is how many files are wiped between progress updates and cancellation checks
+const deleteBatchSize = 32
+
+func (s *service) deleteFolders(ctx context.Context, j *job, folderIDs []int64) error {
 	if len(folderIDs) == 0 {
 		return fmt.Errorf("no folder IDs provided for deletion")
 	}
 
+	// Subfolders are deleted along with their parents
+	folderIDs, err := s.getFolderSubtrees(folderIDs)
+	if err != nil {
+		return fmt.Errorf("failed to get subfolders: %w", err)
+	}
+
 	// First, get all file IDs in the selected folders
 	fileIDs, err := s.getFileIDsInFolders(folderIDs)
 	if err != nil {
 		return fmt.Errorf("failed to get file IDs in folders: %w", err)
 	}
 
-	// Delete all files using the existing DeleteFiles method
-	if len(fileIDs) > 0 {
-		err = s.DeleteFiles(fileIDs)
-		if err != nil {
+	sizes, total := s.fileSizes(fileIDs)
+	j.setTotal(len(fileIDs), total)
+
+	// Delete the files in batches using the existing DeleteFiles method. A
+	// cancelled deletion keeps the folders holding the files not yet wiped.
+	for start := 0; start < len(fileIDs); start += deleteBatchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		batch := fileIDs[start:min(start+deleteBatchSize, len(fileIDs))]
+		if err := s.DeleteFiles(batch); err != nil {
 			return fmt.Errorf("failed to delete files in folders: %w", err)
 		}
+
+		var bytes int64
+		for _, id := range batch {
+			bytes += sizes[id]
+		}
+		j.advance(len(batch), bytes)
 	}
 
 	// Now delete the empty folders
@@ -406,6 +1167,19 @@ func (s *service) DeleteFolders(folderIDs []int64) error {
 		if err != nil {
 			return fmt.Errorf("failed to delete folder %d: %w", folderID, err)
 		}
+		if err := removeTagAssignments(tx, "folder_tags", "folder_id", []int64{folderID}); err != nil {
+			return err
+		}
+		if err := removeNotes(tx, "folder_id", []int64{folderID}); err != nil {
+			return err
+		}
+		// A rule for a folder that no longer exists has nothing left to do
+		if _, err := tx.Exec("DELETE FROM retention_rules WHERE folder_id = ?", folderID); err != nil {
+			return fmt.Errorf("failed to delete retention rules of folder %d: %w", folderID, err)
+		}
+		if err := auditutils.Append(tx, auditutils.Entry{Event: auditutils.EventDeleted, FolderID: folderID}); err != nil {
+			return err
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -415,6 +1189,31 @@ func (s *service) DeleteFolders(folderIDs []int64) error {
 	return nil
 }
 
+// fileSizes returns the size of each live file and their total. Sizes are
+// only used for progress, so a failed lookup reports zero.
+func (s *service) fileSizes(ids []int64) (map[int64]int64, int64) {
+	sizes := make(map[int64]int64, len(ids))
+	if len(ids) == 0 {
+		return sizes, 0
+	}
+
+	rows, err := s.db.Query("SELECT id, size FROM files WHERE is_deleted = 0 AND id IN ("+placeholders(len(ids))+")", int64sToArgs(ids)...)
+	if err != nil {
+		return sizes, 0
+	}
+	defer rows.Close()
+
+	var total int64
+	for rows.Next() {
+		var id, size int64
+		if rows.Scan(&id, &size) == nil {
+			sizes[id] = size
+			total += size
+		}
+	}
+	return sizes, total
+}
+
 // Helper method to get all file IDs in the specified folders
 func (s *service) getFileIDsInFolders(folderIDs []int64) ([]int64, error) {
 	if len(folderIDs) == 0 {
@@ -0,0 +1,49 @@
+package filestore
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// getIntSetting reads a numeric vault setting, returning fallback when it was never set
+func (s *service) getIntSetting(key string, fallback int) (int, error) {
+	var value string
+	err := s.db.QueryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return fallback, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read setting %s: %w", key, err)
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value for setting %s: %w", key, err)
+	}
+	return n, nil
+}
+
+// getSetting reads a vault setting, returning fallback when it was never set
+func (s *service) getSetting(key string, fallback string) (string, error) {
+	var value string
+	err := s.db.QueryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return fallback, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read setting %s: %w", key, err)
+	}
+	return value, nil
+}
+
+func (s *service) setSetting(key string, value string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO settings (key, value) VALUES (?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value
+	`, key, value)
+	if err != nil {
+		return fmt.Errorf("failed to save setting %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	minFreeBytesSetting   = "storage_min_free_bytes"
+	minFreePercentSetting = "storage_min_free_percent"
+
+	defaultMinFreeBytes   = 1 << 30 // 1 GiB
+	defaultMinFreePercent = 5
+)
+
+// GetVaultStats reports TVault usage, fragmentation, usage by folder and type,
+// and the free space left on the disk
+func (s *service) GetVaultStats() (*VaultStats, error) {
+	stats := &VaultStats{Folders: []FolderUsage{}, Categories: []CategoryUsage{}}
+
+	if info, err := os.Stat(s.tvaultPath); err == nil {
+		stats.VaultSize = info.Size()
+	}
+
+	err := s.db.QueryRow(`
+		SELECT
+			COALESCE(SUM(length), 0),
+			COALESCE(SUM(CASE WHEN kind = 'thumbnail' THEN length ELSE 0 END), 0)
+		FROM vault_extents
+	`).Scan(&stats.LiveBytes, &stats.ThumbnailBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum live extents: %w", err)
+	}
+
+	err = s.db.QueryRow(`
+		SELECT COALESCE(SUM(length), 0), COUNT(*), COALESCE(MAX(length), 0) FROM free_spaces
+	`).Scan(&stats.FreeBytes, &stats.FreeRegions, &stats.LargestFree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum free space: %w", err)
+	}
+	if stats.FreeBytes > 0 {
+		stats.Fragmentation = 1 - float64(stats.LargestFree)/float64(stats.FreeBytes)
+	}
+
+	err = s.db.QueryRow(`
+		SELECT
+			COUNT(CASE WHEN trashed_at IS NULL THEN 1 END),
+			COALESCE(SUM(CASE WHEN trashed_at IS NULL THEN size END), 0),
+			COUNT(trashed_at),
+			COALESCE(SUM(CASE WHEN trashed_at IS NOT NULL THEN size END), 0)
+		FROM files WHERE is_deleted = 0
+	`).Scan(&stats.FileCount, &stats.FileBytes, &stats.TrashedCount, &stats.TrashedBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count files: %w", err)
+	}
+
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM folders WHERE trashed_at IS NULL").Scan(&stats.FolderCount); err != nil {
+		return nil, fmt.Errorf("failed to count folders: %w", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT f.id, f.name, COUNT(files.id), COALESCE(SUM(files.size), 0)
+		FROM folders f
+		LEFT JOIN files ON files.folder_id = f.id AND files.is_deleted = 0 AND files.trashed_at IS NULL
+		WHERE f.trashed_at IS NULL
+		GROUP BY f.id, f.name
+		ORDER BY 4 DESC, f.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query folder usage: %w", err)
+	}
+	for rows.Next() {
+		var usage FolderUsage
+		if err := rows.Scan(&usage.FolderID, &usage.Name, &usage.FileCount, &usage.Bytes); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan folder usage: %w", err)
+		}
+		stats.Folders = append(stats.Folders, usage)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating folder usage: %w", err)
+	}
+
+	for _, category := range []string{CategoryImage, CategoryVideo, CategoryAudio, CategoryDocument, CategoryOther} {
+		condition, err := categoryCondition(category)
+		if err != nil {
+			return nil, err
+		}
+
+		usage := CategoryUsage{Category: category}
+		err = s.db.QueryRow(`
+			SELECT COUNT(*), COALESCE(SUM(size), 0) FROM files
+			WHERE is_deleted = 0 AND trashed_at IS NULL AND `+condition,
+		).Scan(&usage.FileCount, &usage.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sum %s files: %w", category, err)
+		}
+		stats.Categories = append(stats.Categories, usage)
+	}
+
+	// Statistics are still useful when the disk cannot be queried
+	disk, err := s.diskSpace(0)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	stats.Disk = disk
+
+	return stats, nil
+}
+
+// CheckDiskSpace reports whether incomingBytes fit on the vault's disk without
+// crossing the storage thresholds, emitting a storage-warning event if not
+func (s *service) CheckDiskSpace(incomingBytes int64) (*DiskSpaceCheck, error) {
+	check, err := s.diskSpace(incomingBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if check.Low {
+		fmt.Printf("Warning: Disk space is low, %d bytes free with %d incoming\n", check.FreeBytes, incomingBytes)
+		s.emit("storage-warning", *check)
+	}
+	return check, nil
+}
+
+func (s *service) diskSpace(incomingBytes int64) (*DiskSpaceCheck, error) {
+	thresholds, err := s.GetStorageThresholds()
+	if err != nil {
+		return nil, err
+	}
+
+	disk, err := filestoreutils.DiskSpace(filepath.Dir(s.tvaultPath))
+	if err != nil {
+		return nil, err
+	}
+
+	check := &DiskSpaceCheck{
+		FreeBytes:     disk.Free,
+		TotalBytes:    disk.Total,
+		IncomingBytes: incomingBytes,
+		FreeAfter:     disk.Free - incomingBytes,
+	}
+	check.Insufficient = check.FreeAfter < 0
+	check.Low = check.Insufficient ||
+		check.FreeAfter < thresholds.MinFreeBytes ||
+		check.FreeAfter*100 < disk.Total*int64(thresholds.MinFreePercent)
+	return check, nil
+}
+
+func (s *service) GetStorageThresholds() (StorageThresholds, error) {
+	minBytes, err := s.getIntSetting(minFreeBytesSetting, defaultMinFreeBytes)
+	if err != nil {
+		return StorageThresholds{}, err
+	}
+	minPercent, err := s.getIntSetting(minFreePercentSetting, defaultMinFreePercent)
+	if err != nil {
+		return StorageThresholds{}, err
+	}
+	return StorageThresholds{MinFreeBytes: int64(minBytes), MinFreePercent: minPercent}, nil
+}
+
+func (s *service) SetStorageThresholds(thresholds StorageThresholds) error {
+	if thresholds.MinFreeBytes < 0 {
+		return fmt.Errorf("minimum free space cannot be negative")
+	}
+	if thresholds.MinFreePercent < 0 || thresholds.MinFreePercent > 100 {
+		return fmt.Errorf("minimum free percentage must be between 0 and 100")
+	}
+
+	if err := s.setSetting(minFreeBytesSetting, strconv.FormatInt(thresholds.MinFreeBytes, 10)); err != nil {
+		return err
+	}
+	return s.setSetting(minFreePercentSetting, strconv.Itoa(thresholds.MinFreePercent))
+}
@@ -0,0 +1,90 @@
+package filestore
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGetVaultStats(t *testing.T) {
+	s, folderID := setupTestService(t)
+	cases, _ := s.CreateFolder("Cases", 0)
+
+	ids, _ := storeRandomFiles(t, s, folderID, []int{1000, 2000, 3000})
+	for i, mimeType := range []string{"image/jpeg", "video/mp4"} {
+		if _, err := s.StoreFile(cases.ID, []string{"photo.jpg", "clip.mp4"}[i], mimeType, bytes.NewReader(make([]byte, 500*(i+1)))); err != nil {
+			t.Fatalf("StoreFile failed: %v", err)
+		}
+	}
+	s.TrashFiles([]int64{ids[2]})
+	s.DeleteFiles([]int64{ids[0]})
+
+	stats, err := s.GetVaultStats()
+	if err != nil {
+		t.Fatalf("GetVaultStats failed: %v", err)
+	}
+
+	if stats.FileCount != 3 || stats.FileBytes != 3500 || stats.TrashedCount != 1 || stats.TrashedBytes != 3000 || stats.FolderCount != 2 {
+		t.Errorf("Unexpected counts: %+v", stats)
+	}
+	if stats.FreeRegions != 1 || stats.FreeBytes == 0 || stats.Fragmentation != 0 {
+		t.Errorf("Expected the deleted file to leave one free region, got %+v", stats)
+	}
+	if stats.LiveBytes+stats.FreeBytes > stats.VaultSize {
+		t.Errorf("Live %d and free %d bytes exceed the vault size %d", stats.LiveBytes, stats.FreeBytes, stats.VaultSize)
+	}
+
+	if len(stats.Folders) != 2 || stats.Folders[0].FolderID != folderID || stats.Folders[0].Bytes != 2000 || stats.Folders[1].Bytes != 1500 {
+		t.Errorf("Unexpected folder usage: %+v", stats.Folders)
+	}
+
+	want := map[string]int64{CategoryImage: 500, CategoryVideo: 1000, CategoryAudio: 0, CategoryDocument: 0, CategoryOther: 2000}
+	for _, usage := range stats.Categories {
+		if usage.Bytes != want[usage.Category] {
+			t.Errorf("Category %s: expected %d bytes, got %d", usage.Category, want[usage.Category], usage.Bytes)
+		}
+	}
+
+	if stats.Disk == nil || stats.Disk.TotalBytes == 0 || stats.Disk.FreeBytes == 0 {
+		t.Errorf("Expected the disk to be measured, got %+v", stats.Disk)
+	}
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	s, _ := setupTestService(t)
+
+	var warnings []DiskSpaceCheck
+	s.emit = func(name string, data ...interface{}) {
+		if name == "storage-warning" {
+			warnings = append(warnings, data[0].(DiskSpaceCheck))
+		}
+	}
+
+	if err := s.SetStorageThresholds(StorageThresholds{MinFreePercent: 101}); err == nil {
+		t.Error("Expected a percentage over 100 to be rejected")
+	}
+	if err := s.SetStorageThresholds(StorageThresholds{}); err != nil {
+		t.Fatalf("SetStorageThresholds failed: %v", err)
+	}
+
+	check, err := s.CheckDiskSpace(0)
+	if err != nil {
+		t.Fatalf("CheckDiskSpace failed: %v", err)
+	}
+	if check.Low || len(warnings) != 0 {
+		t.Errorf("Expected no warning without thresholds, got %+v", check)
+	}
+
+	// A transfer larger than the free space is flagged before it is accepted
+	check, _ = s.CheckDiskSpace(check.FreeBytes + 1)
+	if !check.Insufficient || !check.Low || len(warnings) != 1 {
+		t.Errorf("Expected an insufficient space warning, got %+v", check)
+	}
+
+	s.SetStorageThresholds(StorageThresholds{MinFreeBytes: check.FreeBytes})
+	if thresholds, _ := s.GetStorageThresholds(); thresholds.MinFreeBytes != check.FreeBytes {
+		t.Errorf("Thresholds were not saved: %+v", thresholds)
+	}
+	if check, _ := s.CheckDiskSpace(1); !check.Low || check.Insufficient {
+		t.Errorf("Expected crossing the threshold to warn, got %+v", check)
+	}
+}
@@ -0,0 +1,411 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultTagColor is used when a tag is created without a colour
+const defaultTagColor = "#6b7280"
+
+var tagColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+func (s *service) CreateTag(name string, color string) (*Tag, error) {
+	name, err := validateTagName(name)
+	if err != nil {
+		return nil, err
+	}
+	if color == "" {
+		color = defaultTagColor
+	}
+	if err := validateTagColor(color); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkTagNameFree(name, 0); err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec(
+		"INSERT INTO tags (name, search_name, color) VALUES (?, ?, ?)",
+		name, filestoreutils.SearchName(name), strings.ToLower(color),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tag: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag ID: %w", err)
+	}
+
+	return &Tag{ID: id, Name: name, Color: strings.ToLower(color)}, nil
+}
+
+// ListTags returns every tag by name, with how many live files and folders carry it
+func (s *service) ListTags() ([]Tag, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			t.id, t.name, t.color,
+			(SELECT COUNT(*) FROM file_tags ft JOIN files f ON f.id = ft.file_id
+				WHERE ft.tag_id = t.id AND f.is_deleted = 0 AND f.trashed_at IS NULL),
+			(SELECT COUNT(*) FROM folder_tags fo JOIN folders d ON d.id = fo.folder_id
+				WHERE fo.tag_id = t.id AND d.trashed_at IS NULL)
+		FROM tags t
+		ORDER BY t.search_name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.FileCount, &tag.FolderCount); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tags: %w", err)
+	}
+	return tags, nil
+}
+
+// RenameTag renames a tag. Renaming onto another tag's name is refused; MergeTags combines them.
+func (s *service) RenameTag(id int64, name string) error {
+	name, err := validateTagName(name)
+	if err != nil {
+		return err
+	}
+	if err := s.checkTagNameFree(name, id); err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec("UPDATE tags SET name = ?, search_name = ? WHERE id = ?", name, filestoreutils.SearchName(name), id)
+	if err != nil {
+		return fmt.Errorf("failed to rename tag: %w", err)
+	}
+	return checkTagUpdated(result, id)
+}
+
+func (s *service) SetTagColor(id int64, color string) error {
+	if err := validateTagColor(color); err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec("UPDATE tags SET color = ? WHERE id = ?", strings.ToLower(color), id)
+	if err != nil {
+		return fmt.Errorf("failed to change tag colour: %w", err)
+	}
+	return checkTagUpdated(result, id)
+}
+
+// DeleteTag removes a tag from everything carrying it
+func (s *service) DeleteTag(id int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteTagRecord(tx, id); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// MergeTags moves the files and folders of the source tags onto the target
+// tag, then deletes the source tags
+func (s *service) MergeTags(sourceIDs []int64, targetID int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkTagExists(tx, targetID); err != nil {
+		return err
+	}
+
+	for _, sourceID := range sourceIDs {
+		if sourceID == targetID {
+			continue
+		}
+		if err := checkTagExists(tx, sourceID); err != nil {
+			return err
+		}
+
+		for _, table := range []string{"file_tags", "folder_tags"} {
+			column := strings.TrimSuffix(table, "_tags") + "_id"
+			_, err := tx.Exec(fmt.Sprintf(
+				"INSERT OR IGNORE INTO %s (%s, tag_id) SELECT %s, ? FROM %s WHERE tag_id = ?",
+				table, column, column, table,
+			), targetID, sourceID)
+			if err != nil {
+				return fmt.Errorf("failed to merge tag %d: %w", sourceID, err)
+			}
+		}
+
+		if err := deleteTagRecord(tx, sourceID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// TagFiles adds a tag to files. Files that already carry it are left as they are.
+func (s *service) TagFiles(fileIDs []int64, tagID int64) error {
+	return s.assignTag(`
+		INSERT OR IGNORE INTO file_tags (file_id, tag_id)
+		SELECT id, ? FROM files WHERE id IN (%s) AND is_deleted = 0
+	`, fileIDs, tagID)
+}
+
+func (s *service) UntagFiles(fileIDs []int64, tagID int64) error {
+	return s.assignTag("DELETE FROM file_tags WHERE tag_id = ? AND file_id IN (%s)", fileIDs, tagID)
+}
+
+// TagFolders adds a tag to folders. Only the folders themselves are tagged, not their contents.
+func (s *service) TagFolders(folderIDs []int64, tagID int64) error {
+	return s.assignTag(`
+		INSERT OR IGNORE INTO folder_tags (folder_id, tag_id)
+		SELECT id, ? FROM folders WHERE id IN (%s)
+	`, folderIDs, tagID)
+}
+
+func (s *service) UntagFolders(folderIDs []int64, tagID int64) error {
+	return s.assignTag("DELETE FROM folder_tags WHERE tag_id = ? AND folder_id IN (%s)", folderIDs, tagID)
+}
+
+// assignTag runs query, with the tag ID and the IDs filled in, after checking the tag exists
+func (s *service) assignTag(query string, ids []int64, tagID int64) error {
+	if len(ids) == 0 {
+		return fmt.Errorf("no IDs provided")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkTagExists(tx, tagID); err != nil {
+		return err
+	}
+
+	args := []interface{}{tagID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	if _, err := tx.Exec(fmt.Sprintf(query, placeholders(len(ids))), args...); err != nil {
+		return fmt.Errorf("failed to update tags: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// fileTags returns the tags of each file, by file ID
+func (s *service) fileTags(fileIDs []int64) (map[int64][]Tag, error) {
+	return s.tagsOf("file_tags", "file_id", fileIDs)
+}
+
+// folderTags returns the tags of each folder, by folder ID
+func (s *service) folderTags(folderIDs []int64) (map[int64][]Tag, error) {
+	return s.tagsOf("folder_tags", "folder_id", folderIDs)
+}
+
+func (s *service) tagsOf(table string, column string, ids []int64) (map[int64][]Tag, error) {
+	tags := make(map[int64][]Tag)
+	if len(ids) == 0 {
+		return tags, nil
+	}
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT a.%s, t.id, t.name, t.color
+		FROM %s a
+		JOIN tags t ON t.id = a.tag_id
+		WHERE a.%s IN (%s)
+		ORDER BY t.search_name
+	`, column, table, column, placeholders(len(ids))), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var tag Tag
+		if err := rows.Scan(&id, &tag.ID, &tag.Name, &tag.Color); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags[id] = append(tags[id], tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tags: %w", err)
+	}
+	return tags, nil
+}
+
+// attachFileTags fills in the tags of listed files
+func (s *service) attachFileTags(files []FileInfo) error {
+	ids := make([]int64, len(files))
+	for i, file := range files {
+		ids[i] = file.ID
+	}
+	tags, err := s.fileTags(ids)
+	if err != nil {
+		return err
+	}
+	for i := range files {
+		files[i].Tags = tags[files[i].ID]
+	}
+	return nil
+}
+
+// attachFolderTags fills in the tags of listed folders
+func (s *service) attachFolderTags(folders []FolderInfo) error {
+	ids := make([]int64, len(folders))
+	for i, folder := range folders {
+		ids[i] = folder.ID
+	}
+	tags, err := s.folderTags(ids)
+	if err != nil {
+		return err
+	}
+	for i := range folders {
+		folders[i].Tags = tags[folders[i].ID]
+	}
+	return nil
+}
+
+// tagCondition selects rows of the tagged table whose ID column carries every one of tagIDs
+func tagCondition(idColumn string, table string, itemColumn string, tagIDs []int64) (string, []interface{}) {
+	condition := fmt.Sprintf(
+		"%s IN (SELECT %s FROM %s WHERE tag_id IN (%s) GROUP BY %s HAVING COUNT(*) = ?)",
+		idColumn, itemColumn, table, placeholders(len(tagIDs)), itemColumn,
+	)
+
+	var args []interface{}
+	unique := make(map[int64]bool)
+	for _, id := range tagIDs {
+		args = append(args, id)
+		unique[id] = true
+	}
+	return condition, append(args, len(unique))
+}
+
+// tagNames returns the names of tags, for export metadata
+func tagNames(tags []Tag) []string {
+	var names []string
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names
+}
+
+func validateTagName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("tag name cannot be empty")
+	}
+	if len(name) > maxNameLength {
+		return "", fmt.Errorf("tag name is longer than %d bytes", maxNameLength)
+	}
+	return name, nil
+}
+
+func validateTagColor(color string) error {
+	if !tagColorPattern.MatchString(color) {
+		return fmt.Errorf("invalid tag colour %q, expected #rrggbb", color)
+	}
+	return nil
+}
+
+// checkTagNameFree fails if a tag other than id already has name, ignoring case
+func (s *service) checkTagNameFree(name string, id int64) error {
+	var existing int64
+	err := s.db.QueryRow("SELECT id FROM tags WHERE search_name = ?", filestoreutils.SearchName(name)).Scan(&existing)
+	if err == sql.ErrNoRows || (err == nil && existing == id) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check tag name: %w", err)
+	}
+	return fmt.Errorf("a tag named %q already exists", name)
+}
+
+// deleteTagRecord deletes a tag and its assignments
+func deleteTagRecord(tx *sql.Tx, id int64) error {
+	for _, table := range []string{"file_tags", "folder_tags"} {
+		if err := removeTagAssignments(tx, table, "tag_id", []int64{id}); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec("DELETE FROM tags WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+	return checkTagUpdated(result, id)
+}
+
+// removeTagAssignments deletes the rows of table whose column is one of ids.
+// Foreign keys are not enforced on the connection, so deleting a tag, file or
+// folder removes its assignments explicitly.
+func removeTagAssignments(tx *sql.Tx, table string, column string, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s IN (%s)", table, column, placeholders(len(ids)))
+	if _, err := tx.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to remove tags: %w", err)
+	}
+	return nil
+}
+
+func checkTagExists(tx *sql.Tx, id int64) error {
+	var exists int
+	err := tx.QueryRow("SELECT 1 FROM tags WHERE id = ?", id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("tag not found with ID: %d", id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up tag: %w", err)
+	}
+	return nil
+}
+
+func checkTagUpdated(result sql.Result, id int64) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check tag update: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("tag not found with ID: %d", id)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package filestore
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"Tella-Desktop/backend/utils/filestoreutils"
+)
+
+func TestTags(t *testing.T) {
+	s, folderID := setupTestService(t)
+	ids, _ := storeRandomFiles(t, s, folderID, []int{100, 200, 300})
+
+	urgent, err := s.CreateTag(" Urgent ", "")
+	if err != nil {
+		t.Fatalf("CreateTag failed: %v", err)
+	}
+	if urgent.Name != "Urgent" || urgent.Color != defaultTagColor {
+		t.Errorf("Unexpected tag: %+v", urgent)
+	}
+	if _, err := s.CreateTag("urgent", ""); err == nil {
+		t.Error("Expected a name differing only in case to be rejected")
+	}
+	if _, err := s.CreateTag("Blue", "blue"); err == nil {
+		t.Error("Expected a colour that is not #rrggbb to be rejected")
+	}
+
+	witness, _ := s.CreateTag("Witness", "#1E90FF")
+	if witness.Color != "#1e90ff" {
+		t.Errorf("Expected the colour to be lowercased, got %s", witness.Color)
+	}
+	if err := s.RenameTag(witness.ID, "URGENT"); err == nil {
+		t.Error("Expected renaming onto an existing name to be rejected")
+	}
+
+	if err := s.TagFiles(ids[:2], urgent.ID); err != nil {
+		t.Fatalf("TagFiles failed: %v", err)
+	}
+	if err := s.TagFiles(ids[1:], witness.ID); err != nil {
+		t.Fatalf("TagFiles failed: %v", err)
+	}
+	if err := s.TagFolders([]int64{folderID}, witness.ID); err != nil {
+		t.Fatalf("TagFolders failed: %v", err)
+	}
+	if err := s.TagFiles(ids, 999); err == nil {
+		t.Error("Expected an unknown tag to be rejected")
+	}
+
+	// Only files carrying every requested tag match
+	result, err := s.QueryFiles(FileQuery{TagIDs: []int64{urgent.ID, witness.ID}})
+	if err != nil {
+		t.Fatalf("QueryFiles failed: %v", err)
+	}
+	if len(result.Files) != 1 || result.Files[0].ID != ids[1] || len(result.Files[0].Tags) != 2 {
+		t.Errorf("Expected only the file with both tags, got %+v", result.Files)
+	}
+
+	other, _ := s.CreateFolder("Other", 0)
+	storeRandomFiles(t, s, other.ID, []int{50})
+	folders, err := s.GetStoredFolders(FolderListOptions{TagIDs: []int64{witness.ID}})
+	if err != nil {
+		t.Fatalf("GetStoredFolders failed: %v", err)
+	}
+	if len(folders) != 1 || folders[0].ID != folderID || len(folders[0].Tags) != 1 {
+		t.Errorf("Expected only the tagged folder, got %+v", folders)
+	}
+
+	if err := s.UntagFiles([]int64{ids[1]}, urgent.ID); err != nil {
+		t.Fatalf("UntagFiles failed: %v", err)
+	}
+
+	// Merging keeps every file of both tags, without duplicates
+	if err := s.MergeTags([]int64{urgent.ID}, witness.ID); err != nil {
+		t.Fatalf("MergeTags failed: %v", err)
+	}
+	tags, _ := s.ListTags()
+	if len(tags) != 1 || tags[0].ID != witness.ID || tags[0].FileCount != 3 || tags[0].FolderCount != 1 {
+		t.Errorf("Unexpected tags after merging: %+v", tags)
+	}
+
+	if err := s.DeleteTag(witness.ID); err != nil {
+		t.Fatalf("DeleteTag failed: %v", err)
+	}
+	var remaining int
+	s.db.QueryRow("SELECT (SELECT COUNT(*) FROM file_tags) + (SELECT COUNT(*) FROM folder_tags)").Scan(&remaining)
+	if remaining != 0 {
+		t.Errorf("Expected deleting the tag to untag everything, %d assignments left", remaining)
+	}
+}
+
+func TestExportZipTags(t *testing.T) {
+	s, folderID := setupTestService(t)
+	ids, _ := storeRandomFiles(t, s, folderID, []int{100, 200})
+	tag, _ := s.CreateTag("Evidence", "")
+	s.TagFiles(ids[:1], tag.ID)
+	s.TagFolders([]int64{folderID}, tag.ID)
+
+	readTags := func(path string) *filestoreutils.ExportTags {
+		reader, err := zip.OpenReader(path)
+		if err != nil {
+			t.Fatalf("Failed to open ZIP: %v", err)
+		}
+		defer reader.Close()
+
+		for _, file := range reader.File {
+			if file.Name != filestoreutils.ExportTagsFile {
+				continue
+			}
+			rc, _ := file.Open()
+			defer rc.Close()
+			data, _ := io.ReadAll(rc)
+			var tags filestoreutils.ExportTags
+			if err := json.Unmarshal(data, &tags); err != nil {
+				t.Fatalf("Failed to parse %s: %v", filestoreutils.ExportTagsFile, err)
+			}
+			return &tags
+		}
+		return nil
+	}
+
+	report, err := s.ExportZipFolders([]int64{folderID}, nil, ExportOptions{Destination: t.TempDir()})
+	if err != nil {
+		t.Fatalf("ExportZipFolders failed: %v", err)
+	}
+	tags := readTags(report.Paths[0])
+	if tags == nil || len(tags.Folder) != 1 || tags.Folder[0] != "Evidence" || len(tags.Files) != 1 {
+		t.Errorf("Unexpected export tags: %+v", tags)
+	}
+
+	// Neutral exports do not reveal how files were labelled
+	report, err = s.ExportZipFolders([]int64{folderID}, nil, ExportOptions{Destination: t.TempDir(), NeutralNames: true})
+	if err != nil {
+		t.Fatalf("ExportZipFolders failed: %v", err)
+	}
+	if tags := readTags(report.Paths[0]); tags != nil {
+		t.Errorf("Expected no tags in a neutral export, got %+v", tags)
+	}
+}
@@ -0,0 +1,204 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/auditutils"
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// tempCopyLifetime is how long a copy opened in another application is kept
+// before being wiped, even if the vault stays unlocked
+const tempCopyLifetime = 30 * time.Minute
+
+// OpenExternally decrypts a file into a private temporary directory and opens
+// it with the system's default application. The copy is recorded so it is
+// wiped after tempCopyLifetime, when the vault is locked, or at the next
+// unlock if the app did not shut down cleanly.
+func (s *service) OpenExternally(id int64) (string, error) {
+	metadata, err := filestoreutils.GetFileMetadataByID(s.db, id)
+	if err != nil {
+		return "", err
+	}
+
+	// A directory of its own keeps the original name, which other applications show
+	dir, err := os.MkdirTemp(s.tempDir, filestoreutils.TempCopyPrefix)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to protect temporary directory: %w", err)
+	}
+	path := filepath.Join(dir, filestoreutils.EnsureFileExtension(filepath.Base(metadata.Name), metadata.MimeType))
+
+	if err := s.writeTempCopy(id, path); err != nil {
+		s.removeTempCopy(path)
+		return "", err
+	}
+
+	tempID, err := s.recordTempCopy(id, path)
+	if err != nil {
+		s.removeTempCopy(path)
+		return "", err
+	}
+	s.scheduleTempWipe(tempID)
+
+	if err := s.openExternal(path); err != nil {
+		s.wipeTempFile(tempID)
+		return "", fmt.Errorf("failed to open file in default application: %w", err)
+	}
+
+	fmt.Printf("Opened file %d externally from %s\n", id, path)
+	return path, nil
+}
+
+func (s *service) writeTempCopy(id int64, path string) error {
+	reader, err := s.OpenFile(id)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary copy: %w", err)
+	}
+	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write temporary copy: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write temporary copy: %w", err)
+	}
+	return nil
+}
+
+// recordTempCopy registers the copy for wiping and logs that the file was viewed
+func (s *service) recordTempCopy(id int64, path string) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	tempID, err := filestoreutils.RecordTempFile(tx, id, path)
+	if err != nil {
+		return 0, err
+	}
+	if err := auditutils.AppendFile(tx, auditutils.EventViewed, "", id, "opened in the default application"); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return tempID, nil
+}
+
+func (s *service) scheduleTempWipe(tempID int64) {
+	s.tempMu.Lock()
+	defer s.tempMu.Unlock()
+
+	s.tempTimers[tempID] = time.AfterFunc(tempCopyLifetime, func() {
+		if err := s.wipeTempFile(tempID); err != nil {
+			fmt.Printf("Warning: Failed to wipe expired temporary copy: %v\n", err)
+		}
+	})
+}
+
+// wipeTempFile securely deletes one recorded copy and forgets it
+func (s *service) wipeTempFile(tempID int64) error {
+	s.tempMu.Lock()
+	if timer, ok := s.tempTimers[tempID]; ok {
+		timer.Stop()
+		delete(s.tempTimers, tempID)
+	}
+	s.tempMu.Unlock()
+
+	var path string
+	if err := s.db.QueryRow("SELECT temp_path FROM temp_files WHERE id = ?", tempID).Scan(&path); err != nil {
+		return fmt.Errorf("failed to get temporary copy %d: %w", tempID, err)
+	}
+	if err := s.removeTempCopy(path); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec("DELETE FROM temp_files WHERE id = ?", tempID); err != nil {
+		return fmt.Errorf("failed to forget temporary copy: %w", err)
+	}
+	return nil
+}
+
+// WipeTempFiles securely deletes every temporary copy, before the vault is
+// locked or the app quits. Copies recorded by an earlier session that crashed
+// are wiped too.
+func (s *service) WipeTempFiles() error {
+	s.tempMu.Lock()
+	for id, timer := range s.tempTimers {
+		timer.Stop()
+		delete(s.tempTimers, id)
+	}
+	s.tempMu.Unlock()
+
+	ids, err := s.queryIDs("SELECT id FROM temp_files")
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, id := range ids {
+		if err := s.wipeTempFile(id); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			failed++
+		}
+	}
+
+	// Copies written but never recorded
+	if err := filestoreutils.WipeTempCopies(s.tempDir); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		failed++
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to wipe %d temporary copies", failed)
+	}
+	return nil
+}
+
+// removeTempCopy wipes a copy and removes its directory. A copy already gone,
+// for instance wiped at startup before the vault was unlocked, is not an error.
+func (s *service) removeTempCopy(path string) error {
+	if err := filestoreutils.SecurelyDeleteFile(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to wipe temporary copy %s: %w", path, err)
+	}
+	os.Remove(filepath.Dir(path))
+	return nil
+}
+
+// openWithDefaultApp hands a file to the desktop's default application for its type
+func openWithDefaultApp(path string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", path)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", path)
+	default:
+		cmd = exec.Command("xdg-open", path)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// Reap the launcher once it exits; the application it started keeps running
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,86 @@
+package filestore
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func countTempFiles(t *testing.T, s *service) int {
+	t.Helper()
+
+	var count int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM temp_files").Scan(&count); err != nil {
+		t.Fatalf("Failed to count temp files: %v", err)
+	}
+	return count
+}
+
+func TestOpenExternally(t *testing.T) {
+	s, folderID := setupTestService(t)
+	ids, contents := storeRandomFiles(t, s, folderID, []int{100, 200})
+
+	var opened []string
+	s.openExternal = func(path string) error {
+		opened = append(opened, path)
+		return nil
+	}
+
+	path, err := s.OpenExternally(ids[0])
+	if err != nil {
+		t.Fatalf("OpenExternally failed: %v", err)
+	}
+	if len(opened) != 1 || opened[0] != path || filepath.Base(path) != "TestOpenExternallya.file" {
+		t.Errorf("Expected the copy to be opened once, got %v", opened)
+	}
+
+	data, _ := os.ReadFile(path)
+	if !bytes.Equal(data, contents[ids[0]]) {
+		t.Error("Temporary copy does not match the stored file")
+	}
+	if info, _ := os.Stat(filepath.Dir(path)); info.Mode().Perm() != 0700 {
+		t.Errorf("Expected a private directory, got %v", info.Mode().Perm())
+	}
+	if got := auditEvents(t, s, ids[0]); len(got) != 2 || got[1] != "viewed" {
+		t.Errorf("Expected opening to be logged, got %v", got)
+	}
+
+	// Expiry wipes a single copy
+	s.OpenExternally(ids[1])
+	var tempID int64
+	s.db.QueryRow("SELECT id FROM temp_files WHERE file_id = ?", ids[0]).Scan(&tempID)
+	if err := s.wipeTempFile(tempID); err != nil {
+		t.Fatalf("wipeTempFile failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(path)); !os.IsNotExist(err) {
+		t.Error("Expected the expired copy and its directory to be removed")
+	}
+	if countTempFiles(t, s) != 1 || len(s.tempTimers) != 1 {
+		t.Errorf("Expected one copy left, got %d records and %d timers", countTempFiles(t, s), len(s.tempTimers))
+	}
+
+	// Locking wipes the rest, including copies never recorded
+	os.Mkdir(filepath.Join(s.tempDir, "open-crashed"), 0700)
+	os.WriteFile(filepath.Join(s.tempDir, "open-crashed", "evidence.jpg"), []byte("plaintext"), 0600)
+	if err := s.WipeTempFiles(); err != nil {
+		t.Fatalf("WipeTempFiles failed: %v", err)
+	}
+	if left, _ := os.ReadDir(s.tempDir); len(left) != 0 || countTempFiles(t, s) != 0 || len(s.tempTimers) != 0 {
+		t.Errorf("Expected every copy to be wiped, found %d entries", len(left))
+	}
+}
+
+func TestOpenExternallyLaunchFailure(t *testing.T) {
+	s, folderID := setupTestService(t)
+	ids, _ := storeRandomFiles(t, s, folderID, []int{100})
+
+	s.openExternal = func(string) error { return fmt.Errorf("no default application") }
+	if _, err := s.OpenExternally(ids[0]); err == nil {
+		t.Fatal("Expected a launch failure to be reported")
+	}
+	if left, _ := os.ReadDir(s.tempDir); len(left) != 0 || countTempFiles(t, s) != 0 {
+		t.Errorf("Expected the copy to be wiped when it cannot be opened, found %d entries", len(left))
+	}
+}
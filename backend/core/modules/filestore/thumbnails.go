@@ -0,0 +1,398 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"context"
+	"database/sql"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Thumbnail edge lengths generated for every image, largest first so each
+// one can be scaled down from the previous
+const (
+	ThumbnailLarge = 512
+	ThumbnailSmall = 128
+)
+
+var thumbnailSizes = []int{ThumbnailLarge, ThumbnailSmall}
+
+// Values of files.thumbnail_state
+const (
+	thumbnailPending     = 0
+	thumbnailDone        = 1
+	thumbnailUnsupported = 2
+)
+
+const (
+	blurhashXComponents = 4
+	blurhashYComponents = 3
+)
+
+// vaultExtent is a region of the TVault owned by a thumbnail or file
+type vaultExtent struct {
+	offset int64
+	length int64
+}
+
+func (s *service) GetThumbnail(fileID int64, size int) (*Thumbnail, error) {
+	var thumbnail Thumbnail
+	var thumbUUID string
+	var length int64
+
+	// Prefer the smallest thumbnail at least as large as requested, else the largest there is
+	offset, err := s.pinExtent(func() (int64, error) {
+		var offset int64
+		err := s.db.QueryRow(`
+			SELECT uuid, size, width, height, mime_type, offset, length
+			FROM thumbnails
+			WHERE file_id = ?
+			ORDER BY (size >= ?) DESC, CASE WHEN size >= ? THEN size ELSE -size END ASC
+			LIMIT 1
+		`, fileID, size, size).Scan(
+			&thumbUUID, &thumbnail.Size, &thumbnail.Width, &thumbnail.Height,
+			&thumbnail.MimeType, &offset, &length,
+		)
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("no thumbnail available for file %d", fileID)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to query thumbnail: %w", err)
+		}
+		return offset, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer s.releaseExtent(offset)
+
+	tvault, err := os.Open(s.tvaultPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open TVault: %w", err)
+	}
+	defer tvault.Close()
+
+	reader, err := filestoreutils.NewChunkReader(tvault, offset, length, filestoreutils.GenerateFileKey(thumbUUID, s.dbKey))
+	if err != nil {
+		return nil, err
+	}
+	thumbnail.Data, err = io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt thumbnail: %w", err)
+	}
+
+	thumbnail.FileID = fileID
+	return &thumbnail, nil
+}
+
+// generatePreviews creates the thumbnails and blurhash of a stored image.
+// Files that are not decodable images are marked so they are not tried again.
+func (s *service) generatePreviews(fileID int64, mimeType string) error {
+	if !filestoreutils.ThumbnailMimeTypes[mimeType] {
+		return s.setThumbnailState(fileID, thumbnailUnsupported, "")
+	}
+
+	reader, err := s.OpenFile(fileID)
+	if err != nil {
+		return err
+	}
+	orientation := filestoreutils.ImageOrientation(reader)
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		reader.Close()
+		return fmt.Errorf("failed to rewind image: %w", err)
+	}
+	img, err := filestoreutils.DecodeImage(reader)
+	reader.Close()
+	if err != nil {
+		if stateErr := s.setThumbnailState(fileID, thumbnailUnsupported, ""); stateErr != nil {
+			return stateErr
+		}
+		return err
+	}
+
+	// Drop anything left by an earlier attempt that was interrupted
+	if err := s.removeThumbnails([]int64{fileID}); err != nil {
+		return err
+	}
+
+	// Sizes are made from the previous one, so only the first is turned upright
+	var source image.Image = img
+	for i, size := range thumbnailSizes {
+		thumb := filestoreutils.ResizeImage(source, size)
+		if i == 0 {
+			thumb = filestoreutils.OrientImage(thumb, orientation)
+		}
+		data, thumbMime, err := filestoreutils.EncodeThumbnail(thumb)
+		if err != nil {
+			return err
+		}
+		if err := s.storeThumbnail(fileID, size, thumb.Bounds().Dx(), thumb.Bounds().Dy(), thumbMime, data); err != nil {
+			return err
+		}
+		source = thumb
+	}
+
+	blurhash, err := filestoreutils.EncodeBlurhash(source, blurhashXComponents, blurhashYComponents)
+	if err != nil {
+		return err
+	}
+
+	return s.setThumbnailState(fileID, thumbnailDone, blurhash)
+}
+
+func (s *service) setThumbnailState(fileID int64, state int, blurhash string) error {
+	var hash interface{}
+	if blurhash != "" {
+		hash = blurhash
+	}
+	_, err := s.db.Exec("UPDATE files SET thumbnail_state = ?, blurhash = ? WHERE id = ?", state, hash, fileID)
+	if err != nil {
+		return fmt.Errorf("failed to update thumbnail state: %w", err)
+	}
+	return nil
+}
+
+// storeThumbnail encrypts a thumbnail under its own UUID-derived key and stores it in the TVault
+func (s *service) storeThumbnail(fileID int64, size, width, height int, mimeType string, data []byte) error {
+	return s.waitForTail(func() error {
+		return s.writeThumbnail(fileID, size, width, height, mimeType, data)
+	})
+}
+
+func (s *service) writeThumbnail(fileID int64, size, width, height int, mimeType string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	tvault, err := os.OpenFile(s.tvaultPath, os.O_RDWR, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open TVault: %w", err)
+	}
+	defer tvault.Close()
+
+	vaultInfo, err := tvault.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat TVault: %w", err)
+	}
+
+	length := filestoreutils.ChunkedLength(int64(len(data)))
+	offset, err := s.allocate(tx, length)
+	if err != nil {
+		return err
+	}
+
+	thumbUUID := uuid.New().String()
+	writer, err := filestoreutils.NewChunkWriter(tvault, offset, filestoreutils.GenerateFileKey(thumbUUID, s.dbKey))
+	if err != nil {
+		return fmt.Errorf("failed to write thumbnail: %w", err)
+	}
+	if _, err := writer.Write(data); err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		s.discardWrite(tvault, offset, length, vaultInfo.Size())
+		return fmt.Errorf("failed to write thumbnail: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO thumbnails (file_id, uuid, size, width, height, mime_type, offset, length, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, datetime('now'))
+	`, fileID, thumbUUID, size, width, height, mimeType, offset, length)
+	if err != nil {
+		s.discardWrite(tvault, offset, length, vaultInfo.Size())
+		return fmt.Errorf("failed to insert thumbnail: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.discardWrite(tvault, offset, length, vaultInfo.Size())
+		return fmt.Errorf("failed to commit thumbnail: %w", err)
+	}
+
+	return nil
+}
+
+// removeThumbnails deletes and wipes the thumbnails of the given files
+func (s *service) removeThumbnails(fileIDs []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	extents, err := deleteThumbnailRecords(tx, fileIDs)
+	if err != nil {
+		return err
+	}
+	if len(extents) == 0 {
+		return nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit thumbnail removal: %w", err)
+	}
+
+	s.wipeExtents(extents)
+	return nil
+}
+
+// deleteThumbnailRecords removes thumbnail rows and returns their extents to free
+// space. The caller wipes the returned extents after committing.
+func deleteThumbnailRecords(tx *sql.Tx, fileIDs []int64) ([]vaultExtent, error) {
+	if len(fileIDs) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, len(fileIDs))
+	for i, id := range fileIDs {
+		args[i] = id
+	}
+	in := strings.TrimSuffix(strings.Repeat("?,", len(fileIDs)), ",")
+
+	rows, err := tx.Query("SELECT offset, length FROM thumbnails WHERE file_id IN ("+in+")", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query thumbnails: %w", err)
+	}
+	var extents []vaultExtent
+	for rows.Next() {
+		var extent vaultExtent
+		if err := rows.Scan(&extent.offset, &extent.length); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan thumbnail: %w", err)
+		}
+		extents = append(extents, extent)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating thumbnails: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM thumbnails WHERE file_id IN ("+in+")", args...); err != nil {
+		return nil, fmt.Errorf("failed to delete thumbnails: %w", err)
+	}
+	for _, extent := range extents {
+		if err := filestoreutils.AddFreeSpace(tx, extent.offset, extent.length); err != nil {
+			return nil, fmt.Errorf("failed to free thumbnail space: %w", err)
+		}
+	}
+
+	return extents, nil
+}
+
+// wipeExtents overwrites freed regions of the TVault. Callers hold s.mu.
+func (s *service) wipeExtents(extents []vaultExtent) {
+	for _, extent := range extents {
+		if err := filestoreutils.SecurelyOverwriteFileData(s.tvaultPath, extent.offset, extent.length); err != nil {
+			fmt.Printf("Warning: Failed to securely overwrite data at offset %d: %v\n", extent.offset, err)
+		}
+	}
+}
+
+func (s *service) StartThumbnailBackfill() error {
+	s.backfillMu.Lock()
+	defer s.backfillMu.Unlock()
+
+	if s.backfillDone != nil {
+		select {
+		case <-s.backfillDone:
+		default:
+			return fmt.Errorf("thumbnail backfill is already running")
+		}
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	done := make(chan struct{})
+	s.backfillCancel = cancel
+	s.backfillDone = done
+
+	go func() {
+		defer close(done)
+		s.runThumbnailBackfill(ctx)
+	}()
+
+	return nil
+}
+
+func (s *service) StopThumbnailBackfill() error {
+	s.backfillMu.Lock()
+	defer s.backfillMu.Unlock()
+
+	if s.backfillDone == nil {
+		return nil
+	}
+
+	s.backfillCancel()
+	<-s.backfillDone
+	s.backfillCancel = nil
+	s.backfillDone = nil
+
+	return nil
+}
+
+// runThumbnailBackfill generates previews for images stored before thumbnails existed
+func (s *service) runThumbnailBackfill(ctx context.Context) {
+	mimeTypes := make([]string, 0, len(filestoreutils.ThumbnailMimeTypes))
+	args := []interface{}{thumbnailPending}
+	for mimeType := range filestoreutils.ThumbnailMimeTypes {
+		mimeTypes = append(mimeTypes, "?")
+		args = append(args, mimeType)
+	}
+	pending := fmt.Sprintf("is_deleted = 0 AND thumbnail_state = ? AND mime_type IN (%s)", strings.Join(mimeTypes, ","))
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM files WHERE "+pending, args...).Scan(&total); err != nil {
+		fmt.Printf("Warning: Failed to count files for thumbnail backfill: %v\n", err)
+		return
+	}
+
+	processed := 0
+	var lastID int64
+	for processed < total {
+		select {
+		case <-ctx.Done():
+			s.emit("thumbnail-backfill-progress", map[string]interface{}{
+				"processed": processed, "total": total, "done": false, "cancelled": true,
+			})
+			return
+		default:
+		}
+
+		var id int64
+		var mimeType string
+		err := s.db.QueryRow("SELECT id, mime_type FROM files WHERE "+pending+" AND id > ? ORDER BY id LIMIT 1",
+			append(args, lastID)...).Scan(&id, &mimeType)
+		if err == sql.ErrNoRows {
+			break
+		}
+		if err != nil {
+			fmt.Printf("Warning: Thumbnail backfill stopped: %v\n", err)
+			break
+		}
+		lastID = id
+
+		if err := s.generatePreviews(id, mimeType); err != nil {
+			fmt.Printf("Warning: Failed to generate previews for file %d: %v\n", id, err)
+		}
+
+		processed++
+		s.emit("thumbnail-backfill-progress", map[string]interface{}{
+			"processed": processed, "total": total, "done": false,
+		})
+	}
+
+	s.emit("thumbnail-backfill-progress", map[string]interface{}{
+		"processed": processed, "total": total, "done": true,
+	})
+}
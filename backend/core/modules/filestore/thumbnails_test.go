@@ -0,0 +1,147 @@
+package filestore
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodeTestPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestStoreFileGeneratesPreviews(t *testing.T) {
+	s, folderID := setupTestService(t)
+
+	// Store a file first so the image's extent is not at the start and can be compacted
+	ids, contents := storeRandomFiles(t, s, folderID, []int{3000})
+
+	metadata, err := s.StoreFile(folderID, "photo.png", "image/png", bytes.NewReader(encodeTestPNG(t, 800, 600)))
+	if err != nil {
+		t.Fatalf("Failed to store image: %v", err)
+	}
+
+	response, _ := s.GetFilesInFolder(folderID)
+	for _, file := range response.Files {
+		if file.ID == metadata.ID && len(file.Blurhash) != 28 {
+			t.Errorf("Expected a 4x3 blurhash, got %q", file.Blurhash)
+		}
+	}
+
+	small, err := s.GetThumbnail(metadata.ID, 100)
+	if err != nil {
+		t.Fatalf("Failed to get thumbnail: %v", err)
+	}
+	if small.Size != ThumbnailSmall || small.Width != 128 || small.Height != 96 || small.MimeType != "image/jpeg" {
+		t.Errorf("Unexpected small thumbnail: %+v", small)
+	}
+	if _, _, err := image.Decode(bytes.NewReader(small.Data)); err != nil {
+		t.Errorf("Thumbnail does not decode: %v", err)
+	}
+
+	large, _ := s.GetThumbnail(metadata.ID, 4000)
+	if large.Size != ThumbnailLarge || large.Width != 512 {
+		t.Errorf("Expected the largest thumbnail for an oversized request, got %+v", large)
+	}
+
+	// Thumbnails are live extents that compaction moves along with files
+	if err := s.DeleteFiles(ids); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+	delete(contents, ids[0])
+	runCompactionToEnd(t, s)
+	if _, err := s.GetThumbnail(metadata.ID, ThumbnailSmall); err != nil {
+		t.Errorf("Thumbnail unreadable after compaction: %v", err)
+	}
+
+	// Deleting the image frees its thumbnails too
+	if err := s.DeleteFiles([]int64{metadata.ID}); err != nil {
+		t.Fatalf("Failed to delete image: %v", err)
+	}
+	var remaining int
+	s.db.QueryRow("SELECT COUNT(*) FROM thumbnails").Scan(&remaining)
+	if remaining != 0 {
+		t.Errorf("Expected thumbnails to be removed, %d remain", remaining)
+	}
+	if regions, _ := s.db.Query("SELECT 1 FROM free_spaces"); regions.Next() {
+		regions.Close()
+		t.Errorf("Expected an empty vault to have no free space left")
+	}
+}
+
+func TestThumbnailFollowsOrientation(t *testing.T) {
+	s, folderID := setupTestService(t)
+
+	// A landscape JPEG tagged to be shown turned 90° clockwise, as phones store portrait photos
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 800, 600)), nil)
+	exif := []byte("\xFF\xE1\x00\x22Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00\x06\x00\x00\x00\x00\x00\x00")
+	photo := append(append([]byte{0xFF, 0xD8}, exif...), buf.Bytes()[2:]...)
+
+	metadata, err := s.StoreFile(folderID, "portrait.jpg", "image/jpeg", bytes.NewReader(photo))
+	if err != nil {
+		t.Fatalf("Failed to store image: %v", err)
+	}
+	if small, err := s.GetThumbnail(metadata.ID, ThumbnailSmall); err != nil || small.Width != 96 || small.Height != 128 {
+		t.Errorf("Expected a portrait thumbnail, got %+v (%v)", small, err)
+	}
+	if large, _ := s.GetThumbnail(metadata.ID, ThumbnailLarge); large.Width != 384 || large.Height != 512 {
+		t.Errorf("Expected a portrait large thumbnail, got %dx%d", large.Width, large.Height)
+	}
+}
+
+func TestThumbnailBackfill(t *testing.T) {
+	s, folderID := setupTestService(t)
+
+	metadata, err := s.StoreFile(folderID, "old.png", "image/png", bytes.NewReader(encodeTestPNG(t, 64, 64)))
+	if err != nil {
+		t.Fatalf("Failed to store image: %v", err)
+	}
+	broken, _ := s.StoreFile(folderID, "broken.png", "image/png", bytes.NewReader([]byte("not a png")))
+
+	// Simulate images stored before previews existed
+	if err := s.removeThumbnails([]int64{metadata.ID}); err != nil {
+		t.Fatalf("Failed to remove thumbnails: %v", err)
+	}
+	s.db.Exec("UPDATE files SET thumbnail_state = 0, blurhash = NULL")
+
+	var events []map[string]interface{}
+	s.emit = func(name string, data ...interface{}) {
+		if name == "thumbnail-backfill-progress" {
+			events = append(events, data[0].(map[string]interface{}))
+		}
+	}
+
+	if err := s.StartThumbnailBackfill(); err != nil {
+		t.Fatalf("Failed to start backfill: %v", err)
+	}
+	<-s.backfillDone
+
+	if _, err := s.GetThumbnail(metadata.ID, ThumbnailSmall); err != nil {
+		t.Errorf("Backfill did not create thumbnails: %v", err)
+	}
+	var state int
+	s.db.QueryRow("SELECT thumbnail_state FROM files WHERE id = ?", broken.ID).Scan(&state)
+	if state != thumbnailUnsupported {
+		t.Errorf("Undecodable image state = %d, want %d", state, thumbnailUnsupported)
+	}
+	if len(events) == 0 || events[len(events)-1]["done"] != true || events[len(events)-1]["processed"] != 2 {
+		t.Errorf("Unexpected progress events: %v", events)
+	}
+}
@@ -0,0 +1,405 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/auditutils"
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	trashRetentionSetting = "trash_retention_days"
+	defaultTrashRetention = 30
+
+	// How often expired trash and retention rules are checked while the vault is unlocked
+	trashPurgeInterval = time.Hour
+)
+
+func (s *service) TrashFiles(ids []int64) error {
+	if len(ids) == 0 {
+		return fmt.Errorf("no file IDs provided")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		result, err := tx.Exec(`
+			UPDATE files SET trashed_at = datetime('now'), trash_root = NULL
+			WHERE id = ? AND is_deleted = 0 AND trashed_at IS NULL
+		`, id)
+		if err != nil {
+			return fmt.Errorf("failed to trash file %d: %w", id, err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return fmt.Errorf("file not found with ID: %d", id)
+		}
+		if err := auditutils.AppendFile(tx, auditutils.EventTrashed, "", id, ""); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) TrashFolders(folderIDs []int64) error {
+	if len(folderIDs) == 0 {
+		return fmt.Errorf("no folder IDs provided")
+	}
+
+	// Subtrees are resolved up front since the connection is held by the transaction below
+	subtrees := make(map[int64][]int64, len(folderIDs))
+	for _, id := range folderIDs {
+		subtree, err := s.getFolderSubtrees([]int64{id})
+		if err != nil {
+			return err
+		}
+		subtrees[id] = subtree
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, root := range folderIDs {
+		if err := checkFolderExists(tx, root); err != nil {
+			return err
+		}
+
+		// Items already in the trash keep their own root so they are restored separately
+		args := []interface{}{root}
+		for _, id := range subtrees[root] {
+			args = append(args, id)
+		}
+		in := placeholders(len(subtrees[root]))
+
+		if _, err := tx.Exec(`
+			UPDATE folders SET trashed_at = datetime('now'), trash_root = ?
+			WHERE id IN (`+in+`) AND trashed_at IS NULL
+		`, args...); err != nil {
+			return fmt.Errorf("failed to trash folder %d: %w", root, err)
+		}
+		if _, err := tx.Exec(`
+			UPDATE files SET trashed_at = datetime('now'), trash_root = ?
+			WHERE folder_id IN (`+in+`) AND is_deleted = 0 AND trashed_at IS NULL
+		`, args...); err != nil {
+			return fmt.Errorf("failed to trash files in folder %d: %w", root, err)
+		}
+
+		if err := auditutils.Append(tx, auditutils.Entry{Event: auditutils.EventTrashed, FolderID: root}); err != nil {
+			return err
+		}
+		if err := appendFilesAudit(tx, auditutils.EventTrashed, fmt.Sprintf("with folder %d", root), "SELECT id FROM files WHERE trash_root = ? AND is_deleted = 0", root); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) RestoreFiles(ids []int64) error {
+	if len(ids) == 0 {
+		return fmt.Errorf("no file IDs provided")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		var name string
+		var folderID int64
+		err := tx.QueryRow(`
+			SELECT name, folder_id FROM files
+			WHERE id = ? AND is_deleted = 0 AND trashed_at IS NOT NULL AND trash_root IS NULL
+		`, id).Scan(&name, &folderID)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("file %d is not in the trash", id)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get trashed file: %w", err)
+		}
+
+		if err := checkFolderExists(tx, folderID); err != nil {
+			return fmt.Errorf("cannot restore '%s', its folder is in the trash: %w", name, err)
+		}
+
+		// A file added under the same name in the meantime keeps it
+		restoredName, err := uniqueFileName(tx, name, folderID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`
+			UPDATE files SET trashed_at = NULL, name = ?, search_name = ? WHERE id = ?
+		`, restoredName, filestoreutils.SearchName(restoredName), id); err != nil {
+			return fmt.Errorf("failed to restore file %d: %w", id, err)
+		}
+		if err := auditutils.AppendFile(tx, auditutils.EventRestored, "", id, ""); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) RestoreFolders(folderIDs []int64) error {
+	if len(folderIDs) == 0 {
+		return fmt.Errorf("no folder IDs provided")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, root := range folderIDs {
+		var name string
+		var parentID int64
+		err := tx.QueryRow(`
+			SELECT name, COALESCE(parent_id, 0) FROM folders WHERE id = ? AND trash_root = id
+		`, root).Scan(&name, &parentID)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("folder %d is not in the trash", root)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get trashed folder: %w", err)
+		}
+
+		if parentID != 0 {
+			if err := checkFolderExists(tx, parentID); err != nil {
+				return fmt.Errorf("cannot restore '%s', its parent folder is in the trash: %w", name, err)
+			}
+		}
+
+		restoredName, err := uniqueFolderName(tx, name, parentID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE folders SET name = ? WHERE id = ?", restoredName, root); err != nil {
+			return fmt.Errorf("failed to rename restored folder: %w", err)
+		}
+
+		if err := auditutils.Append(tx, auditutils.Entry{Event: auditutils.EventRestored, FolderID: root}); err != nil {
+			return err
+		}
+		if err := appendFilesAudit(tx, auditutils.EventRestored, fmt.Sprintf("with folder %d", root), "SELECT id FROM files WHERE trash_root = ? AND is_deleted = 0", root); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("UPDATE folders SET trashed_at = NULL, trash_root = NULL WHERE trash_root = ?", root); err != nil {
+			return fmt.Errorf("failed to restore folder %d: %w", root, err)
+		}
+		if _, err := tx.Exec("UPDATE files SET trashed_at = NULL, trash_root = NULL WHERE trash_root = ?", root); err != nil {
+			return fmt.Errorf("failed to restore files of folder %d: %w", root, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) GetTrash() ([]TrashItem, error) {
+	rows, err := s.db.Query(`
+		SELECT 'file', id, name, folder_id, size, 1, trashed_at
+		FROM files
+		WHERE is_deleted = 0 AND trashed_at IS NOT NULL AND trash_root IS NULL
+		UNION ALL
+		SELECT 'folder', f.id, f.name, COALESCE(f.parent_id, 0),
+			(SELECT COALESCE(SUM(size), 0) FROM files WHERE trash_root = f.id AND is_deleted = 0),
+			(SELECT COUNT(*) FROM files WHERE trash_root = f.id AND is_deleted = 0),
+			f.trashed_at
+		FROM folders f
+		WHERE f.trash_root = f.id
+		ORDER BY 7 DESC, 2 DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trash: %w", err)
+	}
+	defer rows.Close()
+
+	var items []TrashItem
+	for rows.Next() {
+		var item TrashItem
+		if err := rows.Scan(&item.Kind, &item.ID, &item.Name, &item.ParentID, &item.Size, &item.FileCount, &item.TrashedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan trash item: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating trash: %w", err)
+	}
+
+	return items, nil
+}
+
+func (s *service) EmptyTrash() error {
+	return s.purgeTrash("")
+}
+
+func (s *service) GetTrashRetention() (int, error) {
+	return s.getIntSetting(trashRetentionSetting, defaultTrashRetention)
+}
+
+func (s *service) SetTrashRetention(days int) error {
+	if days < 0 {
+		return fmt.Errorf("retention period cannot be negative")
+	}
+	return s.setSetting(trashRetentionSetting, strconv.Itoa(days))
+}
+
+// purgeTrash securely deletes trashed items, all of them or only those
+// trashed before the given SQLite datetime modifier such as '-30 days'
+func (s *service) purgeTrash(olderThan string) error {
+	filter := ""
+	var args []interface{}
+	if olderThan != "" {
+		filter = " AND trashed_at <= datetime('now', ?)"
+		args = append(args, olderThan)
+	}
+
+	fileIDs, err := s.queryIDs("SELECT id FROM files WHERE is_deleted = 0 AND trashed_at IS NOT NULL AND trash_root IS NULL"+filter, args...)
+	if err != nil {
+		return err
+	}
+	folderIDs, err := s.queryIDs("SELECT id FROM folders WHERE trash_root = id"+filter, args...)
+	if err != nil {
+		return err
+	}
+
+	// Files inside trashed folders go with their folder
+	if len(folderIDs) > 0 {
+		if err := s.DeleteFolders(folderIDs); err != nil {
+			return err
+		}
+	}
+	if len(fileIDs) > 0 {
+		remaining, err := s.queryIDs("SELECT id FROM files WHERE is_deleted = 0 AND id IN ("+placeholders(len(fileIDs))+")", int64sToArgs(fileIDs)...)
+		if err != nil {
+			return err
+		}
+		if len(remaining) > 0 {
+			if err := s.DeleteFiles(remaining); err != nil {
+				return err
+			}
+		}
+	}
+
+	if n := len(fileIDs) + len(folderIDs); n > 0 {
+		fmt.Printf("Wiped %d items from the trash\n", n)
+	}
+	return nil
+}
+
+// purgeExpiredTrash wipes items that have been in the trash longer than the retention period
+func (s *service) purgeExpiredTrash() error {
+	days, err := s.GetTrashRetention()
+	if err != nil {
+		return err
+	}
+	if days == 0 {
+		return nil
+	}
+	return s.purgeTrash(fmt.Sprintf("-%d days", days))
+}
+
+// startTrashPurge wipes expired trash and applies retention rules now, and
+// then periodically until stopped
+func (s *service) startTrashPurge() {
+	s.purgeMu.Lock()
+	defer s.purgeMu.Unlock()
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	done := make(chan struct{})
+	s.purgeCancel = cancel
+	s.purgeDone = done
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+		for {
+			if err := s.purgeExpiredTrash(); err != nil {
+				fmt.Printf("Warning: Failed to wipe expired trash: %v\n", err)
+			}
+			if _, err := s.ApplyRetentionRules(false); err != nil {
+				fmt.Printf("Warning: Failed to apply retention rules: %v\n", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *service) StopTrashPurge() error {
+	s.purgeMu.Lock()
+	defer s.purgeMu.Unlock()
+
+	if s.purgeDone == nil {
+		return nil
+	}
+
+	s.purgeCancel()
+	<-s.purgeDone
+	s.purgeCancel = nil
+	s.purgeDone = nil
+
+	return nil
+}
+
+func (s *service) queryIDs(query string, args ...interface{}) ([]int64, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trash: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func int64sToArgs(ids []int64) []interface{} {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return args
+}
@@ -0,0 +1,169 @@
+package filestore
+
+import (
+	"bytes"
+	"testing"
+
+	"Tella-Desktop/backend/utils/filestoreutils"
+)
+
+func TestTrashAndRestoreFiles(t *testing.T) {
+	s, folderID := setupTestService(t)
+	ids, contents := storeRandomFiles(t, s, folderID, []int{100, 200})
+
+	metadata, _ := filestoreutils.GetFileMetadataByID(s.db, ids[0])
+	name := metadata.Name
+
+	if err := s.TrashFiles([]int64{ids[0]}); err != nil {
+		t.Fatalf("Failed to trash file: %v", err)
+	}
+	if err := s.TrashFiles([]int64{ids[0]}); err == nil {
+		t.Error("Expected trashing a file twice to be rejected")
+	}
+
+	response, _ := s.GetFilesInFolder(folderID)
+	if len(response.Files) != 1 || response.Files[0].ID != ids[1] {
+		t.Errorf("Expected only the untrashed file to be listed, got %+v", response.Files)
+	}
+	result, err := s.QueryFiles(FileQuery{})
+	if err != nil {
+		t.Fatalf("QueryFiles failed: %v", err)
+	}
+	if len(result.Files) != 1 {
+		t.Errorf("Expected trashed file to be left out of queries, got %d files", len(result.Files))
+	}
+
+	trash, err := s.GetTrash()
+	if err != nil {
+		t.Fatalf("GetTrash failed: %v", err)
+	}
+	if len(trash) != 1 || trash[0].Kind != TrashKindFile || trash[0].ID != ids[0] || trash[0].Size != 100 {
+		t.Errorf("Unexpected trash: %+v", trash)
+	}
+
+	// The name is free again while the file is in the trash
+	if _, err := s.StoreFile(folderID, name, "application/octet-stream", bytes.NewReader([]byte("new"))); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	if err := s.RestoreFiles([]int64{ids[0]}); err != nil {
+		t.Fatalf("Failed to restore file: %v", err)
+	}
+	response, _ = s.GetFilesInFolder(folderID)
+	if len(response.Files) != 3 {
+		t.Fatalf("Expected 3 files after restore, got %d", len(response.Files))
+	}
+	for _, file := range response.Files {
+		if file.ID == ids[0] && file.Name == name {
+			t.Errorf("Expected restored file to be renamed, still called %s", file.Name)
+		}
+	}
+	checkFileContents(t, s, contents)
+
+	if err := s.RestoreFiles([]int64{ids[0]}); err == nil {
+		t.Error("Expected restoring a file that is not in the trash to be rejected")
+	}
+}
+
+func TestTrashAndRestoreFolders(t *testing.T) {
+	s, _ := setupTestService(t)
+	cases, _ := s.CreateFolder("Cases", 0)
+	interviews, _ := s.CreateFolder("Interviews", cases.ID)
+	ids, contents := storeRandomFiles(t, s, interviews.ID, []int{100, 200})
+
+	// A file trashed on its own stays in the trash when its folder is restored
+	if err := s.TrashFiles([]int64{ids[1]}); err != nil {
+		t.Fatalf("Failed to trash file: %v", err)
+	}
+	if err := s.TrashFolders([]int64{cases.ID}); err != nil {
+		t.Fatalf("Failed to trash folder: %v", err)
+	}
+
+	if _, err := s.GetFilesInFolder(interviews.ID); err == nil {
+		t.Error("Expected trashed subfolder to be hidden")
+	}
+	trash, _ := s.GetTrash()
+	if len(trash) != 2 {
+		t.Fatalf("Expected the folder and the file in the trash, got %+v", trash)
+	}
+
+	if err := s.RestoreFiles([]int64{ids[1]}); err == nil {
+		t.Error("Expected restoring into a trashed folder to be rejected")
+	}
+	if err := s.RestoreFolders([]int64{interviews.ID}); err == nil {
+		t.Error("Expected restoring a subfolder on its own to be rejected")
+	}
+
+	if _, err := s.CreateFolder("Cases", 0); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	if err := s.RestoreFolders([]int64{cases.ID}); err != nil {
+		t.Fatalf("Failed to restore folder: %v", err)
+	}
+
+	path, err := s.GetFolderPath(interviews.ID)
+	if err != nil {
+		t.Fatalf("Failed to get folder path: %v", err)
+	}
+	if path[0].Name == "Cases" {
+		t.Error("Expected restored folder to be renamed around the new one")
+	}
+	response, _ := s.GetFilesInFolder(interviews.ID)
+	if len(response.Files) != 1 || response.Files[0].ID != ids[0] {
+		t.Errorf("Expected only the folder's own file back, got %+v", response.Files)
+	}
+
+	if err := s.RestoreFiles([]int64{ids[1]}); err != nil {
+		t.Fatalf("Failed to restore file: %v", err)
+	}
+	checkFileContents(t, s, contents)
+}
+
+func TestEmptyAndExpireTrash(t *testing.T) {
+	s, folderID := setupTestService(t)
+	other, _ := s.CreateFolder("Other", 0)
+	ids, _ := storeRandomFiles(t, s, folderID, []int{100, 200})
+	storeRandomFiles(t, s, other.ID, []int{300})
+
+	if days, _ := s.GetTrashRetention(); days != defaultTrashRetention {
+		t.Errorf("Got default retention %d, want %d", days, defaultTrashRetention)
+	}
+	if err := s.SetTrashRetention(-1); err == nil {
+		t.Error("Expected negative retention to be rejected")
+	}
+	if err := s.SetTrashRetention(7); err != nil {
+		t.Fatalf("Failed to set retention: %v", err)
+	}
+	if days, _ := s.GetTrashRetention(); days != 7 {
+		t.Errorf("Got retention %d, want 7", days)
+	}
+
+	s.TrashFiles(ids)
+	s.TrashFolders([]int64{other.ID})
+	s.db.Exec("UPDATE files SET trashed_at = datetime('now', '-10 days') WHERE id = ?", ids[0])
+
+	if err := s.purgeExpiredTrash(); err != nil {
+		t.Fatalf("Failed to purge expired trash: %v", err)
+	}
+	var deleted bool
+	s.db.QueryRow("SELECT is_deleted FROM files WHERE id = ?", ids[0]).Scan(&deleted)
+	if !deleted {
+		t.Error("Expected expired file to be wiped")
+	}
+	if trash, _ := s.GetTrash(); len(trash) != 2 {
+		t.Errorf("Expected 2 items left in the trash, got %+v", trash)
+	}
+
+	if err := s.EmptyTrash(); err != nil {
+		t.Fatalf("Failed to empty trash: %v", err)
+	}
+	if trash, _ := s.GetTrash(); len(trash) != 0 {
+		t.Errorf("Expected empty trash, got %+v", trash)
+	}
+	var live, folders int
+	s.db.QueryRow("SELECT COUNT(*) FROM files WHERE is_deleted = 0").Scan(&live)
+	s.db.QueryRow("SELECT COUNT(*) FROM folders WHERE id = ?", other.ID).Scan(&folders)
+	if live != 0 || folders != 0 {
+		t.Errorf("Expected everything wiped, %d files and %d folders left", live, folders)
+	}
+}
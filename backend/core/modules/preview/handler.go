@@ -0,0 +1,161 @@
+package preview
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PathPrefix is where the asset server routes preview requests
+const PathPrefix = "/preview/"
+
+var errLocked = errors.New("vault is locked")
+
+// Files is the part of the file store previews are read from
+type Files interface {
+	OpenFile(id int64) (io.ReadSeekCloser, error)
+}
+
+type preview struct {
+	fileID   int64
+	name     string
+	mimeType string
+}
+
+// Handler serves decrypted files to the webview at unguessable URLs. Content
+// is decrypted from the TVault as it is read, so no plaintext reaches the disk.
+// URLs only work while the vault is unlocked and change every time it is.
+type Handler struct {
+	mu      sync.RWMutex
+	files   Files
+	session string             // random first path segment, new for every unlock
+	tokens  map[string]preview // random second path segment for each previewed file
+	locked  chan struct{}      // closed by Stop to cut off responses still streaming
+}
+
+func NewHandler() *Handler {
+	return &Handler{}
+}
+
+// Start serves previews from files under a new session path
+func (h *Handler) Start(files Files) error {
+	session, err := randomToken()
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.stop()
+	h.files = files
+	h.session = session
+	h.tokens = make(map[string]preview)
+	h.locked = make(chan struct{})
+	return nil
+}
+
+// Stop invalidates every preview URL and ends responses still being sent
+func (h *Handler) Stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stop()
+}
+
+func (h *Handler) stop() {
+	if h.locked != nil {
+		close(h.locked)
+	}
+	h.files = nil
+	h.session = ""
+	h.tokens = nil
+	h.locked = nil
+}
+
+// URL returns the path the webview loads a file's preview from
+func (h *Handler) URL(fileID int64, name string, mimeType string) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.files == nil {
+		return "", errLocked
+	}
+	h.tokens[token] = preview{fileID: fileID, name: name, mimeType: mimeType}
+	return PathPrefix + h.session + "/" + token, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	session, token, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, PathPrefix), "/")
+	if !strings.HasPrefix(r.URL.Path, PathPrefix) || !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.mu.RLock()
+	files, locked := h.files, h.locked
+	file, found := h.tokens[token]
+	valid := found && files != nil && session == h.session
+	h.mu.RUnlock()
+	if !valid {
+		http.NotFound(w, r)
+		return
+	}
+
+	reader, err := files.OpenFile(file.fileID)
+	if err != nil {
+		fmt.Printf("Warning: Failed to open preview of file %d: %v\n", file.fileID, err)
+		http.Error(w, "Failed to open file", http.StatusInternalServerError)
+		return
+	}
+	defer reader.Close()
+
+	header := w.Header()
+	header.Set("Content-Type", file.mimeType)
+	header.Set("Cache-Control", "no-store")
+	header.Set("X-Content-Type-Options", "nosniff")
+	// Previewed documents must not run scripts inside the app
+	header.Set("Content-Security-Policy", "sandbox")
+
+	// ServeContent answers Range requests, which video and audio seeking relies on
+	http.ServeContent(w, r, file.name, time.Time{}, &lockableReader{ReadSeeker: reader, locked: locked})
+}
+
+// lockableReader fails once the vault is locked, so a long video stops
+// streaming instead of being decrypted after the lock
+type lockableReader struct {
+	io.ReadSeeker
+	locked <-chan struct{}
+}
+
+func (r *lockableReader) Read(p []byte) (int, error) {
+	select {
+	case <-r.locked:
+		return 0, errLocked
+	default:
+		return r.ReadSeeker.Read(p)
+	}
+}
+
+func randomToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to generate preview token: %w", err)
+	}
+	return hex.EncodeToString(token), nil
+}
@@ -0,0 +1,97 @@
+package preview
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error { return nil }
+
+type testFiles map[int64][]byte
+
+func (f testFiles) OpenFile(id int64) (io.ReadSeekCloser, error) {
+	data, ok := f[id]
+	if !ok {
+		return nil, fmt.Errorf("file not found with ID: %d", id)
+	}
+	return nopCloser{bytes.NewReader(data)}, nil
+}
+
+func get(h *Handler, url string, header http.Header) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, url, nil)
+	for key, values := range header {
+		request.Header[key] = values
+	}
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestServePreview(t *testing.T) {
+	video := bytes.Repeat([]byte("0123456789"), 1000)
+	h := NewHandler()
+	if _, err := h.URL(1, "clip.mp4", "video/mp4"); err == nil {
+		t.Error("Expected no URLs while the vault is locked")
+	}
+
+	if err := h.Start(testFiles{1: video}); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	url, err := h.URL(1, "clip.mp4", "video/mp4")
+	if err != nil {
+		t.Fatalf("URL failed: %v", err)
+	}
+
+	response := get(h, url, nil)
+	if response.Code != http.StatusOK || !bytes.Equal(response.Body.Bytes(), video) {
+		t.Fatalf("Expected the whole file, got status %d with %d bytes", response.Code, response.Body.Len())
+	}
+	if response.Header().Get("Content-Type") != "video/mp4" || response.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Unexpected headers: %v", response.Header())
+	}
+
+	// Seeking in a video requests byte ranges
+	response = get(h, url, http.Header{"Range": {"bytes=5000-5009"}})
+	if response.Code != http.StatusPartialContent || response.Body.String() != "0123456789" {
+		t.Errorf("Expected a partial response, got status %d: %q", response.Code, response.Body.String())
+	}
+	if got := response.Header().Get("Content-Range"); got != "bytes 5000-5009/10000" {
+		t.Errorf("Unexpected Content-Range %q", got)
+	}
+
+	if response := get(h, url+"x", nil); response.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown token to be rejected, got %d", response.Code)
+	}
+
+	// Locking invalidates URLs, and unlocking again issues new ones
+	h.Stop()
+	if response := get(h, url, nil); response.Code != http.StatusNotFound {
+		t.Errorf("Expected previews to stop after locking, got %d", response.Code)
+	}
+	h.Start(testFiles{1: video})
+	if response := get(h, url, nil); response.Code != http.StatusNotFound {
+		t.Errorf("Expected URLs from an earlier unlock to stay invalid, got %d", response.Code)
+	}
+}
+
+func TestStopEndsStreaming(t *testing.T) {
+	locked := make(chan struct{})
+	reader := &lockableReader{ReadSeeker: bytes.NewReader(make([]byte, 100)), locked: locked}
+
+	buf := make([]byte, 10)
+	if _, err := reader.Read(buf); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	close(locked)
+	if _, err := reader.Read(buf); err != errLocked {
+		t.Errorf("Expected reads to fail after locking, got %v", err)
+	}
+}
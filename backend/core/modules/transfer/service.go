@@ -1,6 +1,7 @@
 package transfer
 
 import (
+	"Tella-Desktop/backend/utils/auditutils"
 	"Tella-Desktop/backend/utils/transferutils"
 	"context"
 	"database/sql"
@@ -61,12 +62,20 @@ func (s *service) PrepareUpload(request *PrepareUploadRequest) (*PrepareUploadRe
 
 	s.pendingTransfers.Store(request.SessionID, pendingTransfer)
 
+	// Warn while the user decides, before a transfer too large for the disk is accepted
+	totalSize := s.calculateTotalSize(request.Files)
+	storage, err := s.fileService.CheckDiskSpace(totalSize)
+	if err != nil {
+		runtime.LogError(s.ctx, fmt.Sprintf("Failed to check disk space: %v", err))
+	}
+
 	runtime.EventsEmit(s.ctx, "prepare-upload-request", map[string]interface{}{
 		"sessionId":  request.SessionID,
 		"title":      request.Title,
 		"files":      request.Files,
 		"totalFiles": len(request.Files),
-		"totalSize":  s.calculateTotalSize(request.Files),
+		"totalSize":  totalSize,
+		"storage":    storage,
 	})
 
 	select {
@@ -93,7 +102,7 @@ func (s *service) AcceptTransfer(sessionID string) error {
 		return fmt.Errorf("invalid pending transfer data")
 	}
 
-	folderID, err := s.createTransferFolder(pendingTransfer.Title)
+	folderID, err := s.createTransferFolder(pendingTransfer.Title, sessionID)
 	if err != nil {
 		return fmt.Errorf("failed to create transfer folder: %w", err)
 	}
@@ -194,7 +203,8 @@ func (s *service) HandleUpload(sessionID, transmissionID, fileID string, reader
 		"fileSize":  transfer.FileInfo.Size,
 	})
 
-	metadata, err := s.fileService.StoreFile(actualFolderID, fileName, mimeType, reader)
+	sender := fmt.Sprintf("nearby device (session %s)", sessionID)
+	metadata, err := s.fileService.ReceiveFile(actualFolderID, fileName, mimeType, reader, sender)
 	if err != nil {
 		transfer.Status = "failed"
 		s.transfers.Store(fileID, transfer)
@@ -223,7 +233,7 @@ func (s *service) calculateTotalSize(files []FileInfo) int64 {
 	return total
 }
 
-func (s *service) createTransferFolder(title string) (int64, error) {
+func (s *service) createTransferFolder(title string, sessionID string) (int64, error) {
 	tx, err := s.db.Begin()
 	if err != nil {
 		return 0, fmt.Errorf("failed to begin transaction: %w", err)
@@ -244,6 +254,15 @@ func (s *service) createTransferFolder(title string) (int64, error) {
 		return 0, fmt.Errorf("failed to get folder ID: %w", err)
 	}
 
+	err = auditutils.Append(tx, auditutils.Entry{
+		Event:    auditutils.EventCreated,
+		FolderID: folderID,
+		Details:  fmt.Sprintf("'%s' for transfer accepted from nearby device (session %s)", title, sessionID),
+	})
+	if err != nil {
+		return 0, err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
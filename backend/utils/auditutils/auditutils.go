@@ -0,0 +1,269 @@
+package auditutils
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Event types recorded in the audit log
+const (
+	EventReceived = "received" // stored from a nearby device
+	EventImported = "imported" // stored from a local file
+	EventStored   = "stored"
+	EventCopied   = "copied"
+	EventCreated  = "created"
+	EventViewed   = "viewed"
+	EventExported = "exported"
+	EventMoved    = "moved"
+	EventRenamed  = "renamed"
+	EventTrashed  = "trashed"
+	EventRestored = "restored"
+	EventDeleted  = "deleted"
+	EventWiped    = "wiped" // an exported copy was securely deleted
+)
+
+// ActorLocal is the person using this device
+const ActorLocal = "local user"
+
+// GenesisHash is the previous hash of the first entry
+var GenesisHash = hex.EncodeToString(make([]byte, sha256.Size))
+
+// Entry is one event in the chain-of-custody log. Hash covers every other
+// field, including the hash of the entry before it.
+type Entry struct {
+	ID          int64  `json:"id"`
+	Event       string `json:"event"`
+	Actor       string `json:"actor"`
+	FileID      int64  `json:"fileId"`      // 0 when the event concerns no file
+	FolderID    int64  `json:"folderId"`    // 0 when the event concerns no folder
+	ContentHash string `json:"contentHash"` // SHA-256 of the file's plaintext, when known
+	Details     string `json:"details"`
+	CreatedAt   string `json:"createdAt"` // RFC 3339 in UTC, as hashed
+	PrevHash    string `json:"prevHash"`
+	Hash        string `json:"hash"`
+}
+
+// ComputeHash returns the SHA-256 of the entry's JSON encoding without its
+// own hash. Field order is fixed, so the chain can be checked outside Tella.
+func ComputeHash(entry Entry) string {
+	entry.Hash = ""
+	data, _ := json.Marshal(struct {
+		ID          int64  `json:"id"`
+		Event       string `json:"event"`
+		Actor       string `json:"actor"`
+		FileID      int64  `json:"fileId"`
+		FolderID    int64  `json:"folderId"`
+		ContentHash string `json:"contentHash"`
+		Details     string `json:"details"`
+		CreatedAt   string `json:"createdAt"`
+		PrevHash    string `json:"prevHash"`
+	}{entry.ID, entry.Event, entry.Actor, entry.FileID, entry.FolderID, entry.ContentHash, entry.Details, entry.CreatedAt, entry.PrevHash})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Append adds an entry after the current head of the log. Callers pass the
+// transaction of the change being logged, so both commit or neither does.
+func Append(tx *sql.Tx, entry Entry) error {
+	err := tx.QueryRow("SELECT id, hash FROM audit_log ORDER BY id DESC LIMIT 1").Scan(&entry.ID, &entry.PrevHash)
+	if err == sql.ErrNoRows {
+		entry.ID, entry.PrevHash = 0, GenesisHash
+	} else if err != nil {
+		return fmt.Errorf("failed to read audit log head: %w", err)
+	}
+
+	entry.ID++
+	if entry.Actor == "" {
+		entry.Actor = ActorLocal
+	}
+	entry.CreatedAt = time.Now().UTC().Format(time.RFC3339Nano)
+	entry.Hash = ComputeHash(entry)
+
+	_, err = tx.Exec(`
+		INSERT INTO audit_log (id, event, actor, file_id, folder_id, content_hash, details, created_at, prev_hash, hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.ID, entry.Event, entry.Actor, nullableID(entry.FileID), nullableID(entry.FolderID), entry.ContentHash, entry.Details, entry.CreatedAt, entry.PrevHash, entry.Hash)
+	if err != nil {
+		return fmt.Errorf("failed to append audit entry: %w", err)
+	}
+	return nil
+}
+
+// AppendFile logs an event about a stored file, taking its folder and
+// content hash from the files table
+func AppendFile(tx *sql.Tx, event string, actor string, fileID int64, details string) error {
+	entry := Entry{Event: event, Actor: actor, FileID: fileID, Details: details}
+	err := tx.QueryRow("SELECT folder_id, COALESCE(sha256, '') FROM files WHERE id = ?", fileID).Scan(&entry.FolderID, &entry.ContentHash)
+	if err != nil {
+		return fmt.Errorf("failed to get file %d for audit log: %w", fileID, err)
+	}
+	return Append(tx, entry)
+}
+
+func nullableID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// List returns entries in log order, only those about fileID unless it is 0
+func List(q queryer, fileID int64) ([]Entry, error) {
+	query := `
+		SELECT id, event, actor, COALESCE(file_id, 0), COALESCE(folder_id, 0),
+			content_hash, details, created_at, prev_hash, hash
+		FROM audit_log`
+	var args []interface{}
+	if fileID != 0 {
+		query += " WHERE file_id = ?"
+		args = append(args, fileID)
+	}
+
+	rows, err := q.Query(query+" ORDER BY id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var entry Entry
+		if err := rows.Scan(&entry.ID, &entry.Event, &entry.Actor, &entry.FileID, &entry.FolderID,
+			&entry.ContentHash, &entry.Details, &entry.CreatedAt, &entry.PrevHash, &entry.Hash); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// Verification reports whether every entry matches its hash and links to the one before it
+type Verification struct {
+	Valid   bool     `json:"valid"`
+	Entries int      `json:"entries"`
+	Head    string   `json:"head"` // hash of the last entry
+	Errors  []string `json:"errors"`
+}
+
+// Verify walks the chain from the first entry. A changed entry no longer
+// matches its hash, and a removed or inserted one breaks the link after it.
+func Verify(entries []Entry) *Verification {
+	result := &Verification{Entries: len(entries), Head: GenesisHash, Errors: []string{}}
+
+	var lastID int64
+	for _, entry := range entries {
+		if entry.ID != lastID+1 {
+			result.Errors = append(result.Errors, fmt.Sprintf("entry %d follows entry %d", entry.ID, lastID))
+		}
+		if entry.PrevHash != result.Head {
+			result.Errors = append(result.Errors, fmt.Sprintf("entry %d does not link to the entry before it", entry.ID))
+		}
+		if ComputeHash(entry) != entry.Hash {
+			result.Errors = append(result.Errors, fmt.Sprintf("entry %d does not match its hash", entry.ID))
+		}
+		lastID = entry.ID
+		result.Head = entry.Hash
+	}
+
+	result.Valid = len(result.Errors) == 0
+	return result
+}
+
+// SigningKey derives the vault's Ed25519 audit signing key from the database key
+func SigningKey(dbKey []byte) ed25519.PrivateKey {
+	hash := sha256.New()
+	hash.Write(dbKey)
+	hash.Write([]byte("audit-log-signing"))
+	return ed25519.NewKeyFromSeed(hash.Sum(nil))
+}
+
+// Signature is written next to an exported log. It signs the exact bytes of the export.
+type Signature struct {
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"publicKey"` // hex encoded
+	SHA256    string `json:"sha256"`    // of the exported file
+	Signature string `json:"signature"` // hex encoded
+	Head      string `json:"head"`      // hash of the last exported entry
+	Entries   int    `json:"entries"`
+	SignedAt  string `json:"signedAt"`
+}
+
+// Sign signs an exported log with key
+func Sign(key ed25519.PrivateKey, data []byte, head string, entries int) Signature {
+	sum := sha256.Sum256(data)
+	return Signature{
+		Algorithm: "Ed25519",
+		PublicKey: hex.EncodeToString(key.Public().(ed25519.PublicKey)),
+		SHA256:    hex.EncodeToString(sum[:]),
+		Signature: hex.EncodeToString(ed25519.Sign(key, data)),
+		Head:      head,
+		Entries:   entries,
+		SignedAt:  time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// VerifySignature checks a signature over an exported log
+func VerifySignature(signature Signature, data []byte) bool {
+	publicKey, err := hex.DecodeString(signature.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return false
+	}
+	sig, err := hex.DecodeString(signature.Signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(publicKey, data, sig)
+}
+
+// WriteJSON writes entries as an indented JSON array
+func WriteJSON(w io.Writer, entries []Entry) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+// CSVHeader lists the columns written by WriteCSV
+var CSVHeader = []string{"id", "event", "actor", "file_id", "folder_id", "content_hash", "details", "created_at", "prev_hash", "hash"}
+
+// WriteCSV writes entries with a header row, one entry per row
+func WriteCSV(w io.Writer, entries []Entry) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(CSVHeader); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		err := writer.Write([]string{
+			strconv.FormatInt(entry.ID, 10),
+			entry.Event,
+			entry.Actor,
+			strconv.FormatInt(entry.FileID, 10),
+			strconv.FormatInt(entry.FolderID, 10),
+			entry.ContentHash,
+			entry.Details,
+			entry.CreatedAt,
+			entry.PrevHash,
+			entry.Hash,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,227 @@
+package authutils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DataLocationFile, in the configuration directory, records where the vault
+// was moved to. Without it the vault stays in the XDG data directory.
+const DataLocationFile = "vault-location"
+
+var (
+	locationMu sync.RWMutex
+	dataDir    string
+)
+
+// LoadDataLocation reads where the vault was moved to, if it was
+func LoadDataLocation() error {
+	data, err := os.ReadFile(getDataLocationPath())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read vault location: %w", err)
+	}
+
+	locationMu.Lock()
+	dataDir = strings.TrimSpace(string(data))
+	locationMu.Unlock()
+	return nil
+}
+
+// GetDataDir returns the directory holding the vaults, databases and profile index
+func GetDataDir() string {
+	return filepath.Dir(resolveDataFile(TVaultFile))
+}
+
+// MoveDataDir copies the data directory to destination, checks every copy
+// against its original, and only then switches to the new location and
+// removes the originals. destination must be empty or not exist yet, and
+// every vault must be locked.
+func MoveDataDir(destination string) error {
+	source, err := filepath.Abs(GetDataDir())
+	if err != nil {
+		return fmt.Errorf("failed to resolve vault directory: %w", err)
+	}
+	destination, err = filepath.Abs(destination)
+	if err != nil {
+		return fmt.Errorf("failed to resolve destination: %w", err)
+	}
+	if isWithin(destination, source) || isWithin(source, destination) {
+		return fmt.Errorf("destination must be outside the current vault directory")
+	}
+
+	created, err := prepareDestination(destination)
+	if err != nil {
+		return err
+	}
+
+	files, err := copyDataDir(source, destination)
+	if err != nil {
+		// Leave the destination as it was found
+		if created {
+			os.RemoveAll(destination)
+		} else {
+			removeCopies(destination, files)
+		}
+		return err
+	}
+
+	if err := writeDataLocation(destination); err != nil {
+		if created {
+			os.RemoveAll(destination)
+		} else {
+			removeCopies(destination, files)
+		}
+		return err
+	}
+
+	// The copies are verified and in use; the originals can go
+	if err := removeCopies(source, files); err != nil {
+		return fmt.Errorf("vault moved, but failed to remove the original: %w", err)
+	}
+	return nil
+}
+
+// prepareDestination creates the destination, or checks that it is empty.
+// It reports whether the directory was created.
+func prepareDestination(destination string) (bool, error) {
+	entries, err := os.ReadDir(destination)
+	if err == nil {
+		if len(entries) > 0 {
+			return false, fmt.Errorf("destination is not empty: %s", destination)
+		}
+		return false, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("failed to read destination: %w", err)
+	}
+
+	if err := os.MkdirAll(destination, 0700); err != nil {
+		return false, fmt.Errorf("failed to create destination: %w", err)
+	}
+	return true, nil
+}
+
+// copyDataDir copies and verifies every file under source, returning the
+// paths copied relative to it. Directories come before their contents.
+func copyDataDir(source, destination string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(source, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(source, path)
+		if err != nil || relPath == "." {
+			return err
+		}
+
+		target := filepath.Join(destination, relPath)
+		if entry.IsDir() {
+			if err := os.Mkdir(target, 0700); err != nil {
+				return fmt.Errorf("failed to create directory %s: %w", relPath, err)
+			}
+		} else if err := copyVerified(path, target); err != nil {
+			return err
+		}
+
+		files = append(files, relPath)
+		return nil
+	})
+	return files, err
+}
+
+// copyVerified copies a file, flushes it to disk, and reads the copy back to
+// compare its hash with the original's
+func copyVerified(source, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", source, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", target, err)
+	}
+
+	sourceHash := sha256.New()
+	if _, err := io.Copy(out, io.TeeReader(in, sourceHash)); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy %s: %w", source, err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to flush %s: %w", target, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+
+	copied, err := os.Open(target)
+	if err != nil {
+		return fmt.Errorf("failed to verify %s: %w", target, err)
+	}
+	defer copied.Close()
+
+	targetHash := sha256.New()
+	if _, err := io.Copy(targetHash, copied); err != nil {
+		return fmt.Errorf("failed to verify %s: %w", target, err)
+	}
+	if !bytes.Equal(sourceHash.Sum(nil), targetHash.Sum(nil)) {
+		return fmt.Errorf("copy of %s does not match the original", source)
+	}
+	return nil
+}
+
+// removeCopies removes the listed files, then their directories, from root.
+// Anything else found there is left alone.
+func removeCopies(root string, files []string) error {
+	var failed error
+	for i := len(files) - 1; i >= 0; i-- {
+		if err := os.Remove(filepath.Join(root, files[i])); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			failed = err
+		}
+	}
+	return failed
+}
+
+func writeDataLocation(dir string) error {
+	path := getDataLocationPath()
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(dir), 0600); err != nil {
+		return fmt.Errorf("failed to save vault location: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to save vault location: %w", err)
+	}
+
+	locationMu.Lock()
+	dataDir = dir
+	locationMu.Unlock()
+	return nil
+}
+
+func getDataLocationPath() string {
+	path, err := xdgConfigFile(filepath.Join(TellaAppName, DataLocationFile))
+	if err != nil {
+		return filepath.Join(".", DataLocationFile)
+	}
+	return path
+}
+
+// isWithin reports whether path is dir or inside it
+func isWithin(path, dir string) bool {
+	relPath, err := filepath.Rel(dir, path)
+	return err == nil && relPath != ".." && !strings.HasPrefix(relPath, ".."+string(filepath.Separator))
+}
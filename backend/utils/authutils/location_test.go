@@ -0,0 +1,78 @@
+package authutils
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMoveDataDir(t *testing.T) {
+	mockDataDir(t)
+	configDir := t.TempDir()
+	originalXdgConfigFile := xdgConfigFile
+	t.Cleanup(func() { xdgConfigFile = originalXdgConfigFile })
+	xdgConfigFile = func(path string) (string, error) {
+		path = filepath.Join(configDir, path)
+		return path, os.MkdirAll(filepath.Dir(path), 0755)
+	}
+
+	vault := bytes.Repeat([]byte("vault"), 1000)
+	os.WriteFile(GetTVaultPath(), vault, 0600)
+	os.WriteFile(GetDatabasePath(), []byte("database"), 0600)
+	id, _ := AddProfile()
+	os.WriteFile(filepath.Join(GetProfileDir(id), TVaultFile), []byte("second vault"), 0600)
+	source := GetDataDir()
+
+	if err := MoveDataDir(filepath.Join(source, "inside")); err == nil {
+		t.Error("Expected moving into the vault directory to be rejected")
+	}
+	occupied := t.TempDir()
+	os.WriteFile(filepath.Join(occupied, "notes.txt"), nil, 0600)
+	if err := MoveDataDir(occupied); err == nil {
+		t.Error("Expected a non-empty destination to be rejected")
+	}
+
+	destination := filepath.Join(t.TempDir(), "vault")
+	if err := MoveDataDir(destination); err != nil {
+		t.Fatalf("MoveDataDir failed: %v", err)
+	}
+
+	if GetTVaultPath() != filepath.Join(destination, TVaultFile) {
+		t.Errorf("Expected the vault at the destination, got %s", GetTVaultPath())
+	}
+	if data, _ := os.ReadFile(GetTVaultPath()); !bytes.Equal(data, vault) {
+		t.Error("Moved vault does not match the original")
+	}
+	if profiles, _ := ListProfiles(); len(profiles) != 2 {
+		t.Errorf("Expected both profiles to move, got %+v", profiles)
+	}
+	if left, _ := os.ReadDir(source); len(left) != 0 {
+		t.Errorf("Expected the originals to be removed, found %d entries in %s", len(left), source)
+	}
+
+	// The new location is used after a restart
+	dataDir = ""
+	if err := LoadDataLocation(); err != nil || GetDataDir() != destination {
+		t.Errorf("Expected %s to be loaded, got %s (%v)", destination, GetDataDir(), err)
+	}
+}
+
+func TestEnablePortableMode(t *testing.T) {
+	originalXdgDataFile, originalXdgCacheFile, originalXdgConfigFile := xdgDataFile, xdgCacheFile, xdgConfigFile
+	t.Cleanup(func() {
+		xdgDataFile, xdgCacheFile, xdgConfigFile = originalXdgDataFile, originalXdgCacheFile, originalXdgConfigFile
+		portableRoot = ""
+	})
+
+	root := filepath.Join(t.TempDir(), PortableDataDir)
+	if err := EnablePortableMode(root); err != nil {
+		t.Fatalf("EnablePortableMode failed: %v", err)
+	}
+
+	for _, path := range []string{GetTVaultPath(), GetDatabasePath(), GetTempDir(), GetExportDir(), getDataLocationPath()} {
+		if !isWithin(path, root) {
+			t.Errorf("Expected %s to be inside %s", path, root)
+		}
+	}
+}
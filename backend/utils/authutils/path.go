@@ -28,22 +28,14 @@ var xdgConfigFile = func(relPath string) (string, error) {
 	return xdg.ConfigFile(relPath)
 }
 
+// GetTVaultPath returns the vault file of the active profile
 func GetTVaultPath() string {
-	path, err := xdgDataFile(filepath.Join(TellaAppName, TVaultFile))
-	if err != nil {
-		// Fallback to local directory
-		return filepath.Join(".", TVaultFile)
-	}
-	return path
+	return getDataFile(ActiveProfile(), TVaultFile)
 }
 
+// GetDatabasePath returns the database file of the active profile
 func GetDatabasePath() string {
-	path, err := xdgDataFile(filepath.Join(TellaAppName, TellaDBFile))
-	if err != nil {
-		// Fallback to local directory
-		return filepath.Join(".", TellaDBFile)
-	}
-	return path
+	return getDataFile(ActiveProfile(), TellaDBFile)
 }
 
 func GetTempDir() string {
@@ -65,7 +57,11 @@ func GetTempDir() string {
 }
 
 func GetExportDir() string {
+	// Exports stay on the drive in portable mode
 	downloadDir := xdg.UserDirs.Download
+	if portableRoot != "" {
+		downloadDir = portableRoot
+	}
 
 	exportDir := filepath.Join(downloadDir, TellaAppName)
 
@@ -0,0 +1,74 @@
+package authutils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Portable mode keeps every file the app writes in a directory next to the
+// binary, so the app and its vault can be carried on a removable drive
+// without leaving anything on the host.
+const (
+	PortableMarkerFile = "tella-portable"
+	PortableFlag       = "--portable"
+	PortableDataDir    = "TellaData"
+)
+
+var portableRoot string
+
+// DetectPortableMode enables portable mode when the binary was started with
+// PortableFlag or a PortableMarkerFile sits next to it
+func DetectPortableMode(args []string) (bool, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return false, fmt.Errorf("failed to locate executable: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+
+	dir := filepath.Dir(exe)
+	// On macOS the binary is inside the app bundle; the marker sits next to the bundle
+	if strings.HasSuffix(dir, filepath.Join(".app", "Contents", "MacOS")) {
+		dir = filepath.Dir(filepath.Dir(filepath.Dir(dir)))
+	}
+
+	_, err = os.Stat(filepath.Join(dir, PortableMarkerFile))
+	if err != nil && !slices.Contains(args, PortableFlag) {
+		return false, nil
+	}
+
+	return true, EnablePortableMode(filepath.Join(dir, PortableDataDir))
+}
+
+// EnablePortableMode stores data, cache, configuration, certificates and
+// exports under root instead of the user's XDG directories
+func EnablePortableMode(root string) error {
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return fmt.Errorf("failed to create portable data directory: %w", err)
+	}
+
+	portableRoot = root
+	xdgDataFile = portableFile("data")
+	xdgCacheFile = portableFile("cache")
+	xdgConfigFile = portableFile("config")
+	return nil
+}
+
+// PortableRoot returns the portable data directory, or "" outside portable mode
+func PortableRoot() string {
+	return portableRoot
+}
+
+func portableFile(kind string) func(string) (string, error) {
+	return func(relPath string) (string, error) {
+		path := filepath.Join(portableRoot, kind, relPath)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+}
@@ -0,0 +1,286 @@
+package authutils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Profile constants. The default profile keeps the vault created before
+// profiles existed in the app's data directory; every other profile lives in
+// a directory of its own named by a random ID.
+const (
+	DefaultProfileID = "default"
+	ProfilesDir      = "profiles"
+	ProfilesFile     = "profiles.json"
+	profileIDLength  = 16
+)
+
+var ErrProfileNotFound = errors.New("profile not found")
+
+// Profile is an entry in the profile index. The name is encrypted with a key
+// derived from the profile's database key, so neither the index nor the
+// directory layout reveals what a vault is about.
+type Profile struct {
+	ID            string `json:"id"`
+	EncryptedName []byte `json:"name,omitempty"`
+}
+
+type profileIndex struct {
+	Active   string    `json:"active"`
+	Profiles []Profile `json:"profiles"`
+}
+
+var (
+	profileMu     sync.RWMutex
+	activeProfile = DefaultProfileID
+)
+
+// ActiveProfile returns the ID of the profile the vault paths point at
+func ActiveProfile() string {
+	profileMu.RLock()
+	defer profileMu.RUnlock()
+	return activeProfile
+}
+
+// RestoreActiveProfile selects the profile that was active when the app last ran
+func RestoreActiveProfile() error {
+	index, err := readProfileIndex()
+	if err != nil {
+		return err
+	}
+
+	profileMu.Lock()
+	defer profileMu.Unlock()
+	activeProfile = DefaultProfileID
+	if index.find(index.Active) >= 0 {
+		activeProfile = index.Active
+	}
+	return nil
+}
+
+// SetActiveProfile points the vault paths at another profile and remembers
+// the choice for the next start
+func SetActiveProfile(id string) error {
+	index, err := readProfileIndex()
+	if err != nil {
+		return err
+	}
+	if index.find(id) < 0 {
+		return ErrProfileNotFound
+	}
+
+	index.Active = id
+	if err := writeProfileIndex(index); err != nil {
+		return err
+	}
+
+	profileMu.Lock()
+	activeProfile = id
+	profileMu.Unlock()
+	return nil
+}
+
+// ListProfiles returns every profile in creation order, starting with the default one
+func ListProfiles() ([]Profile, error) {
+	index, err := readProfileIndex()
+	if err != nil {
+		return nil, err
+	}
+	return index.Profiles, nil
+}
+
+// AddProfile creates a new, empty profile and returns its ID. The vault is
+// created once the profile is active and a password is set.
+func AddProfile() (string, error) {
+	index, err := readProfileIndex()
+	if err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, profileIDLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate profile ID: %w", err)
+	}
+	id := hex.EncodeToString(buf)
+
+	if err := os.MkdirAll(GetProfileDir(id), 0700); err != nil {
+		return "", fmt.Errorf("failed to create profile directory: %w", err)
+	}
+
+	index.Profiles = append(index.Profiles, Profile{ID: id})
+	if err := writeProfileIndex(index); err != nil {
+		os.RemoveAll(GetProfileDir(id))
+		return "", err
+	}
+	return id, nil
+}
+
+// RemoveProfile forgets a profile and deletes its directory. It is only used
+// to undo a profile whose creation failed; the default profile is never removed.
+func RemoveProfile(id string) error {
+	if id == DefaultProfileID {
+		return fmt.Errorf("the default profile cannot be removed")
+	}
+
+	index, err := readProfileIndex()
+	if err != nil {
+		return err
+	}
+	i := index.find(id)
+	if i < 0 {
+		return ErrProfileNotFound
+	}
+
+	index.Profiles = append(index.Profiles[:i], index.Profiles[i+1:]...)
+	if index.Active == id {
+		index.Active = DefaultProfileID
+	}
+	if err := writeProfileIndex(index); err != nil {
+		return err
+	}
+
+	profileMu.Lock()
+	if activeProfile == id {
+		activeProfile = DefaultProfileID
+	}
+	profileMu.Unlock()
+
+	if err := os.RemoveAll(GetProfileDir(id)); err != nil {
+		return fmt.Errorf("failed to remove profile directory: %w", err)
+	}
+	return nil
+}
+
+// SetProfileName stores a profile's name, encrypted with its database key
+func SetProfileName(id string, name string, dbKey []byte) error {
+	encrypted, err := EncryptData([]byte(name), profileNameKey(dbKey))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt profile name: %w", err)
+	}
+
+	index, err := readProfileIndex()
+	if err != nil {
+		return err
+	}
+	i := index.find(id)
+	if i < 0 {
+		return ErrProfileNotFound
+	}
+
+	index.Profiles[i].EncryptedName = encrypted
+	return writeProfileIndex(index)
+}
+
+// DecryptProfileName reveals a profile's name once its vault is unlocked
+func DecryptProfileName(profile Profile, dbKey []byte) (string, error) {
+	if len(profile.EncryptedName) == 0 {
+		return "", nil
+	}
+	name, err := DecryptData(profile.EncryptedName, profileNameKey(dbKey))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt profile name: %w", err)
+	}
+	return string(name), nil
+}
+
+// profileNameKey keeps the key that encrypts names separate from the one
+// that encrypts the database
+func profileNameKey(dbKey []byte) []byte {
+	key := sha256.Sum256(append(append([]byte{}, dbKey...), []byte("profile-name")...))
+	return key[:]
+}
+
+// GetProfileDir returns the directory holding a profile's vault and database
+func GetProfileDir(id string) string {
+	return filepath.Dir(getDataFile(id, TVaultFile))
+}
+
+// getDataFile resolves a file in a profile's data directory
+func getDataFile(id string, name string) string {
+	relPath := name
+	if id != DefaultProfileID {
+		relPath = filepath.Join(ProfilesDir, id, name)
+	}
+
+	return resolveDataFile(relPath)
+}
+
+func getProfileIndexPath() string {
+	return resolveDataFile(ProfilesFile)
+}
+
+// resolveDataFile places a file in the data directory the vault was moved
+// to, or else in the XDG data directory
+func resolveDataFile(relPath string) string {
+	locationMu.RLock()
+	dir := dataDir
+	locationMu.RUnlock()
+	if dir != "" {
+		path := filepath.Join(dir, relPath)
+		os.MkdirAll(filepath.Dir(path), 0700)
+		return path
+	}
+
+	path, err := xdgDataFile(filepath.Join(TellaAppName, relPath))
+	if err != nil {
+		// Fallback to local directory
+		return filepath.Join(".", relPath)
+	}
+	return path
+}
+
+// readProfileIndex loads the profile index. Without one, only the default profile exists.
+func readProfileIndex() (*profileIndex, error) {
+	index := &profileIndex{Active: DefaultProfileID}
+
+	data, err := os.ReadFile(getProfileIndexPath())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read profile index: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, index); err != nil {
+			return nil, fmt.Errorf("failed to parse profile index: %w", err)
+		}
+	}
+
+	if index.find(DefaultProfileID) < 0 {
+		index.Profiles = append([]Profile{{ID: DefaultProfileID}}, index.Profiles...)
+	}
+	return index, nil
+}
+
+// writeProfileIndex replaces the index in one step, so a crash never leaves it half written
+func writeProfileIndex(index *profileIndex) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode profile index: %w", err)
+	}
+
+	path := getProfileIndexPath()
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write profile index: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write profile index: %w", err)
+	}
+	return nil
+}
+
+func (index *profileIndex) find(id string) int {
+	for i, profile := range index.Profiles {
+		if profile.ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,87 @@
+package authutils
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func mockDataDir(t *testing.T) string {
+	t.Helper()
+
+	xdgDir := t.TempDir()
+	originalXdgDataFile := xdgDataFile
+	t.Cleanup(func() {
+		xdgDataFile = originalXdgDataFile
+		activeProfile = DefaultProfileID
+		dataDir = ""
+	})
+
+	xdgDataFile = func(path string) (string, error) {
+		path = filepath.Join(xdgDir, path)
+		return path, os.MkdirAll(filepath.Dir(path), 0755)
+	}
+	return xdgDir
+}
+
+func TestProfiles(t *testing.T) {
+	dataDir := mockDataDir(t)
+
+	// Before any profile is created, the vault stays where it always was
+	if got := GetTVaultPath(); got != filepath.Join(dataDir, TellaAppName, TVaultFile) {
+		t.Errorf("Unexpected default vault path %s", got)
+	}
+
+	id, err := AddProfile()
+	if err != nil {
+		t.Fatalf("AddProfile failed: %v", err)
+	}
+	if err := SetActiveProfile(id); err != nil {
+		t.Fatalf("SetActiveProfile failed: %v", err)
+	}
+
+	profileDir := filepath.Join(dataDir, TellaAppName, ProfilesDir, id)
+	if GetTVaultPath() != filepath.Join(profileDir, TVaultFile) || GetDatabasePath() != filepath.Join(profileDir, TellaDBFile) {
+		t.Errorf("Expected paths inside %s, got %s and %s", profileDir, GetTVaultPath(), GetDatabasePath())
+	}
+
+	dbKey := bytes.Repeat([]byte{7}, 32)
+	if err := SetProfileName(id, "Riverside investigation", dbKey); err != nil {
+		t.Fatalf("SetProfileName failed: %v", err)
+	}
+
+	// Nothing on disk gives the name away
+	index, _ := os.ReadFile(filepath.Join(dataDir, TellaAppName, ProfilesFile))
+	if bytes.Contains(index, []byte("Riverside")) {
+		t.Error("Profile index contains the name in plain text")
+	}
+
+	profiles, _ := ListProfiles()
+	if len(profiles) != 2 || profiles[0].ID != DefaultProfileID || profiles[1].ID != id {
+		t.Fatalf("Unexpected profiles %+v", profiles)
+	}
+	if name, err := DecryptProfileName(profiles[1], dbKey); err != nil || name != "Riverside investigation" {
+		t.Errorf("Expected the name back, got %q (%v)", name, err)
+	}
+	if _, err := DecryptProfileName(profiles[1], bytes.Repeat([]byte{8}, 32)); err == nil {
+		t.Error("Expected another profile's key to fail")
+	}
+
+	// The choice survives a restart
+	activeProfile = DefaultProfileID
+	if err := RestoreActiveProfile(); err != nil || ActiveProfile() != id {
+		t.Errorf("Expected profile %s to be restored, got %s (%v)", id, ActiveProfile(), err)
+	}
+
+	if err := SetActiveProfile("missing"); err != ErrProfileNotFound {
+		t.Errorf("Expected an unknown profile to be rejected, got %v", err)
+	}
+
+	if err := RemoveProfile(id); err != nil {
+		t.Fatalf("RemoveProfile failed: %v", err)
+	}
+	if _, err := os.Stat(profileDir); !os.IsNotExist(err) || ActiveProfile() != DefaultProfileID {
+		t.Error("Expected the profile to be removed and the default selected")
+	}
+}
@@ -0,0 +1,341 @@
+package filestoreutils
+
+import (
+	"Tella-Desktop/backend/utils/constants"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrOverlappingFreeSpace is returned when a region is freed twice or overlaps live data
+var ErrOverlappingFreeSpace = errors.New("free space overlaps an existing free region")
+
+// FreeRegion is a contiguous unused area of the TVault
+type FreeRegion struct {
+	ID     int64
+	Offset int64
+	Length int64
+}
+
+// End returns the offset just past the region
+func (r FreeRegion) End() int64 {
+	return r.Offset + r.Length
+}
+
+// VaultEnd returns the logical end of the TVault: the end of the last live extent
+// or free region, or the header size for an empty vault. The file itself may be
+// longer if a previous truncation was interrupted.
+func VaultEnd(tx *sql.Tx) (int64, error) {
+	end := int64(constants.TVaultHeaderSize)
+
+	var extentEnd, freeEnd sql.NullInt64
+	err := tx.QueryRow(`
+		SELECT offset + length FROM vault_extents
+		ORDER BY offset DESC LIMIT 1
+	`).Scan(&extentEnd)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to query last extent: %w", err)
+	}
+
+	err = tx.QueryRow(`
+		SELECT offset + length FROM free_spaces
+		ORDER BY offset DESC LIMIT 1
+	`).Scan(&freeEnd)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to query last free region: %w", err)
+	}
+
+	if extentEnd.Valid && extentEnd.Int64 > end {
+		end = extentEnd.Int64
+	}
+	if freeEnd.Valid && freeEnd.Int64 > end {
+		end = freeEnd.Int64
+	}
+
+	return end, nil
+}
+
+// trailingFreeRegion returns the free region that reaches the end of the TVault, if any
+func trailingFreeRegion(tx *sql.Tx) (*FreeRegion, error) {
+	var region FreeRegion
+	err := tx.QueryRow(`
+		SELECT id, offset, length FROM free_spaces
+		ORDER BY offset DESC LIMIT 1
+	`).Scan(&region.ID, &region.Offset, &region.Length)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query last free region: %w", err)
+	}
+
+	end, err := VaultEnd(tx)
+	if err != nil {
+		return nil, err
+	}
+	if region.End() < end {
+		return nil, nil
+	}
+
+	return &region, nil
+}
+
+// AllocateSpace reserves size bytes in the TVault and returns their offset.
+//
+// The smallest free region that fits is used and its remainder stays free. If
+// no region fits, the space is taken from the end of the TVault, starting at
+// the trailing free region when there is one.
+func AllocateSpace(tx *sql.Tx, size int64) (int64, error) {
+	if size <= 0 {
+		return 0, fmt.Errorf("invalid allocation size: %d", size)
+	}
+
+	var region FreeRegion
+	err := tx.QueryRow(`
+		SELECT id, offset, length FROM free_spaces
+		WHERE length >= ?
+		ORDER BY length ASC, offset ASC LIMIT 1
+	`, size).Scan(&region.ID, &region.Offset, &region.Length)
+
+	if err == nil {
+		if region.Length == size {
+			_, err = tx.Exec("DELETE FROM free_spaces WHERE id = ?", region.ID)
+		} else {
+			// Split the region and keep the tail free
+			_, err = tx.Exec(`
+				UPDATE free_spaces SET offset = ?, length = ? WHERE id = ?
+			`, region.Offset+size, region.Length-size, region.ID)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to reserve free region: %w", err)
+		}
+		return region.Offset, nil
+	} else if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to query free regions: %w", err)
+	}
+
+	return AllocateTail(tx)
+}
+
+// AllocateTail reserves the open-ended space at the end of the TVault, used
+// when the size of the data is not known before writing it
+func AllocateTail(tx *sql.Tx) (int64, error) {
+	trailing, err := trailingFreeRegion(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	if trailing != nil {
+		if _, err := tx.Exec("DELETE FROM free_spaces WHERE id = ?", trailing.ID); err != nil {
+			return 0, fmt.Errorf("failed to reserve trailing region: %w", err)
+		}
+		return trailing.Offset, nil
+	}
+
+	return VaultEnd(tx)
+}
+
+// AddFreeSpace records a new free space area in the database, merging it with
+// adjacent free regions
+func AddFreeSpace(tx *sql.Tx, offset, length int64) error {
+	if length <= 0 {
+		return nil
+	}
+	if offset < constants.TVaultHeaderSize {
+		return fmt.Errorf("free space at %d overlaps the TVault header", offset)
+	}
+
+	region := FreeRegion{Offset: offset, Length: length}
+
+	// Merge with the region directly before
+	var prev FreeRegion
+	err := tx.QueryRow(`
+		SELECT id, offset, length FROM free_spaces
+		WHERE offset < ? ORDER BY offset DESC LIMIT 1
+	`, offset).Scan(&prev.ID, &prev.Offset, &prev.Length)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to query previous free region: %w", err)
+	}
+	if err == nil {
+		if prev.End() > region.Offset {
+			return ErrOverlappingFreeSpace
+		}
+		if prev.End() == region.Offset {
+			if _, err := tx.Exec("DELETE FROM free_spaces WHERE id = ?", prev.ID); err != nil {
+				return fmt.Errorf("failed to merge free regions: %w", err)
+			}
+			region.Offset = prev.Offset
+			region.Length += prev.Length
+		}
+	}
+
+	// Merge with the region directly after
+	var next FreeRegion
+	err = tx.QueryRow(`
+		SELECT id, offset, length FROM free_spaces
+		WHERE offset >= ? ORDER BY offset ASC LIMIT 1
+	`, offset).Scan(&next.ID, &next.Offset, &next.Length)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to query next free region: %w", err)
+	}
+	if err == nil {
+		if next.Offset < offset+length {
+			return ErrOverlappingFreeSpace
+		}
+		if next.Offset == offset+length {
+			if _, err := tx.Exec("DELETE FROM free_spaces WHERE id = ?", next.ID); err != nil {
+				return fmt.Errorf("failed to merge free regions: %w", err)
+			}
+			region.Length += next.Length
+		}
+	}
+
+	return insertFreeRegion(tx, region.Offset, region.Length)
+}
+
+// ReleaseTrailingFreeSpace drops the free region at the end of the TVault, if
+// any, and returns the new logical end. The caller truncates the file with
+// TruncateVault once the transaction has committed.
+func ReleaseTrailingFreeSpace(tx *sql.Tx) (int64, error) {
+	trailing, err := trailingFreeRegion(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	if trailing != nil {
+		if _, err := tx.Exec("DELETE FROM free_spaces WHERE id = ?", trailing.ID); err != nil {
+			return 0, fmt.Errorf("failed to release trailing region: %w", err)
+		}
+	}
+
+	return VaultEnd(tx)
+}
+
+// TruncateVault shrinks the TVault file to end if it is currently longer
+func TruncateVault(tvaultPath string, end int64) error {
+	info, err := os.Stat(tvaultPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat TVault: %w", err)
+	}
+	if info.Size() <= end {
+		return nil
+	}
+
+	if err := os.Truncate(tvaultPath, end); err != nil {
+		return fmt.Errorf("failed to truncate TVault: %w", err)
+	}
+	return nil
+}
+
+// GetFreeRegions returns all free regions ordered by offset
+func GetFreeRegions(db *sql.DB) ([]FreeRegion, error) {
+	rows, err := db.Query("SELECT id, offset, length FROM free_spaces ORDER BY offset ASC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query free regions: %w", err)
+	}
+	defer rows.Close()
+
+	var regions []FreeRegion
+	for rows.Next() {
+		var region FreeRegion
+		if err := rows.Scan(&region.ID, &region.Offset, &region.Length); err != nil {
+			return nil, fmt.Errorf("failed to scan free region: %w", err)
+		}
+		regions = append(regions, region)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating free regions: %w", err)
+	}
+
+	return regions, nil
+}
+
+// AllocateAt reserves exactly [offset, offset+length), which must lie inside a single free region
+func AllocateAt(tx *sql.Tx, offset, length int64) error {
+	var region FreeRegion
+	err := tx.QueryRow(`
+		SELECT id, offset, length FROM free_spaces
+		WHERE offset <= ? ORDER BY offset DESC LIMIT 1
+	`, offset).Scan(&region.ID, &region.Offset, &region.Length)
+	if err == sql.ErrNoRows || (err == nil && region.End() < offset+length) {
+		return fmt.Errorf("no free region covers %d bytes at %d", length, offset)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query free region: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM free_spaces WHERE id = ?", region.ID); err != nil {
+		return fmt.Errorf("failed to reserve free region: %w", err)
+	}
+
+	// Keep whatever is left on either side free
+	if head := offset - region.Offset; head > 0 {
+		if err := insertFreeRegion(tx, region.Offset, head); err != nil {
+			return err
+		}
+	}
+	if tail := region.End() - (offset + length); tail > 0 {
+		if err := insertFreeRegion(tx, offset+length, tail); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func insertFreeRegion(tx *sql.Tx, offset, length int64) error {
+	_, err := tx.Exec(`
+		INSERT INTO free_spaces (offset, length, created_at)
+		VALUES (?, ?, datetime('now'))
+	`, offset, length)
+	if err != nil {
+		return fmt.Errorf("failed to add free space record: %w", err)
+	}
+	return nil
+}
+
+// RebuildFreeSpace recomputes the free list as the gaps between live extents.
+// This recovers space leaked by earlier allocators that never recorded split remainders.
+func RebuildFreeSpace(tx *sql.Tx) error {
+	rows, err := tx.Query(`
+		SELECT DISTINCT offset, length FROM vault_extents ORDER BY offset ASC
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to query extents: %w", err)
+	}
+
+	var gaps []FreeRegion
+	pos := int64(constants.TVaultHeaderSize)
+	for rows.Next() {
+		var offset, length int64
+		if err := rows.Scan(&offset, &length); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan extent: %w", err)
+		}
+		if offset < pos {
+			rows.Close()
+			return fmt.Errorf("overlapping extents at offset %d", offset)
+		}
+		if offset > pos {
+			gaps = append(gaps, FreeRegion{Offset: pos, Length: offset - pos})
+		}
+		pos = offset + length
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating extents: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM free_spaces"); err != nil {
+		return fmt.Errorf("failed to clear free regions: %w", err)
+	}
+	for _, gap := range gaps {
+		if err := insertFreeRegion(tx, gap.Offset, gap.Length); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,316 @@
+package filestoreutils
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"testing/quick"
+
+	"Tella-Desktop/backend/core/database"
+	"Tella-Desktop/backend/utils/constants"
+
+	"github.com/google/uuid"
+)
+
+// setupAllocatorDB creates an encrypted database with one folder to attach files to
+func setupAllocatorDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := database.Initialize(filepath.Join(t.TempDir(), ".tella.db"), make([]byte, constants.KeyLength))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec("INSERT INTO folders (id, name) VALUES (1, 'test')"); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+
+	return db.DB
+}
+
+// allocateForTest reserves space and records it as a live file, returning the file ID
+func allocateForTest(db *sql.DB, size int64) (int64, int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	offset, err := AllocateSpace(tx, size)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	id, err := InsertFileMetadata(tx, uuid.New().String(), "file", size, "text/plain", 1, offset, size, CipherVersionChunked, CompressionNone, ContentHashes{})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return id, offset, tx.Commit()
+}
+
+// freeForTest marks a file deleted, returns its space and trims the vault
+func freeForTest(db *sql.DB, id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var offset, length int64
+	if err := tx.QueryRow("SELECT offset, length FROM files WHERE id = ?", id).Scan(&offset, &length); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE files SET is_deleted = 1 WHERE id = ?", id); err != nil {
+		return err
+	}
+	if err := AddFreeSpace(tx, offset, length); err != nil {
+		return err
+	}
+	if _, err := ReleaseTrailingFreeSpace(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type layoutEntry struct {
+	offset, length int64
+	free           bool
+}
+
+// checkLayout verifies that live extents and free regions tile the vault exactly,
+// without overlaps, and that no two free regions are left adjacent
+func checkLayout(db *sql.DB, requireTrimmed bool) error {
+	var entries []layoutEntry
+
+	rows, err := db.Query("SELECT offset, length FROM vault_extents")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var e layoutEntry
+		if err := rows.Scan(&e.offset, &e.length); err != nil {
+			rows.Close()
+			return err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+
+	regions, err := GetFreeRegions(db)
+	if err != nil {
+		return err
+	}
+	for _, region := range regions {
+		if region.Length <= 0 {
+			return fmt.Errorf("empty free region at %d", region.Offset)
+		}
+		entries = append(entries, layoutEntry{region.Offset, region.Length, true})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].offset < entries[j].offset })
+
+	pos := int64(constants.TVaultHeaderSize)
+	for i, e := range entries {
+		if e.offset != pos {
+			return fmt.Errorf("gap or overlap at %d: next entry starts at %d", pos, e.offset)
+		}
+		if e.free && i > 0 && entries[i-1].free {
+			return fmt.Errorf("adjacent free regions at %d not coalesced", e.offset)
+		}
+		pos += e.length
+	}
+
+	if requireTrimmed && len(entries) > 0 && entries[len(entries)-1].free {
+		return fmt.Errorf("trailing free region at %d was not released", entries[len(entries)-1].offset)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	end, err := VaultEnd(tx)
+	if err != nil {
+		return err
+	}
+	if end != pos {
+		return fmt.Errorf("VaultEnd() = %d, layout ends at %d", end, pos)
+	}
+
+	return nil
+}
+
+// allocatorOp is one randomly generated step of an allocation workload
+type allocatorOp struct {
+	Free bool
+	Size uint16
+	Pick uint16
+}
+
+func TestAllocatorProperties(t *testing.T) {
+	property := func(ops []allocatorOp) bool {
+		db := setupAllocatorDB(t)
+		var live []int64
+
+		for i, op := range ops {
+			if op.Free && len(live) > 0 {
+				idx := int(op.Pick) % len(live)
+				if err := freeForTest(db, live[idx]); err != nil {
+					t.Logf("step %d: free failed: %v", i, err)
+					return false
+				}
+				live = append(live[:idx], live[idx+1:]...)
+
+				if err := checkLayout(db, true); err != nil {
+					t.Logf("step %d (free): %v", i, err)
+					return false
+				}
+				continue
+			}
+
+			id, _, err := allocateForTest(db, int64(op.Size)%4096+1)
+			if err != nil {
+				t.Logf("step %d: allocate failed: %v", i, err)
+				return false
+			}
+			live = append(live, id)
+
+			if err := checkLayout(db, false); err != nil {
+				t.Logf("step %d (allocate): %v", i, err)
+				return false
+			}
+		}
+
+		// Freeing everything must leave an empty vault
+		for _, id := range live {
+			if err := freeForTest(db, id); err != nil {
+				t.Logf("final free failed: %v", err)
+				return false
+			}
+		}
+		regions, err := GetFreeRegions(db)
+		if err != nil || len(regions) != 0 {
+			t.Logf("expected no free regions after freeing everything, got %v (%v)", regions, err)
+			return false
+		}
+
+		return true
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 50}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAllocateSpaceSplitsRegion(t *testing.T) {
+	db := setupAllocatorDB(t)
+
+	a, _, _ := allocateForTest(db, 100)
+	_, _, _ = allocateForTest(db, 100)
+	if err := freeForTest(db, a); err != nil {
+		t.Fatalf("Failed to free: %v", err)
+	}
+
+	_, offset, err := allocateForTest(db, 30)
+	if err != nil {
+		t.Fatalf("Failed to allocate: %v", err)
+	}
+	if offset != constants.TVaultHeaderSize {
+		t.Errorf("Expected allocation at %d, got %d", constants.TVaultHeaderSize, offset)
+	}
+
+	regions, _ := GetFreeRegions(db)
+	if len(regions) != 1 || regions[0].Offset != constants.TVaultHeaderSize+30 || regions[0].Length != 70 {
+		t.Errorf("Expected remainder of 70 bytes at %d, got %v", constants.TVaultHeaderSize+30, regions)
+	}
+}
+
+func TestAddFreeSpaceCoalesces(t *testing.T) {
+	db := setupAllocatorDB(t)
+
+	var ids []int64
+	for i := 0; i < 4; i++ {
+		id, _, err := allocateForTest(db, 50)
+		if err != nil {
+			t.Fatalf("Failed to allocate: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	// Free the first and third, then the second which joins them
+	for _, id := range []int64{ids[0], ids[2], ids[1]} {
+		if err := freeForTest(db, id); err != nil {
+			t.Fatalf("Failed to free: %v", err)
+		}
+	}
+
+	regions, _ := GetFreeRegions(db)
+	if len(regions) != 1 || regions[0].Length != 150 {
+		t.Errorf("Expected one coalesced region of 150 bytes, got %v", regions)
+	}
+}
+
+func TestAddFreeSpaceRejectsOverlap(t *testing.T) {
+	db := setupAllocatorDB(t)
+
+	allocateForTest(db, 100)
+	allocateForTest(db, 100)
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := AddFreeSpace(tx, constants.TVaultHeaderSize, 100); err != nil {
+		t.Fatalf("Failed to add free space: %v", err)
+	}
+	if err := AddFreeSpace(tx, constants.TVaultHeaderSize+50, 100); err != ErrOverlappingFreeSpace {
+		t.Errorf("Expected ErrOverlappingFreeSpace, got %v", err)
+	}
+}
+
+func TestTrailingSpaceIsReleased(t *testing.T) {
+	db := setupAllocatorDB(t)
+	tvaultPath := filepath.Join(t.TempDir(), ".tvault")
+
+	a, _, _ := allocateForTest(db, 100)
+	b, _, _ := allocateForTest(db, 200)
+	if err := os.WriteFile(tvaultPath, make([]byte, constants.TVaultHeaderSize+300), 0600); err != nil {
+		t.Fatalf("Failed to create vault file: %v", err)
+	}
+
+	if err := freeForTest(db, b); err != nil {
+		t.Fatalf("Failed to free: %v", err)
+	}
+
+	tx, _ := db.Begin()
+	end, err := VaultEnd(tx)
+	tx.Rollback()
+	if err != nil || end != constants.TVaultHeaderSize+100 {
+		t.Fatalf("VaultEnd() = %d, %v; want %d", end, err, constants.TVaultHeaderSize+100)
+	}
+
+	if err := TruncateVault(tvaultPath, end); err != nil {
+		t.Fatalf("Failed to truncate: %v", err)
+	}
+	info, _ := os.Stat(tvaultPath)
+	if info.Size() != end {
+		t.Errorf("TVault size = %d, want %d", info.Size(), end)
+	}
+
+	// The next allocation that does not fit anywhere extends the vault from its end
+	if err := freeForTest(db, a); err != nil {
+		t.Fatalf("Failed to free: %v", err)
+	}
+	_, offset, err := allocateForTest(db, 500)
+	if err != nil || offset != constants.TVaultHeaderSize {
+		t.Errorf("Expected allocation at %d, got %d (%v)", constants.TVaultHeaderSize, offset, err)
+	}
+}
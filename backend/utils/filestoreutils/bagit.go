@@ -0,0 +1,708 @@
+package filestoreutils
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BagIt packaging as described in RFC 8493
+
+const (
+	BagItVersion     = "1.0"
+	bagSoftwareAgent = "Tella Desktop"
+)
+
+// bagAlgorithms are the manifest algorithms written to every bag
+var bagAlgorithms = []string{"sha256", "sha512"}
+
+// bagHashes are the manifest algorithms the validator can check
+var bagHashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// BagInfoField is one "Label: Value" line of bag-info.txt. Labels may repeat.
+type BagInfoField struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// BagFile is a stored file and where it goes in the bag's payload
+type BagFile struct {
+	FileInfo
+	Path string // slash-separated, relative to data/
+}
+
+// BagValidation reports whether a bag is complete and its checksums match
+type BagValidation struct {
+	Valid      bool     `json:"valid"`
+	Version    string   `json:"version"`
+	Files      int      `json:"files"` // payload files found in data/
+	Bytes      int64    `json:"bytes"`
+	Algorithms []string `json:"algorithms"`
+	Errors     []string `json:"errors"`
+}
+
+// bagTarget receives the files of a bag, either as a directory or inside a ZIP
+type bagTarget interface {
+	create(name string) (io.Writer, error)
+	close() error
+}
+
+type dirBagTarget struct {
+	root    string
+	current *os.File
+}
+
+func (t *dirBagTarget) create(name string) (io.Writer, error) {
+	if err := t.closeCurrent(); err != nil {
+		return nil, err
+	}
+
+	if !fs.ValidPath(name) {
+		return nil, fmt.Errorf("invalid path in bag: %q", name)
+	}
+	path := filepath.Join(t.root, filepath.FromSlash(name))
+	if rel, err := filepath.Rel(t.root, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("path escapes the bag: %q", name)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create bag directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", name, err)
+	}
+	t.current = file
+	return file, nil
+}
+
+func (t *dirBagTarget) closeCurrent() error {
+	if t.current == nil {
+		return nil
+	}
+	err := t.current.Close()
+	t.current = nil
+	return err
+}
+
+func (t *dirBagTarget) close() error {
+	return t.closeCurrent()
+}
+
+// zipBagTarget writes the bag under a single top-level directory, as bags are usually zipped
+type zipBagTarget struct {
+	file   *os.File
+	writer *zip.Writer
+	prefix string
+}
+
+func (t *zipBagTarget) create(name string) (io.Writer, error) {
+	if !fs.ValidPath(name) {
+		return nil, fmt.Errorf("invalid path in bag: %q", name)
+	}
+	return t.writer.Create(t.prefix + name)
+}
+
+func (t *zipBagTarget) close() error {
+	if err := t.writer.Close(); err != nil {
+		t.file.Close()
+		return err
+	}
+	return t.file.Close()
+}
+
+// CreateBag writes files as a BagIt bag named name in exportDir, as a
+// directory or a ZIP. A bag is only useful when complete, so it is removed
+// again if any file fails or ctx is cancelled. added, when set, is called
+// after each file. The notes of files and of the folders in folderNotes,
+// keyed by their path under data/, are added as tag files.
+func CreateBag(ctx context.Context, open FileOpener, name string, files []BagFile, folderNotes map[string][]ExportNote, info []BagInfoField, exportDir string, zipped bool, scrub *ScrubOptions, added func(ExportEntry)) (string, []ExportEntry, error) {
+	var bagPath string
+	var target bagTarget
+	if zipped {
+		bagPath = CreateUniqueFilename(exportDir, name+".zip")
+		file, err := os.OpenFile(bagPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to create bag ZIP: %w", err)
+		}
+		target = &zipBagTarget{file: file, writer: zip.NewWriter(file), prefix: strings.TrimSuffix(filepath.Base(bagPath), ".zip") + "/"}
+	} else {
+		bagPath = CreateUniqueFilename(exportDir, name)
+		if err := os.Mkdir(bagPath, 0755); err != nil {
+			return "", nil, fmt.Errorf("failed to create bag directory: %w", err)
+		}
+		target = &dirBagTarget{root: bagPath}
+	}
+
+	entries, err := writeBag(ctx, target, open, files, folderNotes, info, scrub, added)
+	if closeErr := target.close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to finish bag: %w", closeErr)
+	}
+	if err != nil {
+		os.RemoveAll(bagPath)
+		return "", nil, err
+	}
+
+	for i := range entries {
+		entries[i].Path = bagPath
+	}
+	return bagPath, entries, nil
+}
+
+func writeBag(ctx context.Context, target bagTarget, open FileOpener, files []BagFile, folderNotes map[string][]ExportNote, info []BagInfoField, scrub *ScrubOptions, added func(ExportEntry)) ([]ExportEntry, error) {
+	manifests := make(map[string]*bytes.Buffer)
+	for _, algorithm := range bagAlgorithms {
+		manifests[algorithm] = &bytes.Buffer{}
+	}
+
+	var entries []ExportEntry
+	var payloadBytes int64
+	tags := ExportTags{Files: make(map[string][]string)}
+	notes := make(map[string][]ExportNote)
+	for dir, folder := range folderNotes {
+		notes[bagNotesPath(dir, true)] = folder
+	}
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		payloadPath := "data/" + file.Path
+		digests, written, report, err := writeBagFile(target, open, file.ID, payloadPath, scrub)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add '%s' to bag: %w", file.Name, err)
+		}
+		payloadBytes += written
+		if len(file.Tags) > 0 {
+			tags.Files[payloadPath] = file.Tags
+		}
+		if len(file.Notes) > 0 {
+			notes[bagNotesPath(file.Path, false)] = file.Notes
+		}
+		for _, algorithm := range bagAlgorithms {
+			fmt.Fprintf(manifests[algorithm], "%s  %s\n", digests[algorithm], encodeBagPath(payloadPath))
+		}
+
+		entry := ExportEntry{
+			FileID:         file.ID,
+			SourceName:     file.Name,
+			Name:           payloadPath,
+			Scrub:          report,
+			SHA256:         file.SHA256,
+			SHA512:         file.SHA512,
+			ExportedSHA256: digests["sha256"],
+		}
+		entries = append(entries, entry)
+		if added != nil {
+			added(entry)
+		}
+	}
+
+	// Tag files, then the tag manifests covering them
+	fields := []BagInfoField{
+		{Label: "Bagging-Date", Value: time.Now().Format("2006-01-02")},
+		{Label: "Bag-Software-Agent", Value: bagSoftwareAgent},
+		{Label: "Payload-Oxum", Value: fmt.Sprintf("%d.%d", payloadBytes, len(files))},
+	}
+	var bagInfo bytes.Buffer
+	for _, field := range append(fields, info...) {
+		// Values may not span lines unless continued with leading whitespace
+		value := strings.Join(strings.Fields(field.Value), " ")
+		if value == "" {
+			continue
+		}
+		fmt.Fprintf(&bagInfo, "%s: %s\n", field.Label, value)
+	}
+
+	type tagFile struct {
+		name string
+		data []byte
+	}
+	tagFiles := []tagFile{
+		{"bagit.txt", []byte("BagIt-Version: " + BagItVersion + "\nTag-File-Character-Encoding: UTF-8\n")},
+		{"bag-info.txt", bagInfo.Bytes()},
+	}
+	// File tags go in a tag file of their own, covered by the tag manifests
+	tagsData, err := encodeExportTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if tagsData != nil {
+		tagFiles = append(tagFiles, tagFile{ExportTagsFile, tagsData})
+	}
+	// So do notes, one sidecar per file or folder
+	notePaths := make([]string, 0, len(notes))
+	for name := range notes {
+		notePaths = append(notePaths, name)
+	}
+	sort.Strings(notePaths)
+	for _, name := range notePaths {
+		data, err := encodeExportNotes(notes[name])
+		if err != nil {
+			return nil, err
+		}
+		if data != nil {
+			tagFiles = append(tagFiles, tagFile{name, data})
+		}
+	}
+	for _, algorithm := range bagAlgorithms {
+		tagFiles = append(tagFiles, tagFile{"manifest-" + algorithm + ".txt", manifests[algorithm].Bytes()})
+	}
+
+	tagManifests := make(map[string]*bytes.Buffer)
+	for _, algorithm := range bagAlgorithms {
+		tagManifests[algorithm] = &bytes.Buffer{}
+	}
+	for _, tag := range tagFiles {
+		if err := writeBagData(target, tag.name, tag.data); err != nil {
+			return nil, err
+		}
+		for _, algorithm := range bagAlgorithms {
+			digest := bagHashes[algorithm]()
+			digest.Write(tag.data)
+			fmt.Fprintf(tagManifests[algorithm], "%s  %s\n", hex.EncodeToString(digest.Sum(nil)), encodeBagPath(tag.name))
+		}
+	}
+	for _, algorithm := range bagAlgorithms {
+		if err := writeBagData(target, "tagmanifest-"+algorithm+".txt", tagManifests[algorithm].Bytes()); err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+func writeBagFile(target bagTarget, open FileOpener, id int64, name string, scrub *ScrubOptions) (map[string]string, int64, *ScrubReport, error) {
+	reader, err := open(id)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	defer reader.Close()
+
+	w, err := target.create(name)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	hashes := make(map[string]hash.Hash)
+	writers := []io.Writer{w}
+	for _, algorithm := range bagAlgorithms {
+		hashes[algorithm] = bagHashes[algorithm]()
+		writers = append(writers, hashes[algorithm])
+	}
+	counter := &countingWriter{}
+	writers = append(writers, counter)
+
+	report, err := WriteExportData(io.MultiWriter(writers...), reader, scrub)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	digests := make(map[string]string)
+	for algorithm, digest := range hashes {
+		digests[algorithm] = hex.EncodeToString(digest.Sum(nil))
+	}
+	return digests, counter.n, report, nil
+}
+
+func writeBagData(target bagTarget, name string, data []byte) error {
+	w, err := target.create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// encodeBagPath percent-encodes the characters manifests cannot hold literally
+func encodeBagPath(name string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(name)
+}
+
+func decodeBagPath(name string) string {
+	return strings.NewReplacer("%0D", "\r", "%0d", "\r", "%0A", "\n", "%0a", "\n", "%25", "%").Replace(name)
+}
+
+// bagSource reads the files of a bag from a directory or a ZIP
+type bagSource interface {
+	files() []string // slash-separated paths relative to the bag root
+	open(name string) (io.ReadCloser, error)
+	size(name string) int64
+	close() error
+}
+
+type dirBagSource struct {
+	root  string
+	names []string
+}
+
+func (s *dirBagSource) files() []string { return s.names }
+
+func (s *dirBagSource) open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.root, filepath.FromSlash(name)))
+}
+
+func (s *dirBagSource) size(name string) int64 {
+	info, err := os.Stat(filepath.Join(s.root, filepath.FromSlash(name)))
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+func (s *dirBagSource) close() error { return nil }
+
+type zipBagSource struct {
+	reader  *zip.ReadCloser
+	entries map[string]*zip.File
+	names   []string
+}
+
+func (s *zipBagSource) files() []string { return s.names }
+
+func (s *zipBagSource) open(name string) (io.ReadCloser, error) {
+	entry, ok := s.entries[name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return entry.Open()
+}
+
+func (s *zipBagSource) size(name string) int64 {
+	if entry, ok := s.entries[name]; ok {
+		return int64(entry.UncompressedSize64)
+	}
+	return 0
+}
+
+func (s *zipBagSource) close() error { return s.reader.Close() }
+
+func openBagSource(bagPath string) (bagSource, error) {
+	info, err := os.Stat(bagPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bag: %w", err)
+	}
+
+	if info.IsDir() {
+		source := &dirBagSource{root: bagPath}
+		err := filepath.WalkDir(bagPath, func(p string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.Type().IsRegular() {
+				rel, _ := filepath.Rel(bagPath, p)
+				source.names = append(source.names, filepath.ToSlash(rel))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list bag: %w", err)
+		}
+		return source, nil
+	}
+
+	reader, err := zip.OpenReader(bagPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bag ZIP: %w", err)
+	}
+
+	// The bag is either at the root of the ZIP or in a single top-level directory
+	prefix := ""
+	for _, entry := range reader.File {
+		if strings.Count(entry.Name, "/") == 1 && path.Base(entry.Name) == "bagit.txt" {
+			prefix = path.Dir(entry.Name) + "/"
+		}
+		if entry.Name == "bagit.txt" {
+			prefix = ""
+			break
+		}
+	}
+
+	source := &zipBagSource{reader: reader, entries: make(map[string]*zip.File)}
+	for _, entry := range reader.File {
+		if entry.FileInfo().IsDir() || !strings.HasPrefix(entry.Name, prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(entry.Name, prefix)
+		source.entries[name] = entry
+		source.names = append(source.names, name)
+	}
+	return source, nil
+}
+
+// ValidateBag checks a bag directory or ZIP against RFC 8493: the required
+// tag files are present, every payload file is listed in every manifest,
+// and all checksums and the Payload-Oxum match.
+func ValidateBag(bagPath string) (*BagValidation, error) {
+	source, err := openBagSource(bagPath)
+	if err != nil {
+		return nil, err
+	}
+	defer source.close()
+
+	result := &BagValidation{Algorithms: []string{}, Errors: []string{}}
+	fail := func(format string, args ...interface{}) {
+		result.Errors = append(result.Errors, fmt.Sprintf(format, args...))
+	}
+
+	present := make(map[string]bool)
+	var payload []string
+	for _, name := range source.files() {
+		present[name] = true
+		if strings.HasPrefix(name, "data/") {
+			payload = append(payload, name)
+			result.Bytes += source.size(name)
+		}
+	}
+	result.Files = len(payload)
+
+	// bagit.txt declares the version and tag file encoding
+	declaration, err := readBagTagFile(source, "bagit.txt")
+	if err != nil {
+		fail("missing or unreadable bagit.txt: %v", err)
+	} else {
+		result.Version = declaration["BagIt-Version"]
+		if result.Version == "" {
+			fail("bagit.txt does not declare BagIt-Version")
+		}
+		if encoding := declaration["Tag-File-Character-Encoding"]; !strings.EqualFold(encoding, "UTF-8") {
+			fail("unsupported tag file encoding %q", encoding)
+		}
+	}
+	if present["fetch.txt"] {
+		fail("bag lists remote files in fetch.txt, which are not checked")
+	}
+
+	var manifests, tagManifests []string
+	for _, name := range source.files() {
+		if strings.Contains(name, "/") {
+			continue
+		}
+		if algorithm, ok := manifestAlgorithm(name, "manifest-"); ok {
+			manifests = append(manifests, algorithm)
+		}
+		if algorithm, ok := manifestAlgorithm(name, "tagmanifest-"); ok {
+			tagManifests = append(tagManifests, algorithm)
+		}
+	}
+	sort.Strings(manifests)
+	sort.Strings(tagManifests)
+	result.Algorithms = manifests
+	if len(manifests) == 0 {
+		fail("bag has no payload manifest")
+	}
+
+	// Expected digests per file and algorithm, from all manifests
+	expected := make(map[string]map[string]string)
+	listed := make(map[string]map[string]bool)
+	addManifest := func(manifest, algorithm string, tag bool) {
+		lines, err := readManifest(source, manifest)
+		if err != nil {
+			fail("failed to read %s: %v", manifest, err)
+			return
+		}
+		listed[manifest] = make(map[string]bool)
+		for _, line := range lines {
+			name := path.Clean(line.path)
+			if strings.HasPrefix(name, "../") || path.IsAbs(name) {
+				fail("%s lists a path outside the bag: %s", manifest, line.path)
+				continue
+			}
+			if !tag && !strings.HasPrefix(name, "data/") {
+				fail("%s lists a file outside data/: %s", manifest, line.path)
+				continue
+			}
+			if !present[name] {
+				fail("%s lists a missing file: %s", manifest, name)
+				continue
+			}
+			listed[manifest][name] = true
+			if expected[name] == nil {
+				expected[name] = make(map[string]string)
+			}
+			expected[name][algorithm] = strings.ToLower(line.checksum)
+		}
+	}
+	for _, algorithm := range manifests {
+		addManifest("manifest-"+algorithm+".txt", algorithm, false)
+	}
+	for _, algorithm := range tagManifests {
+		addManifest("tagmanifest-"+algorithm+".txt", algorithm, true)
+	}
+
+	// Completeness: every payload file appears in every payload manifest
+	for _, name := range payload {
+		for _, algorithm := range manifests {
+			if manifest := listed["manifest-"+algorithm+".txt"]; manifest != nil && !manifest[name] {
+				fail("payload file %s is not listed in manifest-%s.txt", name, algorithm)
+			}
+		}
+	}
+
+	// Validity: every listed file matches its checksums
+	names := make([]string, 0, len(expected))
+	for name := range expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		actual, err := hashBagFile(source, name, expected[name])
+		if err != nil {
+			fail("failed to read %s: %v", name, err)
+			continue
+		}
+		for algorithm, digest := range expected[name] {
+			if actual[algorithm] != digest {
+				fail("%s checksum mismatch for %s", algorithm, name)
+			}
+		}
+	}
+
+	if info, err := readBagTagFile(source, "bag-info.txt"); err == nil {
+		if oxum := info["Payload-Oxum"]; oxum != "" {
+			octets, count, ok := strings.Cut(oxum, ".")
+			wantBytes, err1 := strconv.ParseInt(octets, 10, 64)
+			wantCount, err2 := strconv.Atoi(count)
+			if !ok || err1 != nil || err2 != nil {
+				fail("malformed Payload-Oxum %q", oxum)
+			} else if wantCount != len(payload) || wantBytes != result.Bytes {
+				fail("Payload-Oxum %s does not match the payload", oxum)
+			}
+		}
+	}
+
+	result.Valid = len(result.Errors) == 0
+	return result, nil
+}
+
+func manifestAlgorithm(name, prefix string) (string, bool) {
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".txt") {
+		return "", false
+	}
+	algorithm := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".txt")
+	_, ok := bagHashes[algorithm]
+	return algorithm, ok
+}
+
+type manifestLine struct {
+	checksum string
+	path     string
+}
+
+func readManifest(source bagSource, name string) ([]manifestLine, error) {
+	reader, err := source.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var lines []manifestLine
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		checksum, rest, ok := strings.Cut(line, " ")
+		if !ok {
+			checksum, rest, ok = strings.Cut(line, "\t")
+		}
+		if !ok {
+			return nil, fmt.Errorf("malformed line %q", line)
+		}
+		lines = append(lines, manifestLine{checksum: checksum, path: decodeBagPath(strings.TrimLeft(rest, " \t"))})
+	}
+	return lines, scanner.Err()
+}
+
+// readBagTagFile parses "Label: Value" lines, joining continuation lines.
+// Only the first value of a repeated label is kept.
+func readBagTagFile(source bagSource, name string) (map[string]string, error) {
+	reader, err := source.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	fields := make(map[string]string)
+	var last string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			if last != "" {
+				fields[last] += " " + strings.TrimSpace(line)
+			}
+			continue
+		}
+		label, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		label = strings.TrimSpace(label)
+		if _, seen := fields[label]; !seen {
+			fields[label] = strings.TrimSpace(value)
+			last = label
+		} else {
+			last = ""
+		}
+	}
+	return fields, scanner.Err()
+}
+
+func hashBagFile(source bagSource, name string, algorithms map[string]string) (map[string]string, error) {
+	reader, err := source.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	hashes := make(map[string]hash.Hash)
+	var writers []io.Writer
+	for algorithm := range algorithms {
+		hashes[algorithm] = bagHashes[algorithm]()
+		writers = append(writers, hashes[algorithm])
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), reader); err != nil {
+		return nil, err
+	}
+
+	digests := make(map[string]string)
+	for algorithm, digest := range hashes {
+		digests[algorithm] = hex.EncodeToString(digest.Sum(nil))
+	}
+	return digests, nil
+}
@@ -0,0 +1,154 @@
+package filestoreutils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type nopReadSeekCloser struct {
+	*bytes.Reader
+}
+
+func (nopReadSeekCloser) Close() error { return nil }
+
+func testBagFiles() ([]BagFile, FileOpener) {
+	contents := map[int64][]byte{
+		1: []byte("first statement"),
+		2: bytes.Repeat([]byte("audio"), 1000),
+		3: []byte("name with % and newline"),
+	}
+	files := []BagFile{
+		{FileInfo: FileInfo{ID: 1, Name: "statement.txt"}, Path: "statement.txt"},
+		{FileInfo: FileInfo{ID: 2, Name: "interview.mp3"}, Path: "Interviews/interview.mp3"},
+		{FileInfo: FileInfo{ID: 3, Name: "odd"}, Path: "100% odd\nname.txt"},
+	}
+	open := func(id int64) (io.ReadSeekCloser, error) {
+		data, ok := contents[id]
+		if !ok {
+			return nil, fmt.Errorf("file not found with ID: %d", id)
+		}
+		return nopReadSeekCloser{bytes.NewReader(data)}, nil
+	}
+	return files, open
+}
+
+func TestCreateAndValidateBag(t *testing.T) {
+	files, open := testBagFiles()
+	info := []BagInfoField{{Label: "Source-Organization", Value: "Example\nCollective"}}
+	files[2].Notes = []ExportNote{{Author: "Ana", Body: "Sent by the witness"}}
+	folderNotes := map[string][]ExportNote{"Interviews": {{Body: "Recorded on site", Status: "verified"}}}
+
+	for _, zipped := range []bool{false, true} {
+		dir := t.TempDir()
+		var added int
+		bagPath, entries, err := CreateBag(context.Background(), open, "evidence", files, folderNotes, info, dir, zipped, nil, func(ExportEntry) { added++ })
+		if err != nil {
+			t.Fatalf("CreateBag (zipped %v) failed: %v", zipped, err)
+		}
+		if len(entries) != 3 || added != 3 || entries[1].Name != "data/Interviews/interview.mp3" {
+			t.Errorf("Unexpected entries: %+v", entries)
+		}
+
+		result, err := ValidateBag(bagPath)
+		if err != nil {
+			t.Fatalf("ValidateBag failed: %v", err)
+		}
+		if !result.Valid || result.Files != 3 || result.Version != BagItVersion || len(result.Algorithms) != 2 {
+			t.Errorf("Expected a valid bag (zipped %v), got %+v", zipped, result)
+		}
+
+		// Notes are tag files, outside the payload
+		if !zipped {
+			if _, err := os.Stat(filepath.Join(bagPath, ExportBagNotesDir, "Interviews", ExportFolderNotesFile)); err != nil {
+				t.Errorf("Expected the folder notes in the bag: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(bagPath, ExportBagNotesDir, "100% odd\nname.txt"+ExportNotesSuffix)); err != nil {
+				t.Errorf("Expected the file notes in the bag: %v", err)
+			}
+		}
+	}
+}
+
+func TestValidateBagReportsProblems(t *testing.T) {
+	files, open := testBagFiles()
+	bagPath, _, err := CreateBag(context.Background(), open, "evidence", files, nil, nil, t.TempDir(), false, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateBag failed: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(bagPath, "data", "statement.txt"), []byte("first statemenT"), 0644)
+	os.WriteFile(filepath.Join(bagPath, "data", "extra.txt"), []byte("unlisted"), 0644)
+
+	result, err := ValidateBag(bagPath)
+	if err != nil {
+		t.Fatalf("ValidateBag failed: %v", err)
+	}
+	if result.Valid {
+		t.Fatal("Expected a tampered bag to be invalid")
+	}
+
+	errors := strings.Join(result.Errors, "\n")
+	for _, want := range []string{
+		"sha256 checksum mismatch for data/statement.txt",
+		"data/extra.txt is not listed in manifest-sha512.txt",
+		"Payload-Oxum",
+	} {
+		if !strings.Contains(errors, want) {
+			t.Errorf("Expected an error mentioning %q, got:\n%s", want, errors)
+		}
+	}
+}
+
+func TestCreateBagRemovesIncompleteBag(t *testing.T) {
+	files, open := testBagFiles()
+	dir := t.TempDir()
+
+	files = append(files, BagFile{FileInfo: FileInfo{ID: 99, Name: "missing"}, Path: "missing.bin"})
+	if _, _, err := CreateBag(context.Background(), open, "evidence", files, nil, nil, dir, true, nil, nil); err == nil {
+		t.Fatal("Expected a bag with an unreadable file to fail")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := CreateBag(ctx, open, "evidence", files[:1], nil, nil, dir, false, nil, nil); err != context.Canceled {
+		t.Fatalf("Expected cancellation, got %v", err)
+	}
+
+	if left, _ := os.ReadDir(dir); len(left) != 0 {
+		t.Errorf("Expected incomplete bags to be removed, found %d entries", len(left))
+	}
+}
+
+func TestCreateBagRefusesPathsOutside(t *testing.T) {
+	_, open := testBagFiles()
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "exports")
+	os.Mkdir(dir, 0755)
+
+	for _, zipped := range []bool{false, true} {
+		for _, name := range []string{"../escape.txt", "Interviews/../../escape.txt", "/escape.txt"} {
+			files := []BagFile{{FileInfo: FileInfo{ID: 1, Name: "escape.txt"}, Path: name}}
+			if _, _, err := CreateBag(context.Background(), open, "evidence", files, nil, nil, dir, zipped, nil, nil); err == nil {
+				t.Errorf("Expected %q to be refused (zipped %v)", name, zipped)
+			}
+		}
+	}
+	if left, _ := os.ReadDir(parent); len(left) != 1 {
+		t.Errorf("Expected nothing to be written outside the destination, found %d entries", len(left))
+	}
+	if left, _ := os.ReadDir(dir); len(left) != 0 {
+		t.Errorf("Expected refused bags to be removed, found %d entries", len(left))
+	}
+
+	for name, want := range map[string]string{"../../escape.txt": "escape.txt", "..": "", "a\\..\\b": "b", "x\x00y": "xy", "/": ""} {
+		if got := SafePathComponent(name); got != want {
+			t.Errorf("SafePathComponent(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
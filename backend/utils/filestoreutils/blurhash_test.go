@@ -0,0 +1,107 @@
+package filestoreutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strings"
+	"testing"
+)
+
+func TestEncodeBlurhashSolidColor(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 32, 24))
+	for y := 0; y < 24; y++ {
+		for x := 0; x < 32; x++ {
+			img.Set(x, y, color.NRGBA{R: 255, G: 0, B: 0, A: 255})
+		}
+	}
+
+	hash, err := EncodeBlurhash(img, 4, 3)
+	if err != nil {
+		t.Fatalf("Failed to encode blurhash: %v", err)
+	}
+
+	// Size flag, max AC, 4 characters of DC and 2 per AC component
+	if len(hash) != 6+2*(4*3-1) {
+		t.Fatalf("Blurhash %q has length %d", hash, len(hash))
+	}
+	if hash[0] != blurhashCharacters[3+2*9] {
+		t.Errorf("Unexpected size flag in %q", hash)
+	}
+	if dc := hash[2:6]; dc != encodeBase83(0xFF0000, 4) {
+		t.Errorf("DC component %q does not encode pure red", dc)
+	}
+
+	for _, c := range hash {
+		if !strings.ContainsRune(blurhashCharacters, c) {
+			t.Errorf("Blurhash %q contains invalid character %q", hash, c)
+		}
+	}
+
+	// Detail in the image shows up in the AC components
+	img.Set(0, 0, color.NRGBA{R: 0, G: 0, B: 255, A: 255})
+	for x := 0; x < 16; x++ {
+		img.Set(x, 5, color.NRGBA{R: 0, G: 255, B: 0, A: 255})
+	}
+	detailed, _ := EncodeBlurhash(img, 4, 3)
+	if detailed[6:] == hash[6:] {
+		t.Errorf("AC components did not change with image detail")
+	}
+}
+
+func TestResizeImageKeepsAspectRatio(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 1000, 250))
+	thumb := ResizeImage(img, 128)
+	if thumb.Bounds().Dx() != 128 || thumb.Bounds().Dy() != 32 {
+		t.Errorf("Resized to %v, want 128x32", thumb.Bounds())
+	}
+
+	small := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	if ResizeImage(small, 128).Bounds() != small.Bounds() {
+		t.Errorf("Small image should not be enlarged")
+	}
+}
+
+// withOrientation inserts an EXIF block holding only the orientation after a JPEG's SOI marker
+func withOrientation(data []byte, orientation int) []byte {
+	tiff := minimalOrientationTIFF(orientation)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(2+6+len(tiff)))
+	segment = append(append(segment, "Exif\x00\x00"...), tiff...)
+	return append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+}
+
+func TestImageOrientation(t *testing.T) {
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 8, 4)), nil)
+
+	if orientation := ImageOrientation(bytes.NewReader(buf.Bytes())); orientation != 1 {
+		t.Errorf("Expected no orientation to read as 1, got %d", orientation)
+	}
+	tagged := withOrientation(buf.Bytes(), 6)
+	if orientation := ImageOrientation(bytes.NewReader(tagged)); orientation != 6 {
+		t.Errorf("Expected orientation 6, got %d", orientation)
+	}
+	if img, err := DecodeImage(bytes.NewReader(tagged)); err != nil || img.Bounds().Dx() != 8 {
+		t.Errorf("Expected the tagged JPEG to decode: %v", err)
+	}
+}
+
+func TestOrientImage(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(0, 0, red)
+
+	for orientation, want := range map[int]image.Point{1: {0, 0}, 2: {2, 0}, 3: {2, 1}, 4: {0, 1}, 5: {0, 0}, 6: {1, 0}, 7: {1, 2}, 8: {0, 2}} {
+		oriented := OrientImage(img, orientation)
+		size := image.Pt(3, 2)
+		if orientation >= 5 {
+			size = image.Pt(2, 3)
+		}
+		if oriented.Bounds().Size() != size || oriented.NRGBAAt(want.X, want.Y) != red {
+			t.Errorf("Orientation %d: expected the corner at %v in %v, got %v", orientation, want, size, oriented.Bounds())
+		}
+	}
+}
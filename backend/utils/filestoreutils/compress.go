@@ -0,0 +1,289 @@
+package filestoreutils
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"strings"
+)
+
+// Compression algorithms recorded in files.compression
+const (
+	CompressionNone    = ""
+	CompressionDeflate = "deflate"
+)
+
+// Padding schemes applied to compressed data, so that the stored length says
+// little about how well a file compressed and therefore about its content.
+// The deflate stream marks its own end, and the padding after it is ignored.
+const (
+	// PaddingNone stores compressed data as is
+	PaddingNone = "none"
+	// PaddingPadme rounds lengths up by at most about 12%, leaking only the
+	// order of magnitude and a few leading bits of the length
+	PaddingPadme = "padme"
+	// PaddingPowerOfTwo rounds lengths up to the next power of two
+	PaddingPowerOfTwo = "power2"
+)
+
+// compressionProbeSize is how much of a file is sampled to decide whether to compress it
+const compressionProbeSize = 64 * 1024
+
+// compressionMaxEntropy is the entropy, in bits per byte, above which a sample
+// is taken to be already compressed or encrypted
+const compressionMaxEntropy = 7.5
+
+// compressibleTypes lists MIME types and prefixes worth compressing. Types
+// that are already compressed (JPEG, MP4, ZIP based formats) are left out,
+// and the entropy probe catches the rest, such as PDFs of scanned images.
+var compressibleTypes = []string{
+	"text/",
+	"application/pdf",
+	"application/json",
+	"application/xml",
+	"application/rtf",
+	"application/msword",
+	"application/vnd.ms-excel",
+	"application/vnd.ms-powerpoint",
+	"application/vnd.oasis.opendocument.text-flat-xml",
+	"application/x-tar",
+	"application/sql",
+	"audio/wav",
+	"audio/x-wav",
+	"audio/wave",
+	"audio/vnd.wave",
+	"audio/aiff",
+	"audio/x-aiff",
+	"image/bmp",
+	"image/tiff",
+	"image/svg+xml",
+}
+
+// ValidPadding reports whether padding is a known padding scheme
+func ValidPadding(padding string) bool {
+	return padding == PaddingNone || padding == PaddingPadme || padding == PaddingPowerOfTwo
+}
+
+// ShouldCompress decides from the MIME type and a sample of the content
+// whether compressing a file is likely to save space
+func ShouldCompress(mimeType string, sample []byte) bool {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+
+	compressible := strings.HasSuffix(mimeType, "+xml") || strings.HasSuffix(mimeType, "+json")
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(mimeType, prefix) {
+			compressible = true
+			break
+		}
+	}
+	if !compressible || len(sample) == 0 {
+		return false
+	}
+
+	if len(sample) > compressionProbeSize {
+		sample = sample[:compressionProbeSize]
+	}
+	return byteEntropy(sample) < compressionMaxEntropy
+}
+
+// byteEntropy returns the Shannon entropy of data in bits per byte
+func byteEntropy(data []byte) float64 {
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+
+	entropy := 0.0
+	total := float64(len(data))
+	for _, count := range counts {
+		if count > 0 {
+			p := float64(count) / total
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
+
+// PaddedLength returns the length compressed data of the given length is padded to
+func PaddedLength(length int64, padding string) int64 {
+	if length < 2 {
+		return length
+	}
+
+	switch padding {
+	case PaddingPadme:
+		exponent := bits.Len64(uint64(length)) - 1
+		mask := int64(1)<<(exponent-bits.Len64(uint64(exponent))) - 1
+		return (length + mask) &^ mask
+	case PaddingPowerOfTwo:
+		return int64(1) << bits.Len64(uint64(length-1))
+	default:
+		return length
+	}
+}
+
+// CompressBuffer compresses and pads data held in memory. It reports false
+// when the padded result would not be smaller than data, which is then
+// better stored as is.
+func CompressBuffer(data []byte, padding string) ([]byte, bool, error) {
+	var buf bytes.Buffer
+	writer, err := NewCompressWriter(&buf, padding)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, false, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, false, err
+	}
+
+	if buf.Len() >= len(data) {
+		return nil, false, nil
+	}
+	return buf.Bytes(), true, nil
+}
+
+// CompressWriter deflates a stream and pads its end according to a padding scheme
+type CompressWriter struct {
+	dst     io.Writer
+	written *countingWriter
+	flate   *flate.Writer
+	padding string
+	size    int64
+}
+
+// NewCompressWriter starts a compressed stream. Close must be called to
+// finish the stream and write the padding; it does not close dst.
+func NewCompressWriter(dst io.Writer, padding string) (*CompressWriter, error) {
+	if !ValidPadding(padding) {
+		return nil, fmt.Errorf("unknown padding scheme: %s", padding)
+	}
+
+	written := &countingWriter{}
+	dst = io.MultiWriter(dst, written)
+	writer, err := flate.NewWriter(dst, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	return &CompressWriter{dst: dst, written: written, flate: writer, padding: padding}, nil
+}
+
+func (w *CompressWriter) Write(p []byte) (int, error) {
+	n, err := w.flate.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close finishes the deflate stream and pads it
+func (w *CompressWriter) Close() error {
+	if err := w.flate.Close(); err != nil {
+		return err
+	}
+
+	pad := PaddedLength(w.written.n, w.padding) - w.written.n
+	if _, err := io.CopyN(w.dst, zeroReader{}, pad); err != nil {
+		return fmt.Errorf("failed to pad compressed data: %w", err)
+	}
+	return nil
+}
+
+// Size returns the number of uncompressed bytes written so far
+func (w *CompressWriter) Size() int64 {
+	return w.size
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// DecompressReader inflates a compressed file and supports seeking. Seeking
+// forward skips decompressed data, seeking backward starts over from the
+// beginning, so reading front to back stays cheap.
+type DecompressReader struct {
+	src     io.ReadSeeker
+	size    int64
+	inflate io.ReadCloser
+	decoded int64 // position of the decompressor
+	pos     int64 // position seen by the caller
+}
+
+// NewDecompressReader reads the compressed stream in src, which inflates to size bytes
+func NewDecompressReader(src io.ReadSeeker, size int64) *DecompressReader {
+	return &DecompressReader{src: src, size: size}
+}
+
+func (r *DecompressReader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.inflate == nil || r.decoded > r.pos {
+		if err := r.restart(); err != nil {
+			return 0, err
+		}
+	}
+	if r.decoded < r.pos {
+		skipped, err := io.CopyN(io.Discard, r.inflate, r.pos-r.decoded)
+		r.decoded += skipped
+		if err != nil {
+			return 0, fmt.Errorf("failed to decompress file: %w", err)
+		}
+	}
+
+	if remaining := r.size - r.pos; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := r.inflate.Read(p)
+	r.decoded += int64(n)
+	r.pos = r.decoded
+	if errors.Is(err, io.EOF) {
+		if r.pos < r.size {
+			return n, io.ErrUnexpectedEOF
+		}
+		err = nil
+	}
+	if err != nil {
+		return n, fmt.Errorf("failed to decompress file: %w", err)
+	}
+	return n, nil
+}
+
+func (r *DecompressReader) restart() error {
+	if _, err := r.src.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if r.inflate == nil {
+		r.inflate = flate.NewReader(r.src)
+	} else if err := r.inflate.(flate.Resetter).Reset(r.src, nil); err != nil {
+		return err
+	}
+	r.decoded = 0
+	return nil
+}
+
+func (r *DecompressReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.pos + offset
+	case io.SeekEnd:
+		abs = r.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.pos = abs
+	return abs, nil
+}
@@ -0,0 +1,88 @@
+package filestoreutils
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestShouldCompress(t *testing.T) {
+	text := []byte(strings.Repeat("Witness statement, page one. ", 1000))
+	random := randomBytesForTest(t, 64*1024)
+
+	tests := []struct {
+		mimeType string
+		sample   []byte
+		want     bool
+	}{
+		{"text/plain; charset=utf-8", text, true},
+		{"application/pdf", text, true},
+		{"audio/wav", text, true},
+		{"image/svg+xml", text, true},
+		{"application/pdf", random, false}, // already compressed streams inside
+		{"image/jpeg", text, false},
+		{"text/plain", nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := ShouldCompress(tt.mimeType, tt.sample); got != tt.want {
+			t.Errorf("ShouldCompress(%q) = %v, want %v", tt.mimeType, got, tt.want)
+		}
+	}
+}
+
+func TestPaddedLength(t *testing.T) {
+	for _, length := range []int64{0, 1, 2, 9, 100, 1000, 123456, 1 << 30} {
+		padme := PaddedLength(length, PaddingPadme)
+		if padme < length || float64(padme-length) > 0.12*float64(length)+1 {
+			t.Errorf("padme(%d) = %d, want at most 12%% more", length, padme)
+		}
+		power := PaddedLength(length, PaddingPowerOfTwo)
+		if power < length || (length > 1 && power&(power-1) != 0) {
+			t.Errorf("power2(%d) = %d, want the next power of two", length, power)
+		}
+		if PaddedLength(length, PaddingNone) != length {
+			t.Errorf("Expected no padding for %d", length)
+		}
+	}
+
+	// Lengths close to each other are padded to the same size
+	if PaddedLength(1000, PaddingPadme) != PaddedLength(1010, PaddingPadme) {
+		t.Error("Expected nearby lengths to be indistinguishable")
+	}
+}
+
+func TestCompressRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat("The quick brown fox jumps over the lazy dog. ", 5000))
+
+	packed, smaller, err := CompressBuffer(data, PaddingPadme)
+	if err != nil || !smaller {
+		t.Fatalf("Expected text to compress, got smaller=%v (%v)", smaller, err)
+	}
+	if int64(len(packed)) != PaddedLength(int64(len(packed)), PaddingPadme) {
+		t.Errorf("Compressed length %d is not padded", len(packed))
+	}
+
+	reader := NewDecompressReader(bytes.NewReader(packed), int64(len(data)))
+	got, err := io.ReadAll(reader)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Round trip failed: %v", err)
+	}
+
+	// Seeking backward and forward, as range requests do
+	buf := make([]byte, 100)
+	for _, offset := range []int64{200000, 10, 150000} {
+		reader.Seek(offset, io.SeekStart)
+		if _, err := io.ReadFull(reader, buf); err != nil || !bytes.Equal(buf, data[offset:offset+100]) {
+			t.Errorf("Read at %d returned wrong data (%v)", offset, err)
+		}
+	}
+	if end, _ := reader.Seek(0, io.SeekEnd); end != int64(len(data)) {
+		t.Errorf("Seek to end = %d, want %d", end, len(data))
+	}
+
+	if _, smaller, _ := CompressBuffer(randomBytesForTest(t, 1000), PaddingNone); smaller {
+		t.Error("Expected random data not to compress")
+	}
+}
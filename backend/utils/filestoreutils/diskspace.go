@@ -0,0 +1,7 @@
+package filestoreutils
+
+// DiskUsage is the space on a filesystem, in bytes
+type DiskUsage struct {
+	Free  int64 // available to the app
+	Total int64
+}
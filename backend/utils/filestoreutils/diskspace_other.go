@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !windows
+
+package filestoreutils
+
+import "fmt"
+
+// DiskSpace is not supported on this platform
+func DiskSpace(path string) (*DiskUsage, error) {
+	return nil, fmt.Errorf("disk space is not supported on this platform")
+}
@@ -0,0 +1,22 @@
+//go:build linux || darwin
+
+package filestoreutils
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// DiskSpace reports the space on the filesystem holding path
+func DiskSpace(path string) (*DiskUsage, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return nil, fmt.Errorf("failed to get disk space: %w", err)
+	}
+
+	// Bavail leaves out blocks reserved for the superuser, which the app cannot use
+	return &DiskUsage{
+		Free:  int64(stat.Bavail) * int64(stat.Bsize),
+		Total: int64(stat.Blocks) * int64(stat.Bsize),
+	}, nil
+}
@@ -0,0 +1,31 @@
+package filestoreutils
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// DiskSpace reports the space on the volume holding path
+func DiskSpace(path string) (*DiskUsage, error) {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get disk space: %w", err)
+	}
+
+	var freeToCaller, total, totalFree uint64
+	ok, _, err := getDiskFreeSpaceEx.Call(
+		uintptr(unsafe.Pointer(pathPtr)),
+		uintptr(unsafe.Pointer(&freeToCaller)),
+		uintptr(unsafe.Pointer(&total)),
+		uintptr(unsafe.Pointer(&totalFree)),
+	)
+	if ok == 0 {
+		return nil, fmt.Errorf("failed to get disk space: %w", err)
+	}
+
+	// freeToCaller honours disk quotas, which the app is subject to
+	return &DiskUsage{Free: int64(freeToCaller), Total: int64(total)}, nil
+}
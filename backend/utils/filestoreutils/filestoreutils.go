@@ -1,13 +1,23 @@
 package filestoreutils
 
 import (
-	"Tella-Desktop/backend/utils/authutils"
 	"archive/zip"
+	"context"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/sha512"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -23,14 +33,26 @@ func InsertFileMetadata(
 	folderID int64,
 	offset int64,
 	length int64,
+	cipherVersion int,
+	compression string,
+	hashes ContentHashes,
 ) (int64, error) {
+	nullable := func(value string) interface{} {
+		if value == "" {
+			return nil
+		}
+		return value
+	}
+
 	result, err := tx.Exec(`
 		INSERT INTO files (
-			uuid, name, size, folder_id, mime_type, offset, length, 
-			is_deleted, created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, 0, datetime('now'), datetime('now'))
+			uuid, name, search_name, size, folder_id, mime_type, offset, length, cipher_version,
+			compression, content_hash, sha256, sha512, is_deleted, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0, datetime('now'), datetime('now'))
 	`,
-		fileUUID, fileName, size, folderID, mimeType, offset, length,
+		fileUUID, fileName, SearchName(fileName), size, folderID, mimeType, offset, length, cipherVersion,
+		compression,
+		nullable(hashes.Keyed), nullable(hashes.SHA256), nullable(hashes.SHA512),
 	)
 
 	if err != nil {
@@ -40,34 +62,50 @@ func InsertFileMetadata(
 	return result.LastInsertId()
 }
 
-// findSpace looks for a suitable free space or returns the end of the file
-func FindSpace(tx *sql.Tx, size int64, tvaultPath string) (int64, error) {
-	// First try to find a free space that fits
-	var freeSpaceID, offset int64
-	err := tx.QueryRow(`
-		SELECT id, offset FROM free_spaces 
-		WHERE length >= ? 
-		ORDER BY length ASC LIMIT 1
-	`, size).Scan(&freeSpaceID, &offset)
+// SearchName returns the case-folded form of a file name used for search and sorting
+func SearchName(name string) string {
+	return strings.ToLower(name)
+}
+
+// BackfillSearchNames fills search_name for files stored before it existed
+func BackfillSearchNames(db *sql.DB) error {
+	rows, err := db.Query("SELECT id, name FROM files WHERE search_name IS NULL")
+	if err != nil {
+		return fmt.Errorf("failed to query files without search names: %w", err)
+	}
 
-	if err == nil {
-		// Found a free space, remove or resize it
-		_, err = tx.Exec("DELETE FROM free_spaces WHERE id = ?", freeSpaceID)
-		if err != nil {
-			return 0, err
+	names := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan file: %w", err)
 		}
-		return offset, nil
-	} else if err != sql.ErrNoRows {
-		return 0, err
+		names[id] = name
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating files: %w", err)
 	}
 
-	// No suitable free space found, append to end of file
-	file, err := os.Stat(tvaultPath)
+	if len(names) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
 	if err != nil {
-		return 0, err
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for id, name := range names {
+		if _, err := tx.Exec("UPDATE files SET search_name = ? WHERE id = ?", SearchName(name), id); err != nil {
+			return fmt.Errorf("failed to update search name: %w", err)
+		}
 	}
 
-	return file.Size(), nil
+	return tx.Commit()
 }
 
 // GenerateFileKey generates a file-specific encryption key
@@ -78,6 +116,53 @@ func GenerateFileKey(fileUUID string, dbKey []byte) []byte {
 	return hash.Sum(nil)
 }
 
+// NewContentHash returns a keyed hash for detecting identical file contents.
+// Keying it with the database key means equal hashes reveal nothing to anyone
+// without that key.
+func NewContentHash(dbKey []byte) hash.Hash {
+	key := sha256.New()
+	key.Write(dbKey)
+	key.Write([]byte("content-hash"))
+	return hmac.New(sha256.New, key.Sum(nil))
+}
+
+// ContentHashes are digests of a file's plaintext, hex encoded
+type ContentHashes struct {
+	Keyed  string // for deduplication, see NewContentHash
+	SHA256 string
+	SHA512 string
+}
+
+// ContentHasher computes all ContentHashes in a single pass over the data
+type ContentHasher struct {
+	keyed  hash.Hash
+	sha256 hash.Hash
+	sha512 hash.Hash
+}
+
+func NewContentHasher(dbKey []byte) *ContentHasher {
+	return &ContentHasher{
+		keyed:  NewContentHash(dbKey),
+		sha256: sha256.New(),
+		sha512: sha512.New(),
+	}
+}
+
+func (h *ContentHasher) Write(p []byte) (int, error) {
+	h.keyed.Write(p)
+	h.sha256.Write(p)
+	h.sha512.Write(p)
+	return len(p), nil
+}
+
+func (h *ContentHasher) Sum() ContentHashes {
+	return ContentHashes{
+		Keyed:  hex.EncodeToString(h.keyed.Sum(nil)),
+		SHA256: hex.EncodeToString(h.sha256.Sum(nil)),
+		SHA512: hex.EncodeToString(h.sha512.Sum(nil)),
+	}
+}
+
 // CreateUniqueFilename creates a unique filename by appending a counter if the file already exists
 func CreateUniqueFilename(dir, fileName string) string {
 	originalPath := filepath.Join(dir, fileName)
@@ -208,6 +293,38 @@ func GetFileExtensionFromMimeType(mimeType string) string {
 	}
 }
 
+// DetectMimeType guesses a file's mimetype from its first bytes, falling back to the
+// file extension when the content only matches a generic container or text type
+func DetectMimeType(fileName string, head []byte) string {
+	detected := http.DetectContentType(head)
+	if mediaType, _, err := mime.ParseMediaType(detected); err == nil {
+		detected = mediaType
+	}
+
+	switch detected {
+	case "application/octet-stream", "text/plain", "application/zip":
+		if byExtension := mime.TypeByExtension(strings.ToLower(filepath.Ext(fileName))); byExtension != "" {
+			if mediaType, _, err := mime.ParseMediaType(byExtension); err == nil {
+				return mediaType
+			}
+		}
+	}
+
+	return detected
+}
+
+// SafePathComponent reduces a stored name to a single path component for
+// exports. Names of received files and folders come from another device and
+// may contain separators or "..". It returns "" when nothing usable is left.
+func SafePathComponent(name string) string {
+	name = strings.NewReplacer("\\", "/", "\x00", "").Replace(name)
+	name = path.Base(name)
+	if name == "." || name == ".." || name == "/" {
+		return ""
+	}
+	return name
+}
+
 // EnsureFileExtension ensures a filename has the correct extension based on its mimetype
 func EnsureFileExtension(fileName, mimeType string) string {
 	// Check if the filename already has an extension
@@ -222,11 +339,36 @@ func EnsureFileExtension(fileName, mimeType string) string {
 
 // FileInfo represents basic file information
 type FileInfo struct {
-	ID        int64  `json:"id"`
-	Name      string `json:"name"`
-	MimeType  string `json:"mimeType"`
-	Timestamp string `json:"timestamp"`
-	Size      int64  `json:"size"`
+	ID        int64        `json:"id"`
+	Name      string       `json:"name"`
+	MimeType  string       `json:"mimeType"`
+	Timestamp string       `json:"timestamp"`
+	Size      int64        `json:"size"`
+	SHA256    string       `json:"sha256,omitempty"`
+	SHA512    string       `json:"sha512,omitempty"`
+	Tags      []string     `json:"tags,omitempty"`  // written to ExportTagsFile
+	Notes     []ExportNote `json:"notes,omitempty"` // written to a sidecar, see ExportNotesSuffix
+}
+
+// ExportTagsFile is added to ZIP exports and bags holding tagged files or folders
+const ExportTagsFile = "tella-tags.json"
+
+// ExportTags is the content of ExportTagsFile. Files are keyed by their path in the export.
+type ExportTags struct {
+	Folder []string            `json:"folder,omitempty"`
+	Files  map[string][]string `json:"files,omitempty"`
+}
+
+// encodeExportTags returns ExportTagsFile's content, or nil when nothing is tagged
+func encodeExportTags(tags ExportTags) ([]byte, error) {
+	if len(tags.Folder) == 0 && len(tags.Files) == 0 {
+		return nil, nil
+	}
+	data, err := json.MarshalIndent(tags, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode tags: %w", err)
+	}
+	return append(data, '\n'), nil
 }
 
 // FolderInfo represents basic folder information
@@ -238,15 +380,20 @@ type FolderInfo struct {
 
 // FileMetadata represents complete file metadata including encryption details
 type FileMetadata struct {
-	ID        int64
-	UUID      string
-	Name      string
-	Size      int64
-	MimeType  string
-	FolderID  int64
-	Offset    int64
-	Length    int64
-	CreatedAt time.Time
+	ID            int64
+	UUID          string
+	Name          string
+	Size          int64
+	MimeType      string
+	FolderID      int64
+	Offset        int64
+	Length        int64
+	CipherVersion int
+	Compression   string // algorithm applied before encryption, see CompressionDeflate
+	KeyUUID       string // UUID the extent was encrypted under, differs from UUID for deduplicated files
+	SHA256        string
+	SHA512        string
+	CreatedAt     time.Time
 }
 
 // GetFileMetadataByID retrieves file metadata from database by ID
@@ -254,10 +401,15 @@ func GetFileMetadataByID(db *sql.DB, id int64) (*FileMetadata, error) {
 	var metadata FileMetadata
 
 	err := db.QueryRow(`
-		SELECT uuid, name, mime_type, offset, length
+		SELECT id, uuid, name, size, mime_type, folder_id, offset, length, cipher_version,
+			compression, COALESCE(key_uuid, uuid), COALESCE(sha256, ''), COALESCE(sha512, '')
 		FROM files
 		WHERE id = ? AND is_deleted = 0
-	`, id).Scan(&metadata.UUID, &metadata.Name, &metadata.MimeType, &metadata.Offset, &metadata.Length)
+	`, id).Scan(
+		&metadata.ID, &metadata.UUID, &metadata.Name, &metadata.Size, &metadata.MimeType,
+		&metadata.FolderID, &metadata.Offset, &metadata.Length, &metadata.CipherVersion,
+		&metadata.Compression, &metadata.KeyUUID, &metadata.SHA256, &metadata.SHA512,
+	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -305,9 +457,9 @@ func GetSelectedFilesInFolder(db *sql.DB, folderID int64, fileIDs []int64) ([]Fi
 	}
 
 	query := fmt.Sprintf(`
-		SELECT id, name, mime_type, created_at, size 
+		SELECT id, name, mime_type, created_at, size, COALESCE(sha256, ''), COALESCE(sha512, '')
 		FROM files 
-		WHERE folder_id = ? AND id IN (%s) AND is_deleted = 0 
+		WHERE folder_id = ? AND id IN (%s) AND is_deleted = 0 AND trashed_at IS NULL
 		ORDER BY created_at DESC
 	`, strings.Join(placeholders, ","))
 
@@ -320,7 +472,7 @@ func GetSelectedFilesInFolder(db *sql.DB, folderID int64, fileIDs []int64) ([]Fi
 	var files []FileInfo
 	for rows.Next() {
 		var file FileInfo
-		if err := rows.Scan(&file.ID, &file.Name, &file.MimeType, &file.Timestamp, &file.Size); err != nil {
+		if err := rows.Scan(&file.ID, &file.Name, &file.MimeType, &file.Timestamp, &file.Size, &file.SHA256, &file.SHA512); err != nil {
 			return nil, fmt.Errorf("failed to scan file: %w", err)
 		}
 		files = append(files, file)
@@ -333,29 +485,50 @@ func GetSelectedFilesInFolder(db *sql.DB, folderID int64, fileIDs []int64) ([]Fi
 	return files, nil
 }
 
+// FileOpener opens a stored file for on-the-fly decryption, normally filestore.Service.OpenFile
+type FileOpener func(id int64) (io.ReadSeekCloser, error)
+
+// ExportEntryOptions controls how a single file is written out of the TVault
+type ExportEntryOptions struct {
+	Name  string        // replaces the stored name when set
+	Scrub *ScrubOptions // strips metadata when set
+}
+
+// ExportEntry reports what was written for a single file
+type ExportEntry struct {
+	FileID     int64
+	SourceName string // name in the vault
+	Name       string // name the file was exported under
+	Path       string
+	Scrub      *ScrubReport
+	SHA256     string // recorded when the file was stored
+	SHA512     string
+	// ExportedSHA256 is the digest of the bytes written, which differs from
+	// SHA256 when metadata was scrubbed
+	ExportedSHA256 string
+	Err            error
+}
+
 // ExportSingleFile exports a single file to the specified directory
-func ExportSingleFile(db *sql.DB, dbKey []byte, id int64, tvault *os.File, exportDir string) (string, error) {
+func ExportSingleFile(db *sql.DB, open FileOpener, id int64, exportDir string, options ExportEntryOptions) (*ExportEntry, error) {
 	metadata, err := GetFileMetadataByID(db, id)
 	if err != nil {
-		return "", err
-	}
-
-	// Read encrypted data from TVault
-	encryptedData := make([]byte, metadata.Length)
-	_, err = tvault.ReadAt(encryptedData, metadata.Offset)
-	if err != nil {
-		return "", fmt.Errorf("failed to read file from TVault: %w", err)
+		return nil, err
 	}
 
-	// Generate file key and decrypt
-	fileKey := GenerateFileKey(metadata.UUID, dbKey)
-	decryptedData, err := authutils.DecryptData(encryptedData, fileKey)
+	// Decrypt on the fly while copying out of the TVault
+	reader, err := open(id)
 	if err != nil {
-		return "", fmt.Errorf("failed to decrypt file: %w", err)
+		return nil, err
 	}
+	defer reader.Close()
 
 	// Ensure filename has proper extension based on mimetype
-	fileName := EnsureFileExtension(metadata.Name, metadata.MimeType)
+	fileName := metadata.Name
+	if options.Name != "" {
+		fileName = options.Name
+	}
+	fileName = EnsureFileExtension(fileName, metadata.MimeType)
 
 	// Create unique filename in export directory
 	exportPath := CreateUniqueFilename(exportDir, fileName)
@@ -363,14 +536,17 @@ func ExportSingleFile(db *sql.DB, dbKey []byte, id int64, tvault *os.File, expor
 	// Create the exported file
 	exportFile, err := os.Create(exportPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to create export file: %w", err)
+		return nil, fmt.Errorf("failed to create export file: %w", err)
 	}
 	defer exportFile.Close()
 
 	// Write decrypted data to export file
-	_, err = exportFile.Write(decryptedData)
+	digest := sha256.New()
+	report, err := WriteExportData(io.MultiWriter(exportFile, digest), reader, options.Scrub)
 	if err != nil {
-		return "", fmt.Errorf("failed to write to export file: %w", err)
+		exportFile.Close()
+		os.Remove(exportPath)
+		return nil, fmt.Errorf("failed to write to export file: %w", err)
 	}
 
 	// Set appropriate file permissions
@@ -379,11 +555,24 @@ func ExportSingleFile(db *sql.DB, dbKey []byte, id int64, tvault *os.File, expor
 		fmt.Printf("Failed to set file permissions for %s: %v", exportPath, err)
 	}
 
-	return exportPath, nil
+	return &ExportEntry{
+		FileID:         id,
+		SourceName:     metadata.Name,
+		Name:           filepath.Base(exportPath),
+		Path:           exportPath,
+		Scrub:          report,
+		SHA256:         metadata.SHA256,
+		SHA512:         metadata.SHA512,
+		ExportedSHA256: hex.EncodeToString(digest.Sum(nil)),
+	}, nil
 }
 
-// CreateZipFile creates a ZIP file containing the specified files
-func CreateZipFile(db *sql.DB, dbKey []byte, folderName string, files []FileInfo, tvault *os.File, exportDir string) (string, error) {
+// CreateZipFile creates a ZIP file containing the specified files. The
+// returned entries report the outcome for each file, including failures.
+// added, when set, is called after each file. If ctx is cancelled the
+// partial ZIP is removed. The tags of the folder and its files, if any,
+// are listed in ExportTagsFile, and their notes are added as sidecars.
+func CreateZipFile(ctx context.Context, open FileOpener, folderName string, folder ExportFolderMetadata, files []FileInfo, exportDir string, options func(FileInfo) ExportEntryOptions, added func(ExportEntry)) (string, []ExportEntry, error) {
 	// Create unique ZIP filename
 	zipFileName := fmt.Sprintf("%s.zip", folderName)
 	zipPath := CreateUniqueFilename(exportDir, zipFileName)
@@ -391,7 +580,7 @@ func CreateZipFile(db *sql.DB, dbKey []byte, folderName string, files []FileInfo
 	// Create ZIP file
 	zipFile, err := os.Create(zipPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to create ZIP file: %w", err)
+		return "", nil, fmt.Errorf("failed to create ZIP file: %w", err)
 	}
 	defer zipFile.Close()
 
@@ -400,12 +589,42 @@ func CreateZipFile(db *sql.DB, dbKey []byte, folderName string, files []FileInfo
 	defer zipWriter.Close()
 
 	// Add each file to ZIP
+	var entries []ExportEntry
+	tags := ExportTags{Folder: folder.Tags, Files: make(map[string][]string)}
 	for _, file := range files {
-		err := AddFileToZip(db, dbKey, zipWriter, file, tvault)
+		if err := ctx.Err(); err != nil {
+			zipWriter.Close()
+			zipFile.Close()
+			os.Remove(zipPath)
+			return "", nil, err
+		}
+
+		entry, err := AddFileToZip(open, zipWriter, file, options(file))
 		if err != nil {
 			fmt.Printf("Failed to add file '%s' to ZIP: %v", file.Name, err)
-			continue // Continue with other files
+			entry = &ExportEntry{FileID: file.ID, SourceName: file.Name, Err: err}
+		} else {
+			entry.Path = zipPath
+		}
+		entries = append(entries, *entry)
+		if added != nil {
+			added(*entry)
 		}
+		if len(file.Tags) > 0 && entry.Err == nil {
+			tags.Files[entry.Name] = file.Tags
+		}
+		if entry.Err == nil {
+			if err := addNotesToZip(zipWriter, entry.Name+ExportNotesSuffix, file.Notes); err != nil {
+				fmt.Printf("Failed to add notes of '%s' to ZIP: %v", file.Name, err)
+			}
+		}
+	}
+
+	if err := addTagsToZip(zipWriter, tags); err != nil {
+		fmt.Printf("Failed to add tags to ZIP: %v", err)
+	}
+	if err := addNotesToZip(zipWriter, ExportFolderNotesFile, folder.Notes); err != nil {
+		fmt.Printf("Failed to add folder notes to ZIP: %v", err)
 	}
 
 	// Set appropriate file permissions
@@ -413,58 +632,110 @@ func CreateZipFile(db *sql.DB, dbKey []byte, folderName string, files []FileInfo
 		fmt.Printf("Failed to set ZIP file permissions: %v", err)
 	}
 
-	return zipPath, nil
+	return zipPath, entries, nil
 }
 
-// AddFileToZip adds a single file to an existing ZIP writer
-func AddFileToZip(db *sql.DB, dbKey []byte, zipWriter *zip.Writer, file FileInfo, tvault *os.File) error {
-	// Get file metadata for decryption
-	metadata, err := GetFileMetadataByID(db, file.ID)
-	if err != nil {
-		return fmt.Errorf("failed to get metadata for file %d: %w", file.ID, err)
+func addTagsToZip(zipWriter *zip.Writer, tags ExportTags) error {
+	data, err := encodeExportTags(tags)
+	if err != nil || data == nil {
+		return err
 	}
-
-	// Read and decrypt file
-	encryptedData := make([]byte, metadata.Length)
-	_, err = tvault.ReadAt(encryptedData, metadata.Offset)
+	writer, err := zipWriter.Create(ExportTagsFile)
 	if err != nil {
-		return fmt.Errorf("failed to read encrypted data: %w", err)
+		return err
 	}
+	_, err = writer.Write(data)
+	return err
+}
 
-	fileKey := GenerateFileKey(metadata.UUID, dbKey)
-	decryptedData, err := authutils.DecryptData(encryptedData, fileKey)
+// AddFileToZip adds a single file to an existing ZIP writer
+func AddFileToZip(open FileOpener, zipWriter *zip.Writer, file FileInfo, options ExportEntryOptions) (*ExportEntry, error) {
+	reader, err := open(file.ID)
 	if err != nil {
-		return fmt.Errorf("failed to decrypt file: %w", err)
+		return nil, fmt.Errorf("failed to open file %d: %w", file.ID, err)
 	}
+	defer reader.Close()
 
 	// Ensure filename has proper extension for ZIP entry
-	fileName := EnsureFileExtension(file.Name, file.MimeType)
+	fileName := file.Name
+	if options.Name != "" {
+		fileName = options.Name
+	}
+	fileName = EnsureFileExtension(fileName, file.MimeType)
 
 	// Create file in ZIP
 	fileWriter, err := zipWriter.Create(fileName)
 	if err != nil {
-		return fmt.Errorf("failed to create file in ZIP: %w", err)
+		return nil, fmt.Errorf("failed to create file in ZIP: %w", err)
 	}
 
 	// Write decrypted data to ZIP entry
-	_, err = fileWriter.Write(decryptedData)
+	digest := sha256.New()
+	report, err := WriteExportData(io.MultiWriter(fileWriter, digest), reader, options.Scrub)
 	if err != nil {
-		return fmt.Errorf("failed to write file data to ZIP: %w", err)
-	}
-
-	return nil
+		return nil, fmt.Errorf("failed to write file data to ZIP: %w", err)
+	}
+
+	return &ExportEntry{
+		FileID:         file.ID,
+		SourceName:     file.Name,
+		Name:           fileName,
+		Scrub:          report,
+		SHA256:         file.SHA256,
+		SHA512:         file.SHA512,
+		ExportedSHA256: hex.EncodeToString(digest.Sum(nil)),
+	}, nil
 }
 
 // RecordTempFile records a temporary file in the database for cleanup
-func RecordTempFile(db *sql.DB, fileID int64, tempPath string) error {
-	_, err := db.Exec(`
+func RecordTempFile(tx *sql.Tx, fileID int64, tempPath string) (int64, error) {
+	result, err := tx.Exec(`
 		INSERT INTO temp_files (file_id, temp_path, created_at)
 		VALUES (?, ?, datetime('now'))
 	`, fileID, tempPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record temporary file: %w", err)
+	}
 
-	return err
+	return result.LastInsertId()
 }
 
+// TempCopyPrefix starts the name of every directory holding a decrypted temporary copy
+const TempCopyPrefix = "open-"
+
+// WipeTempCopies securely deletes every decrypted copy left in tempDir, such
+// as those of a session that crashed. It needs no database, so it can run
+// before the vault is unlocked.
+func WipeTempCopies(tempDir string) error {
+	dirs, err := filepath.Glob(filepath.Join(tempDir, TempCopyPrefix+"*"))
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, dir := range dirs {
+		filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err == nil && entry.Type().IsRegular() {
+				if err := SecurelyDeleteFile(path); err != nil {
+					failed++
+				}
+			}
+			return nil
+		})
+		if err := os.RemoveAll(dir); err != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to wipe %d temporary copies", failed)
+	}
+	return nil
+}
+
+// overwriteBlockSize bounds the memory used when wiping file data
+const overwriteBlockSize = 1024 * 1024
+
 // Delete files
 func SecurelyOverwriteFileData(tvaultPath string, offset, length int64) error {
 	file, err := os.OpenFile(tvaultPath, os.O_WRONLY, 0600)
@@ -473,16 +744,24 @@ func SecurelyOverwriteFileData(tvaultPath string, offset, length int64) error {
 	}
 	defer file.Close()
 
-	// Generate random data to overwrite the file content
-	randomData := make([]byte, length)
-	if _, err := rand.Read(randomData); err != nil {
-		return fmt.Errorf("failed to generate random data: %w", err)
+	// Overwrite the file content with random data, one bounded block at a time
+	blockSize := int64(overwriteBlockSize)
+	if length < blockSize {
+		blockSize = length
 	}
-
-	// Overwrite the file data at the specified offset
-	_, err = file.WriteAt(randomData, offset)
-	if err != nil {
-		return fmt.Errorf("failed to overwrite file data: %w", err)
+	randomData := make([]byte, blockSize)
+	for written := int64(0); written < length; {
+		n := length - written
+		if n > blockSize {
+			n = blockSize
+		}
+		if _, err := rand.Read(randomData[:n]); err != nil {
+			return fmt.Errorf("failed to generate random data: %w", err)
+		}
+		if _, err := file.WriteAt(randomData[:n], offset+written); err != nil {
+			return fmt.Errorf("failed to overwrite file data: %w", err)
+		}
+		written += n
 	}
 
 	// Force write to disk
@@ -493,15 +772,52 @@ func SecurelyOverwriteFileData(tvaultPath string, offset, length int64) error {
 	return nil
 }
 
-// AddFreeSpace records a new free space area in the database
-func AddFreeSpace(tx *sql.Tx, offset, length int64) error {
-	_, err := tx.Exec(`
-		INSERT INTO free_spaces (offset, length, created_at)
-		VALUES (?, ?, datetime('now'))
-	`, offset, length)
-
+// SecurelyDeleteFile overwrites a file on the local filesystem with random data before removing it
+func SecurelyDeleteFile(path string) error {
+	info, err := os.Lstat(path)
 	if err != nil {
-		return fmt.Errorf("failed to add free space record: %w", err)
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("not a regular file: %s", path)
+	}
+
+	if info.Size() > 0 {
+		if err := SecurelyOverwriteFileData(path, 0, info.Size()); err != nil {
+			return err
+		}
+		if err := os.Truncate(path, 0); err != nil {
+			return fmt.Errorf("failed to truncate file: %w", err)
+		}
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove file: %w", err)
+	}
+	return nil
+}
+
+// CopyVaultData copies raw (still encrypted) bytes between two non-overlapping
+// regions of the TVault and syncs the result to disk
+func CopyVaultData(tvault *os.File, src, dst, length int64) error {
+	if src < dst+length && dst < src+length {
+		return fmt.Errorf("cannot copy between overlapping regions")
+	}
+
+	buf := make([]byte, min(length, overwriteBlockSize))
+	for copied := int64(0); copied < length; {
+		n := min(length-copied, int64(len(buf)))
+		if _, err := tvault.ReadAt(buf[:n], src+copied); err != nil {
+			return fmt.Errorf("failed to read vault data: %w", err)
+		}
+		if _, err := tvault.WriteAt(buf[:n], dst+copied); err != nil {
+			return fmt.Errorf("failed to write vault data: %w", err)
+		}
+		copied += n
+	}
+
+	if err := tvault.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file changes: %w", err)
 	}
 
 	return nil
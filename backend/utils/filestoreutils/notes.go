@@ -0,0 +1,86 @@
+package filestoreutils
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// ExportNotesSuffix is appended to an exported file's name to name the
+// sidecar holding its notes
+const ExportNotesSuffix = ".notes.json"
+
+// ExportFolderNotesFile holds the notes of the folder a ZIP export was made from
+const ExportFolderNotesFile = "tella-folder-notes.json"
+
+// ExportBagNotesDir holds note sidecars in bags. They are tag files rather
+// than payload, so the payload is exactly the exported files.
+const ExportBagNotesDir = "tella-notes"
+
+// ExportNote is a note as written to a sidecar file
+type ExportNote struct {
+	Author    string `json:"author,omitempty"`
+	Body      string `json:"body"`
+	Status    string `json:"status,omitempty"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// ExportFolderMetadata is what exports record about an exported folder
+type ExportFolderMetadata struct {
+	Tags  []string
+	Notes []ExportNote
+}
+
+// encodeExportNotes returns a sidecar's content, or nil when there are no notes
+func encodeExportNotes(notes []ExportNote) ([]byte, error) {
+	if len(notes) == 0 {
+		return nil, nil
+	}
+	data, err := json.MarshalIndent(notes, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode notes: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// WriteNotesSidecar writes notes next to the file exported to exportPath and
+// returns the sidecar's path, or "" when there are no notes
+func WriteNotesSidecar(exportPath string, notes []ExportNote) (string, error) {
+	data, err := encodeExportNotes(notes)
+	if err != nil || data == nil {
+		return "", err
+	}
+
+	sidecarPath := CreateUniqueFilename(filepath.Dir(exportPath), filepath.Base(exportPath)+ExportNotesSuffix)
+	if err := os.WriteFile(sidecarPath, data, 0644); err != nil {
+		os.Remove(sidecarPath)
+		return "", fmt.Errorf("failed to write notes: %w", err)
+	}
+	return sidecarPath, nil
+}
+
+func addNotesToZip(zipWriter *zip.Writer, name string, notes []ExportNote) error {
+	data, err := encodeExportNotes(notes)
+	if err != nil || data == nil {
+		return err
+	}
+	writer, err := zipWriter.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
+
+// bagNotesPath returns where the notes of a payload file, or of a folder when
+// folder is set, are kept in a bag. Paths are relative to data/.
+func bagNotesPath(payloadPath string, folder bool) string {
+	if folder {
+		return path.Join(ExportBagNotesDir, payloadPath, ExportFolderNotesFile)
+	}
+	return path.Join(ExportBagNotesDir, payloadPath+ExportNotesSuffix)
+}
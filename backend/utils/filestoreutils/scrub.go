@@ -0,0 +1,666 @@
+package filestoreutils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// MaxScrubSize is the largest file whose metadata is scrubbed; scrubbing
+// rewrites the whole file in memory
+const MaxScrubSize = 256 * 1024 * 1024
+
+// ScrubOptions selects metadata that survives scrubbing. Everything else that
+// the scrubber recognises is removed.
+type ScrubOptions struct {
+	KeepOrientation  bool // keep a minimal EXIF block holding only the image orientation
+	KeepColorProfile bool // keep embedded ICC colour profiles
+}
+
+// ScrubReport describes what scrubbing found in one file
+type ScrubReport struct {
+	Format   string   `json:"format"`
+	Removed  []string `json:"removed"`
+	Kept     []string `json:"kept"`
+	Warnings []string `json:"warnings"`
+}
+
+func (r *ScrubReport) removed(format string, args ...interface{}) {
+	r.Removed = append(r.Removed, fmt.Sprintf(format, args...))
+}
+
+func (r *ScrubReport) kept(format string, args ...interface{}) {
+	r.Kept = append(r.Kept, fmt.Sprintf(format, args...))
+}
+
+func (r *ScrubReport) warn(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// ScrubFormat names the format ScrubMetadata would handle, detected from the
+// first bytes of the file, or "" if the format is not supported
+func ScrubFormat(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xFF, 0xD8, 0xFF}):
+		return "JPEG"
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return "PNG"
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		return "WebP"
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		return "PDF"
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return "Office"
+	}
+	return ""
+}
+
+// ScrubMetadata removes identifying metadata from JPEG, PNG, WebP, PDF and
+// Office (OOXML and OpenDocument) files. The format is detected from the
+// content. Unsupported formats are returned unchanged with a warning.
+func ScrubMetadata(data []byte, options ScrubOptions) ([]byte, *ScrubReport, error) {
+	report := &ScrubReport{Format: ScrubFormat(data)}
+
+	var scrubbed []byte
+	var err error
+	switch report.Format {
+	case "JPEG":
+		scrubbed, err = scrubJPEG(data, options, report)
+	case "PNG":
+		scrubbed, err = scrubPNG(data, options, report)
+	case "WebP":
+		scrubbed, err = scrubWebP(data, options, report)
+	case "PDF":
+		scrubbed, err = scrubPDF(data, report)
+	case "Office":
+		scrubbed, err = scrubOfficeDocument(data, report)
+	default:
+		report.Format = "unknown"
+		report.warn("format not supported, metadata was not removed")
+		return data, report, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to scrub %s metadata: %w", report.Format, err)
+	}
+
+	return scrubbed, report, nil
+}
+
+// JPEG
+
+func scrubJPEG(data []byte, options ScrubOptions, report *ScrubReport) ([]byte, error) {
+	var out bytes.Buffer
+	out.Write(data[:2])
+
+	orientation := 0
+	exifAt := -1 // where a minimal EXIF block is inserted to keep the orientation
+	pos := 2
+	for pos < len(data) {
+		if data[pos] != 0xFF {
+			return nil, fmt.Errorf("expected marker at offset %d", pos)
+		}
+		for pos+1 < len(data) && data[pos+1] == 0xFF {
+			pos++ // fill bytes
+		}
+		if pos+1 >= len(data) {
+			return nil, fmt.Errorf("truncated marker at offset %d", pos)
+		}
+		marker := data[pos+1]
+
+		if marker == 0xD9 {
+			out.Write(data[pos : pos+2])
+			if trailing := len(data) - pos - 2; trailing > 0 {
+				report.removed("%d bytes after the end of the image (embedded previews or vendor data)", trailing)
+			}
+			break
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			out.Write(data[pos : pos+2])
+			pos += 2
+			continue
+		}
+
+		if pos+4 > len(data) {
+			return nil, fmt.Errorf("truncated segment at offset %d", pos)
+		}
+		end := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+		if end > len(data) || end < pos+4 {
+			return nil, fmt.Errorf("invalid segment length at offset %d", pos)
+		}
+		segment := data[pos:end]
+		payload := data[pos+4 : end]
+
+		switch {
+		case marker == 0xE0 && bytes.HasPrefix(payload, []byte("JFIF\x00")):
+			out.Write(segment)
+			exifAt = out.Len()
+		case marker == 0xE1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")):
+			o, found := summariseEXIF(payload[6:])
+			if o > orientation {
+				orientation = o
+			}
+			report.removed("EXIF metadata%s", describeFound(found))
+		case marker == 0xE1 && bytes.Contains(payload[:min(len(payload), 40)], []byte("ns.adobe.com/x")):
+			report.removed("XMP metadata")
+		case marker == 0xE2 && bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00")):
+			if options.KeepColorProfile {
+				out.Write(segment)
+				report.kept("ICC colour profile")
+			} else {
+				report.removed("ICC colour profile")
+			}
+		case marker == 0xE2 && bytes.HasPrefix(payload, []byte("MPF\x00")):
+			report.removed("multi-picture index")
+		case marker == 0xED:
+			report.removed("Photoshop/IPTC metadata")
+		case marker == 0xFE:
+			report.removed("comment")
+		case marker == 0xEE:
+			// Adobe colour transform, needed to decode the image correctly
+			out.Write(segment)
+		case marker >= 0xE0 && marker <= 0xEF:
+			report.removed("APP%d segment", marker-0xE0)
+		default:
+			out.Write(segment)
+		}
+		pos = end
+
+		// Entropy-coded data follows the scan header up to the next real marker
+		if marker == 0xDA {
+			scanEnd := pos
+			for scanEnd+1 < len(data) {
+				if data[scanEnd] == 0xFF {
+					next := data[scanEnd+1]
+					if next != 0x00 && next != 0xFF && (next < 0xD0 || next > 0xD7) {
+						break
+					}
+				}
+				scanEnd++
+			}
+			if scanEnd+1 >= len(data) {
+				scanEnd = len(data)
+			}
+			out.Write(data[pos:scanEnd])
+			pos = scanEnd
+		}
+	}
+
+	result := out.Bytes()
+	if options.KeepOrientation && orientation > 1 {
+		if exifAt < 0 {
+			exifAt = 2
+		}
+		tiff := minimalOrientationTIFF(orientation)
+		segment := []byte{0xFF, 0xE1, 0, 0}
+		binary.BigEndian.PutUint16(segment[2:], uint16(2+6+len(tiff)))
+		segment = append(segment, "Exif\x00\x00"...)
+		segment = append(segment, tiff...)
+
+		result = append(result[:exifAt:exifAt], append(segment, result[exifAt:]...)...)
+		report.kept("image orientation")
+	}
+
+	return result, nil
+}
+
+// summariseEXIF reads the orientation and notes which kinds of identifying
+// data a TIFF-structured EXIF block carries in its first directory
+func summariseEXIF(tiff []byte) (int, []string) {
+	if len(tiff) < 8 {
+		return 0, nil
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, nil
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 0, nil
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+
+	orientation := 0
+	found := make(map[string]bool)
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		switch order.Uint16(tiff[entry:]) {
+		case 0x0112:
+			orientation = int(order.Uint16(tiff[entry+8:]))
+		case 0x8825:
+			found["GPS location"] = true
+		case 0x010F, 0x0110:
+			found["camera make and model"] = true
+		case 0x0131:
+			found["software"] = true
+		case 0x0132, 0x8769:
+			found["timestamps and camera details"] = true
+		case 0x013B, 0x8298:
+			found["author and copyright"] = true
+		}
+	}
+
+	var kinds []string
+	for _, kind := range []string{"GPS location", "camera make and model", "timestamps and camera details", "author and copyright", "software"} {
+		if found[kind] {
+			kinds = append(kinds, kind)
+		}
+	}
+	return orientation, kinds
+}
+
+func describeFound(found []string) string {
+	if len(found) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(found, ", ") + ")"
+}
+
+// minimalOrientationTIFF builds an EXIF TIFF structure holding only the orientation tag
+func minimalOrientationTIFF(orientation int) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = append(tiff, 0x00, 0x01)                    // one entry
+	tiff = append(tiff, 0x01, 0x12, 0x00, 0x03)        // Orientation, SHORT
+	tiff = append(tiff, 0x00, 0x00, 0x00, 0x01)        // count 1
+	tiff = append(tiff, 0x00, byte(orientation), 0, 0) // value, padded
+	tiff = append(tiff, 0x00, 0x00, 0x00, 0x00)        // no next directory
+	return tiff
+}
+
+// PNG
+
+func scrubPNG(data []byte, options ScrubOptions, report *ScrubReport) ([]byte, error) {
+	var out bytes.Buffer
+	out.Write(data[:8])
+
+	pos := 8
+	for pos < len(data) {
+		if pos+12 > len(data) {
+			return nil, fmt.Errorf("truncated chunk at offset %d", pos)
+		}
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, fmt.Errorf("invalid chunk length at offset %d", pos)
+		}
+		chunkType := string(data[pos+4 : pos+8])
+		chunkData := data[pos+8 : pos+8+length]
+		chunk := data[pos:end]
+		pos = end
+
+		switch chunkType {
+		case "tEXt", "zTXt", "iTXt":
+			keyword := chunkData
+			if i := bytes.IndexByte(keyword, 0); i >= 0 {
+				keyword = keyword[:i]
+			}
+			report.removed("text chunk %q", string(keyword))
+		case "tIME":
+			report.removed("modification time")
+		case "eXIf":
+			orientation, found := summariseEXIF(chunkData)
+			report.removed("EXIF metadata%s", describeFound(found))
+			if options.KeepOrientation && orientation > 1 {
+				writePNGChunk(&out, "eXIf", minimalOrientationTIFF(orientation))
+				report.kept("image orientation")
+			}
+		case "iCCP":
+			if options.KeepColorProfile {
+				out.Write(chunk)
+				report.kept("ICC colour profile")
+			} else {
+				report.removed("ICC colour profile")
+			}
+		default:
+			out.Write(chunk)
+		}
+
+		if chunkType == "IEND" {
+			if trailing := len(data) - pos; trailing > 0 {
+				report.removed("%d bytes after the end of the image", trailing)
+			}
+			break
+		}
+	}
+
+	return out.Bytes(), nil
+}
+
+func writePNGChunk(out *bytes.Buffer, chunkType string, data []byte) {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], chunkType)
+	out.Write(header[:])
+	out.Write(data)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	out.Write(sum[:])
+}
+
+// WebP
+
+// VP8X feature flags
+const (
+	webpFlagICC  = 0x20
+	webpFlagEXIF = 0x08
+	webpFlagXMP  = 0x04
+)
+
+func scrubWebP(data []byte, options ScrubOptions, report *ScrubReport) ([]byte, error) {
+	riffEnd := 8 + int(binary.LittleEndian.Uint32(data[4:]))
+	if riffEnd > len(data) || riffEnd < 12 {
+		return nil, fmt.Errorf("invalid RIFF size")
+	}
+
+	var chunks bytes.Buffer
+	vp8xAt := -1
+	flags := byte(0)
+
+	pos := 12
+	for pos < riffEnd {
+		if pos+8 > riffEnd {
+			return nil, fmt.Errorf("truncated chunk at offset %d", pos)
+		}
+		fourCC := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		end := pos + 8 + size + size%2
+		if size < 0 || end > riffEnd {
+			return nil, fmt.Errorf("invalid chunk size at offset %d", pos)
+		}
+		chunk := data[pos:end]
+		payload := data[pos+8 : pos+8+size]
+		pos = end
+
+		switch fourCC {
+		case "VP8X":
+			if size < 1 {
+				return nil, fmt.Errorf("invalid VP8X chunk")
+			}
+			vp8xAt = chunks.Len()
+			flags = payload[0]
+			chunks.Write(chunk)
+		case "EXIF":
+			orientation, found := summariseEXIF(bytes.TrimPrefix(payload, []byte("Exif\x00\x00")))
+			report.removed("EXIF metadata%s", describeFound(found))
+			flags &^= webpFlagEXIF
+			if options.KeepOrientation && orientation > 1 {
+				writeWebPChunk(&chunks, "EXIF", minimalOrientationTIFF(orientation))
+				flags |= webpFlagEXIF
+				report.kept("image orientation")
+			}
+		case "XMP ":
+			report.removed("XMP metadata")
+			flags &^= webpFlagXMP
+		case "ICCP":
+			if options.KeepColorProfile {
+				chunks.Write(chunk)
+				report.kept("ICC colour profile")
+			} else {
+				report.removed("ICC colour profile")
+				flags &^= webpFlagICC
+			}
+		default:
+			chunks.Write(chunk)
+		}
+	}
+
+	result := chunks.Bytes()
+	if vp8xAt >= 0 {
+		result[vp8xAt+8] = flags
+	}
+
+	out := make([]byte, 12, 12+len(result))
+	copy(out, "RIFF")
+	binary.LittleEndian.PutUint32(out[4:], uint32(4+len(result)))
+	copy(out[8:], "WEBP")
+	return append(out, result...), nil
+}
+
+func writeWebPChunk(out *bytes.Buffer, fourCC string, data []byte) {
+	var header [8]byte
+	copy(header[:4], fourCC)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(data)))
+	out.Write(header[:])
+	out.Write(data)
+	if len(data)%2 == 1 {
+		out.WriteByte(0)
+	}
+}
+
+// PDF
+
+var pdfInfoKey = regexp.MustCompile(`/(Author|Creator|Producer|Title|Subject|Keywords|CreationDate|ModDate)\s*[(<]`)
+
+var pdfXMPPacket = regexp.MustCompile(`(?s)<\?xpacket begin=.*?<\?xpacket end=[^>]*\?>`)
+
+// scrubPDF blanks document information values and uncompressed XMP packets in
+// place. Lengths never change so the cross-reference table stays valid.
+func scrubPDF(data []byte, report *ScrubReport) ([]byte, error) {
+	out := make([]byte, len(data))
+	copy(out, data)
+
+	found := make(map[string]bool)
+	for _, match := range pdfInfoKey.FindAllSubmatchIndex(out, -1) {
+		key := string(out[match[2]:match[3]])
+		start := match[1] // just past the opening delimiter
+		if out[start-1] == '(' {
+			end := literalStringEnd(out, start)
+			for i := start; i < end; i++ {
+				out[i] = ' '
+			}
+		} else {
+			for i := start; i < len(out) && out[i] != '>'; i++ {
+				if !isPDFWhitespace(out[i]) {
+					out[i] = '0'
+				}
+			}
+		}
+		found[key] = true
+	}
+	for _, key := range []string{"Title", "Author", "Subject", "Keywords", "Creator", "Producer", "CreationDate", "ModDate"} {
+		if found[key] {
+			report.removed("document information %s", key)
+		}
+	}
+
+	packets := pdfXMPPacket.FindAllIndex(out, -1)
+	for _, packet := range packets {
+		for i := packet[0]; i < packet[1]; i++ {
+			if out[i] != '\n' && out[i] != '\r' {
+				out[i] = ' '
+			}
+		}
+	}
+	if len(packets) > 0 {
+		report.removed("XMP metadata")
+	} else if bytes.Contains(out, []byte("/Metadata")) {
+		report.warn("compressed XMP metadata stream could not be removed")
+	}
+
+	if bytes.Contains(out, []byte("/ObjStm")) {
+		report.warn("compressed object streams may still hold metadata")
+	}
+	if n := bytes.Count(out, []byte("%%EOF")); n > 1 {
+		report.warn("document has %d revisions, earlier revisions may contain removed content", n)
+	}
+
+	return out, nil
+}
+
+// literalStringEnd finds the closing parenthesis of a PDF literal string, honouring escapes and nesting
+func literalStringEnd(data []byte, start int) int {
+	depth := 1
+	for i := start; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(data)
+}
+
+func isPDFWhitespace(b byte) bool {
+	return b == ' ' || b == '\n' || b == '\r' || b == '\t' || b == '\f' || b == 0
+}
+
+// Office documents
+
+// Property parts replaced with empty documents of the same kind
+var officePropertyParts = map[string]string{
+	"docProps/core.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" xmlns:dcmitype="http://purl.org/dc/dcmitype/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"></cp:coreProperties>`,
+	"docProps/app.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/extended-properties" xmlns:vt="http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes"></Properties>`,
+	"docProps/custom.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/custom-properties" xmlns:vt="http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes"></Properties>`,
+	"meta.xml": `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<office:document-meta xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" office:version="1.2"><office:meta/></office:document-meta>`,
+}
+
+var officePropertyNames = map[string]string{
+	"docProps/core.xml":   "core properties (author, dates, revision)",
+	"docProps/app.xml":    "application properties (company, editing time)",
+	"docProps/custom.xml": "custom properties",
+	"meta.xml":            "document metadata (author, dates, editing time)",
+}
+
+// scrubOfficeDocument rewrites an OOXML or OpenDocument package, replacing its
+// property parts and clearing entry timestamps. Other zip files are left as they are.
+func scrubOfficeDocument(data []byte, report *ScrubReport) ([]byte, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	isOffice := false
+	for _, f := range archive.File {
+		if f.Name == "[Content_Types].xml" || f.Name == "mimetype" {
+			isOffice = true
+		}
+	}
+	if !isOffice {
+		report.Format = "ZIP"
+		report.warn("archive is not an Office document, metadata was not removed")
+		return data, nil
+	}
+
+	var out bytes.Buffer
+	writer := zip.NewWriter(&out)
+	timestamps := false
+
+	for _, f := range archive.File {
+		if f.ModifiedDate != 0 || f.ModifiedTime != 0 || len(f.Extra) > 0 {
+			timestamps = true
+		}
+
+		header := &zip.FileHeader{Name: f.Name, Method: f.Method}
+		if replacement, ok := officePropertyParts[f.Name]; ok {
+			w, err := writer.CreateHeader(header)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := io.WriteString(w, replacement); err != nil {
+				return nil, err
+			}
+			report.removed("%s", officePropertyNames[f.Name])
+			continue
+		}
+
+		if strings.Contains(f.Name, "comments") || f.Name == "word/people.xml" {
+			report.warn("comment and revision author names in %s are not removed", f.Name)
+		}
+
+		// Copy the compressed data as is, with a header stripped of times and extra fields
+		header.CRC32 = f.CRC32
+		header.CompressedSize64 = f.CompressedSize64
+		header.UncompressedSize64 = f.UncompressedSize64
+		raw, err := f.OpenRaw()
+		if err != nil {
+			return nil, err
+		}
+		w, err := writer.CreateRaw(header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(w, raw); err != nil {
+			return nil, err
+		}
+	}
+
+	if timestamps {
+		report.removed("package entry timestamps")
+	}
+	if archive.Comment != "" {
+		report.removed("archive comment")
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// WriteExportData copies a decrypted file to w, scrubbing its metadata first
+// when options are given. Formats the scrubber does not know are streamed
+// unchanged and reported as such.
+func WriteExportData(w io.Writer, reader io.ReadSeeker, options *ScrubOptions) (*ScrubReport, error) {
+	if options == nil {
+		_, err := io.Copy(w, reader)
+		return nil, err
+	}
+
+	head := make([]byte, 16)
+	n, err := io.ReadFull(reader, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	if ScrubFormat(head[:n]) == "" {
+		report := &ScrubReport{Format: "unknown"}
+		report.warn("format not supported, metadata was not removed")
+		_, err := io.Copy(w, reader)
+		return report, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(reader, MaxScrubSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxScrubSize {
+		return nil, fmt.Errorf("file is too large to remove metadata from")
+	}
+
+	scrubbed, report, err := ScrubMetadata(data, *options)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(scrubbed)
+	return report, err
+}
@@ -0,0 +1,258 @@
+package filestoreutils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testImage() image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 16), uint8(y * 32), 128, 255})
+		}
+	}
+	return img
+}
+
+// testEXIF builds an EXIF block with an orientation, a GPS pointer and a camera model
+func testEXIF(orientation int) []byte {
+	tiff := []byte("II\x2a\x00\x08\x00\x00\x00")
+	tiff = append(tiff, 3, 0)
+	entry := func(tag, typ uint16, value uint32) {
+		var e [12]byte
+		binary.LittleEndian.PutUint16(e[0:], tag)
+		binary.LittleEndian.PutUint16(e[2:], typ)
+		binary.LittleEndian.PutUint32(e[4:], 1)
+		binary.LittleEndian.PutUint32(e[8:], value)
+		tiff = append(tiff, e[:]...)
+	}
+	entry(0x0110, 2, 0x41424344) // Model, inline "ABCD"
+	entry(0x0112, 3, uint32(orientation))
+	entry(0x8825, 4, 0) // GPS IFD pointer
+	return append(tiff, 0, 0, 0, 0)
+}
+
+func jpegSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+func TestScrubJPEG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	var input []byte
+	input = append(input, encoded[:2]...)
+	input = append(input, jpegSegment(0xE1, append([]byte("Exif\x00\x00"), testEXIF(6)...))...)
+	input = append(input, jpegSegment(0xE2, []byte("ICC_PROFILE\x00\x01\x01profile"))...)
+	input = append(input, jpegSegment(0xFE, []byte("taken at the safe house"))...)
+	input = append(input, encoded[2:]...)
+	input = append(input, "trailing vendor data"...)
+
+	tests := []struct {
+		name            string
+		options         ScrubOptions
+		wantOrientation bool
+		wantICC         bool
+	}{
+		{"strip everything", ScrubOptions{}, false, false},
+		{"keep orientation and profile", ScrubOptions{KeepOrientation: true, KeepColorProfile: true}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, report, err := ScrubMetadata(input, tt.options)
+			if err != nil {
+				t.Fatalf("ScrubMetadata failed: %v", err)
+			}
+			if _, err := jpeg.Decode(bytes.NewReader(output)); err != nil {
+				t.Fatalf("Scrubbed JPEG does not decode: %v", err)
+			}
+
+			for _, secret := range []string{"ABCD", "safe house", "trailing vendor"} {
+				if bytes.Contains(output, []byte(secret)) {
+					t.Errorf("Scrubbed JPEG still contains %q", secret)
+				}
+			}
+			if !strings.Contains(strings.Join(report.Removed, ";"), "GPS location") {
+				t.Errorf("Report does not mention GPS location: %v", report.Removed)
+			}
+
+			orientation := 0
+			if i := bytes.Index(output, []byte("Exif\x00\x00")); i >= 0 {
+				orientation, _ = summariseEXIF(output[i+6:])
+			}
+			if tt.wantOrientation != (orientation == 6) {
+				t.Errorf("Got orientation %d, want kept=%v", orientation, tt.wantOrientation)
+			}
+			if tt.wantICC != bytes.Contains(output, []byte("ICC_PROFILE")) {
+				t.Errorf("ICC profile kept=%v, want %v", !tt.wantICC, tt.wantICC)
+			}
+		})
+	}
+}
+
+func TestScrubPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	// Insert metadata chunks after IHDR (8 byte signature + 25 byte chunk)
+	var chunks bytes.Buffer
+	writePNGChunk(&chunks, "tEXt", []byte("Author\x00Jane Source"))
+	writePNGChunk(&chunks, "tIME", []byte{0x07, 0xE8, 1, 2, 3, 4, 5})
+	writePNGChunk(&chunks, "eXIf", testEXIF(3))
+	input := append(append(append([]byte{}, encoded[:33]...), chunks.Bytes()...), encoded[33:]...)
+
+	output, report, err := ScrubMetadata(input, ScrubOptions{KeepOrientation: true})
+	if err != nil {
+		t.Fatalf("ScrubMetadata failed: %v", err)
+	}
+	if _, err := png.Decode(bytes.NewReader(output)); err != nil {
+		t.Fatalf("Scrubbed PNG does not decode: %v", err)
+	}
+	if bytes.Contains(output, []byte("Jane")) || bytes.Contains(output, []byte("tIME")) {
+		t.Error("Scrubbed PNG still contains text or time chunks")
+	}
+	if len(report.Removed) != 3 || len(report.Kept) != 1 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
+
+func TestScrubWebP(t *testing.T) {
+	var chunks bytes.Buffer
+	writeWebPChunk(&chunks, "VP8X", []byte{webpFlagICC | webpFlagEXIF | webpFlagXMP, 0, 0, 0, 15, 0, 0, 7, 0, 0})
+	writeWebPChunk(&chunks, "ICCP", []byte("profile"))
+	writeWebPChunk(&chunks, "VP8L", []byte("bitstream"))
+	writeWebPChunk(&chunks, "EXIF", testEXIF(1))
+	writeWebPChunk(&chunks, "XMP ", []byte("<x:xmpmeta>Jane</x:xmpmeta>"))
+
+	input := []byte("RIFF\x00\x00\x00\x00WEBP")
+	binary.LittleEndian.PutUint32(input[4:], uint32(4+chunks.Len()))
+	input = append(input, chunks.Bytes()...)
+
+	output, _, err := ScrubMetadata(input, ScrubOptions{KeepColorProfile: true})
+	if err != nil {
+		t.Fatalf("ScrubMetadata failed: %v", err)
+	}
+
+	if got := int(binary.LittleEndian.Uint32(output[4:])) + 8; got != len(output) {
+		t.Errorf("RIFF size %d does not match length %d", got, len(output))
+	}
+	if flags := output[20]; flags != webpFlagICC {
+		t.Errorf("Got VP8X flags %#x, want %#x", flags, webpFlagICC)
+	}
+	if bytes.Contains(output, []byte("Jane")) || bytes.Contains(output, []byte("EXIF")) {
+		t.Error("Scrubbed WebP still contains EXIF or XMP")
+	}
+	if !bytes.Contains(output, []byte("bitstream")) || !bytes.Contains(output, []byte("profile")) {
+		t.Error("Scrubbed WebP lost image data or colour profile")
+	}
+}
+
+func TestScrubPDF(t *testing.T) {
+	input := []byte("%PDF-1.4\n1 0 obj\n<< /Author (Jane \\(J\\) Source) /Producer <4A616E65> /Title(Report) >>\nendobj\n" +
+		"2 0 obj\n<< /Type /Metadata /Length 60 >>\nstream\n<?xpacket begin=\"\"?><dc:creator>Jane</dc:creator><?xpacket end=\"w\"?>\nendstream\nendobj\n%%EOF\n")
+
+	output, report, err := ScrubMetadata(input, ScrubOptions{})
+	if err != nil {
+		t.Fatalf("ScrubMetadata failed: %v", err)
+	}
+	if len(output) != len(input) {
+		t.Fatalf("PDF length changed from %d to %d", len(input), len(output))
+	}
+	if bytes.Contains(output, []byte("Jane")) || bytes.Contains(output, []byte("4A61")) || bytes.Contains(output, []byte("Report")) {
+		t.Errorf("Scrubbed PDF still contains metadata: %s", output)
+	}
+	if !bytes.Contains(output, []byte("/Author (")) || !bytes.Contains(output, []byte("endstream")) {
+		t.Error("Scrubbed PDF lost its structure")
+	}
+	if len(report.Removed) != 4 {
+		t.Errorf("Got removed %v, want 3 information entries and XMP", report.Removed)
+	}
+}
+
+func TestScrubOfficeDocument(t *testing.T) {
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", "<Types/>"},
+		{"docProps/core.xml", "<cp:coreProperties><dc:creator>Jane Source</dc:creator></cp:coreProperties>"},
+		{"word/document.xml", "<w:document>Body text</w:document>"},
+	}
+	for _, part := range parts {
+		w, err := writer.CreateHeader(&zip.FileHeader{Name: part.name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, part.content)
+	}
+	writer.Close()
+
+	output, report, err := ScrubMetadata(buf.Bytes(), ScrubOptions{})
+	if err != nil {
+		t.Fatalf("ScrubMetadata failed: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(output), int64(len(output)))
+	if err != nil {
+		t.Fatalf("Scrubbed document is not a valid ZIP: %v", err)
+	}
+	if len(archive.File) != len(parts) {
+		t.Fatalf("Got %d parts, want %d", len(archive.File), len(parts))
+	}
+	for i, f := range archive.File {
+		if f.Name != parts[i].name {
+			t.Errorf("Part %d is %s, want %s", i, f.Name, parts[i].name)
+		}
+		if f.Modified.Year() > 1980 {
+			t.Errorf("Part %s keeps timestamp %v", f.Name, f.Modified)
+		}
+		r, _ := f.Open()
+		content, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", f.Name, err)
+		}
+		if strings.Contains(string(content), "Jane") {
+			t.Errorf("Part %s still contains the author", f.Name)
+		}
+		if f.Name == "word/document.xml" && string(content) != parts[i].content {
+			t.Errorf("Document body changed: %s", content)
+		}
+	}
+	if len(report.Removed) != 2 {
+		t.Errorf("Got removed %v, want core properties and timestamps", report.Removed)
+	}
+}
+
+func TestWriteExportDataUnsupported(t *testing.T) {
+	input := []byte("plain text that is not scrubbed")
+	var out bytes.Buffer
+
+	report, err := WriteExportData(&out, bytes.NewReader(input), &ScrubOptions{})
+	if err != nil {
+		t.Fatalf("WriteExportData failed: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), input) {
+		t.Error("Unsupported file was changed")
+	}
+	if len(report.Warnings) != 1 {
+		t.Errorf("Expected a warning for an unsupported format, got %+v", report)
+	}
+}
@@ -0,0 +1,350 @@
+package filestoreutils
+
+import (
+	"Tella-Desktop/backend/utils/authutils"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Cipher versions recorded in files.cipher_version
+const (
+	// CipherVersionLegacy files are sealed as one AES-GCM message and must be decrypted whole
+	CipherVersionLegacy = 1
+	// CipherVersionChunked files are sealed as a sequence of independently authenticated chunks
+	CipherVersionChunked = 2
+)
+
+const (
+	// ChunkSize is the amount of plaintext sealed in each chunk
+	ChunkSize      = 64 * 1024
+	chunkNonceSize = 12
+	chunkTagSize   = 16
+)
+
+var (
+	ErrCorruptedChunk = errors.New("corrupted or tampered file chunk")
+	ErrInvalidLength  = errors.New("invalid encrypted file length")
+)
+
+// ChunkedLength returns the number of bytes a plaintext of the given size occupies in the TVault.
+//
+// Layout: a random base nonce followed by the chunks. Every chunk except the
+// last holds exactly ChunkSize bytes of plaintext; the last one holds fewer
+// (possibly zero) and is marked as final in its associated data so that
+// truncation is detected.
+func ChunkedLength(size int64) int64 {
+	chunks := size/ChunkSize + 1
+	return chunkNonceSize + size + chunks*chunkTagSize
+}
+
+// chunkedPlaintextSize is the inverse of ChunkedLength
+func chunkedPlaintextSize(length int64) (int64, error) {
+	body := length - chunkNonceSize
+	if body < chunkTagSize {
+		return 0, ErrInvalidLength
+	}
+	fullChunks := body / (ChunkSize + chunkTagSize)
+	rest := body % (ChunkSize + chunkTagSize)
+	if rest < chunkTagSize {
+		return 0, ErrInvalidLength
+	}
+	return fullChunks*ChunkSize + rest - chunkTagSize, nil
+}
+
+func newChunkAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce derives the nonce of a chunk by mixing its index into the base nonce
+func chunkNonce(dst, base []byte, index uint64) []byte {
+	dst = append(dst[:0], base...)
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], index)
+	for i := range counter {
+		dst[chunkNonceSize-8+i] ^= counter[i]
+	}
+	return dst
+}
+
+func chunkAAD(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// ChunkWriter encrypts a stream of plaintext into the TVault starting at a fixed offset
+type ChunkWriter struct {
+	dst     io.WriterAt
+	start   int64
+	next    int64
+	aead    cipher.AEAD
+	base    []byte
+	nonce   []byte
+	buf     []byte
+	sealed  []byte
+	index   uint64
+	written int64
+	closed  bool
+}
+
+// NewChunkWriter starts a chunked file at offset. Close must be called to seal the final chunk.
+func NewChunkWriter(dst io.WriterAt, offset int64, key []byte) (*ChunkWriter, error) {
+	aead, err := newChunkAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	base := make([]byte, chunkNonceSize)
+	if _, err := rand.Read(base); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	if _, err := dst.WriteAt(base, offset); err != nil {
+		return nil, err
+	}
+
+	return &ChunkWriter{
+		dst:    dst,
+		start:  offset,
+		next:   offset + chunkNonceSize,
+		aead:   aead,
+		base:   base,
+		nonce:  make([]byte, chunkNonceSize),
+		buf:    make([]byte, 0, ChunkSize),
+		sealed: make([]byte, 0, ChunkSize+chunkTagSize),
+	}, nil
+}
+
+func (w *ChunkWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed chunk writer")
+	}
+
+	n := 0
+	for len(p) > 0 {
+		space := ChunkSize - len(w.buf)
+		take := len(p)
+		if take > space {
+			take = space
+		}
+		w.buf = append(w.buf, p[:take]...)
+		p = p[take:]
+		n += take
+
+		if len(w.buf) == ChunkSize {
+			if err := w.seal(false); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+func (w *ChunkWriter) seal(final bool) error {
+	nonce := chunkNonce(w.nonce, w.base, w.index)
+	w.sealed = w.aead.Seal(w.sealed[:0], nonce, w.buf, chunkAAD(final))
+	if _, err := w.dst.WriteAt(w.sealed, w.next); err != nil {
+		return err
+	}
+	w.next += int64(len(w.sealed))
+	w.written += int64(len(w.buf))
+	w.index++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Close seals the final chunk. It does not close the underlying writer.
+func (w *ChunkWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.seal(true)
+}
+
+// Size returns the number of plaintext bytes sealed so far
+func (w *ChunkWriter) Size() int64 {
+	return w.written
+}
+
+// Length returns the number of bytes written to the TVault so far
+func (w *ChunkWriter) Length() int64 {
+	return w.next - w.start
+}
+
+// ChunkReader decrypts a chunked file on demand. Only one chunk is held in memory at a time.
+type ChunkReader struct {
+	src    io.ReaderAt
+	offset int64
+	size   int64
+	chunks int64
+	aead   cipher.AEAD
+	base   []byte
+	nonce  []byte
+	enc    []byte
+	chunk  []byte
+	loaded int64
+	pos    int64
+}
+
+// NewChunkReader opens the chunked file stored at offset with the given encrypted length
+func NewChunkReader(src io.ReaderAt, offset, length int64, key []byte) (*ChunkReader, error) {
+	size, err := chunkedPlaintextSize(length)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newChunkAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	base := make([]byte, chunkNonceSize)
+	if _, err := src.ReadAt(base, offset); err != nil {
+		return nil, fmt.Errorf("failed to read nonce: %w", err)
+	}
+
+	return &ChunkReader{
+		src:    src,
+		offset: offset,
+		size:   size,
+		chunks: size/ChunkSize + 1,
+		aead:   aead,
+		base:   base,
+		nonce:  make([]byte, chunkNonceSize),
+		enc:    make([]byte, ChunkSize+chunkTagSize),
+		chunk:  make([]byte, 0, ChunkSize),
+		loaded: -1,
+	}, nil
+}
+
+// Size returns the plaintext size of the file
+func (r *ChunkReader) Size() int64 {
+	return r.size
+}
+
+func (r *ChunkReader) load(index int64) error {
+	if index == r.loaded {
+		return nil
+	}
+
+	plainLen := int64(ChunkSize)
+	if index == r.chunks-1 {
+		plainLen = r.size - index*ChunkSize
+	}
+
+	enc := r.enc[:plainLen+chunkTagSize]
+	at := r.offset + chunkNonceSize + index*(ChunkSize+chunkTagSize)
+	if _, err := r.src.ReadAt(enc, at); err != nil {
+		return fmt.Errorf("failed to read chunk %d: %w", index, err)
+	}
+
+	nonce := chunkNonce(r.nonce, r.base, uint64(index))
+	chunk, err := r.aead.Open(r.chunk[:0], nonce, enc, chunkAAD(index == r.chunks-1))
+	if err != nil {
+		r.loaded = -1
+		return ErrCorruptedChunk
+	}
+
+	r.chunk = chunk
+	r.loaded = index
+	return nil
+}
+
+func (r *ChunkReader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		// The final chunk is still authenticated so an empty or truncated tail is detected
+		if err := r.load(r.chunks - 1); err != nil {
+			return 0, err
+		}
+		return 0, io.EOF
+	}
+
+	n := 0
+	for len(p) > 0 && r.pos < r.size {
+		index := r.pos / ChunkSize
+		if err := r.load(index); err != nil {
+			return n, err
+		}
+		copied := copy(p, r.chunk[r.pos-index*ChunkSize:])
+		p = p[copied:]
+		r.pos += int64(copied)
+		n += copied
+	}
+
+	return n, nil
+}
+
+func (r *ChunkReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.pos + offset
+	case io.SeekEnd:
+		abs = r.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.pos = abs
+	return abs, nil
+}
+
+// NewFileReader returns a seekable plaintext reader for a file stored in the TVault.
+// Legacy files are decrypted whole since a single GCM message cannot be verified piecewise.
+// Compressed files are inflated as they are read.
+func NewFileReader(tvault io.ReaderAt, metadata *FileMetadata, dbKey []byte) (io.ReadSeeker, error) {
+	reader, err := newDecryptReader(tvault, metadata, dbKey)
+	if err != nil {
+		return nil, err
+	}
+
+	switch metadata.Compression {
+	case CompressionNone:
+		return reader, nil
+	case CompressionDeflate:
+		return NewDecompressReader(reader, metadata.Size), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", metadata.Compression)
+	}
+}
+
+func newDecryptReader(tvault io.ReaderAt, metadata *FileMetadata, dbKey []byte) (io.ReadSeeker, error) {
+	keyUUID := metadata.KeyUUID
+	if keyUUID == "" {
+		keyUUID = metadata.UUID
+	}
+	fileKey := GenerateFileKey(keyUUID, dbKey)
+
+	switch metadata.CipherVersion {
+	case CipherVersionChunked:
+		return NewChunkReader(tvault, metadata.Offset, metadata.Length, fileKey)
+	case CipherVersionLegacy:
+		encryptedData := make([]byte, metadata.Length)
+		if _, err := tvault.ReadAt(encryptedData, metadata.Offset); err != nil {
+			return nil, fmt.Errorf("failed to read file from TVault: %w", err)
+		}
+		decryptedData, err := authutils.DecryptData(encryptedData, fileKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt file: %w", err)
+		}
+		return bytes.NewReader(decryptedData), nil
+	default:
+		return nil, fmt.Errorf("unsupported cipher version: %d", metadata.CipherVersion)
+	}
+}
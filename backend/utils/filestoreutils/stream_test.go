@@ -0,0 +1,219 @@
+package filestoreutils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Tella-Desktop/backend/utils/authutils"
+	"Tella-Desktop/backend/utils/constants"
+)
+
+// writeChunkedForTest encrypts data into a fresh temp file at the given offset
+func writeChunkedForTest(t *testing.T, data, key []byte, offset int64) *os.File {
+	t.Helper()
+
+	file, err := os.Create(filepath.Join(t.TempDir(), ".tvault"))
+	if err != nil {
+		t.Fatalf("Failed to create vault file: %v", err)
+	}
+	t.Cleanup(func() { file.Close() })
+
+	writer, err := NewChunkWriter(file, offset, key)
+	if err != nil {
+		t.Fatalf("Failed to create chunk writer: %v", err)
+	}
+	if _, err := writer.Write(data); err != nil {
+		t.Fatalf("Failed to write data: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close writer: %v", err)
+	}
+
+	if writer.Size() != int64(len(data)) {
+		t.Errorf("Size() = %d, want %d", writer.Size(), len(data))
+	}
+	if writer.Length() != ChunkedLength(int64(len(data))) {
+		t.Errorf("Length() = %d, want %d", writer.Length(), ChunkedLength(int64(len(data))))
+	}
+
+	return file
+}
+
+func randomBytesForTest(t *testing.T, n int) []byte {
+	t.Helper()
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("Failed to generate random data: %v", err)
+	}
+	return data
+}
+
+func TestChunkedRoundTrip(t *testing.T) {
+	key := randomBytesForTest(t, constants.KeyLength)
+	testSizes := []int{0, 1, ChunkSize - 1, ChunkSize, ChunkSize + 1, 3*ChunkSize + 17}
+
+	for _, size := range testSizes {
+		t.Run(fmt.Sprintf("Size-%d", size), func(t *testing.T) {
+			data := randomBytesForTest(t, size)
+			offset := int64(constants.TVaultHeaderSize)
+			file := writeChunkedForTest(t, data, key, offset)
+
+			reader, err := NewChunkReader(file, offset, ChunkedLength(int64(size)), key)
+			if err != nil {
+				t.Fatalf("Failed to create chunk reader: %v", err)
+			}
+			if reader.Size() != int64(size) {
+				t.Errorf("Size() = %d, want %d", reader.Size(), size)
+			}
+
+			decrypted, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("Failed to read data: %v", err)
+			}
+			if !bytes.Equal(decrypted, data) {
+				t.Errorf("Decrypted data of size %d does not match original", size)
+			}
+		})
+	}
+}
+
+func TestChunkedSeek(t *testing.T) {
+	key := randomBytesForTest(t, constants.KeyLength)
+	data := randomBytesForTest(t, 5*ChunkSize+123)
+	file := writeChunkedForTest(t, data, key, 0)
+
+	reader, err := NewChunkReader(file, 0, ChunkedLength(int64(len(data))), key)
+	if err != nil {
+		t.Fatalf("Failed to create chunk reader: %v", err)
+	}
+
+	positions := []int64{0, 1, ChunkSize - 3, ChunkSize, 2*ChunkSize + 7, int64(len(data)) - 10, 42}
+	for _, pos := range positions {
+		if _, err := reader.Seek(pos, io.SeekStart); err != nil {
+			t.Fatalf("Seek(%d) failed: %v", pos, err)
+		}
+
+		buf := make([]byte, 100)
+		n, err := io.ReadFull(reader, buf)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			t.Fatalf("Read at %d failed: %v", pos, err)
+		}
+		if !bytes.Equal(buf[:n], data[pos:pos+int64(n)]) {
+			t.Errorf("Data read at offset %d does not match original", pos)
+		}
+	}
+
+	end, err := reader.Seek(-5, io.SeekEnd)
+	if err != nil || end != int64(len(data))-5 {
+		t.Fatalf("Seek from end = %d, %v", end, err)
+	}
+	tail, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to read tail: %v", err)
+	}
+	if !bytes.Equal(tail, data[len(data)-5:]) {
+		t.Errorf("Tail does not match original")
+	}
+}
+
+func TestChunkedTamperDetection(t *testing.T) {
+	key := randomBytesForTest(t, constants.KeyLength)
+	data := randomBytesForTest(t, 2*ChunkSize+50)
+	file := writeChunkedForTest(t, data, key, 0)
+	length := ChunkedLength(int64(len(data)))
+
+	// Flip a byte inside the second chunk
+	corruptAt := int64(chunkNonceSize + ChunkSize + chunkTagSize + 10)
+	b := make([]byte, 1)
+	file.ReadAt(b, corruptAt)
+	b[0] ^= 0xFF
+	file.WriteAt(b, corruptAt)
+
+	reader, err := NewChunkReader(file, 0, length, key)
+	if err != nil {
+		t.Fatalf("Failed to create chunk reader: %v", err)
+	}
+
+	// The first chunk is still readable
+	first := make([]byte, ChunkSize)
+	if _, err := io.ReadFull(reader, first); err != nil {
+		t.Fatalf("Failed to read intact chunk: %v", err)
+	}
+
+	if _, err := io.ReadAll(reader); err != ErrCorruptedChunk {
+		t.Errorf("Expected ErrCorruptedChunk, got %v", err)
+	}
+}
+
+func TestChunkedTruncationDetection(t *testing.T) {
+	key := randomBytesForTest(t, constants.KeyLength)
+	data := randomBytesForTest(t, 3*ChunkSize)
+	file := writeChunkedForTest(t, data, key, 0)
+	length := ChunkedLength(int64(len(data)))
+
+	// Dropping whole chunks leaves a length no valid file can have
+	if _, err := NewChunkReader(file, 0, length-chunkTagSize, key); err != ErrInvalidLength {
+		t.Errorf("Expected ErrInvalidLength, got %v", err)
+	}
+
+	// Cutting into a chunk makes a non-final chunk look like the final one
+	reader, err := NewChunkReader(file, 0, length-chunkTagSize-100, key)
+	if err != nil {
+		t.Fatalf("Failed to create chunk reader: %v", err)
+	}
+	if _, err := io.ReadAll(reader); err != ErrCorruptedChunk {
+		t.Errorf("Expected ErrCorruptedChunk for truncated file, got %v", err)
+	}
+}
+
+func TestChunkedWrongKey(t *testing.T) {
+	key := randomBytesForTest(t, constants.KeyLength)
+	wrongKey := randomBytesForTest(t, constants.KeyLength)
+	data := randomBytesForTest(t, 1000)
+	file := writeChunkedForTest(t, data, key, 0)
+
+	reader, err := NewChunkReader(file, 0, ChunkedLength(int64(len(data))), wrongKey)
+	if err != nil {
+		t.Fatalf("Failed to create chunk reader: %v", err)
+	}
+	if _, err := io.ReadAll(reader); err != ErrCorruptedChunk {
+		t.Errorf("Expected ErrCorruptedChunk with wrong key, got %v", err)
+	}
+}
+
+func TestNewFileReaderLegacy(t *testing.T) {
+	dbKey := randomBytesForTest(t, constants.KeyLength)
+	data := randomBytesForTest(t, 3000)
+	metadata := &FileMetadata{UUID: "legacy-file", CipherVersion: CipherVersionLegacy, Offset: 16}
+
+	encrypted, err := authutils.EncryptData(data, GenerateFileKey(metadata.UUID, dbKey))
+	if err != nil {
+		t.Fatalf("Failed to encrypt data: %v", err)
+	}
+	metadata.Length = int64(len(encrypted))
+
+	file, err := os.Create(filepath.Join(t.TempDir(), ".tvault"))
+	if err != nil {
+		t.Fatalf("Failed to create vault file: %v", err)
+	}
+	defer file.Close()
+	file.WriteAt(encrypted, metadata.Offset)
+
+	reader, err := NewFileReader(file, metadata, dbKey)
+	if err != nil {
+		t.Fatalf("Failed to open legacy file: %v", err)
+	}
+	reader.Seek(100, io.SeekStart)
+	rest, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to read legacy file: %v", err)
+	}
+	if !bytes.Equal(rest, data[100:]) {
+		t.Errorf("Legacy file data does not match original")
+	}
+}
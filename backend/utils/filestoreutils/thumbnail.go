@@ -0,0 +1,193 @@
+package filestoreutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	_ "image/gif"
+)
+
+// MaxThumbnailSourcePixels bounds the images decoded for thumbnails, so a small
+// file declaring huge dimensions cannot exhaust memory
+const MaxThumbnailSourcePixels = 50 * 1000 * 1000
+
+const thumbnailJPEGQuality = 80
+
+// orientationHeadSize bounds how much of a JPEG is searched for its EXIF
+// block, which has to fit in one 64 KiB segment near the start
+const orientationHeadSize = 256 * 1024
+
+// ThumbnailMimeTypes are the formats the standard library can decode
+var ThumbnailMimeTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/jpg":  true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// DecodeImage decodes an image after checking its declared dimensions
+func DecodeImage(reader io.ReadSeeker) (image.Image, error) {
+	config, _, err := image.DecodeConfig(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image header: %w", err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > MaxThumbnailSourcePixels {
+		return nil, fmt.Errorf("image dimensions %dx%d are not supported", config.Width, config.Height)
+	}
+
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind image: %w", err)
+	}
+
+	img, _, err := image.Decode(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, nil
+}
+
+// ImageOrientation returns the EXIF orientation of a JPEG, from 1 to 8, or 1
+// when it has none. Cameras store portrait photos sideways and set this tag.
+func ImageOrientation(reader io.ReadSeeker) int {
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return 1
+	}
+	head, err := io.ReadAll(io.LimitReader(reader, orientationHeadSize))
+	if err != nil || len(head) < 4 || head[0] != 0xFF || head[1] != 0xD8 {
+		return 1
+	}
+
+	pos := 2
+	for pos+4 <= len(head) && head[pos] == 0xFF {
+		marker := head[pos+1]
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		end := pos + 2 + int(binary.BigEndian.Uint16(head[pos+2:]))
+		if end > len(head) || end < pos+4 {
+			break
+		}
+		payload := head[pos+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+			if orientation, _ := summariseEXIF(payload[6:]); orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+			return 1
+		}
+		pos = end
+	}
+	return 1
+}
+
+// OrientImage turns an image as its EXIF orientation says it should be shown.
+// Orientations 5 to 8 swap width and height.
+func OrientImage(img *image.NRGBA, orientation int) *image.NRGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirror horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotate 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirror vertically
+				dx, dy = x, h-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // rotate 90° clockwise
+				dx, dy = h-1-y, x
+			case 7: // transverse
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotate 90° counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			src := img.PixOffset(img.Bounds().Min.X+x, img.Bounds().Min.Y+y)
+			copy(dst.Pix[dst.PixOffset(dx, dy):], img.Pix[src:src+4])
+		}
+	}
+	return dst
+}
+
+// ResizeImage scales an image down so its longest edge is at most maxEdge,
+// averaging every source pixel that falls into each destination pixel.
+// Images that already fit are copied unchanged.
+func ResizeImage(img image.Image, maxEdge int) *image.NRGBA {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	dstW, dstH := srcW, srcH
+	if srcW > maxEdge || srcH > maxEdge {
+		if srcW >= srcH {
+			dstW, dstH = maxEdge, max(1, srcH*maxEdge/srcW)
+		} else {
+			dstW, dstH = max(1, srcW*maxEdge/srcH), maxEdge
+		}
+	}
+
+	// Accumulate premultiplied colour so transparent pixels do not bleed
+	sums := make([][4]uint64, dstW*dstH)
+	counts := make([]uint64, dstW*dstH)
+	for y := 0; y < srcH; y++ {
+		dy := y * dstH / srcH
+		for x := 0; x < srcW; x++ {
+			dx := x * dstW / srcW
+			r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			i := dy*dstW + dx
+			sums[i][0] += uint64(r)
+			sums[i][1] += uint64(g)
+			sums[i][2] += uint64(b)
+			sums[i][3] += uint64(a)
+			counts[i]++
+		}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	for i, sum := range sums {
+		n := counts[i]
+		if n == 0 {
+			continue
+		}
+		premultiplied := color.RGBA64{
+			R: uint16(sum[0] / n),
+			G: uint16(sum[1] / n),
+			B: uint16(sum[2] / n),
+			A: uint16(sum[3] / n),
+		}
+		dst.Set(i%dstW, i/dstW, premultiplied)
+	}
+
+	return dst
+}
+
+// EncodeThumbnail encodes opaque thumbnails as JPEG and ones with transparency as PNG
+func EncodeThumbnail(img *image.NRGBA) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if img.Opaque() {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: thumbnailJPEGQuality}); err != nil {
+			return nil, "", fmt.Errorf("failed to encode thumbnail: %w", err)
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, "", fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), "image/png", nil
+}
@@ -17,6 +17,8 @@ import (
 	"path/filepath"
 	"time"
 
+	"Tella-Desktop/backend/utils/authutils"
+
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
@@ -170,6 +172,10 @@ func writePrivateKeyFile(path string, privateKey *rsa.PrivateKey) error {
 
 // returns the directory where certificates should be stored
 func getCertificateDirectory() string {
+	if root := authutils.PortableRoot(); root != "" {
+		return filepath.Join(root, "certs")
+	}
+
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return filepath.Join(".", "certs")
@@ -4,6 +4,7 @@ import { GetFilesInFolder, ExportFiles, ExportZipFolders, DeleteFiles } from '..
 import { Dialog } from '../Dialog/Dialog';
 import { LoadingDialog } from '../Dialog/LoadingDialog';
 import { SuccessToast } from '../Toast/SuccessToast';
+import { waitForJob } from '../../utils/jobs';
 import {
   Container,
   Header,
@@ -195,7 +196,8 @@ export function FileList({ folderId: propFolderId, folderName: propFolderName }:
     try {
       const fileIds = Array.from(selectedFiles);
       
-      const exportPaths = await ExportZipFolders([folderId], fileIds);
+      const jobId = await ExportZipFolders([folderId], fileIds, { stripMetadata: false, keepOrientation: false, keepColorProfile: false, neutralNames: false, destination: '' });
+      const { paths: exportPaths } = await waitForJob(jobId);
       
       setSuccessMessage(`ZIP file created successfully: ${exportPaths[0]}`);
       
@@ -224,7 +226,8 @@ export function FileList({ folderId: propFolderId, folderName: propFolderName }:
     try {
       const fileIds = Array.from(selectedFiles);
       
-      const exportPaths = await ExportFiles(fileIds);
+      const jobId = await ExportFiles(fileIds, { stripMetadata: false, keepOrientation: false, keepColorProfile: false, neutralNames: false, destination: '' });
+      const { paths: exportPaths } = await waitForJob(jobId);
       
       if (fileIds.length === 1) {
         setSuccessMessage(`File exported successfully to: ${exportPaths[0]}`);
@@ -286,9 +289,9 @@ export function FileList({ folderId: propFolderId, folderName: propFolderName }:
       
       // Show success message
       if (fileIds.length === 1) {
-        setSuccessMessage('File deleted successfully');
+        setSuccessMessage('File moved to trash');
       } else {
-        setSuccessMessage(`${fileIds.length} files deleted successfully`);
+        setSuccessMessage(`${fileIds.length} files moved to trash`);
       }
       
       setSelectedFiles(new Set());
@@ -458,8 +461,8 @@ export function FileList({ folderId: propFolderId, folderName: propFolderName }:
         confirmButtonText="DELETE"
       >
         <p>
-          Deleting {selectedFiles.size === 1 ? 'this file' : `these ${selectedFiles.size} files`} will delete {selectedFiles.size === 1 ? 'it' : 'them'} from 
-          Tella and the system. This action is permanent and cannot be reversed.
+          Deleting {selectedFiles.size === 1 ? 'this file' : `these ${selectedFiles.size} files`} will move {selectedFiles.size === 1 ? 'it' : 'them'} to the 
+          trash. Items in the trash are permanently deleted when it is emptied or after the retention period.
         </p>
       </Dialog>
 
@@ -474,7 +477,7 @@ export function FileList({ folderId: propFolderId, folderName: propFolderName }:
         isOpen={showDeleteLoading}
         onCancel={handleLoadingCancel}
         title="Deleting files"
-        message="Please wait while your files are being permanently deleted. Do not close Tella or the deletion may fail."
+        message="Please wait while your files are being moved to the trash."
       />
 
       <SuccessToast
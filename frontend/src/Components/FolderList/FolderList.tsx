@@ -32,6 +32,7 @@ import {
 import { Dialog } from '../Dialog/Dialog';
 import { LoadingDialog } from '../Dialog/LoadingDialog';
 import { SuccessToast } from '../Toast/SuccessToast';
+import { waitForJob } from '../../utils/jobs';
 
 interface FolderInfo {
   id: number
@@ -75,7 +76,7 @@ export function FolderList() {
     try {
       setLoading(true);
       setError(null);
-      const foldersData = await GetStoredFolders();
+      const foldersData = await GetStoredFolders({ includeEmpty: false, tree: false });
       setFolders(foldersData || []); // Handle null case explicitly
     } catch (err) {
       console.error('Failed to fetch folders:', err);
@@ -145,7 +146,8 @@ export function FolderList() {
       const folderIds = Array.from(selectedFolders);
       
       // Export entire folders as ZIP (empty selectedFileIDs array)
-      const exportPaths = await ExportZipFolders(folderIds, []);
+      const jobId = await ExportZipFolders(folderIds, [], { stripMetadata: false, keepOrientation: false, keepColorProfile: false, neutralNames: false, destination: '' });
+      const { paths: exportPaths } = await waitForJob(jobId);
       
       if (folderIds.length === 1) {
         setSuccessMessage(`Folder exported as ZIP: ${exportPaths[0]}`);
@@ -200,9 +202,9 @@ export function FolderList() {
       
       // Show success message
       if (folderIds.length === 1) {
-        setSuccessMessage('Folder deleted successfully');
+        setSuccessMessage('Folder moved to trash');
       } else {
-        setSuccessMessage(`${folderIds.length} folders deleted successfully`);
+        setSuccessMessage(`${folderIds.length} folders moved to trash`);
       }
       
       setSelectedFolders(new Set());
@@ -347,8 +349,8 @@ export function FolderList() {
         confirmButtonText="DELETE"
       >
         <p>
-          Deleting {selectedFolders.size === 1 ? 'this folder' : `these ${selectedFolders.size} folders`} will permanently delete {selectedFolders.size === 1 ? 'it' : 'them'} and all files inside from 
-          Tella. This action cannot be reversed.
+          Deleting {selectedFolders.size === 1 ? 'this folder' : `these ${selectedFolders.size} folders`} will move {selectedFolders.size === 1 ? 'it' : 'them'} and all files inside to the 
+          trash. Items in the trash are permanently deleted when it is emptied or after the retention period.
         </p>
       </Dialog>
 
@@ -363,7 +365,7 @@ export function FolderList() {
         isOpen={showDeleteLoading}
         onCancel={handleDialogCancel}
         title="Deleting folders"
-        message="Please wait while your folders and files are being permanently deleted. Do not close Tella or the deletion may fail."
+        message="Please wait while your folders and files are being moved to the trash."
       />
 
       <SuccessToast
@@ -0,0 +1,52 @@
+import { GetJob } from '../../wailsjs/go/app/App';
+import { EventsOn } from '../../wailsjs/runtime/runtime';
+
+export interface JobStatus {
+  id: string;
+  kind: string;
+  state: 'running' | 'completed' | 'failed' | 'cancelled';
+  itemsDone: number;
+  itemsTotal: number;
+  bytesDone: number;
+  bytesTotal: number;
+  etaSeconds: number;
+  startedAt: string;
+  finishedAt?: string;
+  errors: { item: string; error: string }[];
+  error?: string;
+  result?: any;
+}
+
+// waitForJob resolves with the job's result once it completes and rejects if it fails or is cancelled.
+// onProgress receives each job-progress event for the job.
+export function waitForJob<T = any>(jobId: string, onProgress?: (status: JobStatus) => void): Promise<T> {
+  return new Promise((resolve, reject) => {
+    let finished = false;
+
+    const settle = (status: JobStatus) => {
+      if (finished) return;
+      onProgress?.(status);
+      if (status.state === 'running') return;
+
+      finished = true;
+      cleanup();
+      if (status.state === 'completed') {
+        resolve(status.result as T);
+      } else {
+        reject(new Error(status.error || `Job ${status.state}`));
+      }
+    };
+
+    const cleanup = EventsOn('job-progress', (status: JobStatus) => {
+      if (status.id === jobId) settle(status);
+    });
+
+    // The job may have finished before the listener was registered
+    GetJob(jobId).then((status) => settle(status as unknown as JobStatus)).catch((error) => {
+      if (finished) return;
+      finished = true;
+      cleanup();
+      reject(error);
+    });
+  });
+}
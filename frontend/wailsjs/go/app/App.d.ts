@@ -1,46 +1,199 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 import {filestore} from '../models';
+import {auth} from '../models';
 import {context} from '../models';
 
 export function AcceptTransfer(arg1:string):Promise<void>;
 
+export function AddFileNote(arg1:number,arg2:string,arg3:string,arg4:string):Promise<filestore.Note>;
+
+export function AddFolderNote(arg1:number,arg2:string,arg3:string,arg4:string):Promise<filestore.Note>;
+
+export function ApplyRetentionRules(arg1:boolean):Promise<Array<filestore.RetentionMatch>>;
+
+export function CancelJob(arg1:string):Promise<void>;
+
 export function ConfirmRegistration():Promise<void>;
 
+export function CopyFiles(arg1:Array<number>,arg2:number):Promise<Array<number>>;
+
+export function CreateFolder(arg1:string,arg2:number):Promise<filestore.FolderInfo>;
+
 export function CreatePassword(arg1:string):Promise<void>;
 
+export function CreateProfile(arg1:string,arg2:string):Promise<string>;
+
+export function CreateRetentionRule(arg1:filestore.RetentionRule):Promise<filestore.RetentionRule>;
+
+export function CreateTag(arg1:string,arg2:string):Promise<filestore.Tag>;
+
 export function DeleteFiles(arg1:Array<number>):Promise<void>;
 
+export function DeleteFilesPermanently(arg1:Array<number>):Promise<void>;
+
 export function DeleteFolders(arg1:Array<number>):Promise<void>;
 
-export function ExportFiles(arg1:Array<number>):Promise<Array<string>>;
+export function DeleteFoldersPermanently(arg1:Array<number>):Promise<string>;
+
+export function DeleteNote(arg1:number):Promise<void>;
+
+export function DeleteRetentionRule(arg1:number):Promise<void>;
+
+export function DeleteTag(arg1:number):Promise<void>;
+
+export function EmptyTrash():Promise<void>;
+
+export function ExportAuditLog(arg1:string,arg2:string):Promise<string>;
+
+export function ExportBag(arg1:Array<number>,arg2:Array<number>,arg3:filestore.ExportOptions,arg4:filestore.BagOptions):Promise<string>;
+
+export function ExportFiles(arg1:Array<number>,arg2:filestore.ExportOptions):Promise<string>;
+
+export function ExportZipFolders(arg1:Array<number>,arg2:Array<number>,arg3:filestore.ExportOptions):Promise<string>;
+
+export function GetAuditLog(arg1:number):Promise<Array<filestore.AuditEntry>>;
 
-export function ExportZipFolders(arg1:Array<number>,arg2:Array<number>):Promise<Array<string>>;
+export function GetCompactionStatus():Promise<filestore.CompactionStatus>;
+
+export function GetCompressionSettings():Promise<filestore.CompressionSettings>;
+
+export function GetExports():Promise<Array<filestore.ExportRecord>>;
+
+export function GetFileNotes(arg1:number):Promise<Array<filestore.Note>>;
 
 export function GetFilesInFolder(arg1:number):Promise<filestore.FilesInFolderResponse>;
 
+export function GetFolderNotes(arg1:number):Promise<Array<filestore.Note>>;
+
+export function GetFolderPath(arg1:number):Promise<Array<filestore.FolderPathEntry>>;
+
+export function GetJob(arg1:string):Promise<filestore.JobStatus>;
+
 export function GetLocalIPs():Promise<Array<string>>;
 
+export function GetNoteHistory(arg1:number):Promise<Array<filestore.NoteRevision>>;
+
+export function GetPreviewURL(arg1:number):Promise<string>;
+
+export function GetRetentionLog():Promise<Array<filestore.RetentionLogEntry>>;
+
 export function GetServerPIN():Promise<string>;
 
-export function GetStoredFolders():Promise<Array<filestore.FolderInfo>>;
+export function GetStorageThresholds():Promise<filestore.StorageThresholds>;
+
+export function GetStoredFolders(arg1:filestore.FolderListOptions):Promise<Array<filestore.FolderInfo>>;
+
+export function GetThumbnail(arg1:number,arg2:number):Promise<filestore.Thumbnail>;
+
+export function GetTrash():Promise<Array<filestore.TrashItem>>;
+
+export function GetTrashRetention():Promise<number>;
+
+export function GetVaultLocation():Promise<auth.VaultLocation>;
+
+export function GetVaultStats():Promise<filestore.VaultStats>;
 
 export function GetWiFiNetworkName():Promise<string>;
 
+export function ImportPaths(arg1:Array<string>,arg2:number,arg3:filestore.ImportOptions):Promise<string>;
+
 export function IsFirstTimeSetup():Promise<boolean>;
 
 export function IsServerRunning():Promise<boolean>;
 
+export function ListChildFolders(arg1:number):Promise<Array<filestore.FolderInfo>>;
+
+export function ListJobs():Promise<Array<filestore.JobStatus>>;
+
+export function ListProfiles():Promise<Array<auth.ProfileInfo>>;
+
+export function ListRetentionRules():Promise<Array<filestore.RetentionRule>>;
+
+export function ListTags():Promise<Array<filestore.Tag>>;
+
 export function LockApp():Promise<void>;
 
+export function MergeTags(arg1:Array<number>,arg2:number):Promise<void>;
+
+export function MoveFiles(arg1:Array<number>,arg2:number):Promise<void>;
+
+export function MoveFolder(arg1:number,arg2:number):Promise<void>;
+
+export function MoveVault(arg1:string):Promise<void>;
+
+export function OpenExternally(arg1:number):Promise<void>;
+
+export function PauseCompaction():Promise<void>;
+
+export function PreviewRetentionRule(arg1:filestore.RetentionRule):Promise<Array<filestore.RetentionMatch>>;
+
+export function QueryFiles(arg1:filestore.FileQuery):Promise<filestore.FileQueryResult>;
+
 export function RejectRegistration():Promise<void>;
 
 export function RejectTransfer(arg1:string):Promise<void>;
 
+export function RenameFile(arg1:number,arg2:string):Promise<void>;
+
+export function RenameFolder(arg1:number,arg2:string):Promise<void>;
+
+export function RenameTag(arg1:number,arg2:string):Promise<void>;
+
+export function RestoreFiles(arg1:Array<number>):Promise<void>;
+
+export function RestoreFolders(arg1:Array<number>):Promise<void>;
+
+export function SearchNotes(arg1:string):Promise<Array<filestore.Note>>;
+
+export function SelectExportDirectory():Promise<string>;
+
+export function SelectImportDirectory():Promise<string>;
+
+export function SelectImportFiles():Promise<Array<string>>;
+
+export function SelectVaultDirectory():Promise<string>;
+
+export function SetCompressionSettings(arg1:filestore.CompressionSettings):Promise<void>;
+
+export function SetStorageThresholds(arg1:filestore.StorageThresholds):Promise<void>;
+
+export function SetTagColor(arg1:number,arg2:string):Promise<void>;
+
+export function SetTrashRetention(arg1:number):Promise<void>;
+
 export function Shutdown(arg1:context.Context):Promise<void>;
 
+export function StartCompaction():Promise<void>;
+
 export function StartServer(arg1:number):Promise<void>;
 
+export function StartThumbnailBackfill():Promise<void>;
+
 export function StopServer():Promise<void>;
 
+export function StopThumbnailBackfill():Promise<void>;
+
+export function SwitchProfile(arg1:string):Promise<void>;
+
+export function TagFiles(arg1:Array<number>,arg2:number):Promise<void>;
+
+export function TagFolders(arg1:Array<number>,arg2:number):Promise<void>;
+
+export function UntagFiles(arg1:Array<number>,arg2:number):Promise<void>;
+
+export function UntagFolders(arg1:Array<number>,arg2:number):Promise<void>;
+
+export function UpdateNote(arg1:number,arg2:string,arg3:string,arg4:string):Promise<filestore.Note>;
+
+export function UpdateRetentionRule(arg1:filestore.RetentionRule):Promise<void>;
+
+export function ValidateBag(arg1:string):Promise<filestore.BagValidation>;
+
+export function VerifyAuditLog():Promise<filestore.AuditVerification>;
+
+export function VerifyFiles(arg1:Array<number>):Promise<Array<filestore.FileVerification>>;
+
 export function VerifyPassword(arg1:string):Promise<void>;
+
+export function WipeExportedCopies(arg1:Array<number>):Promise<Array<filestore.ExportWipe>>;
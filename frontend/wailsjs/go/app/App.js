@@ -6,50 +6,190 @@ export function AcceptTransfer(arg1) {
   return window['go']['app']['App']['AcceptTransfer'](arg1);
 }
 
+export function AddFileNote(arg1, arg2, arg3, arg4) {
+  return window['go']['app']['App']['AddFileNote'](arg1, arg2, arg3, arg4);
+}
+
+export function AddFolderNote(arg1, arg2, arg3, arg4) {
+  return window['go']['app']['App']['AddFolderNote'](arg1, arg2, arg3, arg4);
+}
+
+export function ApplyRetentionRules(arg1) {
+  return window['go']['app']['App']['ApplyRetentionRules'](arg1);
+}
+
+export function CancelJob(arg1) {
+  return window['go']['app']['App']['CancelJob'](arg1);
+}
+
 export function ConfirmRegistration() {
   return window['go']['app']['App']['ConfirmRegistration']();
 }
 
+export function CopyFiles(arg1, arg2) {
+  return window['go']['app']['App']['CopyFiles'](arg1, arg2);
+}
+
+export function CreateFolder(arg1, arg2) {
+  return window['go']['app']['App']['CreateFolder'](arg1, arg2);
+}
+
 export function CreatePassword(arg1) {
   return window['go']['app']['App']['CreatePassword'](arg1);
 }
 
+export function CreateProfile(arg1, arg2) {
+  return window['go']['app']['App']['CreateProfile'](arg1, arg2);
+}
+
+export function CreateRetentionRule(arg1) {
+  return window['go']['app']['App']['CreateRetentionRule'](arg1);
+}
+
+export function CreateTag(arg1, arg2) {
+  return window['go']['app']['App']['CreateTag'](arg1, arg2);
+}
+
 export function DeleteFiles(arg1) {
   return window['go']['app']['App']['DeleteFiles'](arg1);
 }
 
+export function DeleteFilesPermanently(arg1) {
+  return window['go']['app']['App']['DeleteFilesPermanently'](arg1);
+}
+
 export function DeleteFolders(arg1) {
   return window['go']['app']['App']['DeleteFolders'](arg1);
 }
 
-export function ExportFiles(arg1) {
-  return window['go']['app']['App']['ExportFiles'](arg1);
+export function DeleteFoldersPermanently(arg1) {
+  return window['go']['app']['App']['DeleteFoldersPermanently'](arg1);
+}
+
+export function DeleteNote(arg1) {
+  return window['go']['app']['App']['DeleteNote'](arg1);
+}
+
+export function DeleteRetentionRule(arg1) {
+  return window['go']['app']['App']['DeleteRetentionRule'](arg1);
+}
+
+export function DeleteTag(arg1) {
+  return window['go']['app']['App']['DeleteTag'](arg1);
+}
+
+export function EmptyTrash() {
+  return window['go']['app']['App']['EmptyTrash']();
+}
+
+export function ExportAuditLog(arg1, arg2) {
+  return window['go']['app']['App']['ExportAuditLog'](arg1, arg2);
+}
+
+export function ExportBag(arg1, arg2, arg3, arg4) {
+  return window['go']['app']['App']['ExportBag'](arg1, arg2, arg3, arg4);
+}
+
+export function ExportFiles(arg1, arg2) {
+  return window['go']['app']['App']['ExportFiles'](arg1, arg2);
+}
+
+export function ExportZipFolders(arg1, arg2, arg3) {
+  return window['go']['app']['App']['ExportZipFolders'](arg1, arg2, arg3);
+}
+
+export function GetAuditLog(arg1) {
+  return window['go']['app']['App']['GetAuditLog'](arg1);
 }
 
-export function ExportZipFolders(arg1, arg2) {
-  return window['go']['app']['App']['ExportZipFolders'](arg1, arg2);
+export function GetCompactionStatus() {
+  return window['go']['app']['App']['GetCompactionStatus']();
+}
+
+export function GetCompressionSettings() {
+  return window['go']['app']['App']['GetCompressionSettings']();
+}
+
+export function GetExports() {
+  return window['go']['app']['App']['GetExports']();
+}
+
+export function GetFileNotes(arg1) {
+  return window['go']['app']['App']['GetFileNotes'](arg1);
 }
 
 export function GetFilesInFolder(arg1) {
   return window['go']['app']['App']['GetFilesInFolder'](arg1);
 }
 
+export function GetFolderNotes(arg1) {
+  return window['go']['app']['App']['GetFolderNotes'](arg1);
+}
+
+export function GetFolderPath(arg1) {
+  return window['go']['app']['App']['GetFolderPath'](arg1);
+}
+
+export function GetJob(arg1) {
+  return window['go']['app']['App']['GetJob'](arg1);
+}
+
 export function GetLocalIPs() {
   return window['go']['app']['App']['GetLocalIPs']();
 }
 
+export function GetNoteHistory(arg1) {
+  return window['go']['app']['App']['GetNoteHistory'](arg1);
+}
+
+export function GetPreviewURL(arg1) {
+  return window['go']['app']['App']['GetPreviewURL'](arg1);
+}
+
+export function GetRetentionLog() {
+  return window['go']['app']['App']['GetRetentionLog']();
+}
+
 export function GetServerPIN() {
   return window['go']['app']['App']['GetServerPIN']();
 }
 
-export function GetStoredFolders() {
-  return window['go']['app']['App']['GetStoredFolders']();
+export function GetStorageThresholds() {
+  return window['go']['app']['App']['GetStorageThresholds']();
+}
+
+export function GetStoredFolders(arg1) {
+  return window['go']['app']['App']['GetStoredFolders'](arg1);
+}
+
+export function GetThumbnail(arg1, arg2) {
+  return window['go']['app']['App']['GetThumbnail'](arg1, arg2);
+}
+
+export function GetTrash() {
+  return window['go']['app']['App']['GetTrash']();
+}
+
+export function GetTrashRetention() {
+  return window['go']['app']['App']['GetTrashRetention']();
+}
+
+export function GetVaultLocation() {
+  return window['go']['app']['App']['GetVaultLocation']();
+}
+
+export function GetVaultStats() {
+  return window['go']['app']['App']['GetVaultStats']();
 }
 
 export function GetWiFiNetworkName() {
   return window['go']['app']['App']['GetWiFiNetworkName']();
 }
 
+export function ImportPaths(arg1, arg2, arg3) {
+  return window['go']['app']['App']['ImportPaths'](arg1, arg2, arg3);
+}
+
 export function IsFirstTimeSetup() {
   return window['go']['app']['App']['IsFirstTimeSetup']();
 }
@@ -58,10 +198,62 @@ export function IsServerRunning() {
   return window['go']['app']['App']['IsServerRunning']();
 }
 
+export function ListChildFolders(arg1) {
+  return window['go']['app']['App']['ListChildFolders'](arg1);
+}
+
+export function ListJobs() {
+  return window['go']['app']['App']['ListJobs']();
+}
+
+export function ListProfiles() {
+  return window['go']['app']['App']['ListProfiles']();
+}
+
+export function ListRetentionRules() {
+  return window['go']['app']['App']['ListRetentionRules']();
+}
+
+export function ListTags() {
+  return window['go']['app']['App']['ListTags']();
+}
+
 export function LockApp() {
   return window['go']['app']['App']['LockApp']();
 }
 
+export function MergeTags(arg1, arg2) {
+  return window['go']['app']['App']['MergeTags'](arg1, arg2);
+}
+
+export function MoveFiles(arg1, arg2) {
+  return window['go']['app']['App']['MoveFiles'](arg1, arg2);
+}
+
+export function MoveFolder(arg1, arg2) {
+  return window['go']['app']['App']['MoveFolder'](arg1, arg2);
+}
+
+export function MoveVault(arg1) {
+  return window['go']['app']['App']['MoveVault'](arg1);
+}
+
+export function OpenExternally(arg1) {
+  return window['go']['app']['App']['OpenExternally'](arg1);
+}
+
+export function PauseCompaction() {
+  return window['go']['app']['App']['PauseCompaction']();
+}
+
+export function PreviewRetentionRule(arg1) {
+  return window['go']['app']['App']['PreviewRetentionRule'](arg1);
+}
+
+export function QueryFiles(arg1) {
+  return window['go']['app']['App']['QueryFiles'](arg1);
+}
+
 export function RejectRegistration() {
   return window['go']['app']['App']['RejectRegistration']();
 }
@@ -70,18 +262,130 @@ export function RejectTransfer(arg1) {
   return window['go']['app']['App']['RejectTransfer'](arg1);
 }
 
+export function RenameFile(arg1, arg2) {
+  return window['go']['app']['App']['RenameFile'](arg1, arg2);
+}
+
+export function RenameFolder(arg1, arg2) {
+  return window['go']['app']['App']['RenameFolder'](arg1, arg2);
+}
+
+export function RenameTag(arg1, arg2) {
+  return window['go']['app']['App']['RenameTag'](arg1, arg2);
+}
+
+export function RestoreFiles(arg1) {
+  return window['go']['app']['App']['RestoreFiles'](arg1);
+}
+
+export function RestoreFolders(arg1) {
+  return window['go']['app']['App']['RestoreFolders'](arg1);
+}
+
+export function SearchNotes(arg1) {
+  return window['go']['app']['App']['SearchNotes'](arg1);
+}
+
+export function SelectExportDirectory() {
+  return window['go']['app']['App']['SelectExportDirectory']();
+}
+
+export function SelectImportDirectory() {
+  return window['go']['app']['App']['SelectImportDirectory']();
+}
+
+export function SelectImportFiles() {
+  return window['go']['app']['App']['SelectImportFiles']();
+}
+
+export function SelectVaultDirectory() {
+  return window['go']['app']['App']['SelectVaultDirectory']();
+}
+
+export function SetCompressionSettings(arg1) {
+  return window['go']['app']['App']['SetCompressionSettings'](arg1);
+}
+
+export function SetStorageThresholds(arg1) {
+  return window['go']['app']['App']['SetStorageThresholds'](arg1);
+}
+
+export function SetTagColor(arg1, arg2) {
+  return window['go']['app']['App']['SetTagColor'](arg1, arg2);
+}
+
+export function SetTrashRetention(arg1) {
+  return window['go']['app']['App']['SetTrashRetention'](arg1);
+}
+
 export function Shutdown(arg1) {
   return window['go']['app']['App']['Shutdown'](arg1);
 }
 
+export function StartCompaction() {
+  return window['go']['app']['App']['StartCompaction']();
+}
+
 export function StartServer(arg1) {
   return window['go']['app']['App']['StartServer'](arg1);
 }
 
+export function StartThumbnailBackfill() {
+  return window['go']['app']['App']['StartThumbnailBackfill']();
+}
+
 export function StopServer() {
   return window['go']['app']['App']['StopServer']();
 }
 
+export function StopThumbnailBackfill() {
+  return window['go']['app']['App']['StopThumbnailBackfill']();
+}
+
+export function SwitchProfile(arg1) {
+  return window['go']['app']['App']['SwitchProfile'](arg1);
+}
+
+export function TagFiles(arg1, arg2) {
+  return window['go']['app']['App']['TagFiles'](arg1, arg2);
+}
+
+export function TagFolders(arg1, arg2) {
+  return window['go']['app']['App']['TagFolders'](arg1, arg2);
+}
+
+export function UntagFiles(arg1, arg2) {
+  return window['go']['app']['App']['UntagFiles'](arg1, arg2);
+}
+
+export function UntagFolders(arg1, arg2) {
+  return window['go']['app']['App']['UntagFolders'](arg1, arg2);
+}
+
+export function UpdateNote(arg1, arg2, arg3, arg4) {
+  return window['go']['app']['App']['UpdateNote'](arg1, arg2, arg3, arg4);
+}
+
+export function UpdateRetentionRule(arg1) {
+  return window['go']['app']['App']['UpdateRetentionRule'](arg1);
+}
+
+export function ValidateBag(arg1) {
+  return window['go']['app']['App']['ValidateBag'](arg1);
+}
+
+export function VerifyAuditLog() {
+  return window['go']['app']['App']['VerifyAuditLog']();
+}
+
+export function VerifyFiles(arg1) {
+  return window['go']['app']['App']['VerifyFiles'](arg1);
+}
+
 export function VerifyPassword(arg1) {
   return window['go']['app']['App']['VerifyPassword'](arg1);
 }
+
+export function WipeExportedCopies(arg1) {
+  return window['go']['app']['App']['WipeExportedCopies'](arg1);
+}
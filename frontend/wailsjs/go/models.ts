@@ -1,11 +1,338 @@
+export namespace auth {
+	
+	export class ProfileInfo {
+	    id: string;
+	    label: string;
+	    name?: string;
+	    active: boolean;
+	    initialized: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProfileInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.label = source["label"];
+	        this.name = source["name"];
+	        this.active = source["active"];
+	        this.initialized = source["initialized"];
+	    }
+	}
+	export class VaultLocation {
+	    dataDir: string;
+	    portable: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new VaultLocation(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.dataDir = source["dataDir"];
+	        this.portable = source["portable"];
+	    }
+	}
+
+}
+
 export namespace filestore {
 	
+	export class AuditEntry {
+	    id: number;
+	    event: string;
+	    actor: string;
+	    fileId: number;
+	    folderId: number;
+	    contentHash: string;
+	    details: string;
+	    createdAt: string;
+	    prevHash: string;
+	    hash: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new AuditEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.event = source["event"];
+	        this.actor = source["actor"];
+	        this.fileId = source["fileId"];
+	        this.folderId = source["folderId"];
+	        this.contentHash = source["contentHash"];
+	        this.details = source["details"];
+	        this.createdAt = source["createdAt"];
+	        this.prevHash = source["prevHash"];
+	        this.hash = source["hash"];
+	    }
+	}
+	export class AuditVerification {
+	    valid: boolean;
+	    entries: number;
+	    head: string;
+	    errors: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new AuditVerification(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.valid = source["valid"];
+	        this.entries = source["entries"];
+	        this.head = source["head"];
+	        this.errors = source["errors"];
+	    }
+	}
+	export class BagOptions {
+	    zip: boolean;
+	    sourceOrganization: string;
+	    contactName: string;
+	    contactEmail: string;
+	    externalIdentifier: string;
+	    externalDescription: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new BagOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.zip = source["zip"];
+	        this.sourceOrganization = source["sourceOrganization"];
+	        this.contactName = source["contactName"];
+	        this.contactEmail = source["contactEmail"];
+	        this.externalIdentifier = source["externalIdentifier"];
+	        this.externalDescription = source["externalDescription"];
+	    }
+	}
+	export class BagValidation {
+	    valid: boolean;
+	    version: string;
+	    files: number;
+	    bytes: number;
+	    algorithms: string[];
+	    errors: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new BagValidation(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.valid = source["valid"];
+	        this.version = source["version"];
+	        this.files = source["files"];
+	        this.bytes = source["bytes"];
+	        this.algorithms = source["algorithms"];
+	        this.errors = source["errors"];
+	    }
+	}
+	export class CategoryUsage {
+	    category: string;
+	    fileCount: number;
+	    bytes: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new CategoryUsage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.category = source["category"];
+	        this.fileCount = source["fileCount"];
+	        this.bytes = source["bytes"];
+	    }
+	}
+	export class CompactionStatus {
+	    state: string;
+	    movedExtents: number;
+	    movedBytes: number;
+	    freeBytes: number;
+	    vaultSize: number;
+	    progress: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new CompactionStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.state = source["state"];
+	        this.movedExtents = source["movedExtents"];
+	        this.movedBytes = source["movedBytes"];
+	        this.freeBytes = source["freeBytes"];
+	        this.vaultSize = source["vaultSize"];
+	        this.progress = source["progress"];
+	    }
+	}
+	export class CompressionSettings {
+	    enabled: boolean;
+	    padding: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new CompressionSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.padding = source["padding"];
+	    }
+	}
+	export class DiskSpaceCheck {
+	    freeBytes: number;
+	    totalBytes: number;
+	    incomingBytes: number;
+	    freeAfter: number;
+	    low: boolean;
+	    insufficient: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new DiskSpaceCheck(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.freeBytes = source["freeBytes"];
+	        this.totalBytes = source["totalBytes"];
+	        this.incomingBytes = source["incomingBytes"];
+	        this.freeAfter = source["freeAfter"];
+	        this.low = source["low"];
+	        this.insufficient = source["insufficient"];
+	    }
+	}
+	export class ExportOptions {
+	    stripMetadata: boolean;
+	    keepOrientation: boolean;
+	    keepColorProfile: boolean;
+	    neutralNames: boolean;
+	    destination: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ExportOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.stripMetadata = source["stripMetadata"];
+	        this.keepOrientation = source["keepOrientation"];
+	        this.keepColorProfile = source["keepColorProfile"];
+	        this.neutralNames = source["neutralNames"];
+	        this.destination = source["destination"];
+	    }
+	}
+	export class ExportRecordedFile {
+	    fileId: number;
+	    name: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ExportRecordedFile(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.fileId = source["fileId"];
+	        this.name = source["name"];
+	    }
+	}
+	export class ExportRecord {
+	    id: number;
+	    path: string;
+	    sha256: string;
+	    size: number;
+	    exportedAt: string;
+	    closedAt?: string;
+	    status?: string;
+	    files: ExportRecordedFile[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ExportRecord(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.path = source["path"];
+	        this.sha256 = source["sha256"];
+	        this.size = source["size"];
+	        this.exportedAt = source["exportedAt"];
+	        this.closedAt = source["closedAt"];
+	        this.status = source["status"];
+	        this.files = this.convertValues(source["files"], ExportRecordedFile);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class ExportWipe {
+	    exportId: number;
+	    path: string;
+	    status: string;
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ExportWipe(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.exportId = source["exportId"];
+	        this.path = source["path"];
+	        this.status = source["status"];
+	        this.error = source["error"];
+	    }
+	}
+	export class Tag {
+	    id: number;
+	    name: string;
+	    color: string;
+	    fileCount?: number;
+	    folderCount?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Tag(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.color = source["color"];
+	        this.fileCount = source["fileCount"];
+	        this.folderCount = source["folderCount"];
+	    }
+	}
 	export class FileInfo {
 	    id: number;
 	    name: string;
 	    mimeType: string;
 	    timestamp: string;
 	    size: number;
+	    folderId: number;
+	    blurhash?: string;
+	    sha256?: string;
+	    sha512?: string;
+	    tags?: Tag[];
 	
 	    static createFrom(source: any = {}) {
 	        return new FileInfo(source);
@@ -18,6 +345,154 @@ export namespace filestore {
 	        this.mimeType = source["mimeType"];
 	        this.timestamp = source["timestamp"];
 	        this.size = source["size"];
+	        this.folderId = source["folderId"];
+	        this.blurhash = source["blurhash"];
+	        this.sha256 = source["sha256"];
+	        this.sha512 = source["sha512"];
+	        this.tags = this.convertValues(source["tags"], Tag);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class FileSort {
+	    field: string;
+	    descending: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new FileSort(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.field = source["field"];
+	        this.descending = source["descending"];
+	    }
+	}
+	export class FileQuery {
+	    search: string;
+	    folderId: number;
+	    includeSubfolders: boolean;
+	    categories: string[];
+	    tagIds: number[];
+	    minSize: number;
+	    maxSize: number;
+	    createdAfter: string;
+	    createdBefore: string;
+	    sort: FileSort[];
+	    limit: number;
+	    cursor: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new FileQuery(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.search = source["search"];
+	        this.folderId = source["folderId"];
+	        this.includeSubfolders = source["includeSubfolders"];
+	        this.categories = source["categories"];
+	        this.tagIds = source["tagIds"];
+	        this.minSize = source["minSize"];
+	        this.maxSize = source["maxSize"];
+	        this.createdAfter = source["createdAfter"];
+	        this.createdBefore = source["createdBefore"];
+	        this.sort = this.convertValues(source["sort"], FileSort);
+	        this.limit = source["limit"];
+	        this.cursor = source["cursor"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class FileQueryResult {
+	    files: FileInfo[];
+	    nextCursor: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new FileQueryResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.files = this.convertValues(source["files"], FileInfo);
+	        this.nextCursor = source["nextCursor"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class FileVerification {
+	    fileId: number;
+	    name: string;
+	    status: string;
+	    expectedSha256?: string;
+	    expectedSha512?: string;
+	    actualSha256?: string;
+	    actualSha512?: string;
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new FileVerification(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.fileId = source["fileId"];
+	        this.name = source["name"];
+	        this.status = source["status"];
+	        this.expectedSha256 = source["expectedSha256"];
+	        this.expectedSha512 = source["expectedSha512"];
+	        this.actualSha256 = source["actualSha256"];
+	        this.actualSha512 = source["actualSha512"];
+	        this.error = source["error"];
 	    }
 	}
 	export class FilesInFolderResponse {
@@ -55,8 +530,11 @@ export namespace filestore {
 	export class FolderInfo {
 	    id: number;
 	    name: string;
+	    parentId: number;
 	    timestamp: string;
 	    fileCount: number;
+	    tags?: Tag[];
+	    children?: FolderInfo[];
 	
 	    static createFrom(source: any = {}) {
 	        return new FolderInfo(source);
@@ -66,9 +544,405 @@ export namespace filestore {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.id = source["id"];
 	        this.name = source["name"];
+	        this.parentId = source["parentId"];
 	        this.timestamp = source["timestamp"];
 	        this.fileCount = source["fileCount"];
+	        this.tags = this.convertValues(source["tags"], Tag);
+	        this.children = this.convertValues(source["children"], FolderInfo);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class FolderListOptions {
+	    includeEmpty: boolean;
+	    tree: boolean;
+	    tagIds: number[];
+	
+	    static createFrom(source: any = {}) {
+	        return new FolderListOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.includeEmpty = source["includeEmpty"];
+	        this.tree = source["tree"];
+	        this.tagIds = source["tagIds"];
+	    }
+	}
+	export class FolderPathEntry {
+	    id: number;
+	    name: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new FolderPathEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	    }
+	}
+	export class FolderUsage {
+	    folderId: number;
+	    name: string;
+	    fileCount: number;
+	    bytes: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new FolderUsage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.folderId = source["folderId"];
+	        this.name = source["name"];
+	        this.fileCount = source["fileCount"];
+	        this.bytes = source["bytes"];
+	    }
+	}
+	export class ImportOptions {
+	    deleteOriginals: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new ImportOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.deleteOriginals = source["deleteOriginals"];
+	    }
+	}
+	export class JobItemError {
+	    item: string;
+	    error: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new JobItemError(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.item = source["item"];
+	        this.error = source["error"];
+	    }
+	}
+	export class JobStatus {
+	    id: string;
+	    kind: string;
+	    state: string;
+	    itemsDone: number;
+	    itemsTotal: number;
+	    bytesDone: number;
+	    bytesTotal: number;
+	    etaSeconds: number;
+	    startedAt: string;
+	    finishedAt?: string;
+	    errors: JobItemError[];
+	    error?: string;
+	    result?: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new JobStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.kind = source["kind"];
+	        this.state = source["state"];
+	        this.itemsDone = source["itemsDone"];
+	        this.itemsTotal = source["itemsTotal"];
+	        this.bytesDone = source["bytesDone"];
+	        this.bytesTotal = source["bytesTotal"];
+	        this.etaSeconds = source["etaSeconds"];
+	        this.startedAt = source["startedAt"];
+	        this.finishedAt = source["finishedAt"];
+	        this.errors = this.convertValues(source["errors"], JobItemError);
+	        this.error = source["error"];
+	        this.result = source["result"];
 	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Note {
+	    id: number;
+	    fileId?: number;
+	    folderId?: number;
+	    author: string;
+	    body: string;
+	    status?: string;
+	    createdAt: string;
+	    updatedAt: string;
+	    revisions: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Note(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.fileId = source["fileId"];
+	        this.folderId = source["folderId"];
+	        this.author = source["author"];
+	        this.body = source["body"];
+	        this.status = source["status"];
+	        this.createdAt = source["createdAt"];
+	        this.updatedAt = source["updatedAt"];
+	        this.revisions = source["revisions"];
+	    }
+	}
+	export class NoteRevision {
+	    id: number;
+	    noteId: number;
+	    author: string;
+	    body: string;
+	    status?: string;
+	    writtenAt: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new NoteRevision(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.noteId = source["noteId"];
+	        this.author = source["author"];
+	        this.body = source["body"];
+	        this.status = source["status"];
+	        this.writtenAt = source["writtenAt"];
+	    }
+	}
+	export class RetentionLogEntry {
+	    id: number;
+	    ruleId: number;
+	    ruleName: string;
+	    fileId: number;
+	    fileName: string;
+	    size: number;
+	    storedAt: string;
+	    deletedAt: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new RetentionLogEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.ruleId = source["ruleId"];
+	        this.ruleName = source["ruleName"];
+	        this.fileId = source["fileId"];
+	        this.fileName = source["fileName"];
+	        this.size = source["size"];
+	        this.storedAt = source["storedAt"];
+	        this.deletedAt = source["deletedAt"];
+	    }
+	}
+	export class RetentionMatch {
+	    ruleId: number;
+	    ruleName: string;
+	    fileId: number;
+	    fileName: string;
+	    folderId: number;
+	    size: number;
+	    storedAt: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new RetentionMatch(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ruleId = source["ruleId"];
+	        this.ruleName = source["ruleName"];
+	        this.fileId = source["fileId"];
+	        this.fileName = source["fileName"];
+	        this.folderId = source["folderId"];
+	        this.size = source["size"];
+	        this.storedAt = source["storedAt"];
+	    }
+	}
+	export class RetentionRule {
+	    id: number;
+	    name: string;
+	    folderId: number;
+	    includeSubfolders: boolean;
+	    receivedOnly: boolean;
+	    days: number;
+	    enabled: boolean;
+	    createdAt: string;
+	    lastRunAt?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new RetentionRule(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.folderId = source["folderId"];
+	        this.includeSubfolders = source["includeSubfolders"];
+	        this.receivedOnly = source["receivedOnly"];
+	        this.days = source["days"];
+	        this.enabled = source["enabled"];
+	        this.createdAt = source["createdAt"];
+	        this.lastRunAt = source["lastRunAt"];
+	    }
+	}
+	export class StorageThresholds {
+	    minFreeBytes: number;
+	    minFreePercent: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new StorageThresholds(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.minFreeBytes = source["minFreeBytes"];
+	        this.minFreePercent = source["minFreePercent"];
+	    }
+	}
+	
+	export class Thumbnail {
+	    fileId: number;
+	    size: number;
+	    width: number;
+	    height: number;
+	    mimeType: string;
+	    data: number[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Thumbnail(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.fileId = source["fileId"];
+	        this.size = source["size"];
+	        this.width = source["width"];
+	        this.height = source["height"];
+	        this.mimeType = source["mimeType"];
+	        this.data = source["data"];
+	    }
+	}
+	export class TrashItem {
+	    kind: string;
+	    id: number;
+	    name: string;
+	    parentId: number;
+	    size: number;
+	    fileCount: number;
+	    trashedAt: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new TrashItem(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.kind = source["kind"];
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.parentId = source["parentId"];
+	        this.size = source["size"];
+	        this.fileCount = source["fileCount"];
+	        this.trashedAt = source["trashedAt"];
+	    }
+	}
+	export class VaultStats {
+	    vaultSize: number;
+	    liveBytes: number;
+	    thumbnailBytes: number;
+	    freeBytes: number;
+	    freeRegions: number;
+	    largestFree: number;
+	    fragmentation: number;
+	    fileCount: number;
+	    folderCount: number;
+	    fileBytes: number;
+	    trashedCount: number;
+	    trashedBytes: number;
+	    folders: FolderUsage[];
+	    categories: CategoryUsage[];
+	    disk?: DiskSpaceCheck;
+	
+	    static createFrom(source: any = {}) {
+	        return new VaultStats(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.vaultSize = source["vaultSize"];
+	        this.liveBytes = source["liveBytes"];
+	        this.thumbnailBytes = source["thumbnailBytes"];
+	        this.freeBytes = source["freeBytes"];
+	        this.freeRegions = source["freeRegions"];
+	        this.largestFree = source["largestFree"];
+	        this.fragmentation = source["fragmentation"];
+	        this.fileCount = source["fileCount"];
+	        this.folderCount = source["folderCount"];
+	        this.fileBytes = source["fileBytes"];
+	        this.trashedCount = source["trashedCount"];
+	        this.trashedBytes = source["trashedBytes"];
+	        this.folders = this.convertValues(source["folders"], FolderUsage);
+	        this.categories = this.convertValues(source["categories"], CategoryUsage);
+	        this.disk = this.convertValues(source["disk"], DiskSpaceCheck);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 
 }
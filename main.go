@@ -2,6 +2,7 @@ package main
 
 import (
 	"embed"
+	"os"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -9,6 +10,8 @@ import (
 	"github.com/wailsapp/wails/v2/pkg/options/mac"
 	"github.com/wailsapp/wails/v2/pkg/options/linux"
 	"Tella-Desktop/backend/app"
+	"Tella-Desktop/backend/core/modules/preview"
+	"Tella-Desktop/backend/utils/authutils"
 )
 
 //go:embed all:frontend/dist
@@ -18,8 +21,15 @@ var assets embed.FS
 var icon []byte
 
 func main() {
+	// Keep all state next to the binary when running from a removable drive
+	if _, err := authutils.DetectPortableMode(os.Args[1:]); err != nil {
+		println("Error:", err.Error())
+		return
+	}
+
 	// Create an instance of the app structure
-	app := app.NewApp()
+	previews := preview.NewHandler()
+	app := app.NewApp(previews)
 
 	// Create application with options
 	err := wails.Run(&options.App{
@@ -28,6 +38,8 @@ func main() {
 		Height: 768,
 		AssetServer: &assetserver.Options{
 			Assets: assets,
+			// Decrypted previews, see App.GetPreviewURL
+			Handler: previews,
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup:        app.Startup,